@@ -0,0 +1,199 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/spf13/cobra"
+)
+
+//     Section defining all the command that we have in cache feature
+var cacheCmd = &cobra.Command{
+	Use:               "cache",
+	Short:             "Control the local cache of a file in a mounted container",
+	Long:              "Pin, unpin, evict or refresh the local cache of a file in a mounted container, or show its cache status",
+	SuggestFor:        []string{"cach", "cahce"},
+	Example:           "blobfuse2 cache pin ~/mount/dir/file",
+	FlagErrorHandling: cobra.ExitOnError,
+}
+
+var cachePinCmd = &cobra.Command{
+	Use:               "pin <path>",
+	Short:             "Keep the file in local cache till it is unpinned",
+	Long:              "Keep the file in local cache till it is unpinned, the file is downloaded if not already cached",
+	Example:           "blobfuse2 cache pin ~/mount/dir/file",
+	Args:              cobra.ExactArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheCommand(args[0], common.CacheControlPin)
+	},
+}
+
+var cacheUnpinCmd = &cobra.Command{
+	Use:               "unpin <path>",
+	Short:             "Allow the file to be evicted from local cache again",
+	Long:              "Allow the file to be evicted from local cache again",
+	Example:           "blobfuse2 cache unpin ~/mount/dir/file",
+	Args:              cobra.ExactArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheCommand(args[0], common.CacheControlUnpin)
+	},
+}
+
+var cacheEvictCmd = &cobra.Command{
+	Use:               "evict <path>",
+	Short:             "Remove the file from local cache",
+	Long:              "Remove the file from local cache, fails if the file is open elsewhere",
+	Example:           "blobfuse2 cache evict ~/mount/dir/file",
+	Args:              cobra.ExactArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheCommand(args[0], common.CacheControlEvict)
+	},
+}
+
+var cacheRefreshCmd = &cobra.Command{
+	Use:               "refresh <path>",
+	Short:             "Download the file again from the container",
+	Long:              "Download the file again from the container, fails if the file is open elsewhere or has changes not yet uploaded",
+	Example:           "blobfuse2 cache refresh ~/mount/dir/file",
+	Args:              cobra.ExactArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheCommand(args[0], common.CacheControlRefresh)
+	},
+}
+
+var cacheInvalidateCmd = &cobra.Command{
+	Use:               "invalidate <path>",
+	Short:             "Drop the cached attributes of the file",
+	Long:              "Drop the cached attributes of the file so that the next lookup goes to the container",
+	Example:           "blobfuse2 cache invalidate ~/mount/dir/file",
+	Args:              cobra.ExactArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheCommand(args[0], common.CacheControlInvalidateAttr)
+	},
+}
+
+var cacheStatusCmd = &cobra.Command{
+	Use:               "status <path>",
+	Short:             "Show whether the file is cached, dirty or pinned",
+	Long:              "Show whether the file is cached, dirty or pinned",
+	Example:           "blobfuse2 cache status ~/mount/dir/file",
+	Args:              cobra.ExactArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		status, err := cacheControl(args[0], common.CacheControlStatus)
+		if err != nil {
+			return fmt.Errorf("failed to get cache status of %s [%s]", args[0], err.Error())
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", args[0], cacheStatusString(status))
+		return nil
+	},
+}
+
+//--------------- command section ends
+
+func runCacheCommand(path string, command uint32) error {
+	_, err := cacheControl(path, command)
+	if err != nil {
+		return fmt.Errorf("failed to run cache command on %s [%s]", path, err.Error())
+	}
+	return nil
+}
+
+// cacheControl : Run the cache control command on the given file of a mounted container. The command is issued
+// through the cache control xattr, opening the file would download it.
+func cacheControl(path string, command uint32) (uint32, error) {
+	// Status is read first, as only in a blobfuse2 mount setting the xattr runs the command instead of storing it
+	value := make([]byte, 16)
+	n, err := syscall.Getxattr(path, internal.XattrCacheControl, value)
+	if err != nil {
+		if err == syscall.ENOTSUP || err == syscall.ENODATA {
+			return 0, fmt.Errorf("%s is not in a blobfuse2 mount", path)
+		}
+		return 0, err
+	}
+
+	status, err := strconv.ParseUint(string(value[:n]), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not in a blobfuse2 mount", path)
+	}
+	if command == common.CacheControlStatus {
+		return uint32(status), nil
+	}
+
+	for name, cmd := range common.CacheControlCommands {
+		if cmd == command {
+			return 0, syscall.Setxattr(path, internal.XattrCacheControl, []byte(name), 0)
+		}
+	}
+	return 0, syscall.EINVAL
+}
+
+func cacheStatusString(status uint32) string {
+	states := make([]string, 0)
+	if status&common.CacheStatusCached != 0 {
+		states = append(states, "cached")
+	} else {
+		states = append(states, "not cached")
+	}
+	if status&common.CacheStatusDirty != 0 {
+		states = append(states, "dirty")
+	}
+	if status&common.CacheStatusPinned != 0 {
+		states = append(states, "pinned")
+	}
+	return strings.Join(states, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cachePinCmd)
+	cacheCmd.AddCommand(cacheUnpinCmd)
+	cacheCmd.AddCommand(cacheEvictCmd)
+	cacheCmd.AddCommand(cacheRefreshCmd)
+	cacheCmd.AddCommand(cacheInvalidateCmd)
+	cacheCmd.AddCommand(cacheStatusCmd)
+}
@@ -36,6 +36,8 @@ package cmd
 import (
 	_ "github.com/Azure/azure-storage-fuse/v2/component/attr_cache"
 	_ "github.com/Azure/azure-storage-fuse/v2/component/azstorage"
+	_ "github.com/Azure/azure-storage-fuse/v2/component/compression"
+	_ "github.com/Azure/azure-storage-fuse/v2/component/encryption"
 	_ "github.com/Azure/azure-storage-fuse/v2/component/file_cache"
 	_ "github.com/Azure/azure-storage-fuse/v2/component/libfuse"
 	_ "github.com/Azure/azure-storage-fuse/v2/component/loopback"
@@ -335,6 +335,13 @@ var mountCmd = &cobra.Command{
 			}
 		}
 
+		// A container mounted at a point in time can only be read
+		var pointInTime string
+		_ = config.UnmarshalKey("azstorage.point-in-time", &pointInTime)
+		if pointInTime != "" {
+			config.Set("read-only", "true")
+		}
+
 		if !config.IsSet("logging.file-path") {
 			options.Logging.LogFilePath = common.DefaultLogFilePath
 		}
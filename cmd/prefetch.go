@@ -0,0 +1,310 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/component/file_cache"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/spf13/cobra"
+)
+
+var prefetchConfigFile string
+var prefetchWorkers int
+
+var prefetchCmd = &cobra.Command{
+	Use:   "prefetch <paths or globs>",
+	Short: "Download files to the local cache before they are used",
+	Long: "Download files to the file cache temp path before they are used. Paths of a mounted container are downloaded through the mount. " +
+		"With --config-file, paths and globs in the container are downloaded without a mount for the next mount to serve, which needs persist-index in file_cache. " +
+		"Prefetch stops once the cache reaches max-size-mb",
+	SuggestFor:        []string{"prefecth", "prewarm", "warm"},
+	Example:           "blobfuse2 prefetch ~/mount/models\nblobfuse2 prefetch --config-file=config.yaml \"models/**/*.ckpt\"",
+	Args:              cobra.MinimumNArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if prefetchWorkers <= 0 {
+			return fmt.Errorf("workers shall be at least 1")
+		}
+
+		if cmd.Flags().Changed("config-file") {
+			return prefetchFromContainer(cmd.OutOrStdout(), args)
+		}
+
+		files, err := listMountedFiles(args)
+		if err != nil {
+			return err
+		}
+		return runPrefetch(cmd.OutOrStdout(), files, prefetchWorkers, func(item prefetchItem) error {
+			_, err := cacheControl(item.name, common.CacheControlPrefetch)
+			return err
+		})
+	},
+}
+
+// prefetchItem : A file to download and its size
+type prefetchItem struct {
+	name string
+	size int64
+}
+
+// listMountedFiles : Files of a mounted container at the given paths, directories are walked and globs expanded
+func listMountedFiles(args []string) ([]prefetchItem, error) {
+	files := make([]prefetchItem, 0)
+	for _, arg := range args {
+		matches, err := filepath.Glob(common.ExpandPath(arg))
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %s [%s]", arg, err.Error())
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+
+		for _, match := range matches {
+			err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.Mode().IsRegular() {
+					files = append(files, prefetchItem{name: path, size: info.Size()})
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s [%s]", match, err.Error())
+			}
+		}
+	}
+	return files, nil
+}
+
+// prefetchFromContainer : Download files of the container into the temp path of file_cache without a mount
+func prefetchFromContainer(out io.Writer, args []string) error {
+	options.ConfigFile = prefetchConfigFile
+	err := parseConfig()
+	if err != nil {
+		return fmt.Errorf("failed to parse config [%s]", err.Error())
+	}
+
+	err = config.Unmarshal(&options)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal config [%s]", err.Error())
+	}
+
+	fcOpts := file_cache.FileCacheOptions{}
+	err = config.UnmarshalKey("file_cache", &fcOpts)
+	if err != nil {
+		return fmt.Errorf("invalid file_cache config [%s]", err.Error())
+	}
+	if !fcOpts.PersistIndex {
+		return fmt.Errorf("prefetch without a mount needs persist-index in file_cache, for the next mount to serve the files")
+	}
+	if fcOpts.Timeout == 0 {
+		// Without a timeout the cache policy purges files as soon as they are closed
+		timeout := mountCmd.PersistentFlags().Lookup("file-cache-timeout")
+		_ = mountCmd.PersistentFlags().Set(timeout.Name, timeout.DefValue)
+	}
+
+	// The pipeline of the mount, without the fuse layer on top
+	components := make([]string, 0)
+	for _, name := range options.Components {
+		if name != "libfuse" {
+			components = append(components, name)
+		}
+	}
+	if len(components) == 0 {
+		components = []string{"file_cache", "attr_cache", "azstorage"}
+	}
+	if components[0] != "file_cache" {
+		return fmt.Errorf("prefetch needs file_cache at the top of the pipeline")
+	}
+
+	pipeline, err := internal.NewPipeline(components, true)
+	if err != nil {
+		return fmt.Errorf("failed to initialize pipeline [%s]", err.Error())
+	}
+	err = pipeline.Start(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to start pipeline [%s]", err.Error())
+	}
+	defer func() {
+		_ = pipeline.Stop()
+	}()
+
+	files, err := listContainerFiles(pipeline.Header, args)
+	if err != nil {
+		return err
+	}
+
+	return runPrefetch(out, files, prefetchWorkers, func(item prefetchItem) error {
+		_, err := pipeline.Header.CacheControl(internal.CacheControlOptions{Name: item.name, Command: common.CacheControlPrefetch})
+		return err
+	})
+}
+
+// listContainerFiles : Files of the container at the given paths, directories are walked and globs matched
+func listContainerFiles(comp internal.Component, args []string) ([]prefetchItem, error) {
+	found := make(map[string]bool)
+	files := make([]prefetchItem, 0)
+	add := func(attr *internal.ObjAttr) {
+		if !found[attr.Path] {
+			found[attr.Path] = true
+			files = append(files, prefetchItem{name: attr.Path, size: attr.Size})
+		}
+	}
+
+	for _, arg := range args {
+		pattern := strings.Trim(arg, "/")
+		if !strings.ContainsAny(pattern, "*?[") {
+			attr, err := comp.GetAttr(internal.GetAttrOptions{Name: pattern})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get %s [%s]", arg, err.Error())
+			}
+			if !attr.IsDir() {
+				add(attr)
+				continue
+			}
+			pattern = pattern + "/**"
+		}
+
+		err := walkContainer(comp, common.GlobBase(pattern), func(attr *internal.ObjAttr) {
+			if common.MatchGlob(pattern, attr.Path) {
+				add(attr)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s [%s]", arg, err.Error())
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %s", strings.Join(args, " "))
+	}
+	return files, nil
+}
+
+// walkContainer : Call the function for every file under the directory of the container
+func walkContainer(comp internal.Component, dir string, fn func(attr *internal.ObjAttr)) error {
+	entries, err := comp.ReadDir(internal.ReadDirOptions{Name: dir})
+	if err != nil {
+		return err
+	}
+
+	for _, attr := range entries {
+		if attr.IsDir() {
+			err = walkContainer(comp, attr.Path, fn)
+			if err != nil {
+				return err
+			}
+		} else {
+			fn(attr)
+		}
+	}
+	return nil
+}
+
+// runPrefetch : Fetch the files with parallel workers reporting progress, stops once the cache is full
+func runPrefetch(out io.Writer, files []prefetchItem, workers int, fetch func(prefetchItem) error) error {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	done, failed, bytes := 0, 0, int64(0)
+	full := false
+
+	items := make(chan prefetchItem, len(files))
+	for _, item := range files {
+		items <- item
+	}
+	close(items)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				lock.Lock()
+				stop := full
+				lock.Unlock()
+				if stop {
+					continue
+				}
+
+				err := fetch(item)
+
+				lock.Lock()
+				if errors.Is(err, syscall.ENOSPC) {
+					full = true
+				} else if err != nil {
+					failed++
+					fmt.Fprintf(out, "failed to prefetch %s [%s]\n", item.name, err.Error())
+				} else {
+					done++
+					bytes += item.size
+					fmt.Fprintf(out, "[%d/%d] %s (%d bytes)\n", done, len(files), item.name, item.size)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Fprintf(out, "Prefetched %d of %d files, %.2f MB\n", done, len(files), float64(bytes)/(1024*1024))
+	if full {
+		return fmt.Errorf("cache reached max-size-mb, %d files were not prefetched", len(files)-done-failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to prefetch %d files", failed)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(prefetchCmd)
+
+	prefetchCmd.Flags().StringVar(&prefetchConfigFile, "config-file", "config.yaml",
+		"Configures the path for the file where the account credentials are provided, to prefetch without a mount. Default is config.yaml")
+	prefetchCmd.Flags().IntVar(&prefetchWorkers, "workers", 8,
+		"Number of files downloaded in parallel. Default is 8")
+}
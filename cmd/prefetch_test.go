@@ -0,0 +1,206 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type prefetchCmdTestSuite struct {
+	suite.Suite
+	assert  *assert.Assertions
+	testDir string
+}
+
+func (suite *prefetchCmdTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+	err := log.SetDefaultLogger("silent", common.LogConfig{Level: common.ELogLevel.LOG_DEBUG()})
+	if err != nil {
+		panic("Unable to set silent logger as default.")
+	}
+
+	suite.testDir, err = os.MkdirTemp("", "blobfuse2-prefetch-cmd-test")
+	suite.assert.Nil(err)
+}
+
+func (suite *prefetchCmdTestSuite) TearDownTest() {
+	os.RemoveAll(suite.testDir)
+	options = mountOptions{}
+	timeout := mountCmd.PersistentFlags().Lookup("file-cache-timeout")
+	timeout.Changed = false
+	prefetchCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		_ = f.Value.Set(f.DefValue)
+		f.Changed = false
+	})
+}
+
+func TestPrefetchCommand(t *testing.T) {
+	suite.Run(t, new(prefetchCmdTestSuite))
+}
+
+func (suite *prefetchCmdTestSuite) writeFile(name string, size int) {
+	path := filepath.Join(suite.testDir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	suite.assert.Nil(err)
+	err = os.WriteFile(path, make([]byte, size), 0644)
+	suite.assert.Nil(err)
+}
+
+func (suite *prefetchCmdTestSuite) TestHelp() {
+	_, err := executeCommandSecure(rootCmd, "prefetch", "-h")
+	suite.assert.Nil(err)
+}
+
+func (suite *prefetchCmdTestSuite) TestRunPrefetch() {
+	files := []prefetchItem{{"a", 10}, {"b", 20}, {"c", 30}}
+	var lock sync.Mutex
+	fetched := make([]string, 0)
+
+	out := &bytes.Buffer{}
+	err := runPrefetch(out, files, 2, func(item prefetchItem) error {
+		lock.Lock()
+		defer lock.Unlock()
+		fetched = append(fetched, item.name)
+		return nil
+	})
+	suite.assert.Nil(err)
+	sort.Strings(fetched)
+	suite.assert.Equal([]string{"a", "b", "c"}, fetched)
+	suite.assert.Contains(out.String(), "[3/3]")
+	suite.assert.Contains(out.String(), "Prefetched 3 of 3 files")
+}
+
+func (suite *prefetchCmdTestSuite) TestRunPrefetchFailure() {
+	files := []prefetchItem{{"a", 10}, {"b", 20}}
+
+	out := &bytes.Buffer{}
+	err := runPrefetch(out, files, 1, func(item prefetchItem) error {
+		if item.name == "a" {
+			return errors.New("read failed")
+		}
+		return nil
+	})
+	suite.assert.NotNil(err)
+	suite.assert.Contains(out.String(), "failed to prefetch a [read failed]")
+	suite.assert.Contains(out.String(), "Prefetched 1 of 2 files")
+}
+
+func (suite *prefetchCmdTestSuite) TestRunPrefetchCacheFull() {
+	files := []prefetchItem{{"a", 10}, {"b", 20}, {"c", 30}}
+
+	// Nothing more is fetched once the cache is full
+	out := &bytes.Buffer{}
+	err := runPrefetch(out, files, 1, func(item prefetchItem) error {
+		if item.name == "b" {
+			return syscall.ENOSPC
+		}
+		return nil
+	})
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "max-size-mb, 2 files were not prefetched")
+	suite.assert.Contains(out.String(), "Prefetched 1 of 3 files")
+}
+
+func (suite *prefetchCmdTestSuite) TestListMountedFiles() {
+	suite.writeFile("models/a.ckpt", 10)
+	suite.writeFile("models/run1/b.ckpt", 20)
+	suite.writeFile("data.csv", 30)
+
+	files, err := listMountedFiles([]string{filepath.Join(suite.testDir, "models"), filepath.Join(suite.testDir, "*.csv")})
+	suite.assert.Nil(err)
+	suite.assert.ElementsMatch([]prefetchItem{
+		{filepath.Join(suite.testDir, "models/a.ckpt"), 10},
+		{filepath.Join(suite.testDir, "models/run1/b.ckpt"), 20},
+		{filepath.Join(suite.testDir, "data.csv"), 30},
+	}, files)
+
+	_, err = listMountedFiles([]string{filepath.Join(suite.testDir, "*.txt")})
+	suite.assert.NotNil(err)
+}
+
+func (suite *prefetchCmdTestSuite) writeConfig(persistIndex bool) (string, string, string) {
+	tmpPath := filepath.Join(suite.testDir, "cache")
+	storagePath := filepath.Join(suite.testDir, "storage")
+	configFile := filepath.Join(suite.testDir, "config.yaml")
+	err := os.MkdirAll(tmpPath, 0777)
+	suite.assert.Nil(err)
+
+	configuration := fmt.Sprintf("components:\n  - libfuse\n  - file_cache\n  - loopbackfs\n\nfile_cache:\n  path: %s\n  persist-index: %t\n\nloopbackfs:\n  path: %s\n",
+		tmpPath, persistIndex, storagePath)
+	err = os.WriteFile(configFile, []byte(configuration), 0644)
+	suite.assert.Nil(err)
+	return configFile, tmpPath, storagePath
+}
+
+func (suite *prefetchCmdTestSuite) TestPrefetchFromContainer() {
+	configFile, tmpPath, _ := suite.writeConfig(true)
+	suite.writeFile("storage/models/a.ckpt", 10)
+	suite.writeFile("storage/models/run1/b.ckpt", 20)
+	suite.writeFile("storage/models/run1/b.log", 20)
+	suite.writeFile("storage/data.csv", 30)
+
+	out, err := executeCommandSecure(rootCmd, "prefetch", "--config-file", configFile, "models/**/*.ckpt", "data.csv")
+	suite.assert.Nil(err)
+	suite.assert.Contains(out, "Prefetched 3 of 3 files")
+
+	// Files are kept in the temp path for the next mount
+	suite.assert.FileExists(filepath.Join(tmpPath, "models/a.ckpt"))
+	suite.assert.FileExists(filepath.Join(tmpPath, "models/run1/b.ckpt"))
+	suite.assert.FileExists(filepath.Join(tmpPath, "data.csv"))
+	suite.assert.NoFileExists(filepath.Join(tmpPath, "models/run1/b.log"))
+}
+
+func (suite *prefetchCmdTestSuite) TestPrefetchNeedsPersistIndex() {
+	configFile, _, _ := suite.writeConfig(false)
+	suite.writeFile("storage/data.csv", 30)
+
+	_, err := executeCommandSecure(rootCmd, "prefetch", "--config-file", configFile, "data.csv")
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "persist-index")
+}
@@ -0,0 +1,113 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/component/file_cache"
+
+	"github.com/spf13/cobra"
+)
+
+var recoverConfigFile string
+var recoverTmpPath string
+
+var recoverCmd = &cobra.Command{
+	Use:               "recover",
+	Short:             "List files changed in the local cache that are not in the container yet",
+	Long:              "List files left in the file cache temp path with changes not in the container yet. The next mount uploads them, or saves them as <name>.conflict if the container copy changed meanwhile",
+	SuggestFor:        []string{"recovr", "recovery"},
+	Example:           "blobfuse2 recover --config-file=config.yaml",
+	Args:              cobra.ExactArgs(0),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opt := file_cache.FileCacheOptions{}
+
+		if strings.TrimSpace(recoverTmpPath) != "" {
+			opt.TmpPath = recoverTmpPath
+		} else {
+			options.ConfigFile = recoverConfigFile
+			err := parseConfig()
+			if err != nil {
+				return fmt.Errorf("failed to parse config [%s]", err.Error())
+			}
+
+			err = config.UnmarshalKey("file_cache", &opt)
+			if err != nil {
+				return fmt.Errorf("invalid file_cache config [%s]", err.Error())
+			}
+		}
+
+		files, err := file_cache.ListPendingFiles(opt)
+		if err != nil {
+			return fmt.Errorf("failed to list pending files [%s]", err.Error())
+		}
+
+		if len(files) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No files pending recovery")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tCHANGED\tSTATE")
+		for _, f := range files {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", f.Name, f.Size, f.Changed.Format(time.RFC3339), pendingFileState(f))
+		}
+		return w.Flush()
+	},
+}
+
+func pendingFileState(f file_cache.PendingFile) string {
+	if f.Missing {
+		return "local copy missing"
+	}
+	if f.Journaled {
+		return "flushed, upload pending"
+	}
+	return "not flushed"
+}
+
+func init() {
+	rootCmd.AddCommand(recoverCmd)
+
+	recoverCmd.Flags().StringVar(&recoverConfigFile, "config-file", "config.yaml",
+		"Configures the path for the file where the file cache temp path is provided. Default is config.yaml")
+	recoverCmd.Flags().StringVar(&recoverTmpPath, "tmp-path", "",
+		"File cache temp path to inspect, instead of the one in the config file")
+}
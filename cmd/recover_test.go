@@ -0,0 +1,109 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/component/file_cache"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type recoverCmdTestSuite struct {
+	suite.Suite
+	assert  *assert.Assertions
+	tmpPath string
+}
+
+func (suite *recoverCmdTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+	err := log.SetDefaultLogger("silent", common.LogConfig{Level: common.ELogLevel.LOG_DEBUG()})
+	if err != nil {
+		panic("Unable to set silent logger as default.")
+	}
+
+	suite.tmpPath, err = os.MkdirTemp("", "blobfuse2-recover-cmd-test")
+	suite.assert.Nil(err)
+}
+
+func (suite *recoverCmdTestSuite) TearDownTest() {
+	os.RemoveAll(suite.tmpPath)
+	recoverTmpPath = ""
+	recoverCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		_ = f.Value.Set(f.DefValue)
+		f.Changed = false
+	})
+}
+
+func TestRecoverCommand(t *testing.T) {
+	suite.Run(t, new(recoverCmdTestSuite))
+}
+
+func (suite *recoverCmdTestSuite) TestHelp() {
+	_, err := executeCommandSecure(rootCmd, "recover", "-h")
+	suite.assert.Nil(err)
+}
+
+func (suite *recoverCmdTestSuite) TestNoPendingFiles() {
+	out, err := executeCommandSecure(rootCmd, "recover", "--tmp-path", suite.tmpPath)
+	suite.assert.Nil(err)
+	suite.assert.Contains(out, "No files pending recovery")
+}
+
+func (suite *recoverCmdTestSuite) TestJournaledFile() {
+	err := os.WriteFile(filepath.Join(suite.tmpPath, "file"), []byte("data"), 0644)
+	suite.assert.Nil(err)
+	err = os.Mkdir(filepath.Join(suite.tmpPath, ".blobfuse2"), 0755)
+	suite.assert.Nil(err)
+	err = os.WriteFile(filepath.Join(suite.tmpPath, ".blobfuse2", "journal"), []byte("+ \"file\"\n+ \"gone\"\n"), 0644)
+	suite.assert.Nil(err)
+
+	out, err := executeCommandSecure(rootCmd, "recover", "--tmp-path", suite.tmpPath)
+	suite.assert.Nil(err)
+	suite.assert.Contains(out, "flushed, upload pending")
+	suite.assert.Contains(out, "local copy missing")
+}
+
+func (suite *recoverCmdTestSuite) TestStateString() {
+	suite.assert.Equal("not flushed", pendingFileState(file_cache.PendingFile{Name: "a"}))
+	suite.assert.Equal("flushed, upload pending", pendingFileState(file_cache.PendingFile{Name: "a", Journaled: true}))
+	suite.assert.Equal("local copy missing", pendingFileState(file_cache.PendingFile{Name: "a", Journaled: true, Missing: true}))
+}
@@ -0,0 +1,160 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/component/azstorage"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/spf13/cobra"
+)
+
+// Section defining all the command that we have in tier feature
+var tierCmd = &cobra.Command{
+	Use:               "tier",
+	Short:             "Show or change the access tier of files in a mounted container",
+	Long:              "Show or change the access tier of files in a mounted container, through the " + internal.XattrAzureTier + " extended attribute. Directories are walked and globs expanded",
+	SuggestFor:        []string{"tiers", "teir"},
+	Example:           "blobfuse2 tier get ~/mount/models\nblobfuse2 tier set cool \"~/mount/models/*.ckpt\"",
+	FlagErrorHandling: cobra.ExitOnError,
+}
+
+var tierGetCmd = &cobra.Command{
+	Use:               "get <paths or globs>",
+	Short:             "Show the access tier of files",
+	Long:              "Show the access tier of files, Archive files have to be rehydrated before they can be read",
+	Example:           "blobfuse2 tier get ~/mount/models",
+	Args:              cobra.MinimumNArgs(1),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := listMountedFiles(args)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, file := range files {
+			tier, err := getTier(file.name)
+			if err != nil {
+				failed++
+				fmt.Fprintf(cmd.OutOrStdout(), "failed to get tier of %s [%s]\n", file.name, err.Error())
+				continue
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%-8s %s\n", tier, file.name)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to get tier of %d files", failed)
+		}
+		return nil
+	},
+}
+
+var tierSetCmd = &cobra.Command{
+	Use:               "set <tier> <paths or globs>",
+	Short:             "Change the access tier of files",
+	Long:              "Change the access tier of files to hot, cool, archive or a premium page blob tier. Moving a file out of archive starts its rehydration, which can take hours",
+	Example:           "blobfuse2 tier set cool \"~/mount/models/*.ckpt\"",
+	Args:              cobra.MinimumNArgs(2),
+	FlagErrorHandling: cobra.ExitOnError,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tier, found := azstorage.AccessTiers[strings.ToLower(args[0])]
+		if !found || tier == "" {
+			return fmt.Errorf("invalid tier %s", args[0])
+		}
+
+		files, err := listMountedFiles(args[1:])
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, file := range files {
+			err = setTier(file.name, string(tier))
+			if err != nil {
+				failed++
+				fmt.Fprintf(cmd.OutOrStdout(), "failed to set tier of %s [%s]\n", file.name, err.Error())
+			}
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "Changed tier of %d of %d files to %s\n", len(files)-failed, len(files), tier)
+		if failed > 0 {
+			return fmt.Errorf("failed to set tier of %d files", failed)
+		}
+		return nil
+	},
+}
+
+//--------------- command section ends
+
+// getTier : Read the access tier of a file of a mounted container
+func getTier(path string) (string, error) {
+	value := make([]byte, 64)
+	n, err := syscall.Getxattr(path, internal.XattrAzureTier, value)
+	if err != nil {
+		return "", tierError(path, err)
+	}
+	return string(value[:n]), nil
+}
+
+// setTier : Change the access tier of a file of a mounted container
+func setTier(path string, tier string) error {
+	err := syscall.Setxattr(path, internal.XattrAzureTier, []byte(tier), 0)
+	if err != nil {
+		return tierError(path, err)
+	}
+	return nil
+}
+
+func tierError(path string, err error) error {
+	switch err {
+	case syscall.ENOTSUP:
+		return fmt.Errorf("%s is not in a blobfuse2 mount", path)
+	case syscall.ENODATA:
+		return fmt.Errorf("%s has no access tier, it is not in a blobfuse2 mount or its account does not report tiers", path)
+	case syscall.EBUSY:
+		return fmt.Errorf("%s is being rehydrated, its tier can be changed once it completes", path)
+	}
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(tierCmd)
+	tierCmd.AddCommand(tierGetCmd)
+	tierCmd.AddCommand(tierSetCmd)
+}
@@ -92,6 +92,14 @@ func SetSecureConfigOptions(passphrase string) {
 	userOptions.passphrase = passphrase
 }
 
+// SecurePassphrase : passphrase the config file was decrypted with, empty if the config is not secure
+func SecurePassphrase() string {
+	if !userOptions.secureConfig {
+		return ""
+	}
+	return userOptions.passphrase
+}
+
 // SetConfigFile : set config file name to be watched by viper
 func SetConfigFile(configFilePath string) {
 	userOptions.path = configFilePath
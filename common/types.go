@@ -36,6 +36,7 @@ package common
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -151,6 +152,7 @@ const (
 	BlockFlagUnknown uint16 = iota
 	DirtyBlock
 	TruncatedBlock
+	SparseBlock
 )
 
 type Block struct {
@@ -172,6 +174,11 @@ func (block *Block) Truncated() bool {
 	return block.Flags.IsSet(TruncatedBlock)
 }
 
+// Sparse : block holds only zeroes and its id may be shared with other sparse blocks of the same size
+func (block *Block) Sparse() bool {
+	return block.Flags.IsSet(SparseBlock)
+}
+
 // Flags for block offset list
 const (
 	BolFlagUnknown uint16 = iota
@@ -261,6 +268,69 @@ func (bol BlockOffsetList) FindBlocksToModify(offset, length int64) (int, int64,
 	return index, size, offset+length >= bol.BlockList[len(bol.BlockList)-1].EndIndex, appendOnly
 }
 
+// Whence values of lseek to find data and holes in a sparse file
+const (
+	SeekData = 3
+	SeekHole = 4
+)
+
+// SeekData : return start of the first block at or after offset that is not sparse, false if there is no such block
+func (bol BlockOffsetList) SeekData(offset int64) (int64, bool) {
+	found, index := bol.BinarySearch(offset)
+	if !found {
+		return 0, false
+	}
+	for _, blk := range bol.BlockList[index:] {
+		if !blk.Sparse() {
+			return int64(math.Max(float64(offset), float64(blk.StartIndex))), true
+		}
+	}
+	return 0, false
+}
+
+// SeekHole : return start of the first sparse block at or after offset, end of the list if there is no such block
+func (bol BlockOffsetList) SeekHole(offset int64) int64 {
+	found, index := bol.BinarySearch(offset)
+	if !found {
+		return offset
+	}
+	for _, blk := range bol.BlockList[index:] {
+		if blk.Sparse() {
+			return int64(math.Max(float64(offset), float64(blk.StartIndex)))
+		}
+	}
+	return bol.BlockList[len(bol.BlockList)-1].EndIndex
+}
+
+// Cache control commands run on a file of the mount through its cache control extended attribute
+const (
+	CacheControlPin uint32 = iota + 1
+	CacheControlUnpin
+	CacheControlEvict
+	CacheControlRefresh
+	CacheControlInvalidateAttr
+	CacheControlStatus
+	CacheControlPrefetch
+)
+
+// Cache control commands by the name written to the cache control extended attribute of a file, which runs them
+// without opening the file
+var CacheControlCommands = map[string]uint32{
+	"pin":        CacheControlPin,
+	"unpin":      CacheControlUnpin,
+	"evict":      CacheControlEvict,
+	"refresh":    CacheControlRefresh,
+	"invalidate": CacheControlInvalidateAttr,
+	"prefetch":   CacheControlPrefetch,
+}
+
+// Flags reported by CacheControlStatus
+const (
+	CacheStatusCached uint32 = 1 << iota
+	CacheStatusDirty
+	CacheStatusPinned
+)
+
 // A UUID representation compliant with specification in RFC 4122 document.
 type uuid [16]byte
 
@@ -71,6 +71,40 @@ func (suite *typesTestSuite) TestBinarySearch() {
 	suite.assert.Equal(startingIndex, 3)
 }
 
+func (suite *typesTestSuite) TestSeekDataAndHole() {
+	blocksList := []*Block{
+		{StartIndex: 0, EndIndex: 4},
+		{StartIndex: 4, EndIndex: 8},
+		{StartIndex: 8, EndIndex: 12},
+		{StartIndex: 12, EndIndex: 16},
+	}
+	blocksList[1].Flags.Set(SparseBlock)
+	blocksList[3].Flags.Set(SparseBlock)
+	bol := BlockOffsetList{
+		BlockList: blocksList,
+	}
+
+	offset, found := bol.SeekData(2)
+	suite.assert.True(found)
+	suite.assert.EqualValues(2, offset)
+	offset, found = bol.SeekData(5)
+	suite.assert.True(found)
+	suite.assert.EqualValues(8, offset)
+	_, found = bol.SeekData(13)
+	suite.assert.False(found)
+	_, found = bol.SeekData(16)
+	suite.assert.False(found)
+
+	suite.assert.EqualValues(4, bol.SeekHole(1))
+	suite.assert.EqualValues(5, bol.SeekHole(5))
+	suite.assert.EqualValues(12, bol.SeekHole(9))
+
+	// File with no sparse blocks has a hole only at its end
+	blocksList[1].Flags.Clear(SparseBlock)
+	blocksList[3].Flags.Clear(SparseBlock)
+	suite.assert.EqualValues(16, bol.SeekHole(0))
+}
+
 func (suite *typesTestSuite) TestFindBlocksToModify() {
 	blocksList := []*Block{
 		{StartIndex: 0, EndIndex: 4},
@@ -43,6 +43,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -268,6 +269,78 @@ func ExpandPath(path string) string {
 	return os.ExpandEnv(path)
 }
 
+// FileModeFromPosix : Convert the permission bits of a posix mode, setuid, setgid and sticky included, to a file mode
+func FileModeFromPosix(mode uint32) os.FileMode {
+	fileMode := os.FileMode(mode & 0777)
+	if mode&syscall.S_ISUID != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&syscall.S_ISGID != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&syscall.S_ISVTX != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode
+}
+
+// PosixFromFileMode : Convert the permissions of a file mode, setuid, setgid and sticky included, to posix mode bits
+func PosixFromFileMode(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= syscall.S_ISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= syscall.S_ISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= syscall.S_ISVTX
+	}
+	return bits
+}
+
+// MatchGlob : Whether the object path matches the glob pattern, "**" matches any number of directories
+func MatchGlob(pattern string, name string) bool {
+	return matchGlobParts(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(name, "/"), "/"))
+}
+
+func matchGlobParts(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+// GlobBase : Directory of the glob pattern above its first wildcard
+func GlobBase(pattern string) string {
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	base := make([]string, 0, len(parts))
+	for _, part := range parts[:len(parts)-1] {
+		if strings.ContainsAny(part, "*?[\\") {
+			break
+		}
+		base = append(base, part)
+	}
+	return strings.Join(base, "/")
+}
+
 // NotifyMountToParent : Send a signal to parent process about successful mount
 func NotifyMountToParent() error {
 	if !ForegroundMount {
@@ -152,3 +152,43 @@ func (suite *utilTestSuite) TestExpandPath() {
 	expandedPath = ExpandPath(path)
 	suite.assert.Equal(expandedPath, path)
 }
+
+func (suite *utilTestSuite) TestPosixMode() {
+	suite.assert.Equal(os.FileMode(0644), FileModeFromPosix(0100644))
+	suite.assert.Equal(os.ModeSetuid|0755, FileModeFromPosix(04755))
+	suite.assert.Equal(os.ModeSetgid|os.ModeSticky|0775, FileModeFromPosix(03775))
+
+	suite.assert.EqualValues(0644, PosixFromFileMode(os.ModeDir|0644))
+	suite.assert.EqualValues(04755, PosixFromFileMode(FileModeFromPosix(04755)))
+	suite.assert.EqualValues(03775, PosixFromFileMode(os.ModeSetgid|os.ModeSticky|0775))
+}
+
+func (suite *utilTestSuite) TestMatchGlob() {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"models/*.ckpt", "models/a.ckpt", true},
+		{"models/*.ckpt", "models/a.bin", false},
+		{"models/*.ckpt", "models/run1/a.ckpt", false},
+		{"models/**", "models/run1/a.ckpt", true},
+		{"models/**/*.ckpt", "models/a.ckpt", true},
+		{"models/**/*.ckpt", "models/run1/epoch2/a.ckpt", true},
+		{"models/**/*.ckpt", "data/a.ckpt", false},
+		{"**/hg38.fa", "genomes/human/hg38.fa", true},
+		{"/genomes/hg38.fa", "genomes/hg38.fa", true},
+		{"genomes", "genomes/hg38.fa", false},
+	}
+
+	for _, test := range tests {
+		suite.assert.Equal(test.match, MatchGlob(test.pattern, test.name), "%s %s", test.pattern, test.name)
+	}
+}
+
+func (suite *utilTestSuite) TestGlobBase() {
+	suite.assert.Equal("models", GlobBase("models/**/*.ckpt"))
+	suite.assert.Equal("genomes/human", GlobBase("genomes/human/hg38.fa"))
+	suite.assert.Equal("", GlobBase("**/hg38.fa"))
+	suite.assert.Equal("", GlobBase("file"))
+}
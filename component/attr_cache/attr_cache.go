@@ -34,6 +34,7 @@
 package attr_cache
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"os"
@@ -42,10 +43,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Azure/azure-storage-fuse/v2/common"
 	"github.com/Azure/azure-storage-fuse/v2/common/config"
 	"github.com/Azure/azure-storage-fuse/v2/common/log"
 	"github.com/Azure/azure-storage-fuse/v2/internal"
 	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+	"github.com/Azure/azure-storage-fuse/v2/internal/stats_manager"
 )
 
 // By default attr cache is valid for 120 seconds
@@ -58,7 +61,20 @@ type AttrCache struct {
 	cacheOnList  bool
 	noSymlinks   bool
 	cacheMap     map[string]*attrCacheItem
+	children     map[string]map[string]bool // cached paths by their parent directory
 	cacheLock    sync.RWMutex
+
+	maxEntries uint64
+	maxMemory  uint64     // in bytes, 0 means no limit
+	lruList    *list.List // paths of cached items, most recently used first
+	usedMemory int64
+	lruLock    sync.Mutex
+
+	pollInterval uint32
+	pollBudget   uint32
+	recentDirs   map[string]time.Time // directories kept in sync with storage by the poller and when they were last used
+	recentLock   sync.Mutex
+	stopPoll     chan bool
 }
 
 // Structure defining your config parameters
@@ -66,6 +82,10 @@ type AttrCacheOptions struct {
 	Timeout       uint32 `config:"timeout-sec" yaml:"timeout-sec,omitempty"`
 	NoCacheOnList bool   `config:"no-cache-on-list" yaml:"no-cache-on-list,omitempty"`
 	NoSymlinks    bool   `config:"no-symlinks" yaml:"no-symlinks,omitempty"`
+	PollInterval  uint32 `config:"poll-interval-sec" yaml:"poll-interval-sec,omitempty"`
+	PollBudget    uint32 `config:"poll-budget" yaml:"poll-budget,omitempty"`
+	MaxEntries    uint64 `config:"max-entries" yaml:"max-entries,omitempty"`
+	MaxMemoryMB   uint64 `config:"max-memory-mb" yaml:"max-memory-mb,omitempty"`
 
 	// support v1
 	CacheOnList bool `config:"cache-on-list"`
@@ -76,7 +96,7 @@ const compName = "attr_cache"
 // for now caching only first 1 mil files in a directory
 // caching more means increased memory usage of the process
 const maxFilesPerDir = 1000000 // 1 million max files to be cached per directory
-const maxTotalFiles = 10000000 // 10 million max files overall to be cached
+const maxTotalFiles = 10000000 // 10 million max files overall to be cached, unless max-entries says otherwise
 
 //  Verification to check satisfaction criteria with Component Interface
 var _ internal.Component = &AttrCache{}
@@ -104,6 +124,18 @@ func (ac *AttrCache) Start(ctx context.Context) error {
 
 	// AttrCache : start code goes here
 	ac.cacheMap = make(map[string]*attrCacheItem)
+	ac.children = make(map[string]map[string]bool)
+	ac.lruList = list.New()
+	ac.usedMemory = 0
+	ac.recentDirs = make(map[string]time.Time)
+
+	if ac.pollInterval > 0 {
+		ac.stopPoll = make(chan bool, 1)
+		go ac.pollRemoteChanges(ac.stopPoll)
+	}
+
+	// create stats collector for attr cache
+	attrCacheStatsCollector = stats_manager.NewStatsCollector(ac.Name())
 
 	return nil
 }
@@ -112,6 +144,13 @@ func (ac *AttrCache) Start(ctx context.Context) error {
 func (ac *AttrCache) Stop() error {
 	log.Trace("AttrCache::Stop : Stopping component %s", ac.Name())
 
+	if ac.stopPoll != nil {
+		ac.stopPoll <- true
+		ac.stopPoll = nil
+	}
+
+	attrCacheStatsCollector.Destroy()
+
 	return nil
 }
 
@@ -142,8 +181,22 @@ func (ac *AttrCache) Configure(_ bool) error {
 
 	ac.noSymlinks = conf.NoSymlinks
 
-	log.Info("AttrCache::Configure : cache-timeout %d, symlink %t, cache-on-list %t",
-		ac.cacheTimeout, ac.noSymlinks, ac.cacheOnList)
+	ac.pollInterval = conf.PollInterval
+	if config.IsSet(compName + ".poll-budget") {
+		ac.pollBudget = conf.PollBudget
+	} else {
+		ac.pollBudget = defaultPollBudget
+	}
+
+	if config.IsSet(compName + ".max-entries") {
+		ac.maxEntries = conf.MaxEntries
+	} else {
+		ac.maxEntries = maxTotalFiles
+	}
+	ac.maxMemory = conf.MaxMemoryMB * 1024 * 1024
+
+	log.Info("AttrCache::Configure : cache-timeout %d, symlink %t, cache-on-list %t, poll-interval %d, poll-budget %d, max-entries %d, max-memory %d MB",
+		ac.cacheTimeout, ac.noSymlinks, ac.cacheOnList, ac.pollInterval, ac.pollBudget, ac.maxEntries, conf.MaxMemoryMB)
 
 	return nil
 }
@@ -260,6 +313,7 @@ func (ac *AttrCache) ReadDir(options internal.ReadDirOptions) (pathList []*inter
 // StreamDir : Optionally cache attributes of paths returned by next component
 func (ac *AttrCache) StreamDir(options internal.StreamDirOptions) ([]*internal.ObjAttr, string, error) {
 	log.Trace("AttrCache::ReadDir : %s", options.Name)
+	ac.markDirUsed(options.Name)
 
 	pathList, token, err := ac.NextComponent().StreamDir(options)
 	if err == nil && options.Offset < maxFilesPerDir {
@@ -278,12 +332,8 @@ func (ac *AttrCache) cacheAttributes(pathList []*internal.ObjAttr) {
 		currTime := time.Now()
 
 		for _, attr := range pathList {
-			if len(ac.cacheMap) > maxTotalFiles {
-				break
-			}
-
 			ac.cacheLock.Lock()
-			ac.cacheMap[internal.TruncateDirName(attr.Path)] = newAttrCacheItem(attr, true, currTime)
+			ac.addItem(internal.TruncateDirName(attr.Path), newAttrCacheItem(attr, true, currTime))
 			ac.cacheLock.Unlock()
 		}
 
@@ -356,6 +406,20 @@ func (ac *AttrCache) RenameFile(options internal.RenameFileOptions) error {
 	return err
 }
 
+// CopyObject : Mark the destination invalid
+func (ac *AttrCache) CopyObject(options internal.CopyObjectOptions) error {
+	log.Trace("AttrCache::CopyObject : %s -> %s", options.Src, options.Dst)
+
+	err := ac.NextComponent().CopyObject(options)
+	if err == nil {
+		ac.cacheLock.RLock()
+		defer ac.cacheLock.RUnlock()
+		ac.invalidatePath(options.Dst)
+	}
+
+	return err
+}
+
 // WriteFile : Mark the file invalid
 func (ac *AttrCache) WriteFile(options internal.WriteFileOptions) (int, error) {
 
@@ -399,6 +463,23 @@ func (ac *AttrCache) TruncateFile(options internal.TruncateFileOptions) error {
 	return err
 }
 
+// FallocateFile : Update the file with its new size if allocation goes beyond it
+func (ac *AttrCache) FallocateFile(options internal.FallocateFileOptions) error {
+	log.Trace("AttrCache::FallocateFile : %s", options.Name)
+
+	err := ac.NextComponent().FallocateFile(options)
+	if err == nil && !options.KeepSize {
+		ac.cacheLock.RLock()
+		defer ac.cacheLock.RUnlock()
+
+		value, found := ac.cacheMap[options.Name]
+		if found && value.valid() && value.exists() && value.attr.Size < options.Offset+options.Length {
+			value.setSize(options.Offset + options.Length)
+		}
+	}
+	return err
+}
+
 // CopyFromFile : Mark the file invalid
 func (ac *AttrCache) CopyFromFile(options internal.CopyFromFileOptions) error {
 	log.Trace("AttrCache::CopyFromFile : %s", options.Name)
@@ -454,15 +535,20 @@ func (ac *AttrCache) SyncDir(options internal.SyncDirOptions) error {
 func (ac *AttrCache) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr, error) {
 	log.Trace("AttrCache::GetAttr : %s", options.Name)
 	truncatedPath := internal.TruncateDirName(options.Name)
+	ac.markDirUsed(parentDir(truncatedPath))
 
 	ac.cacheLock.RLock()
 	value, found := ac.cacheMap[truncatedPath]
+	if found {
+		ac.touchItem(truncatedPath, value)
+	}
 	ac.cacheLock.RUnlock()
 
 	// Try to serve the request from the attribute cache
 	if found && value.valid() && time.Since(value.cachedAt).Seconds() < float64(ac.cacheTimeout) {
 		if value.isDeleted() {
 			log.Debug("AttrCache::GetAttr : %s served from cache", options.Name)
+			attrCacheStatsCollector.UpdateStats(stats_manager.Increment, cacheHits, (int64)(1))
 			// no entry if path does not exist
 			return &internal.ObjAttr{}, syscall.ENOENT
 		} else {
@@ -472,12 +558,14 @@ func (ac *AttrCache) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr
 			if value.getAttr().IsMetadataRetrieved() || (ac.noSymlinks && !options.RetrieveMetadata) {
 				// path exists and we have all the metadata required or we do not care about metadata
 				log.Debug("AttrCache::GetAttr : %s served from cache", options.Name)
+				attrCacheStatsCollector.UpdateStats(stats_manager.Increment, cacheHits, (int64)(1))
 				return value.getAttr(), nil
 			}
 		}
 	}
 
 	// Get the attributes from next component and cache them
+	attrCacheStatsCollector.UpdateStats(stats_manager.Increment, cacheMisses, (int64)(1))
 	pathAttr, err := ac.NextComponent().GetAttr(options)
 
 	ac.cacheLock.Lock()
@@ -485,12 +573,10 @@ func (ac *AttrCache) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr
 
 	if err == nil {
 		// Retrieved attributes so cache them
-		if len(ac.cacheMap) < maxTotalFiles {
-			ac.cacheMap[truncatedPath] = newAttrCacheItem(pathAttr, true, time.Now())
-		}
+		ac.addItem(truncatedPath, newAttrCacheItem(pathAttr, true, time.Now()))
 	} else if err == syscall.ENOENT {
 		// Path does not exist so cache a no-entry item
-		ac.cacheMap[truncatedPath] = newAttrCacheItem(&internal.ObjAttr{}, false, time.Now())
+		ac.addItem(truncatedPath, newAttrCacheItem(&internal.ObjAttr{}, false, time.Now()))
 	}
 
 	return pathAttr, err
@@ -537,19 +623,179 @@ func (ac *AttrCache) Chmod(options internal.ChmodOptions) error {
 
 		value, found := ac.cacheMap[internal.TruncateDirName(options.Name)]
 		if found && value.valid() && value.exists() {
-			value.setMode(options.Mode)
+			if value.attr.IsModeDefault() {
+				// Storage may have persisted the mode in metadata, refresh the attributes on next access
+				value.invalidate()
+			} else {
+				value.setMode(options.Mode)
+			}
 		}
 	}
 
 	return err
 }
 
-// Chown : Update the file with its new owner and group (when datalake chown is implemented)
+// Chown : Update the file with its new owner and group
 func (ac *AttrCache) Chown(options internal.ChownOptions) error {
 	log.Trace("AttrCache::Chown : Change owner of file/directory %s", options.Name)
 
 	err := ac.NextComponent().Chown(options)
-	// TODO: Implement when datalake chown is supported.
+
+	if err == nil {
+		ac.cacheLock.RLock()
+		defer ac.cacheLock.RUnlock()
+
+		// Owner is resolved by storage, refresh the attributes on next access
+		value, found := ac.cacheMap[internal.TruncateDirName(options.Name)]
+		if found && value.valid() && value.exists() {
+			value.invalidate()
+		}
+	}
+
+	return err
+}
+
+// CacheControl : Invalidate the cached attributes on request, or when the file is being evicted or refreshed
+func (ac *AttrCache) CacheControl(options internal.CacheControlOptions) (uint32, error) {
+	log.Trace("AttrCache::CacheControl : %s, command %x", options.Name, options.Command)
+
+	switch options.Command {
+	case common.CacheControlInvalidateAttr, common.CacheControlEvict, common.CacheControlRefresh:
+		ac.cacheLock.RLock()
+		value, found := ac.cacheMap[internal.TruncateDirName(options.Name)]
+		if found && value.valid() {
+			value.invalidate()
+		}
+		ac.cacheLock.RUnlock()
+	}
+
+	return ac.NextComponent().CacheControl(options)
+}
+
+// cachedMetadata : Return a copy of the cached metadata of a path if the cache has it
+func (ac *AttrCache) cachedMetadata(path string) (map[string]string, bool) {
+	value, found := ac.cacheMap[internal.TruncateDirName(path)]
+	if !found || !value.valid() || !value.exists() || !value.getAttr().IsMetadataRetrieved() {
+		return nil, false
+	}
+
+	metadata := make(map[string]string)
+	for k, v := range value.getAttr().Metadata {
+		metadata[k] = v
+	}
+	return metadata, true
+}
+
+// SetXattr : Update the cached metadata with the new extended attribute
+func (ac *AttrCache) SetXattr(options internal.SetXattrOptions) error {
+	log.Trace("AttrCache::SetXattr : Set xattr %s of file/directory %s", options.Attr, options.Name)
+
+	err := ac.NextComponent().SetXattr(options)
+
+	if err == nil {
+		ac.cacheLock.Lock()
+		defer ac.cacheLock.Unlock()
+
+		metadata, found := ac.cachedMetadata(options.Name)
+		if options.Attr == internal.XattrAzureTier {
+			// The tier is not part of the metadata, and moving out of archive takes a while
+			ac.invalidatePath(options.Name)
+		} else if found {
+			if internal.SetXattrInMetadata(metadata, options.Attr, options.Value, 0) == nil {
+				ac.cacheMap[internal.TruncateDirName(options.Name)].setMetadata(metadata)
+			} else {
+				ac.invalidatePath(options.Name)
+			}
+		}
+	}
+
+	return err
+}
+
+// GetXattr : Serve the extended attribute from the cached metadata if possible
+func (ac *AttrCache) GetXattr(options internal.GetXattrOptions) ([]byte, error) {
+	log.Trace("AttrCache::GetXattr : Get xattr %s of file/directory %s", options.Attr, options.Name)
+
+	ac.cacheLock.RLock()
+	value, found := ac.cacheMap[internal.TruncateDirName(options.Name)]
+	if found && value.valid() && time.Since(value.cachedAt).Seconds() < float64(ac.cacheTimeout) {
+		if value.isDeleted() {
+			ac.cacheLock.RUnlock()
+			log.Debug("AttrCache::GetXattr : %s served from cache", options.Name)
+			return nil, syscall.ENOENT
+		} else if value.getAttr().IsMetadataRetrieved() {
+			data, err := internal.GetXattrFromAttr(value.getAttr(), options.Attr)
+			ac.cacheLock.RUnlock()
+			log.Debug("AttrCache::GetXattr : %s served from cache", options.Name)
+			return data, err
+		}
+	}
+	ac.cacheLock.RUnlock()
+
+	return ac.NextComponent().GetXattr(options)
+}
+
+// ListXattr : Serve the extended attribute names from the cached metadata if possible
+func (ac *AttrCache) ListXattr(options internal.ListXattrOptions) ([]string, error) {
+	log.Trace("AttrCache::ListXattr : List xattrs of file/directory %s", options.Name)
+
+	ac.cacheLock.RLock()
+	value, found := ac.cacheMap[internal.TruncateDirName(options.Name)]
+	if found && value.valid() && time.Since(value.cachedAt).Seconds() < float64(ac.cacheTimeout) {
+		if value.isDeleted() {
+			ac.cacheLock.RUnlock()
+			log.Debug("AttrCache::ListXattr : %s served from cache", options.Name)
+			return nil, syscall.ENOENT
+		} else if value.getAttr().IsMetadataRetrieved() {
+			attrs := internal.ListXattrFromAttr(value.getAttr())
+			ac.cacheLock.RUnlock()
+			log.Debug("AttrCache::ListXattr : %s served from cache", options.Name)
+			return attrs, nil
+		}
+	}
+	ac.cacheLock.RUnlock()
+
+	return ac.NextComponent().ListXattr(options)
+}
+
+// RemoveXattr : Remove the extended attribute from the cached metadata
+func (ac *AttrCache) RemoveXattr(options internal.RemoveXattrOptions) error {
+	log.Trace("AttrCache::RemoveXattr : Remove xattr %s of file/directory %s", options.Attr, options.Name)
+
+	err := ac.NextComponent().RemoveXattr(options)
+
+	if err == nil {
+		ac.cacheLock.Lock()
+		defer ac.cacheLock.Unlock()
+
+		metadata, found := ac.cachedMetadata(options.Name)
+		if found {
+			if internal.RemoveXattrFromMetadata(metadata, options.Attr) == nil {
+				ac.cacheMap[internal.TruncateDirName(options.Name)].setMetadata(metadata)
+			} else {
+				ac.invalidatePath(options.Name)
+			}
+		}
+	}
+
+	return err
+}
+
+// SetTimes : Update the file with its new access and modification times
+func (ac *AttrCache) SetTimes(options internal.SetTimesOptions) error {
+	log.Trace("AttrCache::SetTimes : Set times of file/directory %s", options.Name)
+
+	err := ac.NextComponent().SetTimes(options)
+
+	if err == nil {
+		ac.cacheLock.RLock()
+		defer ac.cacheLock.RUnlock()
+
+		value, found := ac.cacheMap[internal.TruncateDirName(options.Name)]
+		if found && value.valid() && value.exists() {
+			value.setTimes(options.Atime, options.Mtime)
+		}
+	}
 
 	return err
 }
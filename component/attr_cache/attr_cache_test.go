@@ -701,6 +701,32 @@ func (suite *attrCacheTestSuite) TestRenameFile() {
 	assertInvalid(suite, dst)
 }
 
+func (suite *attrCacheTestSuite) TestCopyObject() {
+	defer suite.cleanupTest()
+	src := "a"
+	dst := "b"
+	addPathToCache(suite.assert, suite.attrCache, src, false)
+	addPathToCache(suite.assert, suite.attrCache, dst, false)
+
+	options := internal.CopyObjectOptions{Src: src, Dst: dst}
+
+	// Error
+	suite.mock.EXPECT().CopyObject(options).Return(syscall.ENOTSUP)
+
+	err := suite.attrCache.CopyObject(options)
+	suite.assert.Equal(syscall.ENOTSUP, err)
+	assertUntouched(suite, src)
+	assertUntouched(suite, dst)
+
+	// Success
+	suite.mock.EXPECT().CopyObject(options).Return(nil)
+
+	err = suite.attrCache.CopyObject(options)
+	suite.assert.Nil(err)
+	assertUntouched(suite, src)
+	assertInvalid(suite, dst)
+}
+
 // Tests Write File
 func (suite *attrCacheTestSuite) TestWriteFileError() {
 	defer suite.cleanupTest()
@@ -792,6 +818,67 @@ func (suite *attrCacheTestSuite) TestTruncateFile() {
 	suite.assert.True(suite.attrCache.cacheMap[path].exists())
 }
 
+// Tests FallocateFile
+func (suite *attrCacheTestSuite) TestFallocateFile() {
+	defer suite.cleanupTest()
+	path := "a"
+
+	options := internal.FallocateFileOptions{Name: path, Offset: 0, Length: 4096}
+
+	// Error
+	suite.mock.EXPECT().FallocateFile(options).Return(errors.New("Failed to fallocate a file"))
+
+	err := suite.attrCache.FallocateFile(options)
+	suite.assert.NotNil(err)
+	suite.assert.NotContains(suite.attrCache.cacheMap, path)
+
+	// Entry Already Exists, allocation goes beyond its size
+	addPathToCache(suite.assert, suite.attrCache, path, false)
+	suite.mock.EXPECT().FallocateFile(options).Return(nil)
+
+	err = suite.attrCache.FallocateFile(options)
+	suite.assert.Nil(err)
+	suite.assert.Contains(suite.attrCache.cacheMap, path)
+	suite.assert.EqualValues(4096, suite.attrCache.cacheMap[path].attr.Size)
+	suite.assert.True(suite.attrCache.cacheMap[path].valid())
+
+	// Allocation with keep size does not change the size
+	options = internal.FallocateFileOptions{Name: path, Offset: 0, Length: 8192, KeepSize: true}
+	suite.mock.EXPECT().FallocateFile(options).Return(nil)
+
+	err = suite.attrCache.FallocateFile(options)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(4096, suite.attrCache.cacheMap[path].attr.Size)
+}
+
+// Tests CacheControl
+func (suite *attrCacheTestSuite) TestCacheControlInvalidateAttr() {
+	defer suite.cleanupTest()
+	path := "a"
+	addPathToCache(suite.assert, suite.attrCache, path, false)
+
+	options := internal.CacheControlOptions{Name: path, Command: common.CacheControlInvalidateAttr}
+	suite.mock.EXPECT().CacheControl(options).Return(uint32(0), nil)
+
+	_, err := suite.attrCache.CacheControl(options)
+	suite.assert.Nil(err)
+	assertInvalid(suite, path)
+}
+
+func (suite *attrCacheTestSuite) TestCacheControlStatus() {
+	defer suite.cleanupTest()
+	path := "a"
+	addPathToCache(suite.assert, suite.attrCache, path, false)
+
+	options := internal.CacheControlOptions{Name: path, Command: common.CacheControlStatus}
+	suite.mock.EXPECT().CacheControl(options).Return(common.CacheStatusCached, nil)
+
+	status, err := suite.attrCache.CacheControl(options)
+	suite.assert.Nil(err)
+	suite.assert.Equal(common.CacheStatusCached, status)
+	suite.assert.True(suite.attrCache.cacheMap[path].valid())
+}
+
 // Tests CopyFromFile
 func (suite *attrCacheTestSuite) TestCopyFromFileError() {
 	defer suite.cleanupTest()
@@ -1120,9 +1207,23 @@ func (suite *attrCacheTestSuite) TestChmod() {
 }
 
 // Tests Chown
+func (suite *attrCacheTestSuite) TestChmodModeDefault() {
+	defer suite.cleanupTest()
+	path := "a"
+	options := internal.ChmodOptions{Name: path, Mode: fs.FileMode(0755)}
+
+	// Mode of a storage without native permissions is refreshed from storage
+	addPathToCache(suite.assert, suite.attrCache, path, true)
+	suite.attrCache.cacheMap[path].attr.Flags.Set(internal.PropFlagModeDefault)
+	suite.mock.EXPECT().Chmod(options).Return(nil)
+
+	err := suite.attrCache.Chmod(options)
+	suite.assert.Nil(err)
+	assertInvalid(suite, path)
+}
+
 func (suite *attrCacheTestSuite) TestChown() {
 	defer suite.cleanupTest()
-	// TODO: Implement when datalake chown is supported.
 	owner := 0
 	group := 0
 	var paths = []string{"a", "a/"}
@@ -1156,11 +1257,157 @@ func (suite *attrCacheTestSuite) TestChown() {
 
 			err = suite.attrCache.Chown(options)
 			suite.assert.Nil(err)
-			assertUntouched(suite, truncatedPath)
+			assertInvalid(suite, truncatedPath)
 		})
 	}
 }
 
+// Tests SetTimes
+func (suite *attrCacheTestSuite) TestSetTimes() {
+	defer suite.cleanupTest()
+	mtime := time.Unix(1600000000, 0)
+	var paths = []string{"a", "a/"}
+
+	for _, path := range paths {
+		// This is a little janky but required since testify suite does not support running setup or clean up for subtests.
+		suite.cleanupTest()
+		suite.SetupTest()
+		suite.Run(path, func() {
+			truncatedPath := internal.TruncateDirName(path)
+			options := internal.SetTimesOptions{Name: path, Mtime: mtime}
+
+			// Error
+			suite.mock.EXPECT().SetTimes(options).Return(errors.New("Failed to set times"))
+
+			err := suite.attrCache.SetTimes(options)
+			suite.assert.NotNil(err)
+			suite.assert.NotContains(suite.attrCache.cacheMap, truncatedPath)
+
+			// Entry Already Exists
+			addPathToCache(suite.assert, suite.attrCache, path, false)
+			atime := suite.attrCache.cacheMap[truncatedPath].attr.Atime
+			suite.mock.EXPECT().SetTimes(options).Return(nil)
+
+			err = suite.attrCache.SetTimes(options)
+			suite.assert.Nil(err)
+			suite.assert.True(suite.attrCache.cacheMap[truncatedPath].valid())
+			suite.assert.EqualValues(mtime, suite.attrCache.cacheMap[truncatedPath].attr.Mtime)
+			suite.assert.EqualValues(atime, suite.attrCache.cacheMap[truncatedPath].attr.Atime) // zero atime is left unchanged
+		})
+	}
+}
+
+// Tests SetXattr
+func (suite *attrCacheTestSuite) TestSetXattr() {
+	defer suite.cleanupTest()
+	path := "a"
+	options := internal.SetXattrOptions{Name: path, Attr: "user.origin", Value: []byte("pipeline")}
+
+	// Error
+	suite.mock.EXPECT().SetXattr(options).Return(errors.New("Failed to set xattr"))
+
+	err := suite.attrCache.SetXattr(options)
+	suite.assert.NotNil(err)
+	suite.assert.NotContains(suite.attrCache.cacheMap, path)
+
+	// Entry Already Exists with metadata
+	addPathToCache(suite.assert, suite.attrCache, path, true)
+	suite.mock.EXPECT().SetXattr(options).Return(nil)
+
+	err = suite.attrCache.SetXattr(options)
+	suite.assert.Nil(err)
+	suite.assert.True(suite.attrCache.cacheMap[path].valid())
+	suite.assert.Contains(suite.attrCache.cacheMap[path].attr.Metadata, "bfxattr_origin")
+
+	// Served from the cache
+	value, err := suite.attrCache.GetXattr(internal.GetXattrOptions{Name: path, Attr: "user.origin"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("pipeline", string(value))
+
+	attrs, err := suite.attrCache.ListXattr(internal.ListXattrOptions{Name: path})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues([]string{"user.origin"}, attrs)
+}
+
+// Tests SetXattr of the access tier
+func (suite *attrCacheTestSuite) TestSetXattrTier() {
+	defer suite.cleanupTest()
+	path := "a"
+	options := internal.SetXattrOptions{Name: path, Attr: internal.XattrAzureTier, Value: []byte("Cool")}
+
+	addPathToCache(suite.assert, suite.attrCache, path, true)
+	suite.attrCache.cacheMap[path].attr.Tier = "Hot"
+
+	// Served from the cache
+	value, err := suite.attrCache.GetXattr(internal.GetXattrOptions{Name: path, Attr: internal.XattrAzureTier})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("Hot", string(value))
+
+	suite.mock.EXPECT().SetXattr(options).Return(nil)
+
+	err = suite.attrCache.SetXattr(options)
+	suite.assert.Nil(err)
+	suite.assert.False(suite.attrCache.cacheMap[path].valid())
+	suite.assert.NotContains(suite.attrCache.cacheMap[path].attr.Metadata, "bfxattr_azure_2etier")
+}
+
+// Tests GetXattr
+func (suite *attrCacheTestSuite) TestGetXattr() {
+	defer suite.cleanupTest()
+	path := "a"
+	options := internal.GetXattrOptions{Name: path, Attr: "user.origin"}
+
+	// Entry Does Not Already Exist
+	suite.mock.EXPECT().GetXattr(options).Return([]byte("pipeline"), nil)
+
+	value, err := suite.attrCache.GetXattr(options)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("pipeline", string(value))
+
+	// Entry Already Exists without metadata
+	addPathToCache(suite.assert, suite.attrCache, path, false)
+	suite.mock.EXPECT().GetXattr(options).Return(nil, syscall.ENODATA)
+
+	_, err = suite.attrCache.GetXattr(options)
+	suite.assert.Equal(syscall.ENODATA, err)
+
+	// Entry Already Exists with metadata
+	addPathToCache(suite.assert, suite.attrCache, path, true)
+
+	_, err = suite.attrCache.GetXattr(options)
+	suite.assert.Equal(syscall.ENODATA, err)
+
+	// Entry Deleted
+	suite.attrCache.cacheMap[path].markDeleted(time.Now())
+
+	_, err = suite.attrCache.GetXattr(options)
+	suite.assert.Equal(syscall.ENOENT, err)
+}
+
+// Tests RemoveXattr
+func (suite *attrCacheTestSuite) TestRemoveXattr() {
+	defer suite.cleanupTest()
+	path := "a"
+	options := internal.RemoveXattrOptions{Name: path, Attr: "user.origin"}
+
+	// Entry Already Exists with metadata
+	addPathToCache(suite.assert, suite.attrCache, path, true)
+	suite.attrCache.cacheMap[path].attr.Metadata = map[string]string{"bfxattr_origin": "cGlwZWxpbmU=", "other": "value"}
+	suite.mock.EXPECT().RemoveXattr(options).Return(nil)
+
+	err := suite.attrCache.RemoveXattr(options)
+	suite.assert.Nil(err)
+	suite.assert.True(suite.attrCache.cacheMap[path].valid())
+	suite.assert.EqualValues(map[string]string{"other": "value"}, suite.attrCache.cacheMap[path].attr.Metadata)
+
+	// Cached metadata out of sync with storage
+	suite.mock.EXPECT().RemoveXattr(options).Return(nil)
+
+	err = suite.attrCache.RemoveXattr(options)
+	suite.assert.Nil(err)
+	suite.assert.False(suite.attrCache.cacheMap[path].valid())
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestAttrCacheTestSuite(t *testing.T) {
@@ -34,6 +34,7 @@
 package attr_cache
 
 import (
+	"container/list"
 	"os"
 	"time"
 
@@ -46,6 +47,7 @@ const (
 	AttrFlagUnknown uint16 = iota
 	AttrFlagExists
 	AttrFlagValid
+	AttrFlagModified // attributes were updated locally so they no longer match the listing of storage
 )
 
 // attrCacheItem : Structure of each item in attr cache
@@ -53,6 +55,9 @@ type attrCacheItem struct {
 	attr     *internal.ObjAttr
 	cachedAt time.Time
 	attrFlag common.BitMap16
+
+	lruElem *list.Element // position in the lru list of the cache, nil if the item is not tracked
+	memSize int64         // estimated memory accounted for the item when it was cached
 }
 
 func newAttrCacheItem(attr *internal.ObjAttr, exists bool, cachedAt time.Time) *attrCacheItem {
@@ -94,6 +99,10 @@ func (value *attrCacheItem) getAttr() *internal.ObjAttr {
 	return value.attr
 }
 
+func (value *attrCacheItem) modified() bool {
+	return value.attrFlag.IsSet(AttrFlagModified)
+}
+
 func (value *attrCacheItem) isDeleted() bool {
 	return !value.exists()
 }
@@ -102,10 +111,31 @@ func (value *attrCacheItem) setSize(size int64) {
 	value.attr.Mtime = time.Now()
 	value.attr.Size = size
 	value.cachedAt = time.Now()
+	value.attrFlag.Set(AttrFlagModified)
 }
 
 func (value *attrCacheItem) setMode(mode os.FileMode) {
 	value.attr.Mode = mode
 	value.attr.Ctime = time.Now()
 	value.cachedAt = time.Now()
+	value.attrFlag.Set(AttrFlagModified)
+}
+
+func (value *attrCacheItem) setMetadata(metadata map[string]string) {
+	value.attr.Metadata = metadata
+	value.attr.Ctime = time.Now()
+	value.cachedAt = time.Now()
+	value.attrFlag.Set(AttrFlagModified)
+}
+
+func (value *attrCacheItem) setTimes(atime time.Time, mtime time.Time) {
+	if !atime.IsZero() {
+		value.attr.Atime = atime
+	}
+	if !mtime.IsZero() {
+		value.attr.Mtime = mtime
+	}
+	value.attr.Ctime = time.Now()
+	value.cachedAt = time.Now()
+	value.attrFlag.Set(AttrFlagModified)
 }
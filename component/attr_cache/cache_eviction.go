@@ -0,0 +1,170 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package attr_cache
+
+import (
+	"github.com/Azure/azure-storage-fuse/v2/internal/stats_manager"
+)
+
+// Bytes held by a cached item besides its strings : map entry, list element, item and attribute structures
+const attrCacheItemOverhead = 320
+
+var attrCacheStatsCollector *stats_manager.StatsCollector
+
+// estimateSize : Approximate memory used by the item cached against the given key
+func (value *attrCacheItem) estimateSize(key string) int64 {
+	size := attrCacheItemOverhead + len(key)
+	if value.attr != nil {
+		size += len(value.attr.Path) + len(value.attr.Name) + len(value.attr.ETag)
+		for k, v := range value.attr.Metadata {
+			size += len(k) + len(v)
+		}
+	}
+	return int64(size)
+}
+
+// addItem : Cache the item against the path and evict least recently used items beyond the configured limits
+// Caller shall hold the cache lock for writing
+func (ac *AttrCache) addItem(path string, item *attrCacheItem) {
+	old, found := ac.cacheMap[path]
+
+	ac.lruLock.Lock()
+	if found && old.lruElem != nil {
+		ac.lruList.Remove(old.lruElem)
+		ac.usedMemory -= old.memSize
+		old.lruElem = nil
+	}
+
+	item.memSize = item.estimateSize(path)
+	item.lruElem = ac.lruList.PushFront(path)
+	ac.usedMemory += item.memSize
+	ac.touchParents(path)
+	ac.lruLock.Unlock()
+
+	ac.cacheMap[path] = item
+	if !found {
+		ac.addChild(path)
+	}
+	ac.evictItems()
+}
+
+// addChild : Index the cached path under its parent directory, so that a directory is checked without a scan of the whole cache
+// Caller shall hold the cache lock for writing
+func (ac *AttrCache) addChild(path string) {
+	if ac.children == nil {
+		ac.children = make(map[string]map[string]bool)
+	}
+
+	dir := parentDir(path)
+	if ac.children[dir] == nil {
+		ac.children[dir] = make(map[string]bool)
+	}
+	ac.children[dir][path] = true
+}
+
+// removeChild : Drop the path from the index of its parent directory
+// Caller shall hold the cache lock for writing
+func (ac *AttrCache) removeChild(path string) {
+	dir := parentDir(path)
+	delete(ac.children[dir], path)
+	if len(ac.children[dir]) == 0 {
+		delete(ac.children, dir)
+	}
+}
+
+// touchItem : Mark the cached item and its parent directories as most recently used
+// Caller shall hold the cache lock at least for reading
+func (ac *AttrCache) touchItem(path string, item *attrCacheItem) {
+	ac.lruLock.Lock()
+	defer ac.lruLock.Unlock()
+
+	if item.lruElem != nil {
+		ac.lruList.MoveToFront(item.lruElem)
+	}
+	ac.touchParents(path)
+}
+
+// touchParents : Move the cached parent directories of the path ahead of it in the lru list.
+// A directory is thus never evicted before the entries cached under it, so listings and deletes of
+// the directory keep finding its children.
+// Caller shall hold the lru lock
+func (ac *AttrCache) touchParents(path string) {
+	for dir := parentDir(path); dir != ""; dir = parentDir(dir) {
+		value, found := ac.cacheMap[dir]
+		if found && value.lruElem != nil {
+			ac.lruList.MoveToFront(value.lruElem)
+		}
+	}
+}
+
+// overLimit : Check whether the cache holds more entries or memory than configured
+// Caller shall hold the lru lock
+func (ac *AttrCache) overLimit() bool {
+	if uint64(ac.lruList.Len()) > ac.maxEntries {
+		return true
+	}
+	return ac.maxMemory > 0 && ac.usedMemory > int64(ac.maxMemory)
+}
+
+// evictItems : Drop least recently used items until the cache is within its limits
+// Caller shall hold the cache lock for writing
+func (ac *AttrCache) evictItems() {
+	ac.lruLock.Lock()
+	defer ac.lruLock.Unlock()
+
+	evicted := 0
+	for ac.overLimit() {
+		elem := ac.lruList.Back()
+		if elem == nil {
+			break
+		}
+
+		path := elem.Value.(string)
+		ac.lruList.Remove(elem)
+		value, found := ac.cacheMap[path]
+		if found && value.lruElem == elem {
+			delete(ac.cacheMap, path)
+			ac.removeChild(path)
+			ac.usedMemory -= value.memSize
+			value.lruElem = nil
+		}
+		evicted++
+	}
+
+	if evicted > 0 {
+		attrCacheStatsCollector.UpdateStats(stats_manager.Increment, cacheEvictions, (int64)(evicted))
+		attrCacheStatsCollector.UpdateStats(stats_manager.Replace, cacheEntries, (int64)(ac.lruList.Len()))
+		attrCacheStatsCollector.UpdateStats(stats_manager.Replace, cacheMemory, (int64)(ac.usedMemory))
+	}
+}
@@ -0,0 +1,136 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package attr_cache
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/golang/mock/gomock"
+)
+
+func (suite *attrCacheTestSuite) TestConfigLimits() {
+	defer suite.cleanupTest()
+	suite.assert.EqualValues(maxTotalFiles, suite.attrCache.maxEntries)
+	suite.assert.EqualValues(0, suite.attrCache.maxMemory)
+	suite.cleanupTest()
+
+	suite.setupTestHelper("attr_cache:\n  max-entries: 100\n  max-memory-mb: 2")
+	suite.assert.EqualValues(100, suite.attrCache.maxEntries)
+	suite.assert.EqualValues(2*1024*1024, suite.attrCache.maxMemory)
+}
+
+func (suite *attrCacheTestSuite) TestEvictLeastRecentlyUsed() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+	suite.setupTestHelper("attr_cache:\n  max-entries: 2")
+
+	for _, path := range []string{"a", "b"} {
+		suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: path}).Return(getPathAttr(path, defaultSize, fs.FileMode(defaultMode), true), nil)
+		_, err := suite.attrCache.GetAttr(internal.GetAttrOptions{Name: path})
+		suite.assert.Nil(err)
+	}
+
+	// Served from cache, "a" becomes the most recently used
+	_, err := suite.attrCache.GetAttr(internal.GetAttrOptions{Name: "a"})
+	suite.assert.Nil(err)
+
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "c"}).Return(getPathAttr("c", defaultSize, fs.FileMode(defaultMode), true), nil)
+	_, err = suite.attrCache.GetAttr(internal.GetAttrOptions{Name: "c"})
+	suite.assert.Nil(err)
+
+	suite.assert.Contains(suite.attrCache.cacheMap, "a")
+	suite.assert.NotContains(suite.attrCache.cacheMap, "b")
+	suite.assert.Contains(suite.attrCache.cacheMap, "c")
+	suite.assert.EqualValues(2, suite.attrCache.lruList.Len())
+}
+
+func (suite *attrCacheTestSuite) TestEvictChildrenBeforeParent() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+	suite.setupTestHelper("attr_cache:\n  max-entries: 3")
+
+	paths := []string{"dir/file1", "dir", "dir/file2", "other"}
+	for _, path := range paths {
+		suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: path}).Return(getPathAttr(path, defaultSize, fs.FileMode(defaultMode), true), nil)
+		_, err := suite.attrCache.GetAttr(internal.GetAttrOptions{Name: path})
+		suite.assert.Nil(err)
+	}
+
+	// Using "dir/file2" kept "dir" ahead of it, so the first file goes first
+	suite.assert.NotContains(suite.attrCache.cacheMap, "dir/file1")
+	suite.assert.Contains(suite.attrCache.cacheMap, "dir")
+	suite.assert.Contains(suite.attrCache.cacheMap, "dir/file2")
+	suite.assert.Contains(suite.attrCache.cacheMap, "other")
+
+	// Evicted paths leave the index of their directory
+	suite.assert.Equal(map[string]bool{"dir/file2": true}, suite.attrCache.children["dir"])
+	suite.assert.Equal(map[string]bool{"dir": true, "other": true}, suite.attrCache.children[""])
+}
+
+func (suite *attrCacheTestSuite) TestEvictOnMemoryLimit() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+	suite.setupTestHelper("attr_cache:\n  max-memory-mb: 1")
+
+	var pathList []*internal.ObjAttr
+	for i := 0; i < 5000; i++ {
+		path := fmt.Sprintf("dir/file%d", i)
+		pathList = append(pathList, getPathAttr(path, defaultSize, fs.FileMode(defaultMode), false))
+	}
+	suite.mock.EXPECT().StreamDir(gomock.Any()).Return(pathList, "", nil)
+
+	_, _, err := suite.attrCache.StreamDir(internal.StreamDirOptions{Name: "dir"})
+	suite.assert.Nil(err)
+	suite.assert.LessOrEqual(suite.attrCache.usedMemory, int64(1024*1024))
+	suite.assert.Less(len(suite.attrCache.cacheMap), len(pathList))
+	suite.assert.Equal(len(suite.attrCache.cacheMap), suite.attrCache.lruList.Len())
+}
+
+func (suite *attrCacheTestSuite) TestReplaceKeepsAccounting() {
+	defer suite.cleanupTest()
+
+	suite.mock.EXPECT().GetAttr(gomock.Any()).Return(getPathAttr("a", defaultSize, fs.FileMode(defaultMode), true), nil).Times(2)
+	_, err := suite.attrCache.GetAttr(internal.GetAttrOptions{Name: "a"})
+	suite.assert.Nil(err)
+	used := suite.attrCache.usedMemory
+
+	suite.attrCache.cacheMap["a"].invalidate()
+	_, err = suite.attrCache.GetAttr(internal.GetAttrOptions{Name: "a"})
+	suite.assert.Nil(err)
+	suite.assert.Equal(used, suite.attrCache.usedMemory)
+	suite.assert.EqualValues(1, suite.attrCache.lruList.Len())
+}
@@ -0,0 +1,208 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package attr_cache
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+// By default at most 16 directories are listed again in each poll
+const defaultPollBudget uint32 = 16
+
+// parentDir : Directory holding the path, root is the empty string as in the keys of the cache map
+func parentDir(name string) string {
+	dir := filepath.Dir(internal.TruncateDirName(name))
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// markDirUsed : Remember the directory as recently used so that the poller keeps it in sync with storage
+func (ac *AttrCache) markDirUsed(dir string) {
+	if ac.pollInterval == 0 {
+		return
+	}
+
+	ac.recentLock.Lock()
+	ac.recentDirs[internal.TruncateDirName(dir)] = time.Now()
+	ac.recentLock.Unlock()
+}
+
+// recentDirectories : Directories used while their entries can still be served from cache, most recent first, capped by the poll budget
+func (ac *AttrCache) recentDirectories() []string {
+	ac.recentLock.Lock()
+	defer ac.recentLock.Unlock()
+
+	dirs := make([]string, 0, len(ac.recentDirs))
+	for dir, usedAt := range ac.recentDirs {
+		if time.Since(usedAt).Seconds() >= float64(ac.cacheTimeout) {
+			delete(ac.recentDirs, dir)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+
+	sort.Slice(dirs, func(i, j int) bool {
+		return ac.recentDirs[dirs[i]].After(ac.recentDirs[dirs[j]])
+	})
+
+	if uint32(len(dirs)) > ac.pollBudget {
+		dirs = dirs[:ac.pollBudget]
+	}
+	return dirs
+}
+
+// pollRemoteChanges : List the recently used directories at every poll interval to find paths changed by other writers
+func (ac *AttrCache) pollRemoteChanges(stop <-chan bool) {
+	ticker := time.NewTicker(time.Duration(ac.pollInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			log.Info("AttrCache::pollRemoteChanges : Stopping remote change poller")
+			return
+		case <-ticker.C:
+			for _, dir := range ac.recentDirectories() {
+				ac.pollDirectory(dir)
+			}
+		}
+	}
+}
+
+// pollDirectory : Compare the listing of a directory with the cached attributes and invalidate the paths that changed
+func (ac *AttrCache) pollDirectory(dir string) {
+	listed := make(map[string]*internal.ObjAttr)
+	complete := true
+
+	token := ""
+	for {
+		pathList, nextToken, err := ac.NextComponent().StreamDir(internal.StreamDirOptions{Name: dir, Token: token})
+		if err != nil {
+			log.Err("AttrCache::pollDirectory : Failed to list %s [%s]", dir, err.Error())
+			return
+		}
+
+		for _, attr := range pathList {
+			listed[internal.TruncateDirName(attr.Path)] = attr
+		}
+
+		token = nextToken
+		if token == "" {
+			break
+		}
+		if len(listed) >= maxFilesPerDir {
+			// Paths missing from a partial listing may still exist, deletions can not be detected
+			complete = false
+			break
+		}
+	}
+
+	changed, entriesChanged := ac.findRemoteChanges(dir, listed, complete)
+	for path, isDir := range changed {
+		log.Info("AttrCache::pollDirectory : %s changed in storage", path)
+		internal.InvalidatePath(path, isDir)
+	}
+
+	// Entries added or removed make the listing of the directory stale
+	if entriesChanged {
+		internal.InvalidatePath(dir, true)
+	}
+}
+
+// findRemoteChanges : Invalidate the cached children of the directory that do not match its listing
+// Entries updated through this mount are skipped as they may not match storage till the update is uploaded
+func (ac *AttrCache) findRemoteChanges(dir string, listed map[string]*internal.ObjAttr, complete bool) (map[string]bool, bool) {
+	changed := make(map[string]bool)
+	entriesChanged := false
+
+	ac.cacheLock.Lock()
+	defer ac.cacheLock.Unlock()
+
+	for path, attr := range listed {
+		value, found := ac.cacheMap[path]
+		if !found || !value.valid() || value.modified() {
+			continue
+		}
+
+		switch {
+		case value.isDeleted():
+			// Cached as non existent but created by another writer
+			entriesChanged = true
+		case attr.IsDir():
+			// Listing does not carry real times for directories without a marker blob, only their existence is compared
+			continue
+		case sameObject(value.getAttr(), attr):
+			continue
+		}
+
+		changed[path] = attr.IsDir()
+		value.invalidate()
+	}
+
+	if !complete {
+		return changed, entriesChanged
+	}
+
+	for path := range ac.children[dir] {
+		value, found := ac.cacheMap[path]
+		if !found {
+			continue
+		}
+		if _, found := listed[path]; found || !value.valid() || value.modified() || value.isDeleted() {
+			continue
+		}
+
+		// Deleted by another writer
+		changed[path] = value.getAttr().IsDir()
+		entriesChanged = true
+		value.invalidate()
+	}
+
+	return changed, entriesChanged
+}
+
+// sameObject : Whether the cached attributes still describe the object in storage
+func sameObject(cached *internal.ObjAttr, current *internal.ObjAttr) bool {
+	if cached.ETag != "" && current.ETag != "" {
+		return cached.ETag == current.ETag
+	}
+	return cached.Size == current.Size && cached.Mtime.Equal(current.Mtime)
+}
@@ -0,0 +1,143 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package attr_cache
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/golang/mock/gomock"
+)
+
+func (suite *attrCacheTestSuite) TestConfigPoll() {
+	defer suite.cleanupTest()
+	suite.assert.EqualValues(0, suite.attrCache.pollInterval)
+	suite.assert.EqualValues(defaultPollBudget, suite.attrCache.pollBudget)
+	suite.cleanupTest()
+
+	config := "attr_cache:\n  poll-interval-sec: 30\n  poll-budget: 4"
+	suite.setupTestHelper(config)
+	suite.assert.EqualValues(30, suite.attrCache.pollInterval)
+	suite.assert.EqualValues(4, suite.attrCache.pollBudget)
+	suite.assert.NotNil(suite.attrCache.stopPoll)
+}
+
+func (suite *attrCacheTestSuite) TestRecentDirectories() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+	suite.setupTestHelper("attr_cache:\n  poll-interval-sec: 3600\n  poll-budget: 2")
+
+	suite.mock.EXPECT().GetAttr(gomock.Any()).Return(nil, syscall.ENOENT).AnyTimes()
+	for _, path := range []string{"a/file", "b/file", "c/file", "d"} {
+		suite.attrCache.GetAttr(internal.GetAttrOptions{Name: path})
+		time.Sleep(time.Millisecond)
+	}
+	// Directories not used within the cache timeout are not polled
+	suite.attrCache.recentDirs["old"] = time.Now().Add(-time.Hour)
+
+	// Most recently used first, capped by the budget
+	suite.assert.Equal([]string{"", "c"}, suite.attrCache.recentDirectories())
+	suite.assert.NotContains(suite.attrCache.recentDirs, "old")
+}
+
+func (suite *attrCacheTestSuite) TestPollDirectory() {
+	defer suite.cleanupTest()
+	var invalidated []string
+	internal.AddInvalidationHandler("test", func(name string, isDir bool) { invalidated = append(invalidated, name) })
+	defer internal.RemoveInvalidationHandler("test")
+
+	cached := func(path string, etag string) *internal.ObjAttr {
+		attr := getPathAttr(path, defaultSize, 0777, true)
+		attr.ETag = etag
+		suite.attrCache.addItem(path, newAttrCacheItem(attr, true, time.Now()))
+		return attr
+	}
+	cached("dir/same", "e1")
+	cached("dir/changed", "e2")
+	cached("dir/deleted", "e3")
+	cached("dir/local", "e4")
+	suite.attrCache.cacheMap["dir/local"].setSize(10)
+	suite.attrCache.addItem("dir/created", newAttrCacheItem(&internal.ObjAttr{}, false, time.Now()))
+	cached("other/file", "e5")
+
+	listed := func(path string, etag string) *internal.ObjAttr {
+		attr := getPathAttr(path, defaultSize, 0777, true)
+		attr.ETag = etag
+		return attr
+	}
+	suite.mock.EXPECT().StreamDir(internal.StreamDirOptions{Name: "dir"}).Return([]*internal.ObjAttr{
+		listed("dir/same", "e1"),
+		listed("dir/changed", "e2-new"),
+	}, "token", nil)
+	suite.mock.EXPECT().StreamDir(internal.StreamDirOptions{Name: "dir", Token: "token"}).Return([]*internal.ObjAttr{
+		listed("dir/local", "e4-new"),
+		listed("dir/created", "e6"),
+	}, "", nil)
+
+	suite.attrCache.pollDirectory("dir")
+
+	suite.assert.ElementsMatch([]string{"dir/changed", "dir/deleted", "dir/created", "dir"}, invalidated)
+	suite.assert.Equal("dir", invalidated[len(invalidated)-1])
+	assertUntouched(suite, "dir/same")
+	assertInvalid(suite, "dir/changed")
+	assertInvalid(suite, "dir/deleted")
+	assertInvalid(suite, "dir/created")
+	assertUntouched(suite, "other/file")
+	suite.assert.True(suite.attrCache.cacheMap["dir/local"].valid())
+}
+
+func (suite *attrCacheTestSuite) TestPollDirectoryMtime() {
+	defer suite.cleanupTest()
+	var invalidated []string
+	internal.AddInvalidationHandler("test", func(name string, isDir bool) { invalidated = append(invalidated, name) })
+	defer internal.RemoveInvalidationHandler("test")
+
+	// Without etags the size and last modified time are compared
+	addPathToCache(suite.assert, suite.attrCache, "file", true)
+	addPathToCache(suite.assert, suite.attrCache, "dir", true)
+	file := *suite.attrCache.cacheMap["file"].attr
+	file.Mtime = file.Mtime.Add(time.Second)
+	dir := *suite.attrCache.cacheMap["dir"].attr
+	dir.Flags = internal.NewDirBitMap()
+	dir.Mtime = time.Now().Add(time.Hour)
+
+	suite.mock.EXPECT().StreamDir(internal.StreamDirOptions{Name: ""}).Return([]*internal.ObjAttr{&file, &dir}, "", nil)
+	suite.attrCache.pollDirectory("")
+
+	suite.assert.Equal([]string{"file"}, invalidated)
+	assertInvalid(suite, "file")
+	suite.assert.True(suite.attrCache.cacheMap["dir"].valid())
+}
@@ -36,6 +36,7 @@ package azstorage
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -57,6 +58,7 @@ type AzStorage struct {
 	stConfig    AzStorageConfig
 	startTime   time.Time
 	listBlocked bool
+	locks       *lockManager
 }
 
 const compName = "azstorage"
@@ -147,6 +149,8 @@ func (az *AzStorage) configureAndTest(isParent bool) error {
 		return err
 	}
 
+	az.locks = newLockManager(az.storage, az.stConfig.leaseLocks, az.stConfig.leaseDuration)
+
 	// The daemon runs all pipeline Configure code twice. isParent allows us to only validate credentials in parent mode, preventing a second unnecessary REST call.
 	if isParent {
 		err = az.storage.TestPipeline()
@@ -175,6 +179,9 @@ func (az *AzStorage) Start(ctx context.Context) error {
 // Stop : Disconnect all running operations here
 func (az *AzStorage) Stop() error {
 	log.Trace("AzStorage::Stop : Stopping component %s", az.Name())
+	if az.locks != nil {
+		az.locks.releaseAll()
+	}
 	azStatsCollector.Destroy()
 	return nil
 }
@@ -190,6 +197,10 @@ func (az *AzStorage) ListContainers() ([]string, error) {
 func (az *AzStorage) CreateDir(options internal.CreateDirOptions) error {
 	log.Trace("AzStorage::CreateDir : %s", options.Name)
 
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	err := az.storage.CreateDirectory(internal.TruncateDirName(options.Name))
 
 	if err == nil {
@@ -203,6 +214,10 @@ func (az *AzStorage) CreateDir(options internal.CreateDirOptions) error {
 func (az *AzStorage) DeleteDir(options internal.DeleteDirOptions) error {
 	log.Trace("AzStorage::DeleteDir : %s", options.Name)
 
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	err := az.storage.DeleteDirectory(internal.TruncateDirName(options.Name))
 
 	if err == nil {
@@ -226,6 +241,19 @@ func formatListDirName(path string) string {
 
 func (az *AzStorage) IsDirEmpty(options internal.IsDirEmptyOptions) bool {
 	log.Trace("AzStorage::IsDirEmpty : %s", options.Name)
+	if mirrored, found := az.versionsPath(options.Name); found {
+		list, err := az.readVersionsDir(mirrored)
+		return err == nil && len(list) == 0
+	}
+	if mirrored, found := az.trashPath(options.Name); found {
+		list, err := az.readTrashDir(mirrored)
+		return err == nil && len(list) == 0
+	}
+	if az.atPointInTime() {
+		list, err := az.readDirAt(options.Name)
+		return err == nil && len(list) == 0
+	}
+
 	list, _, err := az.storage.List(formatListDirName(options.Name), nil, 1)
 	if err != nil {
 		log.Err("AzStorage::IsDirEmpty : error listing [%s]", err)
@@ -252,6 +280,16 @@ func (az *AzStorage) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAt
 		}
 	}
 
+	if mirrored, found := az.versionsPath(options.Name); found {
+		return az.readVersionsDir(mirrored)
+	}
+	if mirrored, found := az.trashPath(options.Name); found {
+		return az.readTrashDir(mirrored)
+	}
+	if az.atPointInTime() {
+		return az.readDirAt(options.Name)
+	}
+
 	path := formatListDirName(options.Name)
 	var iteration int = 0
 	var marker *string = nil
@@ -288,6 +326,20 @@ func (az *AzStorage) StreamDir(options internal.StreamDirOptions) ([]*internal.O
 		}
 	}
 
+	if mirrored, found := az.versionsPath(options.Name); found {
+		// Versions of a blob can span pages of the listing, so the whole directory is returned at once
+		list, err := az.readVersionsDir(mirrored)
+		return list, "", err
+	}
+	if mirrored, found := az.trashPath(options.Name); found {
+		list, err := az.readTrashDir(mirrored)
+		return list, "", err
+	}
+	if az.atPointInTime() {
+		list, err := az.readDirAt(options.Name)
+		return list, "", err
+	}
+
 	path := formatListDirName(options.Name)
 
 	new_list, new_marker, err := az.storage.List(path, &options.Token, options.Count)
@@ -330,7 +382,15 @@ func (az *AzStorage) RenameDir(options internal.RenameDirOptions) error {
 	options.Src = internal.TruncateDirName(options.Src)
 	options.Dst = internal.TruncateDirName(options.Dst)
 
-	err := az.storage.RenameDirectory(options.Src, options.Dst)
+	var err error
+	if mirrored, found := az.trashPath(options.Src); found && mirrored != "" && !az.isReadOnly(options.Dst) {
+		// Moving a directory out of the trash restores the deleted blobs under it
+		err = az.restoreDir(mirrored, options.Dst)
+	} else if az.isReadOnly(options.Src, options.Dst) {
+		return syscall.EROFS
+	} else {
+		err = az.storage.RenameDirectory(options.Src, options.Dst)
+	}
 
 	if err == nil {
 		azStatsCollector.PushEvents(renameDir, options.Src, map[string]interface{}{src: options.Src, dest: options.Dst})
@@ -342,6 +402,9 @@ func (az *AzStorage) RenameDir(options internal.RenameDirOptions) error {
 // File operations
 func (az *AzStorage) CreateFile(options internal.CreateFileOptions) (*handlemap.Handle, error) {
 	log.Trace("AzStorage::CreateFile : %s", options.Name)
+	if az.isReadOnly(options.Name) {
+		return nil, syscall.EROFS
+	}
 
 	// Create a handle object for the file being created
 	// This handle will be added to handlemap by the first component in pipeline
@@ -351,7 +414,7 @@ func (az *AzStorage) CreateFile(options internal.CreateFileOptions) (*handlemap.
 		return nil, syscall.EFAULT
 	}
 
-	err := az.storage.CreateFile(options.Name, options.Mode)
+	err := az.storage.CreateFile(options.Name, options.Mode, options.Owner, options.Group)
 	if err != nil {
 		return nil, err
 	}
@@ -368,7 +431,18 @@ func (az *AzStorage) CreateFile(options internal.CreateFileOptions) (*handlemap.
 func (az *AzStorage) OpenFile(options internal.OpenFileOptions) (*handlemap.Handle, error) {
 	log.Trace("AzStorage::OpenFile : %s", options.Name)
 
-	attr, err := az.storage.GetAttr(options.Name)
+	var attr *internal.ObjAttr
+	var err error
+	if az.inTrash(options.Name) {
+		// Deleted blobs have to be restored before they can be read
+		return nil, syscall.EACCES
+	} else if az.inVersions(options.Name) {
+		attr, err = az.openVersion(options)
+	} else if az.atPointInTime() {
+		attr, err = az.openAt(options)
+	} else {
+		attr, err = az.storage.GetAttr(options.Name)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -383,6 +457,12 @@ func (az *AzStorage) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 	handle.Size = int64(attr.Size)
 	handle.Mtime = attr.Mtime
 
+	if az.isReadOnly(options.Name) {
+		// Reads of the handle are served from the version that was opened
+		name, _ := az.versionOf(options.Name)
+		handle.SetValue(blobVersionKey, blobVersion{name: name, versionID: attr.VersionID})
+	}
+
 	// increment open file handles count
 	azStatsCollector.UpdateStats(stats_manager.Increment, openHandles, (int64)(1))
 
@@ -401,6 +481,10 @@ func (az *AzStorage) CloseFile(options internal.CloseFileOptions) error {
 func (az *AzStorage) DeleteFile(options internal.DeleteFileOptions) error {
 	log.Trace("AzStorage::DeleteFile : %s", options.Name)
 
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	err := az.storage.DeleteFile(options.Name)
 
 	if err == nil {
@@ -414,7 +498,15 @@ func (az *AzStorage) DeleteFile(options internal.DeleteFileOptions) error {
 func (az *AzStorage) RenameFile(options internal.RenameFileOptions) error {
 	log.Trace("AzStorage::RenameFile : %s to %s", options.Src, options.Dst)
 
-	err := az.storage.RenameFile(options.Src, options.Dst)
+	var err error
+	if mirrored, found := az.trashPath(options.Src); found && !az.isReadOnly(options.Dst) {
+		// Moving a deleted blob out of the trash restores it
+		err = az.restoreFile(mirrored, options.Dst)
+	} else if az.isReadOnly(options.Src, options.Dst) {
+		return syscall.EROFS
+	} else {
+		err = az.storage.RenameFile(options.Src, options.Dst)
+	}
 
 	if err == nil {
 		azStatsCollector.PushEvents(renameFile, options.Src, map[string]interface{}{src: options.Src, dest: options.Dst})
@@ -423,8 +515,30 @@ func (az *AzStorage) RenameFile(options internal.RenameFileOptions) error {
 	return err
 }
 
+func (az *AzStorage) CopyObject(options internal.CopyObjectOptions) error {
+	log.Trace("AzStorage::CopyObject : %s to %s", options.Src, options.Dst)
+
+	if az.isReadOnly(options.Src, options.Dst) {
+		return syscall.EROFS
+	}
+
+	err := az.storage.CopyObject(options.Src, options.Dst)
+
+	if err == nil {
+		azStatsCollector.PushEvents(copyObject, options.Src, map[string]interface{}{src: options.Src, dest: options.Dst})
+		azStatsCollector.UpdateStats(stats_manager.Increment, copyObject, (int64)(1))
+	}
+	return err
+}
+
 func (az *AzStorage) ReadFile(options internal.ReadFileOptions) (data []byte, err error) {
 	//log.Trace("AzStorage::ReadFile : Read %s", h.Path)
+	if az.isReadOnly(options.Handle.Path) {
+		name, versionID := handleVersion(options.Handle)
+		data = make([]byte, atomic.LoadInt64(&options.Handle.Size))
+		err = az.storage.ReadInBuffer(name, versionID, 0, int64(len(data)), data)
+		return data, err
+	}
 	return az.storage.ReadBuffer(options.Handle.Path, 0, 0)
 }
 
@@ -444,7 +558,8 @@ func (az *AzStorage) ReadInBuffer(options internal.ReadInBufferOptions) (length
 		return 0, nil
 	}
 
-	err = az.storage.ReadInBuffer(options.Handle.Path, options.Offset, dataLen, options.Data)
+	name, versionID := handleVersion(options.Handle)
+	err = az.storage.ReadInBuffer(name, versionID, options.Offset, dataLen, options.Data)
 	if err != nil {
 		log.Err("AzStorage::ReadInBuffer : Failed to read %s [%s]", options.Handle.Path, err.Error())
 	}
@@ -454,17 +569,31 @@ func (az *AzStorage) ReadInBuffer(options internal.ReadInBufferOptions) (length
 }
 
 func (az *AzStorage) WriteFile(options internal.WriteFileOptions) (int, error) {
+	if az.isReadOnly(options.Handle.Path) {
+		return 0, syscall.EROFS
+	}
+	options.Metadata = removeTimesFromMetadata(options.Metadata)
 	err := az.storage.Write(options)
 	return len(options.Data), err
 }
 
 func (az *AzStorage) GetFileBlockOffsets(options internal.GetFileBlockOffsetsOptions) (*common.BlockOffsetList, error) {
+	if az.isReadOnly(options.Name) {
+		// Versions are only read, so they are handled as a single block
+		bol := &common.BlockOffsetList{}
+		bol.Flags.Set(common.SmallFile)
+		return bol, nil
+	}
 	return az.storage.GetFileBlockOffsets(options.Name)
 
 }
 
 func (az *AzStorage) TruncateFile(options internal.TruncateFileOptions) error {
 	log.Trace("AzStorage::TruncateFile : %s to %d bytes", options.Name, options.Size)
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	err := az.storage.TruncateFile(options.Name, options.Size)
 
 	if err == nil {
@@ -474,19 +603,121 @@ func (az *AzStorage) TruncateFile(options internal.TruncateFileOptions) error {
 	return err
 }
 
+func (az *AzStorage) FallocateFile(options internal.FallocateFileOptions) error {
+	log.Trace("AzStorage::FallocateFile : %s offset %d, length %d", options.Name, options.Offset, options.Length)
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
+	err := az.storage.FallocateFile(options.Name, options.Offset, options.Length, options.KeepSize, options.PunchHole)
+
+	if err == nil {
+		azStatsCollector.PushEvents(fallocateFile, options.Name, map[string]interface{}{offset: options.Offset, length: options.Length})
+		azStatsCollector.UpdateStats(stats_manager.Increment, fallocateFile, (int64)(1))
+	}
+	return err
+}
+
+// SeekFile : Find next data or hole in the blob from its block list, sparse blocks are reported as holes
+func (az *AzStorage) SeekFile(options internal.SeekFileOptions) (int64, error) {
+	log.Trace("AzStorage::SeekFile : %s offset %d, whence %d", options.Name, options.Offset, options.Whence)
+
+	attr, err := az.GetAttr(internal.GetAttrOptions{Name: options.Name})
+	if err != nil {
+		return 0, err
+	}
+	if options.Offset >= attr.Size {
+		return 0, syscall.ENXIO
+	}
+
+	if az.isReadOnly(options.Name) {
+		// Versions are reported as all data
+		if options.Whence == common.SeekData {
+			return options.Offset, nil
+		}
+		return attr.Size, nil
+	}
+
+	bol, err := az.storage.GetFileBlockOffsets(options.Name)
+	if err != nil {
+		log.Err("AzStorage::SeekFile : Failed to get block list of %s [%s]", options.Name, err.Error())
+		return 0, err
+	}
+
+	if options.Whence == common.SeekData {
+		if bol.SmallFile() {
+			return options.Offset, nil
+		}
+		offset, found := bol.SeekData(options.Offset)
+		if !found {
+			return 0, syscall.ENXIO
+		}
+		return offset, nil
+	}
+
+	if bol.SmallFile() {
+		return attr.Size, nil
+	}
+	return bol.SeekHole(options.Offset), nil
+}
+
 func (az *AzStorage) CopyToFile(options internal.CopyToFileOptions) error {
 	log.Trace("AzStorage::CopyToFile : Read file %s", options.Name)
-	return az.storage.ReadToFile(options.Name, options.Offset, options.Count, options.File)
+	if az.inTrash(options.Name) {
+		return syscall.EACCES
+	}
+
+	name, versionID, err := az.resolveVersion(options.Name)
+	if err != nil {
+		return err
+	}
+	return az.storage.ReadToFile(name, versionID, options.Offset, options.Count, options.File)
 }
 
 func (az *AzStorage) CopyFromFile(options internal.CopyFromFileOptions) error {
 	log.Trace("AzStorage::CopyFromFile : Upload file %s", options.Name)
-	return az.storage.WriteFromFile(options.Name, options.Metadata, options.File)
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
+	metadata := az.preserveMetadata(options.Name, removeTimesFromMetadata(options.Metadata))
+	if options.Reader != nil {
+		return az.storage.WriteFromReader(options.Name, metadata, options.Reader, options.Size)
+	}
+	return az.storage.WriteFromFile(options.Name, metadata, options.File)
+}
+
+// preserveMetadata : Carry the extended attributes of an existing blob, and its mode and owner when posix
+// attributes are preserved, over to the metadata of its new content
+func (az *AzStorage) preserveMetadata(name string, metadata map[string]string) map[string]string {
+	attr, err := az.storage.GetAttr(name)
+	if err != nil {
+		// New blob, nothing to preserve
+		return metadata
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	for k, v := range attr.Metadata {
+		key := strings.ToLower(k)
+		posix := az.stConfig.preservePosixAttrs && (key == modeKey || key == uidKey || key == gidKey)
+		if strings.HasPrefix(key, internal.XattrMetadataPrefix) || posix {
+			if _, found := metadata[key]; !found {
+				metadata[key] = v
+			}
+		}
+	}
+	return metadata
 }
 
 // Symlink operations
 func (az *AzStorage) CreateLink(options internal.CreateLinkOptions) error {
 	log.Trace("AzStorage::CreateLink : Create symlink %s -> %s", options.Name, options.Target)
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	err := az.storage.CreateLink(options.Name, options.Target)
 
 	if err == nil {
@@ -499,6 +730,19 @@ func (az *AzStorage) CreateLink(options internal.CreateLinkOptions) error {
 
 func (az *AzStorage) ReadLink(options internal.ReadLinkOptions) (string, error) {
 	log.Trace("AzStorage::ReadLink : Read symlink %s", options.Name)
+	if az.inTrash(options.Name) {
+		return "", syscall.EACCES
+	}
+	if az.atPointInTime() {
+		attr, err := az.getAttrAt(options.Name)
+		if err != nil {
+			return "", err
+		}
+		data := make([]byte, attr.Size)
+		err = az.storage.ReadInBuffer(options.Name, attr.VersionID, 0, attr.Size, data)
+		return string(data), err
+	}
+
 	data, err := az.storage.ReadBuffer(options.Name, 0, 0)
 
 	if err != nil {
@@ -512,11 +756,24 @@ func (az *AzStorage) ReadLink(options internal.ReadLinkOptions) (string, error)
 // Attribute operations
 func (az *AzStorage) GetAttr(options internal.GetAttrOptions) (attr *internal.ObjAttr, err error) {
 	//log.Trace("AzStorage::GetAttr : Get attributes of file %s", name)
+	if mirrored, found := az.versionsPath(options.Name); found {
+		return az.getVersionsAttr(mirrored)
+	}
+	if mirrored, found := az.trashPath(options.Name); found {
+		return az.getTrashAttr(mirrored)
+	}
+	if az.atPointInTime() {
+		return az.getAttrAt(options.Name)
+	}
 	return az.storage.GetAttr(options.Name)
 }
 
 func (az *AzStorage) Chmod(options internal.ChmodOptions) error {
 	log.Trace("AzStorage::Chmod : Change mod of file %s", options.Name)
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	err := az.storage.ChangeMod(options.Name, options.Mode)
 
 	if err == nil {
@@ -529,18 +786,203 @@ func (az *AzStorage) Chmod(options internal.ChmodOptions) error {
 
 func (az *AzStorage) Chown(options internal.ChownOptions) error {
 	log.Trace("AzStorage::Chown : Change ownership of file %s to %d-%d", options.Name, options.Owner, options.Group)
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
 	return az.storage.ChangeOwner(options.Name, options.Owner, options.Group)
 }
 
+func (az *AzStorage) SetTimes(options internal.SetTimesOptions) error {
+	log.Trace("AzStorage::SetTimes : Set times of file %s", options.Name)
+
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
+	attr, err := az.storage.GetAttr(options.Name)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string)
+	for k, v := range attr.Metadata {
+		metadata[k] = v
+	}
+
+	if !options.Mtime.IsZero() {
+		metadata[mtimeKey] = options.Mtime.UTC().Format(time.RFC3339Nano)
+	}
+	if !options.Atime.IsZero() {
+		metadata[atimeKey] = options.Atime.UTC().Format(time.RFC3339Nano)
+	}
+
+	err = az.storage.SetMetadata(options.Name, metadata)
+	if err == syscall.ENOENT && attr.IsDir() {
+		// Virtual directories do not have a marker blob to hold the metadata
+		log.Debug("AzStorage::SetTimes : %s is a virtual directory, ignoring", options.Name)
+		return nil
+	}
+
+	if err == nil {
+		azStatsCollector.PushEvents(setTimes, options.Name, map[string]interface{}{mtime: options.Mtime.String()})
+		azStatsCollector.UpdateStats(stats_manager.Increment, setTimes, (int64)(1))
+	}
+
+	return err
+}
+
+func (az *AzStorage) SetXattr(options internal.SetXattrOptions) error {
+	log.Trace("AzStorage::SetXattr : Set xattr %s of file %s", options.Attr, options.Name)
+
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	}
+
+	if options.Attr == internal.XattrAzureTier {
+		// The tier is a property of the blob and not part of its metadata
+		err := az.setTier(options.Name, options.Value)
+		if err == nil {
+			azStatsCollector.PushEvents(setXattr, options.Name, map[string]interface{}{xattr: options.Attr})
+			azStatsCollector.UpdateStats(stats_manager.Increment, setXattr, (int64)(1))
+		}
+		return err
+	}
+
+	attr, err := az.storage.GetAttr(options.Name)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string)
+	for k, v := range attr.Metadata {
+		metadata[k] = v
+	}
+
+	err = internal.SetXattrInMetadata(metadata, options.Attr, options.Value, options.Flags)
+	if err != nil {
+		return err
+	}
+
+	err = az.storage.SetMetadata(options.Name, metadata)
+	if err == nil {
+		azStatsCollector.PushEvents(setXattr, options.Name, map[string]interface{}{xattr: options.Attr})
+		azStatsCollector.UpdateStats(stats_manager.Increment, setXattr, (int64)(1))
+	}
+
+	return err
+}
+
+func (az *AzStorage) GetXattr(options internal.GetXattrOptions) ([]byte, error) {
+	log.Trace("AzStorage::GetXattr : Get xattr %s of file %s", options.Attr, options.Name)
+
+	attr, err := az.GetAttr(internal.GetAttrOptions{Name: options.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.GetXattrFromAttr(attr, options.Attr)
+}
+
+func (az *AzStorage) ListXattr(options internal.ListXattrOptions) ([]string, error) {
+	log.Trace("AzStorage::ListXattr : List xattrs of file %s", options.Name)
+
+	attr, err := az.GetAttr(internal.GetAttrOptions{Name: options.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.ListXattrFromAttr(attr), nil
+}
+
+func (az *AzStorage) RemoveXattr(options internal.RemoveXattrOptions) error {
+	log.Trace("AzStorage::RemoveXattr : Remove xattr %s of file %s", options.Attr, options.Name)
+
+	if az.isReadOnly(options.Name) {
+		return syscall.EROFS
+	} else if options.Attr == internal.XattrAzureTier {
+		// Every blob has a tier, it can be changed but not removed
+		return syscall.EPERM
+	}
+
+	attr, err := az.storage.GetAttr(options.Name)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string)
+	for k, v := range attr.Metadata {
+		metadata[k] = v
+	}
+
+	err = internal.RemoveXattrFromMetadata(metadata, options.Attr)
+	if err != nil {
+		return err
+	}
+
+	err = az.storage.SetMetadata(options.Name, metadata)
+	if err == nil {
+		azStatsCollector.PushEvents(removeXattr, options.Name, map[string]interface{}{xattr: options.Attr})
+		azStatsCollector.UpdateStats(stats_manager.Increment, removeXattr, (int64)(1))
+	}
+
+	return err
+}
+
+// LockFile : Take an advisory lock on the file for the given handle
+func (az *AzStorage) LockFile(options internal.LockFileOptions) error {
+	log.Trace("AzStorage::LockFile : Lock file %s, handle %d, exclusive %t", options.Handle.Path, options.Handle.ID, options.Exclusive)
+
+	if options.CheckOnly {
+		return az.locks.check(options.Handle.Path, options.Handle.ID, options.Exclusive)
+	}
+
+	var err error
+	if options.Wait {
+		err = az.locks.lockWait(options.Handle.Path, options.Handle.ID, options.Exclusive)
+	} else {
+		err = az.locks.lock(options.Handle.Path, options.Handle.ID, options.Exclusive)
+	}
+
+	if err == nil {
+		azStatsCollector.PushEvents(lockFile, options.Handle.Path, map[string]interface{}{exclusive: options.Exclusive})
+		azStatsCollector.UpdateStats(stats_manager.Increment, lockFile, (int64)(1))
+	}
+	return err
+}
+
+// UnlockFile : Release the advisory lock held on the file by the given handle
+func (az *AzStorage) UnlockFile(options internal.UnlockFileOptions) error {
+	log.Trace("AzStorage::UnlockFile : Unlock file %s, handle %d", options.Handle.Path, options.Handle.ID)
+	return az.locks.unlock(options.Handle.Path, options.Handle.ID)
+}
+
+// ReleaseFile : Release any lock still held by the handle once the file is closed
+func (az *AzStorage) ReleaseFile(options internal.ReleaseFileOptions) error {
+	log.Trace("AzStorage::ReleaseFile : Release file %s, handle %d", options.Handle.Path, options.Handle.ID)
+	return az.locks.unlock(options.Handle.Path, options.Handle.ID)
+}
+
 func (az *AzStorage) FlushFile(options internal.FlushFileOptions) error {
 	log.Trace("AzStorage::FlushFile : Flush file %s", options.Handle.Path)
-	return az.storage.StageAndCommit(options.Handle.Path, options.Handle.CacheObj.BlockOffsetList)
+	if az.isReadOnly(options.Handle.Path) {
+		// Nothing can be written to a version
+		return nil
+	}
+
+	// committing the block list replaces the metadata of the blob, carry over what it has apart from the times
+	// which the new content makes stale
+	attr, err := az.storage.GetAttr(options.Handle.Path)
+	if err != nil {
+		log.Err("AzStorage::FlushFile : Failed to get attributes of %s [%s]", options.Handle.Path, err.Error())
+		return err
+	}
+	return az.storage.StageAndCommit(options.Handle.Path, options.Handle.CacheObj.BlockOffsetList, removeTimesFromMetadata(attr.Metadata))
 }
 
 // TODO : Below methods are pending to be implemented
 // SetAttr(string, internal.ObjAttr) error
 // UnlinkFile(string) error
-// ReleaseFile(*handlemap.Handle) error
 // FlushFile(*handlemap.Handle) error
 
 // ------------------------- Factory methods to create objects -------------------------------------------
@@ -630,6 +1072,21 @@ func init() {
 	disableCompression := config.AddBoolFlag("disable-compression", false, "Disable transport layer compression.")
 	config.BindPFlag(compName+".disable-compression", disableCompression)
 
+	preservePosixAttrs := config.AddBoolFlag("preserve-posix-attributes", false, "Store mode and owner set by chmod/chown in blob metadata on block blob accounts.")
+	config.BindPFlag(compName+".preserve-posix-attributes", preservePosixAttrs)
+
+	showVersions := config.AddBoolFlag("show-versions", false, "Browse the versions of every blob in the read only .versions directory at the root of the mount.")
+	config.BindPFlag(compName+".show-versions", showVersions)
+
+	pointInTime := config.AddStringFlag("at", "", "Mount the container read only as it was at the given RFC3339 time, using the versions of its blobs. Blobs deleted before that time are still shown unless soft delete recorded the deletion.")
+	config.BindPFlag(compName+".point-in-time", pointInTime)
+
+	showTrash := config.AddBoolFlag("show-trash", false, "List soft deleted blobs in the .trash directory at the root of the mount, moving them out of it restores them.")
+	config.BindPFlag(compName+".show-trash", showTrash)
+
+	autoRehydrate := config.AddBoolFlag("auto-rehydrate", false, "Start the rehydration of archived blobs to the hot tier when they are read.")
+	config.BindPFlag(compName+".auto-rehydrate", autoRehydrate)
+
 	config.RegisterFlagCompletionFunc("container-name", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	})
@@ -40,17 +40,23 @@ const (
 	uploadProgress   = "UploadProgress"
 	bytesTfrd        = "Bytes Transferred"
 
-	createDir    = "CreateDir"
-	deleteDir    = "DeleteDir"
-	streamDir    = "StreamDir"
-	renameDir    = "RenameDir"
-	createFile   = "CreateFile"
-	deleteFile   = "DeleteFile"
-	renameFile   = "RenameFile"
-	truncateFile = "TruncateFile"
-	createLink   = "CreateLink"
-	readLink     = "ReadLink"
-	chmod        = "Chmod"
+	createDir     = "CreateDir"
+	deleteDir     = "DeleteDir"
+	streamDir     = "StreamDir"
+	renameDir     = "RenameDir"
+	createFile    = "CreateFile"
+	deleteFile    = "DeleteFile"
+	renameFile    = "RenameFile"
+	copyObject    = "CopyObject"
+	truncateFile  = "TruncateFile"
+	fallocateFile = "FallocateFile"
+	createLink    = "CreateLink"
+	readLink      = "ReadLink"
+	chmod         = "Chmod"
+	setTimes      = "SetTimes"
+	setXattr      = "SetXattr"
+	removeXattr   = "RemoveXattr"
+	lockFile      = "LockFile"
 
 	openHandles = "OpenFileHandles"
 	mode        = "Mode"
@@ -59,4 +65,9 @@ const (
 	dest        = "Dest"
 	size        = "Size"
 	target      = "Target"
+	xattr       = "Xattr"
+	mtime       = "Mtime"
+	exclusive   = "Exclusive"
+	offset      = "Offset"
+	length      = "Length"
 )
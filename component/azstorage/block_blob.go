@@ -37,13 +37,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
+	"io"
 	"math"
 	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -60,6 +64,11 @@ import (
 const (
 	folderKey  = "hdi_isfolder"
 	symlinkKey = "is_symlink"
+	mtimeKey   = "bf_mtime"
+	atimeKey   = "bf_atime"
+	modeKey    = "bf_mode"
+	uidKey     = "bf_uid"
+	gidKey     = "bf_gid"
 )
 
 type BlockBlob struct {
@@ -72,6 +81,7 @@ type BlockBlob struct {
 	downloadOptions azblob.DownloadFromBlobOptions
 	listDetails     azblob.BlobListingDetails
 	blockLocks      common.KeyedMutex
+	leaseIDs        sync.Map // lease ids held by this mount, keyed by blob name
 }
 
 // Verify that BlockBlob implements AzConnection interface
@@ -106,6 +116,8 @@ func (bb *BlockBlob) UpdateConfig(cfg AzStorageConfig) error {
 	bb.Config.blockSize = cfg.blockSize
 	bb.Config.maxConcurrency = cfg.maxConcurrency
 	bb.Config.defaultTier = cfg.defaultTier
+	bb.Config.tierRules = cfg.tierRules
+	bb.Config.autoRehydrate = cfg.autoRehydrate
 	bb.Config.ignoreAccessModifiers = cfg.ignoreAccessModifiers
 	return nil
 }
@@ -263,10 +275,18 @@ func (bb *BlockBlob) SetPrefixPath(path string) error {
 }
 
 // CreateFile : Create a new file in the container/virtual directory
-func (bb *BlockBlob) CreateFile(name string, mode os.FileMode) error {
+func (bb *BlockBlob) CreateFile(name string, mode os.FileMode, user int, group int) error {
 	log.Trace("BlockBlob::CreateFile : name %s", name)
 	var data []byte
-	return bb.WriteFromBuffer(name, nil, data)
+	var metadata azblob.Metadata
+	if bb.Config.preservePosixAttrs {
+		metadata = azblob.Metadata{
+			modeKey: formatPosixMode(mode),
+			uidKey:  strconv.Itoa(user),
+			gidKey:  strconv.Itoa(group),
+		}
+	}
+	return bb.WriteFromBuffer(name, metadata, data)
 }
 
 // CreateDirectory : Create a new directory in the container/virtual directory
@@ -294,7 +314,7 @@ func (bb *BlockBlob) DeleteFile(name string) (err error) {
 	log.Trace("BlockBlob::DeleteFile : name %s", name)
 
 	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
-	_, err = blobURL.Delete(context.Background(), azblob.DeleteSnapshotsOptionInclude, bb.blobAccCond)
+	_, err = blobURL.Delete(context.Background(), azblob.DeleteSnapshotsOptionInclude, bb.accessConditions(name))
 	if err != nil {
 		serr := storeBlobErrToErr(err)
 		if serr == ErrFileNotFound {
@@ -312,6 +332,25 @@ func (bb *BlockBlob) DeleteFile(name string) (err error) {
 	return nil
 }
 
+// UndeleteFile : Restore a soft deleted blob along with its soft deleted snapshots
+func (bb *BlockBlob) UndeleteFile(name string) error {
+	log.Trace("BlockBlob::UndeleteFile : name %s", name)
+
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	_, err := blobURL.Undelete(context.Background())
+	if err != nil {
+		serr := storeBlobErrToErr(err)
+		if serr == ErrFileNotFound {
+			log.Err("BlockBlob::UndeleteFile : %s does not exist", name)
+			return syscall.ENOENT
+		}
+		log.Err("BlockBlob::UndeleteFile : Failed to undelete blob %s [%s]", name, err.Error())
+		return err
+	}
+
+	return nil
+}
+
 // DeleteDirectory : Delete a virtual directory in the container/virtual directory
 func (bb *BlockBlob) DeleteDirectory(name string) (err error) {
 	log.Trace("BlockBlob::DeleteDirectory : name %s", name)
@@ -359,7 +398,7 @@ func (bb *BlockBlob) RenameFile(source string, target string) error {
 	}
 
 	startCopy, err := newBlob.StartCopyFromURL(context.Background(), blobURL.URL(),
-		prop.NewMetadata(), azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, bb.Config.defaultTier, nil)
+		prop.NewMetadata(), azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, bb.tierOf(target), nil)
 
 	if err != nil {
 		log.Err("BlockBlob::RenameFile : Failed to start copy of file %s [%s]", source, err.Error())
@@ -398,6 +437,72 @@ func (bb *BlockBlob) RenameFile(source string, target string) error {
 	return err
 }
 
+// CopyObject : Copy the blob to target within the container without moving the data through this machine
+func (bb *BlockBlob) CopyObject(source string, target string) error {
+	log.Trace("BlockBlob::CopyObject : %s -> %s", source, target)
+
+	blobURL := bb.Container.NewBlockBlobURL(filepath.Join(bb.Config.prefixPath, source))
+	newBlob := bb.Container.NewBlockBlobURL(filepath.Join(bb.Config.prefixPath, target))
+
+	prop, err := blobURL.GetProperties(context.Background(), bb.blobAccCond, bb.blobCPKOpt)
+	if err != nil {
+		serr := storeBlobErrToErr(err)
+		if serr == ErrFileNotFound {
+			log.Err("BlockBlob::CopyObject : %s does not exist", source)
+			return syscall.ENOENT
+		} else {
+			log.Err("BlockBlob::CopyObject : Failed to get blob properties for %s [%s]", source, err.Error())
+			return err
+		}
+	}
+
+	// Copy is new content for the target so times set through utimens on the source do not apply
+	metadata := azblob.Metadata(removeTimesFromMetadata(prop.NewMetadata()))
+
+	// Put Blob From URL completes in a single call but the source url has to carry its own authorization
+	if bb.Config.authConfig.AuthMode == EAuthType.SAS() && prop.ContentLength() <= azblob.BlockBlobMaxUploadBlobBytes {
+		_, err = newBlob.PutBlobFromURL(context.Background(), prop.NewHTTPHeaders(), blobURL.URL(), metadata,
+			azblob.ModifiedAccessConditions{}, bb.accessConditions(target), nil, nil, bb.tierOf(target), nil, bb.blobCPKOpt)
+		if err != nil {
+			log.Err("BlockBlob::CopyObject : Failed to put %s from url of %s [%s]", target, source, err.Error())
+			if storeBlobErrToErr(err) == BlobIsUnderLease {
+				return syscall.EBUSY
+			}
+			return err
+		}
+		return nil
+	}
+
+	startCopy, err := newBlob.StartCopyFromURL(context.Background(), blobURL.URL(),
+		metadata, azblob.ModifiedAccessConditions{}, bb.accessConditions(target), bb.tierOf(target), nil)
+	if err != nil {
+		log.Err("BlockBlob::CopyObject : Failed to start copy of file %s [%s]", source, err.Error())
+		if storeBlobErrToErr(err) == BlobIsUnderLease {
+			return syscall.EBUSY
+		}
+		return err
+	}
+
+	copyStatus := startCopy.CopyStatus()
+	for copyStatus == azblob.CopyStatusPending {
+		time.Sleep(time.Second * 1)
+		prop, err = newBlob.GetProperties(context.Background(), bb.blobAccCond, bb.blobCPKOpt)
+		if err != nil {
+			log.Err("BlockBlob::CopyObject : CopyStats : Failed to get blob properties for %s [%s]", target, err.Error())
+			return err
+		}
+		copyStatus = prop.CopyStatus()
+	}
+
+	if copyStatus != azblob.CopyStatusSuccess {
+		log.Err("BlockBlob::CopyObject : Copy of %s to %s ended with status %s", source, target, copyStatus)
+		return syscall.EIO
+	}
+
+	log.Trace("BlockBlob::CopyObject : %s -> %s done", source, target)
+	return nil
+}
+
 // RenameDirectory : Rename the directory
 func (bb *BlockBlob) RenameDirectory(source string, target string) error {
 	log.Trace("BlockBlob::RenameDirectory : %s -> %s", source, target)
@@ -455,6 +560,8 @@ func (bb *BlockBlob) getAttrUsingRest(name string) (attr *internal.ObjAttr, err
 		Crtime: prop.CreationTime(),
 		Flags:  internal.NewFileBitMap(),
 		MD5:    prop.ContentMD5(),
+		ETag:   sanitizeETag(string(prop.ETag())),
+		Tier:   prop.AccessTier(),
 	}
 
 	parseMetadata(attr, prop.NewMetadata())
@@ -462,6 +569,10 @@ func (bb *BlockBlob) getAttrUsingRest(name string) (attr *internal.ObjAttr, err
 	attr.Flags.Set(internal.PropFlagMetadataRetrieved)
 	attr.Flags.Set(internal.PropFlagModeDefault)
 
+	if bb.Config.preservePosixAttrs {
+		parsePosixMetadata(attr)
+	}
+
 	return attr, nil
 }
 
@@ -533,6 +644,26 @@ func (bb *BlockBlob) GetAttr(name string) (attr *internal.ObjAttr, err error) {
 // This fetches the list using a marker so the caller code should handle marker logic
 // If count=0 - fetch max entries
 func (bb *BlockBlob) List(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error) {
+	return bb.listBlobs(prefix, marker, count, bb.listDetails)
+}
+
+// ListVersions : Get a list of blobs matching the given prefix with an entry for every version of each blob
+// Blobs deleted since their last version are listed as well, from their versions
+func (bb *BlockBlob) ListVersions(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error) {
+	details := bb.listDetails
+	details.Versions = true
+	return bb.listBlobs(prefix, marker, count, details)
+}
+
+// ListDeleted : Get a list of soft deleted blobs matching the given prefix, along with the directories under it
+// Times of the deleted blobs are set to the time they were deleted
+func (bb *BlockBlob) ListDeleted(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error) {
+	details := bb.listDetails
+	details.Deleted = true
+	return bb.listBlobs(prefix, marker, count, details)
+}
+
+func (bb *BlockBlob) listBlobs(prefix string, marker *string, count int32, details azblob.BlobListingDetails) ([]*internal.ObjAttr, *string, error) {
 	log.Trace("BlockBlob::List : prefix %s, marker %s", prefix, func(marker *string) string {
 		if marker != nil {
 			return *marker
@@ -556,7 +687,7 @@ func (bb *BlockBlob) List(prefix string, marker *string, count int32) ([]*intern
 	listBlob, err := bb.Container.ListBlobsHierarchySegment(context.Background(), azblob.Marker{Val: marker}, "/",
 		azblob.ListBlobsSegmentOptions{MaxResults: count,
 			Prefix:  listPath,
-			Details: bb.listDetails,
+			Details: details,
 		})
 	// Note: Since we make a list call with a prefix, we will not fail here for a non-existent directory.
 	// The blob service will not validate for us whether or not the path exists.
@@ -594,11 +725,27 @@ func (bb *BlockBlob) List(prefix string, marker *string, count int32) ([]*intern
 			Crtime: dereferenceTime(blobInfo.Properties.CreationTime, blobInfo.Properties.LastModified),
 			Flags:  internal.NewFileBitMap(),
 			MD5:    blobInfo.Properties.ContentMD5,
+			ETag:   sanitizeETag(string(blobInfo.Properties.Etag)),
+			Tier:   string(blobInfo.Properties.AccessTier),
+		}
+		if details.Versions && blobInfo.VersionID != nil {
+			attr.VersionID = *blobInfo.VersionID
+		}
+		if blobInfo.Deleted && blobInfo.Properties.DeletedTime != nil {
+			attr.Mtime = *blobInfo.Properties.DeletedTime
+			attr.Ctime = attr.Mtime
 		}
 
 		parseMetadata(attr, blobInfo.Metadata)
 		attr.Flags.Set(internal.PropFlagMetadataRetrieved)
 		attr.Flags.Set(internal.PropFlagModeDefault)
+		if bb.Config.preservePosixAttrs {
+			parsePosixMetadata(attr)
+		}
+		if details.Deleted && !blobInfo.Deleted && !attr.IsDir() {
+			// Only soft deleted blobs are asked for, live ones come along with them
+			continue
+		}
 		blobList = append(blobList, attr)
 
 		if attr.IsDir() {
@@ -662,11 +809,11 @@ func trackDownload(name string, bytesTransferred int64, count int64, downloadPtr
 }
 
 // ReadToFile : Download a blob to a local file
-func (bb *BlockBlob) ReadToFile(name string, offset int64, count int64, fi *os.File) (err error) {
-	log.Trace("BlockBlob::ReadToFile : name %s, offset : %d, count %d", name, offset, count)
+func (bb *BlockBlob) ReadToFile(name string, versionID string, offset int64, count int64, fi *os.File) (err error) {
+	log.Trace("BlockBlob::ReadToFile : name %s, version %s, offset : %d, count %d", name, versionID, offset, count)
 	//defer exectime.StatTimeCurrentBlock("BlockBlob::ReadToFile")()
 
-	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	blobURL := bb.getBlobURL(name, versionID)
 
 	var downloadPtr *int64 = new(int64)
 	*downloadPtr = 1
@@ -684,6 +831,8 @@ func (bb *BlockBlob) ReadToFile(name string, offset int64, count int64, fi *os.F
 		e := storeBlobErrToErr(err)
 		if e == ErrFileNotFound {
 			return syscall.ENOENT
+		} else if e == BlobIsArchived {
+			return bb.archived(name)
 		} else {
 			log.Err("BlockBlob::ReadToFile : Failed to download blob %s [%s]", name, err.Error())
 			return err
@@ -723,6 +872,15 @@ func (bb *BlockBlob) ReadToFile(name string, offset int64, count int64, fi *os.F
 	return nil
 }
 
+// getBlobURL : URL of the blob, or of one of its versions when a version id is given
+func (bb *BlockBlob) getBlobURL(name string, versionID string) azblob.BlobURL {
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	if versionID != "" {
+		blobURL = blobURL.WithVersionID(versionID)
+	}
+	return blobURL
+}
+
 // ReadBuffer : Download a specific range from a blob to a buffer
 func (bb *BlockBlob) ReadBuffer(name string, offset int64, len int64) ([]byte, error) {
 	log.Trace("BlockBlob::ReadBuffer : name %s", name)
@@ -757,9 +915,9 @@ func (bb *BlockBlob) ReadBuffer(name string, offset int64, len int64) ([]byte, e
 }
 
 // ReadInBuffer : Download specific range from a file to a user provided buffer
-func (bb *BlockBlob) ReadInBuffer(name string, offset int64, len int64, data []byte) error {
+func (bb *BlockBlob) ReadInBuffer(name string, versionID string, offset int64, len int64, data []byte) error {
 	// log.Trace("BlockBlob::ReadInBuffer : name %s", name)
-	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	blobURL := bb.getBlobURL(name, versionID)
 	err := azblob.DownloadBlobToBuffer(context.Background(), blobURL, offset, len, data, bb.downloadOptions)
 
 	if err != nil {
@@ -768,6 +926,8 @@ func (bb *BlockBlob) ReadInBuffer(name string, offset int64, len int64, data []b
 			return syscall.ENOENT
 		} else if e == InvalidRange {
 			return syscall.ERANGE
+		} else if e == BlobIsArchived {
+			return bb.archived(name)
 		}
 
 		log.Err("BlockBlob::ReadInBuffer : Failed to download blob %s [%s]", name, err.Error())
@@ -872,11 +1032,12 @@ func (bb *BlockBlob) WriteFromFile(name string, metadata map[string]string, fi *
 		BlockSize:      blockSize,
 		Parallelism:    bb.Config.maxConcurrency,
 		Metadata:       metadata,
-		BlobAccessTier: bb.Config.defaultTier,
+		BlobAccessTier: bb.tierOf(name),
 		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
 			ContentType: getContentType(name),
 			ContentMD5:  md5sum,
 		},
+		AccessConditions: bb.accessConditions(name),
 	}
 	if common.MonitorBfs() && stat.Size() > 0 {
 		uploadOptions.Progress = func(bytesTransferred int64) {
@@ -907,6 +1068,58 @@ func (bb *BlockBlob) WriteFromFile(name string, metadata map[string]string, fi *
 	return nil
 }
 
+// WriteFromReader : Upload size bytes read from the reader to a blob. The contents are read block by block
+// as they are uploaded, so they never have to be held in full.
+func (bb *BlockBlob) WriteFromReader(name string, metadata map[string]string, r io.ReaderAt, size int64) error {
+	log.Trace("BlockBlob::WriteFromReader : name %s, size %d", name, size)
+	blobURL := bb.Container.NewBlockBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	defer log.TimeTrack(time.Now(), "BlockBlob::WriteFromReader", name)
+
+	blockSize := bb.Config.blockSize
+	if blockSize == 0 {
+		var err error
+		blockSize, err = bb.calculateBlockSize(name, size)
+		if err != nil {
+			return err
+		}
+	}
+
+	md5sum := []byte{}
+	if bb.Config.updateMD5 && size >= azblob.BlockBlobMaxUploadBlobBytes {
+		var err error
+		md5sum, err = getMD5(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			log.Warn("BlockBlob::WriteFromReader : Failed to generate md5 of %s", name)
+			md5sum = []byte{0}
+		}
+	}
+
+	_, err := azblob.UploadStreamToBlockBlob(context.Background(), io.NewSectionReader(r, 0, size), blobURL, azblob.UploadStreamToBlockBlobOptions{
+		BufferSize:     int(blockSize),
+		MaxBuffers:     int(bb.Config.maxConcurrency),
+		Metadata:       metadata,
+		BlobAccessTier: bb.tierOf(name),
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
+			ContentType: getContentType(name),
+			ContentMD5:  md5sum,
+		},
+		AccessConditions: bb.accessConditions(name),
+	})
+	if err != nil {
+		if storeBlobErrToErr(err) == BlobIsUnderLease {
+			log.Err("BlockBlob::WriteFromReader : %s is under a lease, can not update file [%s]", name, err.Error())
+			return syscall.EIO
+		}
+		log.Err("BlockBlob::WriteFromReader : Failed to upload blob %s [%s]", name, err.Error())
+		return err
+	}
+
+	if size > 0 {
+		azStatsCollector.UpdateStats(stats_manager.Increment, bytesUploaded, size)
+	}
+	return nil
+}
+
 // WriteFromBuffer : Upload from a buffer to a blob
 func (bb *BlockBlob) WriteFromBuffer(name string, metadata map[string]string, data []byte) error {
 	log.Trace("BlockBlob::WriteFromBuffer : name %s", name)
@@ -917,10 +1130,11 @@ func (bb *BlockBlob) WriteFromBuffer(name string, metadata map[string]string, da
 		BlockSize:      bb.Config.blockSize,
 		Parallelism:    bb.Config.maxConcurrency,
 		Metadata:       metadata,
-		BlobAccessTier: bb.Config.defaultTier,
+		BlobAccessTier: bb.tierOf(name),
 		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
 			ContentType: getContentType(name),
 		},
+		AccessConditions: bb.accessConditions(name),
 	})
 
 	if err != nil {
@@ -953,6 +1167,10 @@ func (bb *BlockBlob) GetFileBlockOffsets(name string) (*common.BlockOffsetList,
 			StartIndex: int64(blockOffset),
 			EndIndex:   int64(blockOffset) + block.Size,
 		}
+		// only blocks staged by us as zero filled carry the marker, a repeated id may still hold data
+		if isZeroBlockId(blk.Id) {
+			blk.Flags.Set(common.SparseBlock)
+		}
 		blockOffset += block.Size
 		blockList.BlockList = append(blockList.BlockList, blk)
 	}
@@ -974,12 +1192,47 @@ func (bb *BlockBlob) createBlock(blockIdLength, startIndex, size int64) *common.
 	return newBlock
 }
 
+// zeroBlockPrefix : marks the ids of zero filled blocks so sparse ranges are recognised from the block list
+var zeroBlockPrefix = []byte("bfzero")
+
+// zeroBlockId : id of the zero filled block of given size, random if the id length can not hold the marker and size
+func zeroBlockId(idLength int64, size int64) string {
+	if idLength < int64(len(zeroBlockPrefix))+8 {
+		return base64.StdEncoding.EncodeToString(common.NewUUIDWithLength(idLength))
+	}
+	id := make([]byte, idLength)
+	copy(id, zeroBlockPrefix)
+	binary.BigEndian.PutUint64(id[len(zeroBlockPrefix):], uint64(size))
+	return base64.StdEncoding.EncodeToString(id)
+}
+
+func isZeroBlockId(id string) bool {
+	rawId, err := base64.StdEncoding.DecodeString(id)
+	return err == nil && bytes.HasPrefix(rawId, zeroBlockPrefix)
+}
+
+// detachSparseBlock : a sparse block may share its id with other zero filled blocks so give it an id of its own before staging data in it
+func (bb *BlockBlob) detachSparseBlock(blk *common.Block, blockIdLength int64) {
+	if blk.Sparse() {
+		blk.Id = base64.StdEncoding.EncodeToString(common.NewUUIDWithLength(blockIdLength))
+		blk.Flags.Clear(common.SparseBlock)
+	}
+}
+
+// getTruncateBlockSize : size of the zero filled blocks created while extending a file
+func (bb *BlockBlob) getTruncateBlockSize() int64 {
+	if bb.Config.blockSize == 0 {
+		return (16 * 1024 * 1024)
+	}
+	return bb.Config.blockSize
+}
+
 // create new blocks based on the offset and total length we're adding to the file
 func (bb *BlockBlob) createNewBlocks(blockList *common.BlockOffsetList, offset, length int64) int64 {
-	blockSize := bb.Config.blockSize
-	prevIndex := blockList.BlockList[len(blockList.BlockList)-1].EndIndex
-	if blockSize == 0 {
-		blockSize = (16 * 1024 * 1024)
+	blockSize := bb.getTruncateBlockSize()
+	prevIndex := int64(0)
+	if len(blockList.BlockList) > 0 {
+		prevIndex = blockList.BlockList[len(blockList.BlockList)-1].EndIndex
 	}
 	// BufferSize is the size of the buffer that will go beyond our current blob (appended)
 	var bufferSize int64
@@ -1006,7 +1259,7 @@ func (bb *BlockBlob) removeBlocks(blockList *common.BlockOffsetList, size int64,
 		blk.Data = make([]byte, blk.EndIndex-blk.StartIndex)
 		blk.Flags.Set(common.DirtyBlock)
 
-		err := bb.ReadInBuffer(name, blk.StartIndex, blk.EndIndex-blk.StartIndex, blk.Data)
+		err := bb.ReadInBuffer(name, "", blk.StartIndex, blk.EndIndex-blk.StartIndex, blk.Data)
 		if err != nil {
 			log.Err("BlockBlob::removeBlocks : Failed to remove blocks %s [%s]", name, err.Error())
 		}
@@ -1026,11 +1279,20 @@ func (bb *BlockBlob) TruncateFile(name string, size int64) error {
 			return err
 		}
 	}
-	//TODO: the resize might be very big - need to allocate in chunks
-	if size == 0 || attr.Size == 0 {
-		err := bb.WriteFromBuffer(name, nil, make([]byte, size))
+	if size == 0 || (attr.Size == 0 && size <= bb.getTruncateBlockSize()) {
+		err := bb.WriteFromBuffer(name, removeTimesFromMetadata(attr.Metadata), make([]byte, size))
+		if err != nil {
+			log.Err("BlockBlob::TruncateFile : Failed to set the %s to %d bytes [%s]", name, size, err.Error())
+		}
+		return err
+	}
+	if attr.Size == 0 {
+		// extend an empty file with sparse blocks so the zeroes are not uploaded for every block
+		bol := &common.BlockOffsetList{BlockIdLength: int64(len(common.NewUUID()))}
+		bb.createNewBlocks(bol, 0, size)
+		err = bb.StageAndCommit(name, bol, removeTimesFromMetadata(attr.Metadata))
 		if err != nil {
-			log.Err("BlockBlob::TruncateFile : Failed to set the %s to 0 bytes [%s]", name, err.Error())
+			log.Err("BlockBlob::TruncateFile : Failed to extend file %s to %d bytes [%s]", name, size, err.Error())
 		}
 		return err
 	}
@@ -1046,7 +1308,7 @@ func (bb *BlockBlob) TruncateFile(name string, size int64) error {
 		} else if size < attr.Size {
 			bol = bb.removeBlocks(bol, size, name)
 		}
-		err = bb.StageAndCommit(name, bol)
+		err = bb.StageAndCommit(name, bol, removeTimesFromMetadata(attr.Metadata))
 		if err != nil {
 			log.Err("BlockBlob::TruncateFile : Failed to truncate file %s", name, err.Error())
 			return err
@@ -1075,9 +1337,9 @@ func (bb *BlockBlob) TruncateFile(name string, size int64) error {
 		} else if size < attr.Size {
 			// if shrinking just adjust the size
 			data = data[0:size]
-			return bb.WriteFromBuffer(name, nil, data)
+			return bb.WriteFromBuffer(name, removeTimesFromMetadata(attr.Metadata), data)
 		}
-		err = bb.StageAndCommit(name, bol)
+		err = bb.StageAndCommit(name, bol, removeTimesFromMetadata(attr.Metadata))
 		if err != nil {
 			log.Err("BlockBlob::TruncateFile : Failed to truncate file %s", name, err.Error())
 			return err
@@ -1086,6 +1348,74 @@ func (bb *BlockBlob) TruncateFile(name string, size int64) error {
 	return nil
 }
 
+// FallocateFile : allocate or zero out a range of the blob, zero filled ranges are kept sparse in the block list
+func (bb *BlockBlob) FallocateFile(name string, offset int64, length int64, keepSize bool, punchHole bool) error {
+	log.Trace("BlockBlob::FallocateFile : name %s, offset %d, length %d, keep-size %t, punch-hole %t", name, offset, length, keepSize, punchHole)
+
+	attr, err := bb.GetAttr(name)
+	if err != nil {
+		log.Err("BlockBlob::FallocateFile : Failed to get attributes of file %s [%s]", name, err.Error())
+		return err
+	}
+
+	end := offset + length
+	if punchHole && offset < attr.Size {
+		err = bb.zeroRange(name, attr, offset, int64(math.Min(float64(end), float64(attr.Size))))
+		if err != nil {
+			log.Err("BlockBlob::FallocateFile : Failed to zero range %d-%d of file %s [%s]", offset, end, name, err.Error())
+			return err
+		}
+	}
+
+	// storage has no notion of reserved space, only a change in size needs to be applied
+	if !keepSize && end > attr.Size {
+		return bb.TruncateFile(name, end)
+	}
+	return nil
+}
+
+// zeroRange : zero out given range of the blob, blocks that fall completely in the range are replaced by sparse blocks
+func (bb *BlockBlob) zeroRange(name string, attr *internal.ObjAttr, start int64, end int64) error {
+	bol, err := bb.GetFileBlockOffsets(name)
+	if err != nil {
+		log.Err("BlockBlob::zeroRange : Failed to get block list of file %s [%s]", name, err.Error())
+		return err
+	}
+
+	if bol.SmallFile() {
+		data, err := bb.ReadBuffer(name, 0, 0)
+		if err != nil {
+			log.Err("BlockBlob::zeroRange : Failed to read small file %s [%s]", name, err.Error())
+			return err
+		}
+		copy(data[start:end], make([]byte, end-start))
+		return bb.WriteFromBuffer(name, removeTimesFromMetadata(attr.Metadata), data)
+	}
+
+	for i, blk := range bol.BlockList {
+		if blk.EndIndex <= start || blk.StartIndex >= end {
+			continue
+		}
+		if blk.StartIndex >= start && blk.EndIndex <= end {
+			bol.BlockList[i] = bb.createBlock(bol.BlockIdLength, blk.StartIndex, blk.EndIndex-blk.StartIndex)
+			continue
+		}
+		// range covers only a part of this block so rewrite it with that part zeroed
+		blk.Data = make([]byte, blk.EndIndex-blk.StartIndex)
+		err = bb.ReadInBuffer(name, "", blk.StartIndex, blk.EndIndex-blk.StartIndex, blk.Data)
+		if err != nil {
+			log.Err("BlockBlob::zeroRange : Failed to read block of file %s at %d [%s]", name, blk.StartIndex, err.Error())
+			return err
+		}
+		zeroStart := int64(math.Max(float64(start), float64(blk.StartIndex))) - blk.StartIndex
+		zeroEnd := int64(math.Min(float64(end), float64(blk.EndIndex))) - blk.StartIndex
+		copy(blk.Data[zeroStart:zeroEnd], make([]byte, zeroEnd-zeroStart))
+		blk.Flags.Set(common.DirtyBlock)
+	}
+
+	return bb.StageAndCommit(name, bol, removeTimesFromMetadata(attr.Metadata))
+}
+
 // Write : write data at given offset to a blob
 func (bb *BlockBlob) Write(options internal.WriteFileOptions) error {
 	name := options.Handle.Path
@@ -1149,7 +1479,7 @@ func (bb *BlockBlob) Write(options internal.WriteFileOptions) error {
 		oldDataBuffer := make([]byte, oldDataSize+newBufferSize)
 		if !appendOnly {
 			// fetch the blocks that will be impacted by the new changes so we can overwrite them
-			err = bb.ReadInBuffer(name, fileOffsets.BlockList[index].StartIndex, oldDataSize, oldDataBuffer)
+			err = bb.ReadInBuffer(name, "", fileOffsets.BlockList[index].StartIndex, oldDataSize, oldDataBuffer)
 			if err != nil {
 				log.Err("BlockBlob::Write : Failed to read data in buffer %s [%s]", name, err.Error())
 			}
@@ -1157,24 +1487,27 @@ func (bb *BlockBlob) Write(options internal.WriteFileOptions) error {
 		// this gives us where the offset with respect to the buffer that holds our old data - so we can start writing the new data
 		blockOffset := offset - fileOffsets.BlockList[index].StartIndex
 		copy(oldDataBuffer[blockOffset:], data)
-		err := bb.stageAndCommitModifiedBlocks(name, oldDataBuffer, fileOffsets)
+		err := bb.stageAndCommitModifiedBlocks(name, oldDataBuffer, fileOffsets, options.Metadata)
 		return err
 	}
 	return nil
 }
 
 // TODO: make a similar method facing stream that would enable us to write to cached blocks then stage and commit
-func (bb *BlockBlob) stageAndCommitModifiedBlocks(name string, data []byte, offsetList *common.BlockOffsetList) error {
+func (bb *BlockBlob) stageAndCommitModifiedBlocks(name string, data []byte, offsetList *common.BlockOffsetList, metadata map[string]string) error {
 	blobURL := bb.Container.NewBlockBlobURL(filepath.Join(bb.Config.prefixPath, name))
 	blockOffset := int64(0)
 	var blockIDList []string
 	for _, blk := range offsetList.BlockList {
+		if blk.Dirty() {
+			bb.detachSparseBlock(blk, offsetList.BlockIdLength)
+		}
 		blockIDList = append(blockIDList, blk.Id)
 		if blk.Dirty() {
 			_, err := blobURL.StageBlock(context.Background(),
 				blk.Id,
 				bytes.NewReader(data[blockOffset:(blk.EndIndex-blk.StartIndex)+blockOffset]),
-				bb.blobAccCond.LeaseAccessConditions,
+				bb.accessConditions(name).LeaseAccessConditions,
 				nil,
 				bb.downloadOptions.ClientProvidedKeyOptions)
 			if err != nil {
@@ -1187,9 +1520,9 @@ func (bb *BlockBlob) stageAndCommitModifiedBlocks(name string, data []byte, offs
 	_, err := blobURL.CommitBlockList(context.Background(),
 		blockIDList,
 		azblob.BlobHTTPHeaders{ContentType: getContentType(name)},
-		nil,
-		bb.blobAccCond,
-		bb.Config.defaultTier,
+		metadata,
+		bb.accessConditions(name),
+		bb.tierOf(name),
 		nil, // datalake doesn't support tags here
 		bb.downloadOptions.ClientProvidedKeyOptions)
 	if err != nil {
@@ -1199,7 +1532,8 @@ func (bb *BlockBlob) stageAndCommitModifiedBlocks(name string, data []byte, offs
 	return nil
 }
 
-func (bb *BlockBlob) StageAndCommit(name string, bol *common.BlockOffsetList) error {
+// StageAndCommit : stage the dirty blocks of the list and commit it, metadata is set on the blob as part of the commit
+func (bb *BlockBlob) StageAndCommit(name string, bol *common.BlockOffsetList, metadata map[string]string) error {
 	// lock on the blob name so that no stage and commit race condition occur causing failure
 	blobMtx := bb.blockLocks.GetLock(name)
 	blobMtx.Lock()
@@ -1208,19 +1542,41 @@ func (bb *BlockBlob) StageAndCommit(name string, bol *common.BlockOffsetList) er
 	var blockIDList []string
 	var data []byte
 	staged := false
+	// zero filled blocks present in storage keyed on their size, truncated blocks of the same size refer to these
+	// instead of uploading the same zeroes again
+	zeroBlocks := make(map[int64]string)
+	for _, blk := range bol.BlockList {
+		if blk.Sparse() && !blk.Dirty() {
+			zeroBlocks[blk.EndIndex-blk.StartIndex] = blk.Id
+		}
+	}
 	for _, blk := range bol.BlockList {
-		blockIDList = append(blockIDList, blk.Id)
 		if blk.Truncated() {
-			data = make([]byte, blk.EndIndex-blk.StartIndex)
+			blkSize := blk.EndIndex - blk.StartIndex
 			blk.Flags.Clear(common.TruncatedBlock)
+			blk.Flags.Set(common.SparseBlock)
+			if id, found := zeroBlocks[blkSize]; found {
+				blk.Id = id
+				blk.Flags.Clear(common.DirtyBlock)
+				// block list has changed even though nothing is staged for this block
+				staged = true
+			} else {
+				blk.Id = zeroBlockId(bol.BlockIdLength, blkSize)
+				zeroBlocks[blkSize] = blk.Id
+				data = make([]byte, blkSize)
+			}
 		} else {
+			if blk.Dirty() {
+				bb.detachSparseBlock(blk, bol.BlockIdLength)
+			}
 			data = blk.Data
 		}
+		blockIDList = append(blockIDList, blk.Id)
 		if blk.Dirty() {
 			_, err := blobURL.StageBlock(context.Background(),
 				blk.Id,
 				bytes.NewReader(data),
-				bb.blobAccCond.LeaseAccessConditions,
+				bb.accessConditions(name).LeaseAccessConditions,
 				nil,
 				bb.downloadOptions.ClientProvidedKeyOptions)
 			if err != nil {
@@ -1235,10 +1591,10 @@ func (bb *BlockBlob) StageAndCommit(name string, bol *common.BlockOffsetList) er
 		_, err := blobURL.CommitBlockList(context.Background(),
 			blockIDList,
 			azblob.BlobHTTPHeaders{ContentType: getContentType(name)},
-			nil,
-			bb.blobAccCond,
+			metadata,
+			bb.accessConditions(name),
 			// azblob.BlobAccessConditions{ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: bol.Etag}},
-			bb.Config.defaultTier,
+			bb.tierOf(name),
 			nil, // datalake doesn't support tags here
 			bb.downloadOptions.ClientProvidedKeyOptions)
 		if err != nil {
@@ -1252,9 +1608,13 @@ func (bb *BlockBlob) StageAndCommit(name string, bol *common.BlockOffsetList) er
 }
 
 // ChangeMod : Change mode of a blob
-func (bb *BlockBlob) ChangeMod(name string, _ os.FileMode) error {
+func (bb *BlockBlob) ChangeMod(name string, mode os.FileMode) error {
 	log.Trace("BlockBlob::ChangeMod : name %s", name)
 
+	if bb.Config.preservePosixAttrs {
+		return bb.setPosixMetadata(name, map[string]string{modeKey: formatPosixMode(mode)})
+	}
+
 	if bb.Config.ignoreAccessModifiers {
 		// for operations like git clone where transaction fails if chmod is not successful
 		// return success instead of ENOSYS
@@ -1266,9 +1626,21 @@ func (bb *BlockBlob) ChangeMod(name string, _ os.FileMode) error {
 }
 
 // ChangeOwner : Change owner of a blob
-func (bb *BlockBlob) ChangeOwner(name string, _ int, _ int) error {
+func (bb *BlockBlob) ChangeOwner(name string, user int, group int) error {
 	log.Trace("BlockBlob::ChangeOwner : name %s", name)
 
+	if bb.Config.preservePosixAttrs {
+		// -1 means the user or group shall be left unchanged
+		posix := make(map[string]string)
+		if user >= 0 {
+			posix[uidKey] = strconv.Itoa(user)
+		}
+		if group >= 0 {
+			posix[gidKey] = strconv.Itoa(group)
+		}
+		return bb.setPosixMetadata(name, posix)
+	}
+
 	if bb.Config.ignoreAccessModifiers {
 		// for operations like git clone where transaction fails if chown is not successful
 		// return success instead of ENOSYS
@@ -1278,3 +1650,141 @@ func (bb *BlockBlob) ChangeOwner(name string, _ int, _ int) error {
 	// This is not currently supported for a flat namespace account
 	return syscall.ENOTSUP
 }
+
+// setPosixMetadata : Merge mode/owner keys into the existing metadata of a blob
+func (bb *BlockBlob) setPosixMetadata(name string, posix map[string]string) error {
+	attr, err := bb.GetAttr(name)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(azblob.Metadata)
+	for k, v := range attr.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range posix {
+		metadata[k] = v
+	}
+
+	err = bb.SetMetadata(name, metadata)
+	if err == syscall.ENOENT && attr.IsDir() {
+		// Virtual directories do not have a marker blob to hold the metadata
+		log.Debug("BlockBlob::setPosixMetadata : %s is a virtual directory, ignoring", name)
+		return nil
+	}
+	return err
+}
+
+// accessConditions : Access conditions for a write to the blob, carrying the lease id if this mount holds a lease on it
+func (bb *BlockBlob) accessConditions(name string) azblob.BlobAccessConditions {
+	accCond := bb.blobAccCond
+	if leaseID, found := bb.leaseIDs.Load(name); found {
+		accCond.LeaseAccessConditions = azblob.LeaseAccessConditions{LeaseID: leaseID.(string)}
+	}
+	return accCond
+}
+
+// SetMetadata : Replace the metadata of a blob
+func (bb *BlockBlob) SetMetadata(name string, metadata map[string]string) error {
+	log.Trace("BlockBlob::SetMetadata : name %s", name)
+
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	_, err := blobURL.SetMetadata(context.Background(), metadata, bb.accessConditions(name), bb.blobCPKOpt)
+	if err != nil {
+		serr := storeBlobErrToErr(err)
+		if serr == ErrFileNotFound {
+			log.Err("BlockBlob::SetMetadata : %s does not exist", name)
+			return syscall.ENOENT
+		} else if serr == BlobIsUnderLease {
+			log.Err("BlockBlob::SetMetadata : %s is under lease [%s]", name, err.Error())
+			return syscall.EIO
+		} else {
+			log.Err("BlockBlob::SetMetadata : Failed to set metadata of blob %s [%s]", name, err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetTier : Change the access tier of a blob, moving a blob out of archive starts its rehydration
+func (bb *BlockBlob) SetTier(name string, tier azblob.AccessTierType) error {
+	log.Trace("BlockBlob::SetTier : name %s, tier %s", name, tier)
+
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	_, err := blobURL.SetTier(context.Background(), tier, bb.accessConditions(name).LeaseAccessConditions)
+	if err != nil {
+		serr := storeBlobErrToErr(err)
+		if serr == ErrFileNotFound {
+			log.Err("BlockBlob::SetTier : %s does not exist", name)
+			return syscall.ENOENT
+		} else if serr == BlobIsRehydrating {
+			log.Err("BlockBlob::SetTier : %s is being rehydrated, tier can not be changed till it completes", name)
+			return syscall.EBUSY
+		} else {
+			log.Err("BlockBlob::SetTier : Failed to set tier of blob %s [%s]", name, err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AcquireLease : Take a lease on the blob so that other mounts can not modify it
+func (bb *BlockBlob) AcquireLease(name string, duration int32) (string, error) {
+	log.Trace("BlockBlob::AcquireLease : name %s, duration %d", name, duration)
+
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	resp, err := blobURL.AcquireLease(context.Background(), "", duration, azblob.ModifiedAccessConditions{})
+	if err != nil {
+		serr := storeBlobErrToErr(err)
+		if serr == ErrFileNotFound {
+			log.Err("BlockBlob::AcquireLease : %s does not exist", name)
+			return "", syscall.ENOENT
+		} else if serr == LeaseAlreadyPresent {
+			log.Info("BlockBlob::AcquireLease : %s is leased by another client", name)
+			return "", syscall.EAGAIN
+		} else {
+			log.Err("BlockBlob::AcquireLease : Failed to acquire lease on blob %s [%s]", name, err.Error())
+			return "", err
+		}
+	}
+
+	bb.leaseIDs.Store(name, resp.LeaseID())
+	return resp.LeaseID(), nil
+}
+
+// RenewLease : Extend a lease held on the blob
+func (bb *BlockBlob) RenewLease(name string, leaseID string) error {
+	log.Trace("BlockBlob::RenewLease : name %s", name)
+
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	_, err := blobURL.RenewLease(context.Background(), leaseID, azblob.ModifiedAccessConditions{})
+	if err != nil {
+		log.Err("BlockBlob::RenewLease : Failed to renew lease on blob %s [%s]", name, err.Error())
+		return err
+	}
+	return nil
+}
+
+// ReleaseLease : Give up a lease held on the blob
+func (bb *BlockBlob) ReleaseLease(name string, leaseID string) error {
+	log.Trace("BlockBlob::ReleaseLease : name %s", name)
+
+	// Writes from here on shall not carry the lease id even if the release fails, the lease will expire anyway
+	bb.leaseIDs.Delete(name)
+
+	blobURL := bb.Container.NewBlobURL(filepath.Join(bb.Config.prefixPath, name))
+	_, err := blobURL.ReleaseLease(context.Background(), leaseID, azblob.ModifiedAccessConditions{})
+	if err != nil {
+		serr := storeBlobErrToErr(err)
+		if serr == ErrFileNotFound {
+			// Blob was deleted while it was leased
+			log.Debug("BlockBlob::ReleaseLease : %s does not exist", name)
+			return nil
+		}
+		log.Err("BlockBlob::ReleaseLease : Failed to release lease on blob %s [%s]", name, err.Error())
+		return err
+	}
+	return nil
+}
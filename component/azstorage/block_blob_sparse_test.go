@@ -0,0 +1,494 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeBlob : committed block list of a blob, data of a blob with no blocks is kept in small
+type fakeBlob struct {
+	blocks   []string
+	small    []byte
+	metadata http.Header
+}
+
+// fakeBlockServer : Minimal fake of the block blob REST APIs
+type fakeBlockServer struct {
+	sync.Mutex
+	blobs       map[string]*fakeBlob
+	blockData   map[string][]byte // data of every block staged so far
+	stagedBytes int64
+	copies      int // blobs created through copy blob
+	putFromURL  int // blobs created through put blob from url
+}
+
+func (f *fakeBlockServer) content(blob *fakeBlob) []byte {
+	if len(blob.blocks) == 0 {
+		return blob.small
+	}
+	var data []byte
+	for _, id := range blob.blocks {
+		data = append(data, f.blockData[id]...)
+	}
+	return data
+}
+
+// metadataOf : Metadata headers sent with a request that creates a blob
+func metadataOf(r *http.Request) http.Header {
+	metadata := make(http.Header)
+	for k, v := range r.Header {
+		if strings.HasPrefix(strings.ToLower(k), "x-ms-meta-") {
+			metadata[k] = v
+		}
+	}
+	return metadata
+}
+
+func (f *fakeBlockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	name := fakeBlobName(r)
+	blob, found := f.blobs[name]
+	comp := r.URL.Query().Get("comp")
+
+	if r.Method == http.MethodPut {
+		body, _ := io.ReadAll(r.Body)
+		switch comp {
+		case "block":
+			f.blockData[r.URL.Query().Get("blockid")] = body
+			f.stagedBytes += int64(len(body))
+		case "metadata":
+			if found {
+				blob.metadata = metadataOf(r)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		case "blocklist":
+			list := struct {
+				Latest []string `xml:"Latest"`
+			}{}
+			_ = xml.Unmarshal(body, &list)
+			f.blobs[name] = &fakeBlob{blocks: list.Latest, metadata: metadataOf(r)}
+		default:
+			if source := r.Header.Get("x-ms-copy-source"); source != "" {
+				srcURL, _ := url.Parse(source)
+				srcBlob := *f.blobs[strings.TrimPrefix(srcURL.Path, "/container/")]
+				f.blobs[name] = &srcBlob
+				// Put blob from url carries the blob type and completes synchronously, copy blob is accepted
+				if r.Header.Get("x-ms-blob-type") != "" {
+					f.putFromURL++
+					w.WriteHeader(http.StatusCreated)
+					return
+				}
+				f.copies++
+				w.Header().Set("x-ms-copy-status", string(azblob.CopyStatusSuccess))
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			f.blobs[name] = &fakeBlob{small: body, metadata: metadataOf(r)}
+		}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	if !found {
+		fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+		return
+	}
+
+	data := f.content(blob)
+	switch {
+	case r.Method == http.MethodHead:
+		for k, v := range blob.metadata {
+			w.Header()[k] = v
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+	case comp == "blocklist":
+		var list strings.Builder
+		list.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?><BlockList><CommittedBlocks>")
+		for _, id := range blob.blocks {
+			fmt.Fprintf(&list, "<Block><Name>%s</Name><Size>%d</Size></Block>", id, len(f.blockData[id]))
+		}
+		list.WriteString("</CommittedBlocks><UncommittedBlocks /></BlockList>")
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(list.String()))
+	default:
+		fakeDownload(w, r, data)
+	}
+}
+
+// tagKey : Metadata key the user.tag xattr is stored as
+var tagKey, _ = internal.XattrToMetadataKey("user.tag")
+
+type sparseBlockTestSuite struct {
+	suite.Suite
+	assert    *assert.Assertions
+	container *fakeContainer
+	fake      *fakeBlockServer
+	bb        *BlockBlob
+}
+
+func (s *sparseBlockTestSuite) SetupTest() {
+	s.assert = assert.New(s.T())
+	s.fake = &fakeBlockServer{
+		blobs:     make(map[string]*fakeBlob),
+		blockData: make(map[string][]byte),
+	}
+	s.container = newFakeContainer(s.fake)
+	s.bb = s.container.newFakeBlockBlob()
+	s.bb.Config.blockSize = 1024
+}
+
+func (s *sparseBlockTestSuite) TearDownTest() {
+	s.container.close()
+}
+
+// addBlob : Add a blob made of the given number of 1K blocks filled with non zero data
+func (s *sparseBlockTestSuite) addBlob(name string, blocks int) []byte {
+	blob := &fakeBlob{}
+	var data []byte
+	for i := 0; i < blocks; i++ {
+		id := base64.StdEncoding.EncodeToString(common.NewUUID().Bytes())
+		s.fake.blockData[id] = bytes.Repeat([]byte{byte(i + 1)}, 1024)
+		blob.blocks = append(blob.blocks, id)
+		data = append(data, s.fake.blockData[id]...)
+	}
+	s.fake.blobs[name] = blob
+	return data
+}
+
+func (s *sparseBlockTestSuite) TestTruncateExtendEmptyFile() {
+	s.fake.blobs["file"] = &fakeBlob{}
+
+	err := s.bb.TruncateFile("file", 10*1024+512)
+	s.assert.Nil(err)
+
+	// Only one zero block of each size is uploaded
+	s.assert.EqualValues(1024+512, s.fake.stagedBytes)
+	s.assert.Len(s.fake.blobs["file"].blocks, 11)
+	s.assert.Equal(make([]byte, 10*1024+512), s.fake.content(s.fake.blobs["file"]))
+
+	bol, err := s.bb.GetFileBlockOffsets("file")
+	s.assert.Nil(err)
+	for _, blk := range bol.BlockList {
+		s.assert.True(blk.Sparse())
+	}
+}
+
+func (s *sparseBlockTestSuite) TestTruncateExtendReusesZeroBlock() {
+	s.fake.blobs["file"] = &fakeBlob{}
+	s.assert.Nil(s.bb.TruncateFile("file", 4*1024))
+	s.assert.EqualValues(1024, s.fake.stagedBytes)
+
+	// Zero block already in the blob is referred to again on the next extend
+	s.assert.Nil(s.bb.TruncateFile("file", 8*1024))
+	s.assert.EqualValues(1024, s.fake.stagedBytes)
+	s.assert.Equal(make([]byte, 8*1024), s.fake.content(s.fake.blobs["file"]))
+}
+
+func (s *sparseBlockTestSuite) TestRepeatedDataBlockNotSparse() {
+	s.addBlob("file", 1)
+	// A block committed twice by another writer still holds data
+	blob := s.fake.blobs["file"]
+	blob.blocks = append(blob.blocks, blob.blocks[0])
+	expected := s.fake.content(blob)
+
+	bol, err := s.bb.GetFileBlockOffsets("file")
+	s.assert.Nil(err)
+	for _, blk := range bol.BlockList {
+		s.assert.False(blk.Sparse())
+	}
+
+	// Extending the file stages a zero block of its own instead of reusing the data block
+	s.assert.Nil(s.bb.TruncateFile("file", 3*1024))
+	s.assert.Equal(append(expected, make([]byte, 1024)...), s.fake.content(s.fake.blobs["file"]))
+}
+
+func (s *sparseBlockTestSuite) TestFlushFileKeepsMetadata() {
+	expected := s.addBlob("file", 2)
+	s.fake.blobs["file"].metadata = http.Header{
+		"X-Ms-Meta-Bf_mode":  {"0640"},
+		"X-Ms-Meta-Bf_mtime": {"2006-01-02T15:04:05Z"},
+	}
+	s.fake.blobs["file"].metadata.Set("x-ms-meta-"+tagKey, "value")
+	az := &AzStorage{storage: s.bb}
+
+	h := handlemap.NewHandle("file")
+	handlemap.CreateCacheObject(int64(len(expected)), h)
+	bol, err := s.bb.GetFileBlockOffsets("file")
+	s.assert.Nil(err)
+	h.CacheObj.BlockOffsetList = bol
+	bol.BlockList[1].Data = bytes.Repeat([]byte{'a'}, 1024)
+	bol.BlockList[1].Flags.Set(common.DirtyBlock)
+
+	err = az.FlushFile(internal.FlushFileOptions{Handle: h})
+	s.assert.Nil(err)
+	copy(expected[1024:], bol.BlockList[1].Data)
+	s.assert.Equal(expected, s.fake.content(s.fake.blobs["file"]))
+
+	// Xattrs and posix attributes survive the commit, the old mtime is dropped for the new content
+	attr, err := s.bb.GetAttr("file")
+	s.assert.Nil(err)
+	s.assert.Equal("value", attr.Metadata[tagKey])
+	s.assert.Equal("0640", attr.Metadata[modeKey])
+	s.assert.NotContains(attr.Metadata, mtimeKey)
+}
+
+func (s *sparseBlockTestSuite) TestWriteToSparseBlock() {
+	s.fake.blobs["file"] = &fakeBlob{}
+	s.assert.Nil(s.bb.TruncateFile("file", 4*1024))
+
+	data := bytes.Repeat([]byte{'a'}, 100)
+	err := s.bb.Write(internal.WriteFileOptions{Handle: handlemap.NewHandle("file"), Offset: 1024 + 10, Data: data})
+	s.assert.Nil(err)
+
+	// Data lands only in the written block, other sparse blocks stay zero filled
+	expected := make([]byte, 4*1024)
+	copy(expected[1024+10:], data)
+	s.assert.Equal(expected, s.fake.content(s.fake.blobs["file"]))
+}
+
+func (s *sparseBlockTestSuite) TestFallocatePunchHole() {
+	expected := s.addBlob("file", 4)
+
+	err := s.bb.FallocateFile("file", 512, 2048, true, true)
+	s.assert.Nil(err)
+
+	copy(expected[512:2560], make([]byte, 2048))
+	s.assert.Equal(expected, s.fake.content(s.fake.blobs["file"]))
+	// Two partially zeroed blocks and one zero block are uploaded
+	s.assert.EqualValues(3*1024, s.fake.stagedBytes)
+}
+
+func (s *sparseBlockTestSuite) TestFallocatePunchHoleKeepsMetadata() {
+	s.addBlob("file", 2)
+	s.fake.blobs["file"].metadata = http.Header{}
+	s.fake.blobs["file"].metadata.Set("x-ms-meta-"+tagKey, "value")
+	s.fake.blobs["small"] = &fakeBlob{small: []byte("small file"), metadata: http.Header{"X-Ms-Meta-Bf_mode": {"0600"}}}
+
+	s.assert.Nil(s.bb.FallocateFile("file", 0, 1024, true, true))
+	s.assert.Nil(s.bb.FallocateFile("small", 2, 4, true, true))
+
+	attr, err := s.bb.GetAttr("file")
+	s.assert.Nil(err)
+	s.assert.Equal("value", attr.Metadata[tagKey])
+	attr, err = s.bb.GetAttr("small")
+	s.assert.Nil(err)
+	s.assert.Equal("0600", attr.Metadata[modeKey])
+}
+
+func (s *sparseBlockTestSuite) TestFallocatePunchHoleBeyondSize() {
+	expected := s.addBlob("file", 2)
+
+	err := s.bb.FallocateFile("file", 1024, 4096, true, true)
+	s.assert.Nil(err)
+
+	// Size is kept and the hole is limited to the existing data
+	copy(expected[1024:], make([]byte, 1024))
+	s.assert.Equal(expected, s.fake.content(s.fake.blobs["file"]))
+}
+
+func (s *sparseBlockTestSuite) TestFallocateExtend() {
+	expected := s.addBlob("file", 2)
+
+	// Allocation within the size or with keep size does not touch the blob
+	s.assert.Nil(s.bb.FallocateFile("file", 0, 1024, false, false))
+	s.assert.Nil(s.bb.FallocateFile("file", 0, 8192, true, false))
+	s.assert.EqualValues(0, s.fake.stagedBytes)
+	s.assert.Equal(expected, s.fake.content(s.fake.blobs["file"]))
+
+	s.assert.Nil(s.bb.FallocateFile("file", 1024, 4096, false, false))
+	expected = append(expected, make([]byte, 3*1024)...)
+	s.assert.Equal(expected, s.fake.content(s.fake.blobs["file"]))
+	s.assert.EqualValues(1024, s.fake.stagedBytes)
+}
+
+func (s *sparseBlockTestSuite) TestFallocateFileNotExists() {
+	err := s.bb.FallocateFile("file", 0, 1024, false, false)
+	s.assert.NotNil(err)
+}
+
+func (s *sparseBlockTestSuite) TestCreateFilePosixAttrs() {
+	s.bb.Config.preservePosixAttrs = true
+
+	s.assert.Nil(s.bb.CreateFile("file", 0640, 1000, 1001))
+	attr, err := s.bb.GetAttr("file")
+	s.assert.Nil(err)
+	s.assert.Equal(os.FileMode(0640), attr.Mode)
+	s.assert.EqualValues(1000, attr.Uid)
+	s.assert.EqualValues(1001, attr.Gid)
+
+	// Special bits round trip through chmod
+	s.assert.Nil(s.bb.ChangeMod("file", 0755|os.ModeSetuid))
+	attr, err = s.bb.GetAttr("file")
+	s.assert.Nil(err)
+	s.assert.Equal("4755", attr.Metadata[modeKey])
+	s.assert.Equal(0755|os.ModeSetuid, attr.Mode)
+	s.assert.EqualValues(1000, attr.Uid)
+}
+
+func (s *sparseBlockTestSuite) TestSeekFile() {
+	s.addBlob("file", 2)
+	s.assert.Nil(s.bb.TruncateFile("file", 4*1024))
+	s.assert.Nil(s.bb.Write(internal.WriteFileOptions{Handle: handlemap.NewHandle("file"), Offset: 3 * 1024, Data: []byte("data")}))
+	az := &AzStorage{storage: s.bb}
+
+	// data, data, hole, data
+	offset, err := az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 100, Whence: common.SeekData})
+	s.assert.Nil(err)
+	s.assert.EqualValues(100, offset)
+	offset, err = az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 100, Whence: common.SeekHole})
+	s.assert.Nil(err)
+	s.assert.EqualValues(2*1024, offset)
+	offset, err = az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 2*1024 + 10, Whence: common.SeekData})
+	s.assert.Nil(err)
+	s.assert.EqualValues(3*1024, offset)
+	offset, err = az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 3 * 1024, Whence: common.SeekHole})
+	s.assert.Nil(err)
+	s.assert.EqualValues(4*1024, offset)
+
+	_, err = az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 4 * 1024, Whence: common.SeekData})
+	s.assert.Equal(syscall.ENXIO, err)
+}
+
+func (s *sparseBlockTestSuite) TestSeekFileSmallFile() {
+	s.fake.blobs["file"] = &fakeBlob{small: []byte("small file")}
+	az := &AzStorage{storage: s.bb}
+
+	offset, err := az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 2, Whence: common.SeekData})
+	s.assert.Nil(err)
+	s.assert.EqualValues(2, offset)
+	offset, err = az.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 2, Whence: common.SeekHole})
+	s.assert.Nil(err)
+	s.assert.EqualValues(10, offset)
+}
+
+func TestSparseBlockTestSuite(t *testing.T) {
+	suite.Run(t, new(sparseBlockTestSuite))
+}
+
+func (s *sparseBlockTestSuite) TestCopyObject() {
+	data := s.addBlob("src", 3)
+
+	err := s.bb.CopyObject("src", "dst")
+	s.assert.Nil(err)
+	s.assert.Equal(1, s.fake.copies)
+	s.assert.EqualValues(0, s.fake.stagedBytes)
+	s.assert.Equal(data, s.fake.content(s.fake.blobs["dst"]))
+
+	// Source url carries the sas so the copy is done through put blob from url
+	s.bb.Config.authConfig.AuthMode = EAuthType.SAS()
+	err = s.bb.CopyObject("src", "dst2")
+	s.assert.Nil(err)
+	s.assert.Equal(1, s.fake.copies)
+	s.assert.Equal(1, s.fake.putFromURL)
+	s.assert.Equal(data, s.fake.content(s.fake.blobs["dst2"]))
+}
+
+func (s *sparseBlockTestSuite) TestCopyFromReader() {
+	az := &AzStorage{storage: s.bb}
+	s.bb.Config.blockSize = 1024 * 1024
+	data := bytes.Repeat([]byte("0123456789"), 256*1024)
+
+	err := az.CopyFromFile(internal.CopyFromFileOptions{
+		Name:     "file",
+		Reader:   bytes.NewReader(data),
+		Size:     int64(len(data)),
+		Metadata: map[string]string{tagKey: "value"},
+	})
+	s.assert.Nil(err)
+
+	// Contents are staged block by block
+	s.assert.Len(s.fake.blobs["file"].blocks, 3)
+	s.assert.Equal(data, s.fake.content(s.fake.blobs["file"]))
+	attr, err := s.bb.GetAttr("file")
+	s.assert.Nil(err)
+	s.assert.Equal("value", attr.Metadata[tagKey])
+}
+
+func (s *sparseBlockTestSuite) TestCopyFromFileAfterSetXattr() {
+	s.addBlob("file", 1)
+	az := &AzStorage{storage: s.bb}
+	azStatsCollector = nil
+
+	err := az.SetXattr(internal.SetXattrOptions{Name: "file", Attr: "user.tag", Value: []byte("value")})
+	s.assert.Nil(err)
+
+	// Content written after the xattr was set is uploaded in full
+	f, err := os.CreateTemp("", "sparse-test-*")
+	s.assert.Nil(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.WriteString("new content")
+	s.assert.Nil(err)
+	_, err = f.Seek(0, io.SeekStart)
+	s.assert.Nil(err)
+
+	err = az.CopyFromFile(internal.CopyFromFileOptions{Name: "file", File: f})
+	s.assert.Nil(err)
+	s.assert.Equal([]byte("new content"), s.fake.content(s.fake.blobs["file"]))
+
+	value, err := az.GetXattr(internal.GetXattrOptions{Name: "file", Attr: "user.tag"})
+	s.assert.Nil(err)
+	s.assert.Equal([]byte("value"), value)
+}
+
+func (s *sparseBlockTestSuite) TestCopyObjectSourceMissing() {
+	err := s.bb.CopyObject("src", "dst")
+	s.assert.Equal(syscall.ENOENT, err)
+	s.assert.Equal(0, s.fake.copies)
+}
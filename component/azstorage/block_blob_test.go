@@ -2042,6 +2042,112 @@ func (s *blockBlobTestSuite) TestChown() {
 	s.assert.EqualValues(syscall.ENOTSUP, err)
 }
 
+func (s *blockBlobTestSuite) TestSetTimes() {
+	defer s.cleanupTest()
+	// Setup
+	name := generateFileName()
+	s.az.CreateFile(internal.CreateFileOptions{Name: name})
+	mtime := time.Unix(1600000000, 0)
+
+	err := s.az.SetTimes(internal.SetTimesOptions{Name: name, Mtime: mtime})
+	s.assert.Nil(err)
+
+	props, err := s.az.GetAttr(internal.GetAttrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.True(mtime.Equal(props.Mtime))
+
+	// Uploading new content drops the stored time
+	data := []byte("test data")
+	h, _ := s.az.OpenFile(internal.OpenFileOptions{Name: name})
+	_, err = s.az.WriteFile(internal.WriteFileOptions{Handle: h, Offset: 0, Data: data, Metadata: props.Metadata})
+	s.assert.Nil(err)
+
+	props, err = s.az.GetAttr(internal.GetAttrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.False(mtime.Equal(props.Mtime))
+}
+
+func (s *blockBlobTestSuite) TestPreservePosixAttributes() {
+	defer s.cleanupTest()
+	// Setup
+	s.tearDownTestHelper(false) // Don't delete the generated container.
+	config := fmt.Sprintf("azstorage:\n  account-name: %s\n  endpoint: https://%s.blob.core.windows.net/\n  type: block\n  account-key: %s\n  mode: key\n  container: %s\n  fail-unsupported-op: true\n  preserve-posix-attributes: true",
+		storageTestConfigurationParameters.BlockAccount, storageTestConfigurationParameters.BlockAccount, storageTestConfigurationParameters.BlockKey, s.container)
+	s.setupTestHelper(config, s.container, true)
+
+	name := generateFileName()
+	s.az.CreateFile(internal.CreateFileOptions{Name: name, Mode: 0640})
+
+	props, err := s.az.GetAttr(internal.GetAttrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.False(props.IsModeDefault())
+	s.assert.EqualValues(0640, props.Mode)
+	s.assert.False(props.IsOwnerSet())
+
+	err = s.az.Chmod(internal.ChmodOptions{Name: name, Mode: 0755})
+	s.assert.Nil(err)
+	err = s.az.Chown(internal.ChownOptions{Name: name, Owner: 1000, Group: 1001})
+	s.assert.Nil(err)
+
+	// Uploading new content keeps the mode and owner
+	f, _ := os.CreateTemp("", name+".tmp")
+	defer os.Remove(f.Name())
+	f.WriteString("test data")
+	err = s.az.CopyFromFile(internal.CopyFromFileOptions{Name: name, File: f})
+	s.assert.Nil(err)
+
+	props, err = s.az.GetAttr(internal.GetAttrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.EqualValues(0755, props.Mode)
+	s.assert.True(props.IsOwnerSet())
+	s.assert.EqualValues(1000, props.Uid)
+	s.assert.EqualValues(1001, props.Gid)
+
+	// Listing returns the same attributes
+	entries, _, err := s.az.StreamDir(internal.StreamDirOptions{Name: ""})
+	s.assert.Nil(err)
+	s.assert.EqualValues(1, len(entries))
+	s.assert.EqualValues(0755, entries[0].Mode)
+	s.assert.EqualValues(1000, entries[0].Uid)
+	s.assert.EqualValues(1001, entries[0].Gid)
+}
+
+func (s *blockBlobTestSuite) TestXattr() {
+	defer s.cleanupTest()
+	// Setup
+	name := generateFileName()
+	s.az.CreateFile(internal.CreateFileOptions{Name: name})
+
+	err := s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("pipeline")})
+	s.assert.Nil(err)
+
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("other"), Flags: internal.XattrCreate})
+	s.assert.EqualValues(syscall.EEXIST, err)
+
+	value, err := s.az.GetXattr(internal.GetXattrOptions{Name: name, Attr: "user.origin"})
+	s.assert.Nil(err)
+	s.assert.EqualValues("pipeline", string(value))
+
+	attrs, err := s.az.ListXattr(internal.ListXattrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.EqualValues([]string{"user.origin"}, attrs)
+
+	err = s.az.RemoveXattr(internal.RemoveXattrOptions{Name: name, Attr: "user.origin"})
+	s.assert.Nil(err)
+
+	_, err = s.az.GetXattr(internal.GetXattrOptions{Name: name, Attr: "user.origin"})
+	s.assert.EqualValues(syscall.ENODATA, err)
+}
+
+func (s *blockBlobTestSuite) TestSetXattrNotExists() {
+	defer s.cleanupTest()
+	// Setup
+	name := generateFileName()
+
+	err := s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("pipeline")})
+	s.assert.EqualValues(syscall.ENOENT, err)
+}
+
 func (s *blockBlobTestSuite) TestChownIgnore() {
 	defer s.cleanupTest()
 	// Setup
@@ -2262,7 +2368,7 @@ func (s *blockBlobTestSuite) TestFlushFileUpdateChunkedFile() {
 	updatedBlock := make([]byte, 2*MB)
 	rand.Read(updatedBlock)
 	h.CacheObj.BlockOffsetList.BlockList[1].Data = make([]byte, blockSize)
-	s.az.storage.ReadInBuffer(name, int64(blockSize), int64(blockSize), h.CacheObj.BlockOffsetList.BlockList[1].Data)
+	s.az.storage.ReadInBuffer(name, "", int64(blockSize), int64(blockSize), h.CacheObj.BlockOffsetList.BlockList[1].Data)
 	copy(h.CacheObj.BlockOffsetList.BlockList[1].Data[MB:2*MB+MB], updatedBlock)
 	h.CacheObj.BlockOffsetList.BlockList[1].Flags.Set(common.DirtyBlock)
 
@@ -2299,7 +2405,7 @@ func (s *blockBlobTestSuite) TestFlushFileTruncateUpdateChunkedFile() {
 	// truncate block
 	h.CacheObj.BlockOffsetList.BlockList[1].Data = make([]byte, blockSize/2)
 	h.CacheObj.BlockOffsetList.BlockList[1].EndIndex = int64(blockSize + blockSize/2)
-	s.az.storage.ReadInBuffer(name, int64(blockSize), int64(blockSize)/2, h.CacheObj.BlockOffsetList.BlockList[1].Data)
+	s.az.storage.ReadInBuffer(name, "", int64(blockSize), int64(blockSize)/2, h.CacheObj.BlockOffsetList.BlockList[1].Data)
 	h.CacheObj.BlockOffsetList.BlockList[1].Flags.Set(common.DirtyBlock)
 
 	// remove 2 blocks
@@ -2925,7 +3031,7 @@ func (s *blockBlobTestSuite) TestValidateAutoMD5OnRead() {
 			s.assert.Nil(err)
 			s.assert.NotNil(f)
 
-			err = s.az.storage.ReadToFile(name, 0, 100, f)
+			err = s.az.storage.ReadToFile(name, "", 0, 100, f)
 			s.assert.Nil(err)
 
 			_ = s.az.storage.DeleteFile(name)
@@ -2981,7 +3087,7 @@ func (s *blockBlobTestSuite) TestValidateManualMD5OnRead() {
 			s.assert.Nil(err)
 			s.assert.NotNil(f)
 
-			err = s.az.storage.ReadToFile(name, 0, azblob.BlockBlobMaxUploadBlobBytes+1, f)
+			err = s.az.storage.ReadToFile(name, "", 0, azblob.BlockBlobMaxUploadBlobBytes+1, f)
 			s.assert.Nil(err)
 
 			_ = s.az.storage.DeleteFile(name)
@@ -3040,7 +3146,7 @@ func (s *blockBlobTestSuite) TestInvalidMD5OnRead() {
 			s.assert.Nil(err)
 			s.assert.NotNil(f)
 
-			err = s.az.storage.ReadToFile(name, 0, 100, f)
+			err = s.az.storage.ReadToFile(name, "", 0, 100, f)
 			s.assert.NotNil(err)
 			s.assert.Contains(err.Error(), "md5 sum mismatch on download")
 
@@ -3100,7 +3206,7 @@ func (s *blockBlobTestSuite) TestInvalidMD5OnReadNoVaildate() {
 			s.assert.Nil(err)
 			s.assert.NotNil(f)
 
-			err = s.az.storage.ReadToFile(name, 0, 100, f)
+			err = s.az.storage.ReadToFile(name, "", 0, 100, f)
 			s.assert.Nil(err)
 
 			_ = s.az.storage.DeleteFile(name)
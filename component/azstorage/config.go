@@ -38,6 +38,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-storage-fuse/v2/common/config"
 	"github.com/Azure/azure-storage-fuse/v2/common/log"
@@ -136,39 +137,47 @@ const (
 )
 
 type AzStorageOptions struct {
-	AccountType             string `config:"type" yaml:"type,omitempty"`
-	UseHTTP                 bool   `config:"use-http" yaml:"use-http,omitempty"`
-	AccountName             string `config:"account-name" yaml:"account-name,omitempty"`
-	AccountKey              string `config:"account-key" yaml:"account-key,omitempty"`
-	SaSKey                  string `config:"sas" yaml:"sas,omitempty"`
-	ApplicationID           string `config:"appid" yaml:"appid,omitempty"`
-	ResourceID              string `config:"resid" yaml:"resid,omitempty"`
-	ObjectID                string `config:"objid" yaml:"objid,omitempty"`
-	TenantID                string `config:"tenantid" yaml:"tenantid,omitempty"`
-	ClientID                string `config:"clientid" yaml:"clientid,omitempty"`
-	ClientSecret            string `config:"clientsecret" yaml:"clientsecret,omitempty"`
-	ActiveDirectoryEndpoint string `config:"aadendpoint" yaml:"aadendpoint,omitempty"`
-	Endpoint                string `config:"endpoint" yaml:"endpoint,omitempty"`
-	AuthMode                string `config:"mode" yaml:"mode,omitempty"`
-	Container               string `config:"container" yaml:"container,omitempty"`
-	PrefixPath              string `config:"subdirectory" yaml:"subdirectory,omitempty"`
-	BlockSize               int64  `config:"block-size-mb" yaml:"block-size-mb,omitempty"`
-	MaxConcurrency          uint16 `config:"max-concurrency" yaml:"max-concurrency,omitempty"`
-	DefaultTier             string `config:"tier" yaml:"tier,omitempty"`
-	CancelListForSeconds    uint16 `config:"block-list-on-mount-sec" yaml:"block-list-on-mount-sec,omitempty"`
-	MaxRetries              int32  `config:"max-retries" yaml:"max-retries,omitempty"`
-	MaxTimeout              int32  `config:"max-retry-timeout-sec" yaml:"max-retry-timeout-sec,omitempty"`
-	BackoffTime             int32  `config:"retry-backoff-sec" yaml:"retry-backoff-sec,omitempty"`
-	MaxRetryDelay           int32  `config:"max-retry-delay-sec" yaml:"max-retry-delay-sec,omitempty"`
-	HttpProxyAddress        string `config:"http-proxy" yaml:"http-proxy,omitempty"`
-	HttpsProxyAddress       string `config:"https-proxy" yaml:"https-proxy,omitempty"`
-	SdkTrace                bool   `config:"sdk-trace" yaml:"sdk-trace,omitempty"`
-	FailUnsupportedOp       bool   `config:"fail-unsupported-op" yaml:"fail-unsupported-op,omitempty"`
-	AuthResourceString      string `config:"auth-resource" yaml:"auth-resource,omitempty"`
-	UpdateMD5               bool   `config:"update-md5" yaml:"update-md5"`
-	ValidateMD5             bool   `config:"validate-md5" yaml:"validate-md5"`
-	VirtualDirectory        bool   `config:"virtual-directory" yaml:"virtual-directory"`
-	DisableCompression      bool   `config:"disable-compression" yaml:"disable-compression"`
+	AccountType             string   `config:"type" yaml:"type,omitempty"`
+	UseHTTP                 bool     `config:"use-http" yaml:"use-http,omitempty"`
+	AccountName             string   `config:"account-name" yaml:"account-name,omitempty"`
+	AccountKey              string   `config:"account-key" yaml:"account-key,omitempty"`
+	SaSKey                  string   `config:"sas" yaml:"sas,omitempty"`
+	ApplicationID           string   `config:"appid" yaml:"appid,omitempty"`
+	ResourceID              string   `config:"resid" yaml:"resid,omitempty"`
+	ObjectID                string   `config:"objid" yaml:"objid,omitempty"`
+	TenantID                string   `config:"tenantid" yaml:"tenantid,omitempty"`
+	ClientID                string   `config:"clientid" yaml:"clientid,omitempty"`
+	ClientSecret            string   `config:"clientsecret" yaml:"clientsecret,omitempty"`
+	ActiveDirectoryEndpoint string   `config:"aadendpoint" yaml:"aadendpoint,omitempty"`
+	Endpoint                string   `config:"endpoint" yaml:"endpoint,omitempty"`
+	AuthMode                string   `config:"mode" yaml:"mode,omitempty"`
+	Container               string   `config:"container" yaml:"container,omitempty"`
+	PrefixPath              string   `config:"subdirectory" yaml:"subdirectory,omitempty"`
+	BlockSize               int64    `config:"block-size-mb" yaml:"block-size-mb,omitempty"`
+	MaxConcurrency          uint16   `config:"max-concurrency" yaml:"max-concurrency,omitempty"`
+	DefaultTier             string   `config:"tier" yaml:"tier,omitempty"`
+	CancelListForSeconds    uint16   `config:"block-list-on-mount-sec" yaml:"block-list-on-mount-sec,omitempty"`
+	MaxRetries              int32    `config:"max-retries" yaml:"max-retries,omitempty"`
+	MaxTimeout              int32    `config:"max-retry-timeout-sec" yaml:"max-retry-timeout-sec,omitempty"`
+	BackoffTime             int32    `config:"retry-backoff-sec" yaml:"retry-backoff-sec,omitempty"`
+	MaxRetryDelay           int32    `config:"max-retry-delay-sec" yaml:"max-retry-delay-sec,omitempty"`
+	HttpProxyAddress        string   `config:"http-proxy" yaml:"http-proxy,omitempty"`
+	HttpsProxyAddress       string   `config:"https-proxy" yaml:"https-proxy,omitempty"`
+	SdkTrace                bool     `config:"sdk-trace" yaml:"sdk-trace,omitempty"`
+	FailUnsupportedOp       bool     `config:"fail-unsupported-op" yaml:"fail-unsupported-op,omitempty"`
+	AuthResourceString      string   `config:"auth-resource" yaml:"auth-resource,omitempty"`
+	UpdateMD5               bool     `config:"update-md5" yaml:"update-md5"`
+	ValidateMD5             bool     `config:"validate-md5" yaml:"validate-md5"`
+	VirtualDirectory        bool     `config:"virtual-directory" yaml:"virtual-directory"`
+	DisableCompression      bool     `config:"disable-compression" yaml:"disable-compression"`
+	PreservePosixAttrs      bool     `config:"preserve-posix-attributes" yaml:"preserve-posix-attributes"`
+	LockMode                string   `config:"lock-mode" yaml:"lock-mode,omitempty"`
+	LeaseDuration           int32    `config:"lease-duration-sec" yaml:"lease-duration-sec,omitempty"`
+	ShowVersions            bool     `config:"show-versions" yaml:"show-versions"`
+	PointInTime             string   `config:"point-in-time" yaml:"point-in-time,omitempty"`
+	ShowTrash               bool     `config:"show-trash" yaml:"show-trash"`
+	TierRules               []string `config:"tier-rules" yaml:"tier-rules,omitempty"`
+	AutoRehydrate           bool     `config:"auto-rehydrate" yaml:"auto-rehydrate"`
 
 	// v1 support
 	UseAdls        bool   `config:"use-adls" yaml:"-"`
@@ -455,6 +464,52 @@ func ParseAndValidateConfig(az *AzStorage, opt AzStorageOptions) error {
 		az.stConfig.maxRetryDelay = opt.MaxRetryDelay
 	}
 
+	// Mode and owner are stored in blob metadata only for flat namespace accounts, ADLS supports them natively
+	if opt.PreservePosixAttrs && az.stConfig.authConfig.AccountType == EAccountType.ADLS() {
+		log.Warn("ParseAndValidateConfig : preserve-posix-attributes is ignored for ADLS accounts")
+	} else {
+		az.stConfig.preservePosixAttrs = opt.PreservePosixAttrs
+	}
+
+	// File locks are enforced across mounts through blob leases unless local mode is configured
+	switch strings.ToLower(opt.LockMode) {
+	case "", "lease":
+		az.stConfig.leaseLocks = true
+	case "local":
+		az.stConfig.leaseLocks = false
+	default:
+		log.Err("ParseAndValidateConfig : Invalid lock mode %s", opt.LockMode)
+		return errors.New("invalid lock mode")
+	}
+
+	az.stConfig.leaseDuration = defaultLeaseDuration
+	if opt.LeaseDuration != 0 {
+		if opt.LeaseDuration < minLeaseDuration || opt.LeaseDuration > maxLeaseDuration {
+			log.Err("ParseAndValidateConfig : Lease duration has to be between %d and %d seconds", minLeaseDuration, maxLeaseDuration)
+			return errors.New("invalid lease duration")
+		}
+		az.stConfig.leaseDuration = opt.LeaseDuration
+	}
+
+	az.stConfig.showVersions = opt.ShowVersions
+	az.stConfig.showTrash = opt.ShowTrash
+
+	// Container is mounted read only as it was at the given time
+	az.stConfig.pointInTime = time.Time{}
+	if opt.PointInTime != "" {
+		pointInTime, err := time.Parse(time.RFC3339Nano, opt.PointInTime)
+		if err != nil {
+			log.Err("ParseAndValidateConfig : Invalid point in time %s [%s]", opt.PointInTime, err.Error())
+			return errors.New("invalid point-in-time, expected an RFC3339 time")
+		}
+		if pointInTime.After(time.Now()) {
+			log.Err("ParseAndValidateConfig : Point in time %s is in the future", opt.PointInTime)
+			return errors.New("point-in-time is in the future")
+		}
+		az.stConfig.pointInTime = pointInTime.UTC()
+		log.Warn("ParseAndValidateConfig : Mounting at %s, blobs deleted before then from a container with versioning are still shown, unless soft deleted", az.stConfig.pointInTime.Format(time.RFC3339))
+	}
+
 	if config.IsSet(compName + ".set-content-type") {
 		log.Warn("unsupported v1 CLI parameter: set-content-type is always true in blobfuse2.")
 	}
@@ -465,9 +520,9 @@ func ParseAndValidateConfig(az *AzStorage, opt AzStorageOptions) error {
 		log.Warn("unsupported v1 CLI parameter: debug-libcurl is not applicable in blobfuse2.")
 	}
 
-	log.Info("ParseAndValidateConfig : Account: %s, Container: %s, AccountType: %s, Auth: %s, Prefix: %s, Endpoint: %s, ListBlock: %d, MD5 : %v %v, Virtual Directory: %v, Disable Compression: %v",
+	log.Info("ParseAndValidateConfig : Account: %s, Container: %s, AccountType: %s, Auth: %s, Prefix: %s, Endpoint: %s, ListBlock: %d, MD5 : %v %v, Virtual Directory: %v, Disable Compression: %v, Preserve Posix Attributes: %v, Show Versions: %v, Show Trash: %v, Point In Time: %v, Tier Rules: %d, Auto Rehydrate: %v",
 		az.stConfig.authConfig.AccountName, az.stConfig.container, az.stConfig.authConfig.AccountType, az.stConfig.authConfig.AuthMode,
-		az.stConfig.prefixPath, az.stConfig.authConfig.Endpoint, az.stConfig.cancelListForSeconds, az.stConfig.validateMD5, az.stConfig.updateMD5, az.stConfig.virtualDirectory, az.stConfig.disableCompression)
+		az.stConfig.prefixPath, az.stConfig.authConfig.Endpoint, az.stConfig.cancelListForSeconds, az.stConfig.validateMD5, az.stConfig.updateMD5, az.stConfig.virtualDirectory, az.stConfig.disableCompression, az.stConfig.preservePosixAttrs, az.stConfig.showVersions, az.stConfig.showTrash, az.stConfig.pointInTime, len(az.stConfig.tierRules), az.stConfig.autoRehydrate)
 
 	log.Info("ParseAndValidateConfig : Retry Config: Retry count %d, Max Timeout %d, BackOff Time %d, Max Delay %d",
 		az.stConfig.maxRetries, az.stConfig.maxTimeout, az.stConfig.backoffTime, az.stConfig.maxRetryDelay)
@@ -494,6 +549,14 @@ func ParseAndReadDynamicConfig(az *AzStorage, opt AzStorageOptions, reload bool)
 		az.stConfig.defaultTier = getAccessTierType(opt.DefaultTier)
 	}
 
+	tierRules, err := parseTierRules(opt.TierRules)
+	if err != nil {
+		log.Err("ParseAndReadDynamicConfig : %s", err.Error())
+		return err
+	}
+	az.stConfig.tierRules = tierRules
+	az.stConfig.autoRehydrate = opt.AutoRehydrate
+
 	az.stConfig.ignoreAccessModifiers = !opt.FailUnsupportedOp
 	az.stConfig.validateMD5 = opt.ValidateMD5
 	az.stConfig.updateMD5 = opt.UpdateMD5
@@ -35,6 +35,7 @@ package azstorage
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-fuse/v2/common"
@@ -366,6 +367,97 @@ func (s *configTestSuite) TestCompressionType() {
 
 }
 
+func (s *configTestSuite) TestLockMode() {
+	defer config.ResetConfig()
+	assert := assert.New(s.T())
+	az := &AzStorage{}
+	opt := AzStorageOptions{}
+	opt.AccountName = "abcd"
+	opt.Container = "abcd"
+
+	err := ParseAndValidateConfig(az, opt)
+	assert.Nil(err)
+	assert.True(az.stConfig.leaseLocks)
+	assert.EqualValues(defaultLeaseDuration, az.stConfig.leaseDuration)
+
+	opt.LockMode = "local"
+	opt.LeaseDuration = 30
+	err = ParseAndValidateConfig(az, opt)
+	assert.Nil(err)
+	assert.False(az.stConfig.leaseLocks)
+	assert.EqualValues(30, az.stConfig.leaseDuration)
+
+	opt.LockMode = "abcd"
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid lock mode")
+
+	opt.LockMode = "lease"
+	opt.LeaseDuration = 90
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid lease duration")
+}
+
+func (s *configTestSuite) TestPointInTime() {
+	defer config.ResetConfig()
+	assert := assert.New(s.T())
+	az := &AzStorage{}
+	opt := AzStorageOptions{}
+	opt.AccountName = "abcd"
+	opt.Container = "abcd"
+
+	err := ParseAndValidateConfig(az, opt)
+	assert.Nil(err)
+	assert.True(az.stConfig.pointInTime.IsZero())
+
+	opt.PointInTime = "2022-08-01T12:00:00+02:00"
+	err = ParseAndValidateConfig(az, opt)
+	assert.Nil(err)
+	assert.Equal(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC), az.stConfig.pointInTime)
+
+	opt.PointInTime = "yesterday"
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid point-in-time")
+
+	opt.PointInTime = time.Now().Add(time.Hour).Format(time.RFC3339)
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "in the future")
+}
+
+func (s *configTestSuite) TestTierRules() {
+	defer config.ResetConfig()
+	assert := assert.New(s.T())
+	az := &AzStorage{}
+	opt := AzStorageOptions{}
+	opt.AccountName = "abcd"
+	opt.Container = "abcd"
+
+	opt.TierRules = []string{"*.ckpt=Cool", "logs/**=archive"}
+	opt.AutoRehydrate = true
+	err := ParseAndValidateConfig(az, opt)
+	assert.Nil(err)
+	assert.Equal([]tierRule{{pattern: "*.ckpt", tier: azblob.AccessTierCool}, {pattern: "logs/**", tier: azblob.AccessTierArchive}}, az.stConfig.tierRules)
+	assert.True(az.stConfig.autoRehydrate)
+
+	opt.TierRules = []string{"*.ckpt"}
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "expected <pattern>=<tier>")
+
+	opt.TierRules = []string{"*.ckpt=frozen"}
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid tier")
+
+	opt.TierRules = []string{"[.ckpt=cool"}
+	err = ParseAndValidateConfig(az, opt)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid pattern")
+}
+
 func (s *configTestSuite) TestInvalidSASRefresh() {
 	defer config.ResetConfig()
 	assert := assert.New(s.T())
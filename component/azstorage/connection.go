@@ -34,8 +34,10 @@
 package azstorage
 
 import (
+	"io"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/Azure/azure-storage-fuse/v2/common"
 	"github.com/Azure/azure-storage-fuse/v2/common/log"
@@ -75,6 +77,25 @@ type AzStorageConfig struct {
 	validateMD5        bool
 	virtualDirectory   bool
 	disableCompression bool
+	preservePosixAttrs bool
+
+	// Advisory file lock config
+	leaseLocks    bool
+	leaseDuration int32
+
+	// Expose the versions of the blobs in a read only directory
+	showVersions bool
+
+	// Mount the container read only as it was at this time, zero for the current container
+	pointInTime time.Time
+
+	// Expose the soft deleted blobs in a directory they can be restored from
+	showTrash bool
+
+	// Tiers of the uploads matching the rules, the first matching rule wins over defaultTier
+	tierRules []tierRule
+	// Start the rehydration of archived blobs when they are read
+	autoRehydrate bool
 }
 
 type AzStorageConnection struct {
@@ -97,34 +118,48 @@ type AzConnection interface {
 	// This is just for test, shall not be used otherwise
 	SetPrefixPath(string) error
 
-	CreateFile(name string, mode os.FileMode) error
+	CreateFile(name string, mode os.FileMode, user int, group int) error
 	CreateDirectory(name string) error
 	CreateLink(source string, target string) error
 
 	DeleteFile(name string) error
+	UndeleteFile(name string) error
 	DeleteDirectory(name string) error
 
 	RenameFile(string, string) error
 	RenameDirectory(string, string) error
+	CopyObject(source string, target string) error
+	SetTier(name string, tier azblob.AccessTierType) error
 
 	GetAttr(name string) (attr *internal.ObjAttr, err error)
 
 	// Standard operations to be supported by any account type
 	List(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error)
+	ListVersions(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error)
+	ListDeleted(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error)
 
-	ReadToFile(name string, offset int64, count int64, fi *os.File) error
+	// A non empty versionID reads that version of the blob instead of the current one
+	ReadToFile(name string, versionID string, offset int64, count int64, fi *os.File) error
 	ReadBuffer(name string, offset int64, len int64) ([]byte, error)
-	ReadInBuffer(name string, offset int64, len int64, data []byte) error
+	ReadInBuffer(name string, versionID string, offset int64, len int64, data []byte) error
 
 	WriteFromFile(name string, metadata map[string]string, fi *os.File) error
+	WriteFromReader(name string, metadata map[string]string, r io.ReaderAt, size int64) error
 	WriteFromBuffer(name string, metadata map[string]string, data []byte) error
 	Write(options internal.WriteFileOptions) error
 	GetFileBlockOffsets(name string) (*common.BlockOffsetList, error)
 
 	ChangeMod(string, os.FileMode) error
 	ChangeOwner(string, int, int) error
+	SetMetadata(name string, metadata map[string]string) error
+
+	AcquireLease(name string, duration int32) (string, error)
+	RenewLease(name string, leaseID string) error
+	ReleaseLease(name string, leaseID string) error
+
 	TruncateFile(string, int64) error
-	StageAndCommit(name string, bol *common.BlockOffsetList) error
+	FallocateFile(name string, offset int64, length int64, keepSize bool, punchHole bool) error
+	StageAndCommit(name string, bol *common.BlockOffsetList, metadata map[string]string) error
 
 	NewCredentialKey(_, _ string) error
 }
@@ -36,6 +36,7 @@ package azstorage
 import (
 	"context"
 	"errors"
+	"io"
 	"io/fs"
 	"net/url"
 	"os"
@@ -51,6 +52,7 @@ import (
 
 	"github.com/Azure/azure-storage-azcopy/v10/azbfs"
 	"github.com/Azure/azure-storage-azcopy/v10/ste"
+	"github.com/Azure/azure-storage-blob-go/azblob"
 )
 
 type Datalake struct {
@@ -99,6 +101,8 @@ func (dl *Datalake) UpdateConfig(cfg AzStorageConfig) error {
 	dl.Config.blockSize = cfg.blockSize
 	dl.Config.maxConcurrency = cfg.maxConcurrency
 	dl.Config.defaultTier = cfg.defaultTier
+	dl.Config.tierRules = cfg.tierRules
+	dl.Config.autoRehydrate = cfg.autoRehydrate
 	dl.Config.ignoreAccessModifiers = cfg.ignoreAccessModifiers
 	return dl.BlockBlob.UpdateConfig(cfg)
 }
@@ -242,9 +246,9 @@ func (dl *Datalake) SetPrefixPath(path string) error {
 }
 
 // CreateFile : Create a new file in the filesystem/directory
-func (dl *Datalake) CreateFile(name string, mode os.FileMode) error {
+func (dl *Datalake) CreateFile(name string, mode os.FileMode, user int, group int) error {
 	log.Trace("Datalake::CreateFile : name %s", name)
-	err := dl.BlockBlob.CreateFile(name, mode)
+	err := dl.BlockBlob.CreateFile(name, mode, user, group)
 	if err != nil {
 		log.Err("Datalake::CreateFile : Failed to create file %s [%s]", name, err.Error())
 		return err
@@ -279,6 +283,11 @@ func (dl *Datalake) CreateLink(source string, target string) error {
 	return dl.BlockBlob.CreateLink(source, target)
 }
 
+// UndeleteFile : Restore a soft deleted file
+func (dl *Datalake) UndeleteFile(name string) error {
+	return dl.BlockBlob.UndeleteFile(name)
+}
+
 // DeleteFile : Delete a file in the filesystem/directory
 func (dl *Datalake) DeleteFile(name string) (err error) {
 	log.Trace("Datalake::DeleteFile : name %s", name)
@@ -347,6 +356,17 @@ func (dl *Datalake) RenameFile(source string, target string) error {
 	return nil
 }
 
+// CopyObject : Copy the file to target within the filesystem
+// There is no copy in the datalake api so this goes through the blob endpoint, the copy gets the default ACLs of its new parent
+func (dl *Datalake) CopyObject(source string, target string) error {
+	return dl.BlockBlob.CopyObject(source, target)
+}
+
+// SetTier : Change the access tier of a file
+func (dl *Datalake) SetTier(name string, tier azblob.AccessTierType) error {
+	return dl.BlockBlob.SetTier(name, tier)
+}
+
 // RenameDirectory : Rename the directory
 func (dl *Datalake) RenameDirectory(source string, target string) error {
 	log.Trace("Datalake::RenameDirectory : %s -> %s", source, target)
@@ -411,6 +431,7 @@ func (dl *Datalake) GetAttr(name string) (attr *internal.ObjAttr, err error) {
 		Ctime:  lastModified,
 		Crtime: lastModified,
 		Flags:  internal.NewFileBitMap(),
+		ETag:   sanitizeETag(prop.ETag()),
 	}
 	parseProperties(attr, prop.XMsProperties())
 	if azbfs.PathResourceDirectory == azbfs.PathResourceType(prop.XMsResourceType()) {
@@ -494,6 +515,9 @@ func (dl *Datalake) List(prefix string, marker *string, count int32) ([]*interna
 			Crtime: pathInfo.LastModifiedTime(),
 			Flags:  internal.NewFileBitMap(),
 		}
+		if pathInfo.ETag != nil {
+			attr.ETag = sanitizeETag(*pathInfo.ETag)
+		}
 		if pathInfo.IsDirectory != nil && *pathInfo.IsDirectory {
 			attr.Flags = internal.NewDirBitMap()
 			attr.Mode = attr.Mode | os.ModeDir
@@ -512,9 +536,19 @@ func (dl *Datalake) List(prefix string, marker *string, count int32) ([]*interna
 	return pathList, &m, nil
 }
 
+// ListVersions : Get a list of paths matching the given prefix with an entry for every version of each file
+func (dl *Datalake) ListVersions(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error) {
+	return dl.BlockBlob.ListVersions(prefix, marker, count)
+}
+
+// ListDeleted : Get a list of soft deleted files matching the given prefix, along with the directories under it
+func (dl *Datalake) ListDeleted(prefix string, marker *string, count int32) ([]*internal.ObjAttr, *string, error) {
+	return dl.BlockBlob.ListDeleted(prefix, marker, count)
+}
+
 // ReadToFile : Download a file to a local file
-func (dl *Datalake) ReadToFile(name string, offset int64, count int64, fi *os.File) (err error) {
-	return dl.BlockBlob.ReadToFile(name, offset, count, fi)
+func (dl *Datalake) ReadToFile(name string, versionID string, offset int64, count int64, fi *os.File) (err error) {
+	return dl.BlockBlob.ReadToFile(name, versionID, offset, count, fi)
 }
 
 // ReadBuffer : Download a specific range from a file to a buffer
@@ -523,8 +557,8 @@ func (dl *Datalake) ReadBuffer(name string, offset int64, len int64) ([]byte, er
 }
 
 // ReadInBuffer : Download specific range from a file to a user provided buffer
-func (dl *Datalake) ReadInBuffer(name string, offset int64, len int64, data []byte) error {
-	return dl.BlockBlob.ReadInBuffer(name, offset, len, data)
+func (dl *Datalake) ReadInBuffer(name string, versionID string, offset int64, len int64, data []byte) error {
+	return dl.BlockBlob.ReadInBuffer(name, versionID, offset, len, data)
 }
 
 // WriteFromFile : Upload local file to file
@@ -532,6 +566,11 @@ func (dl *Datalake) WriteFromFile(name string, metadata map[string]string, fi *o
 	return dl.BlockBlob.WriteFromFile(name, metadata, fi)
 }
 
+// WriteFromReader : Upload size bytes read from the reader to a file
+func (dl *Datalake) WriteFromReader(name string, metadata map[string]string, r io.ReaderAt, size int64) error {
+	return dl.BlockBlob.WriteFromReader(name, metadata, r, size)
+}
+
 // WriteFromBuffer : Upload from a buffer to a file
 func (dl *Datalake) WriteFromBuffer(name string, metadata map[string]string, data []byte) error {
 	return dl.BlockBlob.WriteFromBuffer(name, metadata, data)
@@ -542,8 +581,8 @@ func (dl *Datalake) Write(options internal.WriteFileOptions) error {
 	return dl.BlockBlob.Write(options)
 }
 
-func (dl *Datalake) StageAndCommit(name string, bol *common.BlockOffsetList) error {
-	return dl.BlockBlob.StageAndCommit(name, bol)
+func (dl *Datalake) StageAndCommit(name string, bol *common.BlockOffsetList, metadata map[string]string) error {
+	return dl.BlockBlob.StageAndCommit(name, bol, metadata)
 }
 
 func (dl *Datalake) GetFileBlockOffsets(name string) (*common.BlockOffsetList, error) {
@@ -554,6 +593,30 @@ func (dl *Datalake) TruncateFile(name string, size int64) error {
 	return dl.BlockBlob.TruncateFile(name, size)
 }
 
+func (dl *Datalake) FallocateFile(name string, offset int64, length int64, keepSize bool, punchHole bool) error {
+	return dl.BlockBlob.FallocateFile(name, offset, length, keepSize, punchHole)
+}
+
+// SetMetadata : Replace the metadata of a path
+func (dl *Datalake) SetMetadata(name string, metadata map[string]string) error {
+	return dl.BlockBlob.SetMetadata(name, metadata)
+}
+
+// AcquireLease : Take a lease on the path so that other mounts can not modify it
+func (dl *Datalake) AcquireLease(name string, duration int32) (string, error) {
+	return dl.BlockBlob.AcquireLease(name, duration)
+}
+
+// RenewLease : Extend a lease held on the path
+func (dl *Datalake) RenewLease(name string, leaseID string) error {
+	return dl.BlockBlob.RenewLease(name, leaseID)
+}
+
+// ReleaseLease : Give up a lease held on the path
+func (dl *Datalake) ReleaseLease(name string, leaseID string) error {
+	return dl.BlockBlob.ReleaseLease(name, leaseID)
+}
+
 // ChangeMod : Change mode of a path
 func (dl *Datalake) ChangeMod(name string, mode os.FileMode) error {
 	log.Trace("Datalake::ChangeMod : Change mode of file %s to %s", name, mode)
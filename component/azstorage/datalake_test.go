@@ -1729,6 +1729,67 @@ func (s *datalakeTestSuite) TestChown() {
 	s.assert.EqualValues(syscall.ENOTSUP, err)
 }
 
+func (s *datalakeTestSuite) TestSetTimes() {
+	defer s.cleanupTest()
+	// Setup
+	name := generateFileName()
+	s.az.CreateFile(internal.CreateFileOptions{Name: name})
+	mtime := time.Unix(1600000000, 0)
+
+	err := s.az.SetTimes(internal.SetTimesOptions{Name: name, Mtime: mtime})
+	s.assert.Nil(err)
+
+	props, err := s.az.GetAttr(internal.GetAttrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.True(mtime.Equal(props.Mtime))
+
+	// Uploading new content drops the stored time
+	data := []byte("test data")
+	h, _ := s.az.OpenFile(internal.OpenFileOptions{Name: name})
+	_, err = s.az.WriteFile(internal.WriteFileOptions{Handle: h, Offset: 0, Data: data, Metadata: props.Metadata})
+	s.assert.Nil(err)
+
+	props, err = s.az.GetAttr(internal.GetAttrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.False(mtime.Equal(props.Mtime))
+}
+
+func (s *datalakeTestSuite) TestXattr() {
+	defer s.cleanupTest()
+	// Setup
+	name := generateFileName()
+	s.az.CreateFile(internal.CreateFileOptions{Name: name})
+
+	err := s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("pipeline")})
+	s.assert.Nil(err)
+
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("other"), Flags: internal.XattrCreate})
+	s.assert.EqualValues(syscall.EEXIST, err)
+
+	value, err := s.az.GetXattr(internal.GetXattrOptions{Name: name, Attr: "user.origin"})
+	s.assert.Nil(err)
+	s.assert.EqualValues("pipeline", string(value))
+
+	attrs, err := s.az.ListXattr(internal.ListXattrOptions{Name: name})
+	s.assert.Nil(err)
+	s.assert.EqualValues([]string{"user.origin"}, attrs)
+
+	err = s.az.RemoveXattr(internal.RemoveXattrOptions{Name: name, Attr: "user.origin"})
+	s.assert.Nil(err)
+
+	_, err = s.az.GetXattr(internal.GetXattrOptions{Name: name, Attr: "user.origin"})
+	s.assert.EqualValues(syscall.ENODATA, err)
+}
+
+func (s *datalakeTestSuite) TestSetXattrNotExists() {
+	defer s.cleanupTest()
+	// Setup
+	name := generateFileName()
+
+	err := s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("pipeline")})
+	s.assert.EqualValues(syscall.ENOENT, err)
+}
+
 func (s *datalakeTestSuite) TestChownIgnore() {
 	defer s.cleanupTest()
 	// Setup
@@ -1876,7 +1937,7 @@ func (s *datalakeTestSuite) TestFlushFileUpdateChunkedFile() {
 	updatedBlock := make([]byte, 2*MB)
 	rand.Read(updatedBlock)
 	h.CacheObj.BlockOffsetList.BlockList[1].Data = make([]byte, blockSize)
-	s.az.storage.ReadInBuffer(name, int64(blockSize), int64(blockSize), h.CacheObj.BlockOffsetList.BlockList[1].Data)
+	s.az.storage.ReadInBuffer(name, "", int64(blockSize), int64(blockSize), h.CacheObj.BlockOffsetList.BlockList[1].Data)
 	copy(h.CacheObj.BlockOffsetList.BlockList[1].Data[MB:2*MB+MB], updatedBlock)
 	h.CacheObj.BlockOffsetList.BlockList[1].Flags.Set(common.DirtyBlock)
 
@@ -1914,7 +1975,7 @@ func (s *datalakeTestSuite) TestFlushFileTruncateUpdateChunkedFile() {
 	// truncate block
 	h.CacheObj.BlockOffsetList.BlockList[1].Data = make([]byte, blockSize/2)
 	h.CacheObj.BlockOffsetList.BlockList[1].EndIndex = int64(blockSize + blockSize/2)
-	s.az.storage.ReadInBuffer(name, int64(blockSize), int64(blockSize)/2, h.CacheObj.BlockOffsetList.BlockList[1].Data)
+	s.az.storage.ReadInBuffer(name, "", int64(blockSize), int64(blockSize)/2, h.CacheObj.BlockOffsetList.BlockList[1].Data)
 	h.CacheObj.BlockOffsetList.BlockList[1].Flags.Set(common.DirtyBlock)
 
 	// remove 2 blocks
@@ -0,0 +1,99 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+)
+
+// fakeContainer : Blob service served over http by a fake of the REST APIs a test needs, for a container named "container"
+type fakeContainer struct {
+	server *httptest.Server
+	logger string // logger in use before the fake silenced it
+	level  common.LogLevel
+}
+
+// newFakeContainer : Start serving the fake, logs of the code under test are dropped until it is closed
+func newFakeContainer(handler http.Handler) *fakeContainer {
+	c := &fakeContainer{logger: log.GetType(), level: log.GetLogLevel()}
+	_ = log.SetDefaultLogger("silent", common.LogConfig{})
+	c.server = httptest.NewServer(handler)
+	return c
+}
+
+func (c *fakeContainer) close() {
+	c.server.Close()
+	_ = log.SetDefaultLogger(c.logger, common.LogConfig{Level: c.level})
+}
+
+// newFakeBlockBlob : Block blob connection of one mount talking to the fake container
+func (c *fakeContainer) newFakeBlockBlob() *BlockBlob {
+	u, _ := url.Parse(c.server.URL + "/container")
+	bb := &BlockBlob{}
+	bb.Container = azblob.NewContainerURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+	return bb
+}
+
+// fakeBlobName : Name of the blob a request to the fake container is for
+func fakeBlobName(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, "/container/")
+}
+
+// fakeError : Fail the request with the given storage error code
+func fakeError(w http.ResponseWriter, status int, code azblob.ServiceCodeType) {
+	w.Header().Set("x-ms-error-code", string(code))
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"utf-8\"?><Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// fakeDownload : Reply with the range of the blob data asked for, or all of it
+func fakeDownload(w http.ResponseWriter, r *http.Request, data []byte) {
+	var start, end int64
+	end = int64(len(data)) - 1
+	if rng := r.Header.Get("x-ms-range"); rng != "" {
+		fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+	}
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", end-start+1))
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write(data[start : end+1])
+}
@@ -0,0 +1,247 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+const (
+	// Blob leases can be taken for 15 to 60 seconds, they are renewed at half the duration
+	minLeaseDuration     int32 = 15
+	maxLeaseDuration     int32 = 60
+	defaultLeaseDuration int32 = 60
+
+	// Interval at which a blocking lock request retries to take the lock
+	lockRetryInterval = 1 * time.Second
+
+	// Fuse can not interrupt a blocking lock request, so it gives up after this long as if interrupted
+	lockWaitTimeout = 60 * time.Second
+)
+
+// fileLock : Advisory lock held on a path by one or more handles of this mount
+type fileLock struct {
+	exclusive bool
+	acquiring bool // lease backing the lock is being acquired, nobody else can take the lock meanwhile
+	holders   map[handlemap.HandleID]bool
+	leaseID   string
+	stop      chan bool
+}
+
+// lockManager : Tracks flock/fcntl locks of this mount.
+// In lease mode the first lock taken on a path acquires a blob lease so that other mounts can neither lock nor modify it,
+// the lease is renewed in background and released along with the last lock on the path.
+// Shared locks are shared only within this mount, across mounts every lock is exclusive.
+type lockManager struct {
+	sync.Mutex
+	storage       AzConnection
+	useLease      bool
+	leaseDuration int32
+	waitTimeout   time.Duration
+	locks         map[string]*fileLock
+}
+
+func newLockManager(storage AzConnection, useLease bool, leaseDuration int32) *lockManager {
+	return &lockManager{
+		storage:       storage,
+		useLease:      useLease,
+		leaseDuration: leaseDuration,
+		waitTimeout:   lockWaitTimeout,
+		locks:         make(map[string]*fileLock),
+	}
+}
+
+// conflicts : Whether a lock held on the path prevents the handle from taking the requested lock
+func (fl *fileLock) conflicts(id handlemap.HandleID, exclusive bool) bool {
+	if fl.acquiring {
+		return true
+	}
+	if fl.holders[id] && len(fl.holders) == 1 {
+		// Only holder of the lock can always convert it
+		return false
+	}
+	return exclusive || fl.exclusive
+}
+
+// check : Returns syscall.EAGAIN if the lock can not be taken by the handle right now
+func (lm *lockManager) check(name string, id handlemap.HandleID, exclusive bool) error {
+	lm.Lock()
+	defer lm.Unlock()
+
+	if fl, found := lm.locks[name]; found && fl.conflicts(id, exclusive) {
+		return syscall.EAGAIN
+	}
+	return nil
+}
+
+// lock : Take or convert a lock on the path for the handle
+// The lock is reserved before its lease is acquired, so that locks on other paths are not held up by the storage call
+func (lm *lockManager) lock(name string, id handlemap.HandleID, exclusive bool) error {
+	lm.Lock()
+	if fl, found := lm.locks[name]; found {
+		defer lm.Unlock()
+		if fl.conflicts(id, exclusive) {
+			return syscall.EAGAIN
+		}
+		fl.holders[id] = true
+		fl.exclusive = exclusive
+		return nil
+	}
+
+	fl := &fileLock{
+		exclusive: exclusive,
+		acquiring: lm.useLease,
+		holders:   map[handlemap.HandleID]bool{id: true},
+	}
+	lm.locks[name] = fl
+	lm.Unlock()
+
+	if !lm.useLease {
+		return nil
+	}
+
+	leaseID, err := lm.storage.AcquireLease(name, lm.leaseDuration)
+	if err == syscall.ENOENT {
+		// Path is not yet uploaded to the container so there is no blob to lease
+		log.Warn("lockManager::lock : %s does not exist in storage, lock is held locally only", name)
+		err = nil
+	}
+
+	lm.Lock()
+	defer lm.Unlock()
+
+	if lm.locks[name] != fl {
+		// Released meanwhile, on unmount or by the handle itself
+		if leaseID != "" {
+			_ = lm.storage.ReleaseLease(name, leaseID)
+		}
+		if err == nil {
+			err = syscall.EAGAIN
+		}
+		return err
+	}
+
+	if err != nil {
+		delete(lm.locks, name)
+		return err
+	}
+
+	fl.acquiring = false
+	if leaseID != "" {
+		fl.leaseID = leaseID
+		fl.stop = make(chan bool)
+		go lm.renew(name, fl.leaseID, fl.stop)
+	}
+	return nil
+}
+
+// lockWait : Take the lock, waiting for conflicting locks to be released
+// Returns syscall.EINTR if the lock could not be taken within the wait timeout, the caller may try again
+func (lm *lockManager) lockWait(name string, id handlemap.HandleID, exclusive bool) error {
+	deadline := time.Now().Add(lm.waitTimeout)
+	for {
+		err := lm.lock(name, id, exclusive)
+		if err != syscall.EAGAIN {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			log.Warn("lockManager::lockWait : %s still locked after %s, giving up", name, lm.waitTimeout)
+			return syscall.EINTR
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// unlock : Release the lock held on the path by the handle, lease is released along with the last holder
+func (lm *lockManager) unlock(name string, id handlemap.HandleID) error {
+	lm.Lock()
+	defer lm.Unlock()
+
+	fl, found := lm.locks[name]
+	if !found || !fl.holders[id] {
+		return nil
+	}
+
+	delete(fl.holders, id)
+	if len(fl.holders) > 0 {
+		return nil
+	}
+
+	delete(lm.locks, name)
+	return lm.release(name, fl)
+}
+
+// releaseAll : Release every lock held by this mount, used on unmount
+func (lm *lockManager) releaseAll() {
+	lm.Lock()
+	defer lm.Unlock()
+
+	for name, fl := range lm.locks {
+		_ = lm.release(name, fl)
+		delete(lm.locks, name)
+	}
+}
+
+// release : Stop renewing and release the lease backing the lock, if any
+func (lm *lockManager) release(name string, fl *fileLock) error {
+	if fl.leaseID == "" {
+		return nil
+	}
+
+	close(fl.stop)
+	return lm.storage.ReleaseLease(name, fl.leaseID)
+}
+
+// renew : Keep the lease alive till the lock is released
+func (lm *lockManager) renew(name string, leaseID string, stop chan bool) {
+	ticker := time.NewTicker(time.Duration(lm.leaseDuration) * time.Second / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := lm.storage.RenewLease(name, leaseID)
+			if err != nil {
+				log.Err("lockManager::renew : Failed to renew lease on %s, lock may be lost [%s]", name, err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeLeaseServer : Minimal fake of the blob lease and metadata REST APIs
+type fakeLeaseServer struct {
+	sync.Mutex
+	blobs   map[string]bool   // blobs present in the container
+	leases  map[string]string // lease id held on a blob
+	renewed int
+	nextID  int
+
+	held string // blob whose lease requests wait for hold to be closed
+	hold chan bool
+}
+
+func (f *fakeLeaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := fakeBlobName(r)
+	if name == f.held {
+		<-f.hold
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if !f.blobs[name] {
+		fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+		return
+	}
+
+	leaseID, leased := f.leases[name]
+	switch r.URL.Query().Get("comp") {
+	case "lease":
+		switch r.Header.Get("x-ms-lease-action") {
+		case "acquire":
+			if leased {
+				fakeError(w, http.StatusConflict, azblob.ServiceCodeLeaseAlreadyPresent)
+				return
+			}
+			f.nextID++
+			f.leases[name] = fmt.Sprintf("lease-%d", f.nextID)
+			w.Header().Set("x-ms-lease-id", f.leases[name])
+			w.WriteHeader(http.StatusCreated)
+		case "renew", "release":
+			if !leased || r.Header.Get("x-ms-lease-id") != leaseID {
+				fakeError(w, http.StatusConflict, azblob.ServiceCodeLeaseIDMismatchWithLeaseOperation)
+				return
+			}
+			if r.Header.Get("x-ms-lease-action") == "renew" {
+				f.renewed++
+				w.Header().Set("x-ms-lease-id", leaseID)
+			} else {
+				delete(f.leases, name)
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	case "metadata":
+		if leased && r.Header.Get("x-ms-lease-id") != leaseID {
+			fakeError(w, http.StatusPreconditionFailed, azblob.ServiceCodeLeaseIDMissing)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+type fileLockTestSuite struct {
+	suite.Suite
+	assert    *assert.Assertions
+	container *fakeContainer
+	fake      *fakeLeaseServer
+}
+
+func (s *fileLockTestSuite) SetupTest() {
+	s.assert = assert.New(s.T())
+	s.fake = &fakeLeaseServer{
+		blobs:  map[string]bool{"file": true},
+		leases: make(map[string]string),
+	}
+	s.container = newFakeContainer(s.fake)
+}
+
+func (s *fileLockTestSuite) TearDownTest() {
+	s.container.close()
+}
+
+func (s *fileLockTestSuite) TestLocalLocks() {
+	lm := newLockManager(nil, false, defaultLeaseDuration)
+
+	// Shared locks can be held by many handles
+	s.assert.Nil(lm.lock("file", 1, false))
+	s.assert.Nil(lm.lock("file", 2, false))
+	s.assert.Equal(syscall.EAGAIN, lm.lock("file", 3, true))
+	s.assert.Equal(syscall.EAGAIN, lm.check("file", 1, true))
+
+	// Sole holder can upgrade its lock
+	s.assert.Nil(lm.unlock("file", 2))
+	s.assert.Nil(lm.check("file", 1, true))
+	s.assert.Nil(lm.lock("file", 1, true))
+	s.assert.Equal(syscall.EAGAIN, lm.lock("file", 2, false))
+
+	// Unlock by a handle not holding the lock is a no-op
+	s.assert.Nil(lm.unlock("file", 3))
+	s.assert.Nil(lm.unlock("file", 1))
+	s.assert.Empty(lm.locks)
+}
+
+func (s *fileLockTestSuite) TestLockWait() {
+	lm := newLockManager(nil, false, defaultLeaseDuration)
+	s.assert.Nil(lm.lock("file", 1, true))
+
+	// Lock released while waiting is taken
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = lm.unlock("file", 1)
+	}()
+	s.assert.Nil(lm.lockWait("file", 2, true))
+
+	// Waiting is bounded, the caller is told to try again
+	lm.waitTimeout = 10 * time.Millisecond
+	start := time.Now()
+	s.assert.Equal(syscall.EINTR, lm.lockWait("file", 3, false))
+	s.assert.Less(time.Since(start), lockRetryInterval+time.Second)
+	s.assert.Equal(map[handlemap.HandleID]bool{2: true}, lm.locks["file"].holders)
+}
+
+func (s *fileLockTestSuite) TestLeaseLocksAcrossMounts() {
+	bb1, bb2 := s.container.newFakeBlockBlob(), s.container.newFakeBlockBlob()
+	lm1 := newLockManager(bb1, true, defaultLeaseDuration)
+	lm2 := newLockManager(bb2, true, defaultLeaseDuration)
+
+	s.assert.Nil(lm1.lock("file", 1, true))
+	s.assert.NotEmpty(s.fake.leases["file"])
+
+	// Other mount can neither lock nor modify the blob
+	s.assert.Equal(syscall.EAGAIN, lm2.lock("file", 2, false))
+	s.assert.Empty(lm2.locks)
+	s.assert.NotNil(bb2.SetMetadata("file", map[string]string{"key": "value"}))
+
+	// Holder of the lease can still modify the blob
+	s.assert.Nil(bb1.SetMetadata("file", map[string]string{"key": "value"}))
+
+	s.assert.Nil(lm1.unlock("file", 1))
+	s.assert.Empty(s.fake.leases)
+	s.assert.Nil(lm2.lock("file", 2, false))
+	s.assert.Nil(bb2.SetMetadata("file", map[string]string{"key": "value"}))
+	lm2.releaseAll()
+	s.assert.Empty(s.fake.leases)
+	s.assert.Empty(lm2.locks)
+}
+
+func (s *fileLockTestSuite) TestLeaseHeldTillLastHolder() {
+	lm := newLockManager(s.container.newFakeBlockBlob(), true, defaultLeaseDuration)
+
+	s.assert.Nil(lm.lock("file", 1, false))
+	s.assert.Nil(lm.lock("file", 2, false))
+	s.assert.Nil(lm.unlock("file", 1))
+	s.assert.NotEmpty(s.fake.leases["file"])
+	s.assert.Nil(lm.unlock("file", 2))
+	s.assert.Empty(s.fake.leases)
+}
+
+func (s *fileLockTestSuite) TestLeaseLockBlobNotExists() {
+	lm := newLockManager(s.container.newFakeBlockBlob(), true, defaultLeaseDuration)
+
+	// File not yet uploaded is locked locally only
+	s.assert.Nil(lm.lock("newfile", 1, true))
+	s.assert.Equal(syscall.EAGAIN, lm.lock("newfile", 2, true))
+	s.assert.Nil(lm.unlock("newfile", 1))
+	s.assert.Empty(lm.locks)
+}
+
+func (s *fileLockTestSuite) TestLeaseAcquiredOutsideLock() {
+	lm := newLockManager(s.container.newFakeBlockBlob(), true, defaultLeaseDuration)
+	s.fake.blobs["other"] = true
+	s.fake.held, s.fake.hold = "file", make(chan bool)
+
+	locked := make(chan error)
+	go func() {
+		locked <- lm.lock("file", 1, false)
+	}()
+	s.assert.Eventually(func() bool {
+		return lm.check("file", 2, false) == syscall.EAGAIN
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The path is reserved while its lease is acquired, other paths can be locked meanwhile
+	s.assert.Equal(syscall.EAGAIN, lm.lock("file", 2, false))
+	s.assert.Nil(lm.lock("other", 3, true))
+
+	close(s.fake.hold)
+	s.assert.Nil(<-locked)
+	s.assert.Nil(lm.lock("file", 2, false))
+	lm.releaseAll()
+	s.assert.Empty(s.fake.leases)
+}
+
+func (s *fileLockTestSuite) TestLeaseRenewal() {
+	lm := newLockManager(s.container.newFakeBlockBlob(), true, 1)
+
+	s.assert.Nil(lm.lock("file", handlemap.HandleID(1), true))
+	time.Sleep(1200 * time.Millisecond)
+	s.assert.Nil(lm.unlock("file", handlemap.HandleID(1)))
+
+	s.fake.Lock()
+	defer s.fake.Unlock()
+	s.assert.GreaterOrEqual(s.fake.renewed, 1)
+}
+
+func TestFileLockTestSuite(t *testing.T) {
+	suite.Run(t, new(fileLockTestSuite))
+}
@@ -0,0 +1,216 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+// A mount at a point in time shows every blob as it was at that time, from the versions of the blobs.
+// Blobs soft deleted before the point in time are hidden using the time storage recorded for the deletion.
+// Deleting a blob of a container with versioning records no such time, so those blobs can not be told apart
+// from blobs deleted after the point in time and are shown.
+
+// atPointInTime : Check whether the mount shows the container at a point in time
+func (az *AzStorage) atPointInTime() bool {
+	return !az.stConfig.pointInTime.IsZero()
+}
+
+// versionTimeOf : Time the given version of a blob became current
+func versionTimeOf(attr *internal.ObjAttr) time.Time {
+	if attr.VersionID != "" {
+		created, err := time.Parse(time.RFC3339Nano, attr.VersionID)
+		if err == nil {
+			return created
+		}
+	}
+	// Blob written before versioning was enabled, it is current since it was last modified
+	return attr.Mtime
+}
+
+// listDeletionTimes : Times the blobs matching the given prefix were soft deleted at, by path
+func (az *AzStorage) listDeletionTimes(prefix string) (map[string][]time.Time, error) {
+	deletions := make(map[string][]time.Time)
+
+	var marker *string = nil
+	for {
+		newList, newMarker, err := az.storage.ListDeleted(prefix, marker, common.MaxDirListCount)
+		if err != nil {
+			log.Err("AzStorage::listDeletionTimes : Failed to list deleted blobs of %s [%s]", prefix, err.Error())
+			return nil, err
+		}
+
+		for _, attr := range newList {
+			if !attr.IsDir() {
+				deletions[attr.Path] = append(deletions[attr.Path], attr.Mtime)
+			}
+		}
+
+		marker = newMarker
+		if newMarker == nil || *newMarker == "" {
+			break
+		}
+	}
+
+	return deletions, nil
+}
+
+// versionAt : Version of a blob that was current at the point in time, nil if it did not exist yet
+// or was deleted at one of the given times between its last version and the point in time
+func (az *AzStorage) versionAt(history []*internal.ObjAttr, deletions []time.Time) *internal.ObjAttr {
+	var current *internal.ObjAttr
+	var currentTime time.Time
+
+	for _, attr := range history {
+		created := versionTimeOf(attr)
+		if created.After(az.stConfig.pointInTime) {
+			continue
+		}
+		if current == nil || created.After(currentTime) {
+			current, currentTime = attr, created
+		}
+	}
+
+	for _, deleted := range deletions {
+		if current != nil && deleted.After(currentTime) && !deleted.After(az.stConfig.pointInTime) {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// dirAttrAt : Attributes of a directory of a mount at a point in time
+func (az *AzStorage) dirAttrAt(path string) *internal.ObjAttr {
+	attr := &internal.ObjAttr{
+		Path:   path,
+		Name:   filepath.Base(path),
+		Size:   4096,
+		Mode:   os.ModeDir,
+		Mtime:  az.stConfig.pointInTime,
+		Atime:  az.stConfig.pointInTime,
+		Ctime:  az.stConfig.pointInTime,
+		Crtime: az.stConfig.pointInTime,
+		Flags:  internal.NewDirBitMap(),
+	}
+	attr.Flags.Set(internal.PropFlagMetadataRetrieved)
+	attr.Flags.Set(internal.PropFlagModeDefault)
+	return attr
+}
+
+// readDirAt : List a directory as it was at the point in time
+// Directories are listed as long as they exist now, as the time they were created at is not known
+func (az *AzStorage) readDirAt(dir string) ([]*internal.ObjAttr, error) {
+	list, err := az.listAllVersions(formatListDirName(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	deletions, err := az.listDeletionTimes(formatListDirName(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]string, 0)
+	histories := make(map[string][]*internal.ObjAttr)
+	for _, attr := range list {
+		if _, found := histories[attr.Path]; !found {
+			order = append(order, attr.Path)
+		}
+		histories[attr.Path] = append(histories[attr.Path], attr)
+	}
+
+	entries := make([]*internal.ObjAttr, 0)
+	for _, path := range order {
+		history := histories[path]
+		if history[0].IsDir() {
+			entries = append(entries, history[0])
+		} else if version := az.versionAt(history, deletions[path]); version != nil {
+			entries = append(entries, version)
+		}
+	}
+
+	return entries, nil
+}
+
+// getAttrAt : Attributes of a blob or directory as it was at the point in time
+func (az *AzStorage) getAttrAt(name string) (*internal.ObjAttr, error) {
+	if name == "" || name == "/" {
+		return az.dirAttrAt(""), nil
+	}
+
+	history, err := az.blobHistory(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range history {
+		if attr.IsDir() {
+			return attr, nil
+		}
+	}
+	deletions, err := az.listDeletionTimes(name)
+	if err != nil {
+		return nil, err
+	}
+	if version := az.versionAt(history, deletions[name]); version != nil {
+		return version, nil
+	}
+
+	// Virtual directory with a blob under it at the point in time
+	entries, err := az.readDirAt(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return az.dirAttrAt(name), nil
+	}
+
+	return nil, syscall.ENOENT
+}
+
+// openAt : Open a blob as it was at the point in time for reading
+func (az *AzStorage) openAt(options internal.OpenFileOptions) (*internal.ObjAttr, error) {
+	if options.Flags&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		return nil, syscall.EROFS
+	}
+	return az.getAttrAt(options.Name)
+}
@@ -0,0 +1,134 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+)
+
+// tierRule : Access tier given to the uploads whose path matches the glob pattern
+// A pattern without a "/" is matched against the name of the file in any directory, "**" matches any number of directories
+type tierRule struct {
+	pattern string
+	tier    azblob.AccessTierType
+}
+
+// parseTierRules : Parse rules given as "<pattern>=<tier>", e.g. "*.ckpt=cool"
+func parseTierRules(rules []string) ([]tierRule, error) {
+	parsed := make([]tierRule, 0, len(rules))
+	for _, rule := range rules {
+		i := strings.LastIndex(rule, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid tier rule %s, expected <pattern>=<tier>", rule)
+		}
+
+		pattern := strings.Trim(strings.TrimSpace(rule[:i]), "/")
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("invalid pattern in tier rule %s", rule)
+		}
+
+		tier, found := AccessTiers[strings.ToLower(strings.TrimSpace(rule[i+1:]))]
+		if !found {
+			return nil, fmt.Errorf("invalid tier in tier rule %s", rule)
+		}
+
+		parsed = append(parsed, tierRule{pattern: pattern, tier: tier})
+	}
+	return parsed, nil
+}
+
+// matches : Check whether the rule applies to the path
+func (rule tierRule) matches(name string) bool {
+	if !strings.Contains(rule.pattern, "/") && rule.pattern != "**" {
+		matched, _ := path.Match(rule.pattern, filepath.Base(name))
+		return matched
+	}
+	return common.MatchGlob(rule.pattern, name)
+}
+
+// tierOf : Access tier to upload the blob with, from the first rule matching its path or the default tier
+func (bb *BlockBlob) tierOf(name string) azblob.AccessTierType {
+	for _, rule := range bb.Config.tierRules {
+		if rule.matches(name) {
+			return rule.tier
+		}
+	}
+	return bb.Config.defaultTier
+}
+
+// archived : Explain why an archived blob can not be read, and start its rehydration if configured to
+func (bb *BlockBlob) archived(name string) error {
+	if !bb.Config.autoRehydrate {
+		log.Err("BlockBlob::archived : %s is in the archive tier and can not be read, rehydrate it with \"blobfuse2 tier set hot <path>\" or mount with auto-rehydrate", name)
+		return syscall.ENODATA
+	}
+
+	err := bb.SetTier(name, azblob.AccessTierHot)
+	if err == syscall.EBUSY {
+		log.Err("BlockBlob::archived : %s is in the archive tier and its rehydration is in progress, retry once it completes", name)
+	} else if err != nil {
+		log.Err("BlockBlob::archived : %s is in the archive tier and its rehydration could not be started [%s]", name, err.Error())
+	} else {
+		log.Err("BlockBlob::archived : %s is in the archive tier, started its rehydration to the hot tier which can take hours, retry once it completes", name)
+	}
+	return syscall.ENODATA
+}
+
+// setTier : Change the access tier of a file to the tier named by the value of its extended attribute
+func (az *AzStorage) setTier(name string, value []byte) error {
+	tier, found := AccessTiers[strings.ToLower(strings.Trim(string(value), " \n\x00"))]
+	if !found || tier == azblob.AccessTierNone {
+		log.Err("AzStorage::setTier : Invalid tier %s for %s", string(value), name)
+		return syscall.EINVAL
+	}
+
+	attr, err := az.storage.GetAttr(name)
+	if err != nil {
+		return err
+	}
+	if attr.IsDir() {
+		// Directories have no tier, the files under them are changed one by one
+		return syscall.EISDIR
+	}
+
+	return az.storage.SetTier(name, tier)
+}
@@ -0,0 +1,228 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type fakeTierBlob struct {
+	data        []byte
+	folder      bool
+	tier        string
+	rehydrating bool
+}
+
+// fakeTierServer : Minimal fake of the blob REST APIs of an account with access tiers
+type fakeTierServer struct {
+	sync.Mutex
+	blobs map[string]*fakeTierBlob
+}
+
+func (f *fakeTierServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	name := fakeBlobName(r)
+	blob, found := f.blobs[name]
+	if r.Method == http.MethodPut && r.URL.Query().Get("comp") == "" {
+		body, _ := io.ReadAll(r.Body)
+		f.blobs[name] = &fakeTierBlob{data: body, tier: r.Header.Get("x-ms-access-tier")}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	if !found {
+		fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+		return
+	}
+
+	switch {
+	case r.URL.Query().Get("comp") == "tier":
+		if blob.rehydrating {
+			fakeError(w, http.StatusConflict, azblob.ServiceCodeBlobBeingRehydrated)
+			return
+		}
+		if blob.tier == string(azblob.AccessTierArchive) {
+			// Moving out of archive takes hours, the blob stays archived meanwhile
+			blob.rehydrating = true
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		blob.tier = r.Header.Get("x-ms-access-tier")
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodHead:
+		if blob.folder {
+			w.Header().Set("x-ms-meta-hdi_isfolder", "true")
+		}
+		w.Header().Set("x-ms-access-tier", blob.tier)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blob.data)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+
+	case blob.tier == string(azblob.AccessTierArchive):
+		fakeError(w, http.StatusConflict, azblob.ServiceCodeBlobArchived)
+
+	default:
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blob.data)))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(blob.data)-1, len(blob.data)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(blob.data)
+	}
+}
+
+type tierTestSuite struct {
+	suite.Suite
+	assert    *assert.Assertions
+	container *fakeContainer
+	fake      *fakeTierServer
+	bb        *BlockBlob
+	az        *AzStorage
+}
+
+func (s *tierTestSuite) SetupTest() {
+	s.assert = assert.New(s.T())
+	s.fake = &fakeTierServer{
+		blobs: map[string]*fakeTierBlob{
+			"file":     {data: []byte("data"), tier: "Hot"},
+			"archived": {data: []byte("cold data"), tier: "Archive"},
+			"dir":      {folder: true, tier: "Hot"},
+		},
+	}
+	s.container = newFakeContainer(s.fake)
+	s.bb = s.container.newFakeBlockBlob()
+	s.bb.Config.blockSize = 1024
+	s.az = &AzStorage{storage: s.bb}
+	azStatsCollector = nil
+}
+
+func (s *tierTestSuite) TearDownTest() {
+	s.container.close()
+}
+
+func (s *tierTestSuite) TestTierOf() {
+	s.bb.Config.defaultTier = azblob.AccessTierHot
+	s.bb.Config.tierRules, _ = parseTierRules([]string{"*.ckpt=cool", "logs/**=archive", "**=none"})
+
+	s.assert.Equal(azblob.AccessTierCool, s.bb.tierOf("model.ckpt"))
+	s.assert.Equal(azblob.AccessTierCool, s.bb.tierOf("runs/1/model.ckpt"))
+	s.assert.Equal(azblob.AccessTierArchive, s.bb.tierOf("logs/2022/08/run.log"))
+	s.assert.Equal(azblob.AccessTierNone, s.bb.tierOf("data/file"))
+
+	s.bb.Config.tierRules = s.bb.Config.tierRules[:2]
+	s.assert.Equal(azblob.AccessTierHot, s.bb.tierOf("data/file"))
+}
+
+func (s *tierTestSuite) TestUploadTier() {
+	s.bb.Config.tierRules, _ = parseTierRules([]string{"*.ckpt=cool"})
+
+	err := s.bb.WriteFromBuffer("runs/model.ckpt", nil, []byte("weights"))
+	s.assert.Nil(err)
+	s.assert.Equal("Cool", s.fake.blobs["runs/model.ckpt"].tier)
+
+	err = s.bb.WriteFromBuffer("runs/notes", nil, []byte("notes"))
+	s.assert.Nil(err)
+	s.assert.Empty(s.fake.blobs["runs/notes"].tier)
+}
+
+func (s *tierTestSuite) TestGetTier() {
+	value, err := s.az.GetXattr(internal.GetXattrOptions{Name: "file", Attr: internal.XattrAzureTier})
+	s.assert.Nil(err)
+	s.assert.EqualValues("Hot", string(value))
+
+	attrs, err := s.az.ListXattr(internal.ListXattrOptions{Name: "archived"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{internal.XattrAzureTier}, attrs)
+}
+
+func (s *tierTestSuite) TestSetTier() {
+	err := s.az.SetXattr(internal.SetXattrOptions{Name: "file", Attr: internal.XattrAzureTier, Value: []byte("cool")})
+	s.assert.Nil(err)
+	s.assert.Equal("Cool", s.fake.blobs["file"].tier)
+
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: "file", Attr: internal.XattrAzureTier, Value: []byte("frozen")})
+	s.assert.Equal(syscall.EINVAL, err)
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: "dir", Attr: internal.XattrAzureTier, Value: []byte("cool")})
+	s.assert.Equal(syscall.EISDIR, err)
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: "missing", Attr: internal.XattrAzureTier, Value: []byte("cool")})
+	s.assert.Equal(syscall.ENOENT, err)
+	err = s.az.RemoveXattr(internal.RemoveXattrOptions{Name: "file", Attr: internal.XattrAzureTier})
+	s.assert.Equal(syscall.EPERM, err)
+
+	// Rehydration of an archived blob is started once
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: "archived", Attr: internal.XattrAzureTier, Value: []byte("Hot")})
+	s.assert.Nil(err)
+	s.assert.True(s.fake.blobs["archived"].rehydrating)
+	err = s.az.SetXattr(internal.SetXattrOptions{Name: "archived", Attr: internal.XattrAzureTier, Value: []byte("Hot")})
+	s.assert.Equal(syscall.EBUSY, err)
+}
+
+func (s *tierTestSuite) TestReadArchived() {
+	data := make([]byte, 9)
+	err := s.bb.ReadInBuffer("archived", "", 0, 9, data)
+	s.assert.Equal(syscall.ENODATA, err)
+	s.assert.False(s.fake.blobs["archived"].rehydrating)
+
+	err = s.bb.ReadInBuffer("file", "", 0, 4, data[:4])
+	s.assert.Nil(err)
+	s.assert.EqualValues("data", string(data[:4]))
+}
+
+func (s *tierTestSuite) TestReadArchivedRehydrate() {
+	s.bb.Config.autoRehydrate = true
+
+	data := make([]byte, 9)
+	err := s.bb.ReadInBuffer("archived", "", 0, 9, data)
+	s.assert.Equal(syscall.ENODATA, err)
+	s.assert.True(s.fake.blobs["archived"].rehydrating)
+
+	// Reads keep failing till the rehydration completes
+	err = s.bb.ReadInBuffer("archived", "", 0, 9, data)
+	s.assert.Equal(syscall.ENODATA, err)
+}
+
+func TestTierTestSuite(t *testing.T) {
+	suite.Run(t, new(tierTestSuite))
+}
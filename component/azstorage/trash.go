@@ -0,0 +1,213 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+// Directory at the root of the mount listing the soft deleted blobs in the tree of the container.
+// Entries can not be read, moving an entry out of it restores the blob, or every blob under a directory.
+const trashDirName = ".trash"
+
+// trashPath : Path of the blob or directory mirrored by a path under the trash directory
+func (az *AzStorage) trashPath(path string) (string, bool) {
+	if !az.stConfig.showTrash {
+		return "", false
+	}
+	return virtualDirPath(path, trashDirName)
+}
+
+// inTrash : Check whether the path is in the trash directory
+func (az *AzStorage) inTrash(paths ...string) bool {
+	for _, path := range paths {
+		if _, found := az.trashPath(path); found {
+			return true
+		}
+	}
+	return false
+}
+
+// trashAttr : Attributes of a deleted blob or of a directory in the trash tree
+func trashAttr(attr *internal.ObjAttr) *internal.ObjAttr {
+	trashed := *attr
+	trashed.Path = filepath.Join(trashDirName, attr.Path)
+	trashed.Name = filepath.Base(trashed.Path)
+	trashed.Flags = internal.NewFileBitMap()
+	if attr.IsDir() {
+		trashed.Flags = internal.NewDirBitMap()
+		trashed.Mode = 0755
+	} else {
+		// Deleted blobs can not be read till they are restored
+		trashed.Mode = 0
+	}
+	trashed.Flags.Set(internal.PropFlagMetadataRetrieved)
+	return &trashed
+}
+
+// listAllDeleted : List the soft deleted blobs and the directories under the given prefix
+func (az *AzStorage) listAllDeleted(prefix string) ([]*internal.ObjAttr, error) {
+	list := make([]*internal.ObjAttr, 0)
+	index := make(map[string]*internal.ObjAttr)
+
+	var marker *string = nil
+	for {
+		newList, newMarker, err := az.storage.ListDeleted(prefix, marker, common.MaxDirListCount)
+		if err != nil {
+			log.Err("AzStorage::listAllDeleted : Failed to list deleted blobs of %s [%s]", prefix, err.Error())
+			return nil, err
+		}
+
+		for _, attr := range newList {
+			// A directory shows up once for its marker and once for the blobs under it
+			if _, found := index[attr.Path]; found {
+				continue
+			}
+			index[attr.Path] = attr
+			list = append(list, attr)
+		}
+
+		marker = newMarker
+		if newMarker == nil || *newMarker == "" {
+			break
+		}
+	}
+
+	return list, nil
+}
+
+// getTrashAttr : Attributes of a path under the trash directory
+func (az *AzStorage) getTrashAttr(mirrored string) (*internal.ObjAttr, error) {
+	if mirrored == "" {
+		attr := trashAttr(&internal.ObjAttr{Flags: internal.NewDirBitMap(), Mtime: az.startTime})
+		attr.Atime, attr.Ctime, attr.Crtime = attr.Mtime, attr.Mtime, attr.Mtime
+		return attr, nil
+	}
+
+	list, err := az.listAllDeleted(mirrored)
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range list {
+		if attr.Path == mirrored {
+			return trashAttr(attr), nil
+		}
+	}
+
+	return nil, syscall.ENOENT
+}
+
+// readTrashDir : List a directory under the trash directory
+func (az *AzStorage) readTrashDir(mirrored string) ([]*internal.ObjAttr, error) {
+	list, err := az.listAllDeleted(formatListDirName(mirrored))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*internal.ObjAttr, 0, len(list))
+	for _, attr := range list {
+		entries = append(entries, trashAttr(attr))
+	}
+	return entries, nil
+}
+
+// restoreFile : Undelete a blob moved out of the trash and move it to where it was moved to
+func (az *AzStorage) restoreFile(name string, dst string) error {
+	log.Trace("AzStorage::restoreFile : Restore %s to %s", name, dst)
+
+	// Undelete leaves a live blob of the same name as it is, the deleted one can not be restored over it
+	if _, err := az.storage.GetAttr(name); err == nil {
+		log.Err("AzStorage::restoreFile : %s exists, deleted blob can not be restored", name)
+		return syscall.EEXIST
+	}
+
+	err := az.storage.UndeleteFile(name)
+	if err != nil {
+		log.Err("AzStorage::restoreFile : Failed to undelete %s [%s]", name, err.Error())
+		return err
+	}
+
+	if dst != name {
+		return az.storage.RenameFile(name, dst)
+	}
+	return nil
+}
+
+// restoreDir : Undelete every deleted blob under a directory moved out of the trash, keeping their paths under where it was moved to
+// Live blobs under the directory are left where they are
+func (az *AzStorage) restoreDir(dir string, dst string) error {
+	log.Trace("AzStorage::restoreDir : Restore %s to %s", dir, dst)
+
+	if dst == dir {
+		// Marker of the directory, if it had one
+		err := az.storage.UndeleteFile(dir)
+		if err != nil && err != syscall.ENOENT {
+			return err
+		}
+	} else if _, err := az.storage.GetAttr(dst); err == syscall.ENOENT {
+		err = az.storage.CreateDirectory(dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	list, err := az.listAllDeleted(formatListDirName(dir))
+	if err != nil {
+		return err
+	}
+
+	for _, attr := range list {
+		target := dst + strings.TrimPrefix(attr.Path, dir)
+		if attr.IsDir() {
+			err = az.restoreDir(attr.Path, target)
+		} else {
+			err = az.restoreFile(attr.Path, target)
+			if err == syscall.EEXIST {
+				log.Warn("AzStorage::restoreDir : Skipping %s, a live blob has its name", attr.Path)
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,335 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type fakeTrashBlob struct {
+	data    []byte
+	folder  bool
+	deleted time.Time
+}
+
+// fakeTrashServer : Minimal fake of the blob REST APIs of a container with soft delete enabled
+type fakeTrashServer struct {
+	sync.Mutex
+	live    map[string]*fakeTrashBlob
+	deleted map[string]*fakeTrashBlob
+}
+
+func (f *fakeTrashServer) item(list *strings.Builder, name string, blob *fakeTrashBlob, deleted bool) {
+	fmt.Fprintf(list, "<Blob><Name>%s</Name><Deleted>%t</Deleted><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType>",
+		name, deleted, time.Now().UTC().Format(time.RFC1123), len(blob.data))
+	if deleted {
+		fmt.Fprintf(list, "<DeletedTime>%s</DeletedTime>", blob.deleted.Format(time.RFC1123))
+	}
+	list.WriteString("</Properties>")
+	if blob.folder {
+		list.WriteString("<Metadata><hdi_isfolder>true</hdi_isfolder></Metadata>")
+	}
+	list.WriteString("</Blob>")
+}
+
+func (f *fakeTrashServer) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	deleted := strings.Contains(query.Get("include"), "deleted")
+
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, blobs := range []map[string]*fakeTrashBlob{f.live, f.deleted} {
+		for name := range blobs {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var items, prefixes strings.Builder
+	dirs := make(map[string]bool)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if i := strings.Index(name[len(prefix):], "/"); i >= 0 {
+			dir := name[:len(prefix)+i+1]
+			if _, live := f.live[name]; (live || deleted) && !dirs[dir] {
+				dirs[dir] = true
+				fmt.Fprintf(&prefixes, "<BlobPrefix><Name>%s</Name></BlobPrefix>", dir)
+			}
+			continue
+		}
+		if blob, found := f.live[name]; found {
+			f.item(&items, name, blob, false)
+		}
+		if blob, found := f.deleted[name]; found && deleted {
+			f.item(&items, name, blob, true)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("<?xml version=\"1.0\" encoding=\"utf-8\"?><EnumerationResults><Blobs>" +
+		items.String() + prefixes.String() + "</Blobs><NextMarker /></EnumerationResults>"))
+}
+
+func (f *fakeTrashServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	comp := r.URL.Query().Get("comp")
+	if comp == "list" {
+		f.list(w, r)
+		return
+	}
+
+	name := fakeBlobName(r)
+	switch r.Method {
+	case http.MethodPut:
+		if comp == "undelete" {
+			blob, found := f.deleted[name]
+			if !found {
+				fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+				return
+			}
+			delete(f.deleted, name)
+			f.live[name] = blob
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if source := r.Header.Get("x-ms-copy-source"); source != "" {
+			srcURL, _ := url.Parse(source)
+			blob := *f.live[strings.TrimPrefix(srcURL.Path, "/container/")]
+			f.live[name] = &blob
+			w.Header().Set("x-ms-copy-status", string(azblob.CopyStatusSuccess))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.live[name] = &fakeTrashBlob{data: body, folder: r.Header.Get("x-ms-meta-hdi_isfolder") == "true"}
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodDelete:
+		blob, found := f.live[name]
+		if !found {
+			fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+			return
+		}
+		delete(f.live, name)
+		blob.deleted = time.Now().UTC()
+		f.deleted[name] = blob
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		blob, found := f.live[name]
+		if !found {
+			fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+			return
+		}
+		if blob.folder {
+			w.Header().Set("x-ms-meta-hdi_isfolder", "true")
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(blob.data)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+var (
+	goneTime = time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	oldTime  = time.Date(2022, 8, 2, 10, 0, 0, 0, time.UTC)
+)
+
+type trashTestSuite struct {
+	suite.Suite
+	assert    *assert.Assertions
+	container *fakeContainer
+	fake      *fakeTrashServer
+	az        *AzStorage
+}
+
+func (s *trashTestSuite) SetupTest() {
+	s.assert = assert.New(s.T())
+	s.fake = &fakeTrashServer{
+		live: map[string]*fakeTrashBlob{
+			"file":     {data: []byte("live")},
+			"dir/kept": {data: []byte("kept")},
+		},
+		deleted: map[string]*fakeTrashBlob{
+			"file":          {data: []byte("deleted"), deleted: goneTime},
+			"gone":          {data: []byte("gone"), deleted: goneTime},
+			"dir/old":       {data: []byte("old"), deleted: oldTime},
+			"removed":       {folder: true, deleted: oldTime},
+			"removed/a":     {data: []byte("a"), deleted: oldTime},
+			"removed/sub/b": {data: []byte("b"), deleted: oldTime},
+		},
+	}
+	s.container = newFakeContainer(s.fake)
+	s.az = &AzStorage{storage: s.container.newFakeBlockBlob(), stConfig: AzStorageConfig{showTrash: true}}
+	azStatsCollector = nil
+}
+
+func (s *trashTestSuite) TearDownTest() {
+	s.container.close()
+}
+
+func (s *trashTestSuite) TestListDeleted() {
+	list, _, err := s.az.storage.ListDeleted("", nil, 0)
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"file", "gone", "removed", "dir"}, names(list))
+	s.assert.Equal(goneTime, list[1].Mtime)
+	s.assert.True(list[2].IsDir())
+
+	list, err = s.az.ReadDir(internal.ReadDirOptions{Name: ".trash"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"file", "gone", "removed", "dir"}, names(list))
+	s.assert.Equal(".trash/gone", list[1].Path)
+	s.assert.Zero(list[1].Mode)
+	s.assert.True(list[3].IsDir())
+
+	list, err = s.az.ReadDir(internal.ReadDirOptions{Name: ".trash/dir"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"old"}, names(list))
+
+	list, _, err = s.az.StreamDir(internal.StreamDirOptions{Name: ".trash/removed"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"a", "sub"}, names(list))
+
+	s.assert.False(s.az.IsDirEmpty(internal.IsDirEmptyOptions{Name: ".trash/removed/sub"}))
+
+	// The trash is not part of the root of the mount
+	list, err = s.az.ReadDir(internal.ReadDirOptions{Name: ""})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"file", "dir"}, names(list))
+}
+
+func (s *trashTestSuite) TestTrashAttr() {
+	attr, err := s.az.GetAttr(internal.GetAttrOptions{Name: ".trash"})
+	s.assert.Nil(err)
+	s.assert.True(attr.IsDir())
+
+	attr, err = s.az.GetAttr(internal.GetAttrOptions{Name: ".trash/dir/old"})
+	s.assert.Nil(err)
+	s.assert.False(attr.IsDir())
+	s.assert.Equal(oldTime, attr.Mtime)
+	s.assert.EqualValues(3, attr.Size)
+
+	attr, err = s.az.GetAttr(internal.GetAttrOptions{Name: ".trash/removed"})
+	s.assert.Nil(err)
+	s.assert.True(attr.IsDir())
+
+	_, err = s.az.GetAttr(internal.GetAttrOptions{Name: ".trash/dir/kept"})
+	s.assert.Equal(syscall.ENOENT, err)
+}
+
+func (s *trashTestSuite) TestRestoreFile() {
+	err := s.az.RenameFile(internal.RenameFileOptions{Src: ".trash/gone", Dst: "gone"})
+	s.assert.Nil(err)
+	s.assert.Equal([]byte("gone"), s.fake.live["gone"].data)
+	s.assert.NotContains(s.fake.deleted, "gone")
+}
+
+func (s *trashTestSuite) TestRestoreFileElsewhere() {
+	err := s.az.RenameFile(internal.RenameFileOptions{Src: ".trash/dir/old", Dst: "restored"})
+	s.assert.Nil(err)
+	s.assert.Equal([]byte("old"), s.fake.live["restored"].data)
+	s.assert.NotContains(s.fake.live, "dir/old")
+}
+
+func (s *trashTestSuite) TestRestoreOverLiveBlob() {
+	err := s.az.RenameFile(internal.RenameFileOptions{Src: ".trash/file", Dst: "file"})
+	s.assert.Equal(syscall.EEXIST, err)
+	s.assert.Equal([]byte("live"), s.fake.live["file"].data)
+	s.assert.Contains(s.fake.deleted, "file")
+}
+
+func (s *trashTestSuite) TestRestoreDir() {
+	err := s.az.RenameDir(internal.RenameDirOptions{Src: ".trash/removed", Dst: "removed"})
+	s.assert.Nil(err)
+	s.assert.True(s.fake.live["removed"].folder)
+	s.assert.Equal([]byte("a"), s.fake.live["removed/a"].data)
+	s.assert.Equal([]byte("b"), s.fake.live["removed/sub/b"].data)
+	s.assert.Empty(s.fake.deleted["removed/a"])
+
+	// Live blobs under the directory are left as they are
+	err = s.az.RenameDir(internal.RenameDirOptions{Src: ".trash/dir", Dst: "dir"})
+	s.assert.Nil(err)
+	s.assert.Equal([]byte("old"), s.fake.live["dir/old"].data)
+	s.assert.Equal([]byte("kept"), s.fake.live["dir/kept"].data)
+}
+
+func (s *trashTestSuite) TestTrashReadOnly() {
+	_, err := s.az.OpenFile(internal.OpenFileOptions{Name: ".trash/gone"})
+	s.assert.Equal(syscall.EACCES, err)
+	err = s.az.CopyToFile(internal.CopyToFileOptions{Name: ".trash/gone"})
+	s.assert.Equal(syscall.EACCES, err)
+
+	_, err = s.az.CreateFile(internal.CreateFileOptions{Name: ".trash/new"})
+	s.assert.Equal(syscall.EROFS, err)
+	err = s.az.DeleteFile(internal.DeleteFileOptions{Name: ".trash/gone"})
+	s.assert.Equal(syscall.EROFS, err)
+	err = s.az.RenameFile(internal.RenameFileOptions{Src: "file", Dst: ".trash/file"})
+	s.assert.Equal(syscall.EROFS, err)
+	err = s.az.RenameFile(internal.RenameFileOptions{Src: ".trash/gone", Dst: ".trash/other"})
+	s.assert.Equal(syscall.EROFS, err)
+	s.assert.Contains(s.fake.deleted, "gone")
+}
+
+func (s *trashTestSuite) TestTrashDisabled() {
+	s.az.stConfig.showTrash = false
+	_, err := s.az.GetAttr(internal.GetAttrOptions{Name: ".trash/gone"})
+	s.assert.Equal(syscall.ENOENT, err)
+}
+
+func TestTrashTestSuite(t *testing.T) {
+	suite.Run(t, new(trashTestSuite))
+}
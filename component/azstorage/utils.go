@@ -46,6 +46,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -265,6 +266,9 @@ const (
 	InvalidRange
 	BlobIsUnderLease
 	InvalidPermission
+	LeaseAlreadyPresent
+	BlobIsArchived
+	BlobIsRehydrating
 )
 
 // ErrStr : Store error to string mapping
@@ -291,6 +295,12 @@ func storeBlobErrToErr(err error) uint16 {
 			return BlobIsUnderLease
 		case azblob.ServiceCodeInsufficientAccountPermissions:
 			return InvalidPermission
+		case azblob.ServiceCodeLeaseAlreadyPresent:
+			return LeaseAlreadyPresent
+		case azblob.ServiceCodeBlobArchived:
+			return BlobIsArchived
+		case azblob.ServiceCodeBlobBeingRehydrated:
+			return BlobIsRehydrating
 		default:
 			return ErrUnknown
 		}
@@ -355,8 +365,80 @@ func parseMetadata(attr *internal.ObjAttr, metadata map[string]string) {
 		} else if strings.ToLower(k) == symlinkKey && v == "true" {
 			attr.Flags = internal.NewSymlinkBitMap()
 			attr.Mode = attr.Mode | os.ModeSymlink
+		} else if strings.ToLower(k) == mtimeKey {
+			// Time set by utimens takes precedence over the last modified time of the blob
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				attr.Mtime = t
+			}
+		} else if strings.ToLower(k) == atimeKey {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				attr.Atime = t
+			}
+		}
+	}
+}
+
+// sanitizeETag : Remove the quotes around etags returned in headers so that they match the ones returned in listings
+func sanitizeETag(etag string) string {
+	return strings.Trim(etag, "\"")
+}
+
+// removeTimesFromMetadata : Returns a copy of the metadata without the times set by utimens
+// Any upload of new content makes the last modified time of the blob authoritative again
+func removeTimesFromMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+
+	newMetadata := make(map[string]string)
+	for k, v := range metadata {
+		if strings.ToLower(k) != mtimeKey && strings.ToLower(k) != atimeKey {
+			newMetadata[k] = v
 		}
 	}
+	return newMetadata
+}
+
+// formatPosixMode : Convert the mode to the octal string stored in blob metadata
+// Only permission, setuid, setgid and sticky bits are stored, file type is derived from the blob itself
+func formatPosixMode(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", common.PosixFromFileMode(mode))
+}
+
+// parsePosixMetadata : Populate mode and owner of the path from the metadata stored by chmod/chown
+func parsePosixMetadata(attr *internal.ObjAttr) {
+	var uid, gid uint64
+	uidFound, gidFound := false, false
+
+	for k, v := range attr.Metadata {
+		var err error
+		switch strings.ToLower(k) {
+		case modeKey:
+			var mode uint64
+			mode, err = strconv.ParseUint(v, 8, 32)
+			if err == nil {
+				attr.Mode = (attr.Mode & os.ModeType) | common.FileModeFromPosix(uint32(mode))
+				attr.Flags.Clear(internal.PropFlagModeDefault)
+			}
+		case uidKey:
+			uid, err = strconv.ParseUint(v, 10, 32)
+			uidFound = err == nil
+		case gidKey:
+			gid, err = strconv.ParseUint(v, 10, 32)
+			gidFound = err == nil
+		}
+
+		if err != nil {
+			log.Warn("utils::parsePosixMetadata : Invalid %s value %s for %s", k, v, attr.Path)
+		}
+	}
+
+	// Owner is reported only if both user and group are known, otherwise the mount defaults are used
+	if uidFound && gidFound {
+		attr.Uid = uint32(uid)
+		attr.Gid = uint32(gid)
+		attr.Flags.Set(internal.PropFlagOwnerSet)
+	}
 }
 
 //    ----------- Content-type handling  ---------------
@@ -561,7 +643,7 @@ func sanitizeSASKey(key string) string {
 	return key
 }
 
-func getMD5(fi *os.File) ([]byte, error) {
+func getMD5(fi io.Reader) ([]byte, error) {
 	hasher := md5.New()
 	_, err := io.Copy(hasher, fi)
 
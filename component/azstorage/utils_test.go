@@ -38,10 +38,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-fuse/v2/common"
 	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
@@ -448,6 +450,72 @@ func (s *utilsTestSuite) TestAutoDetectAuthMode() {
 	assert.Equal(authType, "sas")
 }
 
+func (s *utilsTestSuite) TestParseMetadataTimes() {
+	assert := assert.New(s.T())
+	lastModified := time.Unix(1600000000, 0)
+	attr := &internal.ObjAttr{Mtime: lastModified, Atime: lastModified, Ctime: lastModified}
+
+	mtime := time.Unix(1500000000, 123456789).UTC()
+	metadata := map[string]string{
+		mtimeKey: mtime.Format(time.RFC3339Nano),
+		atimeKey: "invalid",
+	}
+	parseMetadata(attr, metadata)
+	assert.True(mtime.Equal(attr.Mtime))
+	assert.EqualValues(lastModified, attr.Atime) // invalid values are ignored
+	assert.EqualValues(lastModified, attr.Ctime)
+}
+
+func (s *utilsTestSuite) TestRemoveTimesFromMetadata() {
+	assert := assert.New(s.T())
+	metadata := map[string]string{mtimeKey: "a", atimeKey: "b", folderKey: "true"}
+
+	newMetadata := removeTimesFromMetadata(metadata)
+	assert.EqualValues(map[string]string{folderKey: "true"}, newMetadata)
+	assert.Len(metadata, 3) // input is left untouched
+	assert.Nil(removeTimesFromMetadata(nil))
+}
+
+func (s *utilsTestSuite) TestFormatPosixMode() {
+	assert := assert.New(s.T())
+	assert.EqualValues("0755", formatPosixMode(0755))
+	assert.EqualValues("0644", formatPosixMode(os.ModeDir|0644))
+	assert.EqualValues("4755", formatPosixMode(os.ModeSetuid|0755))
+	assert.EqualValues("3775", formatPosixMode(os.ModeSetgid|os.ModeSticky|0775))
+}
+
+func (s *utilsTestSuite) TestParsePosixMetadata() {
+	assert := assert.New(s.T())
+	attr := &internal.ObjAttr{Mode: os.ModeDir, Flags: internal.NewDirBitMap()}
+	attr.Flags.Set(internal.PropFlagModeDefault)
+	attr.Metadata = map[string]string{modeKey: "0750", uidKey: "1000", gidKey: "1001"}
+
+	parsePosixMetadata(attr)
+	assert.False(attr.IsModeDefault())
+	assert.EqualValues(os.ModeDir|0750, attr.Mode)
+	assert.True(attr.IsOwnerSet())
+	assert.EqualValues(1000, attr.Uid)
+	assert.EqualValues(1001, attr.Gid)
+
+	// Special bits round trip through the metadata
+	for _, mode := range []os.FileMode{os.ModeSetuid | 0755, os.ModeSetgid | os.ModeSticky | 0775} {
+		attr = &internal.ObjAttr{Metadata: map[string]string{modeKey: formatPosixMode(mode)}}
+		parsePosixMetadata(attr)
+		assert.Equal(mode, attr.Mode)
+	}
+}
+
+func (s *utilsTestSuite) TestParsePosixMetadataPartial() {
+	assert := assert.New(s.T())
+	attr := &internal.ObjAttr{}
+	attr.Flags.Set(internal.PropFlagModeDefault)
+	attr.Metadata = map[string]string{modeKey: "invalid", uidKey: "1000"}
+
+	parsePosixMetadata(attr)
+	assert.True(attr.IsModeDefault())
+	assert.False(attr.IsOwnerSet()) // group is not known so mount defaults are used
+}
+
 func TestUtilsTestSuite(t *testing.T) {
 	suite.Run(t, new(utilsTestSuite))
 }
@@ -0,0 +1,333 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+// Read only directory at the root of the mount, mirroring the tree of the container.
+// Every blob shows up in it as a directory holding one file per version, named by its version id.
+const versionsDirName = ".versions"
+
+// virtualDirPath : Path mirrored by a path under the given directory at the root of the mount
+func virtualDirPath(path string, dir string) (string, bool) {
+	path = strings.Trim(path, "/")
+	if path == dir {
+		return "", true
+	}
+	if strings.HasPrefix(path, dir+"/") {
+		return path[len(dir)+1:], true
+	}
+	return "", false
+}
+
+// versionsPath : Path of the blob or directory mirrored by a path under the versions directory
+func (az *AzStorage) versionsPath(path string) (string, bool) {
+	if !az.stConfig.showVersions {
+		return "", false
+	}
+	return virtualDirPath(path, versionsDirName)
+}
+
+// inVersions : Check whether the path is in the read only versions directory
+func (az *AzStorage) inVersions(paths ...string) bool {
+	for _, path := range paths {
+		if _, found := az.versionsPath(path); found {
+			return true
+		}
+	}
+	return false
+}
+
+// isReadOnly : Check whether any of the paths can not be changed, being a version, a deleted blob or the mount being at a point in time
+func (az *AzStorage) isReadOnly(paths ...string) bool {
+	return az.atPointInTime() || az.inVersions(paths...) || az.inTrash(paths...)
+}
+
+// versionOf : Blob and version id named by a path under the versions directory, other paths are returned as they are
+func (az *AzStorage) versionOf(path string) (string, string) {
+	mirrored, found := az.versionsPath(path)
+	if !found {
+		return path, ""
+	}
+	return filepath.Dir(mirrored), filepath.Base(mirrored)
+}
+
+// resolveVersion : Blob and version id to read for the given path, the version id is empty for current blobs
+func (az *AzStorage) resolveVersion(path string) (string, string, error) {
+	if az.inVersions(path) {
+		name, versionID := az.versionOf(path)
+		return name, versionID, nil
+	}
+
+	if az.atPointInTime() {
+		attr, err := az.getAttrAt(path)
+		if err != nil {
+			return "", "", err
+		}
+		return path, attr.VersionID, nil
+	}
+
+	return path, "", nil
+}
+
+// Key of the blob version a handle was opened on
+const blobVersionKey = "azstorage.version"
+
+type blobVersion struct {
+	name      string
+	versionID string
+}
+
+// handleVersion : Blob and version id read through the handle
+func handleVersion(handle *handlemap.Handle) (string, string) {
+	if value, found := handle.GetValue(blobVersionKey); found {
+		version := value.(blobVersion)
+		return version.name, version.versionID
+	}
+	return handle.Path, ""
+}
+
+// isVersionID : Version ids are the UTC time the version was created at
+func isVersionID(name string) bool {
+	_, err := time.Parse(time.RFC3339Nano, name)
+	return err == nil && strings.HasSuffix(name, "Z")
+}
+
+// versionsDirAttr : Attributes of a directory in the versions tree
+func versionsDirAttr(mirrored string, mtime time.Time) *internal.ObjAttr {
+	path := filepath.Join(versionsDirName, mirrored)
+	attr := &internal.ObjAttr{
+		Path:   path,
+		Name:   filepath.Base(path),
+		Size:   4096,
+		Mode:   0555,
+		Mtime:  mtime,
+		Atime:  mtime,
+		Ctime:  mtime,
+		Crtime: mtime,
+		Flags:  internal.NewDirBitMap(),
+	}
+	attr.Flags.Set(internal.PropFlagMetadataRetrieved)
+	return attr
+}
+
+// versionFileAttr : Attributes of a version of a blob, presented as a read only regular file
+func versionFileAttr(version *internal.ObjAttr) *internal.ObjAttr {
+	attr := *version
+	attr.Path = filepath.Join(versionsDirName, version.Path, version.VersionID)
+	attr.Name = version.VersionID
+	attr.Mode = 0444
+	attr.Flags = internal.NewFileBitMap()
+	attr.Flags.Set(internal.PropFlagMetadataRetrieved)
+	return &attr
+}
+
+// listAllVersions : List every version of the blobs under the given prefix
+func (az *AzStorage) listAllVersions(prefix string) ([]*internal.ObjAttr, error) {
+	list := make([]*internal.ObjAttr, 0)
+
+	var marker *string = nil
+	for {
+		newList, newMarker, err := az.storage.ListVersions(prefix, marker, common.MaxDirListCount)
+		if err != nil {
+			log.Err("AzStorage::listAllVersions : Failed to list versions of %s [%s]", prefix, err.Error())
+			return nil, err
+		}
+		list = append(list, newList...)
+
+		marker = newMarker
+		if newMarker == nil || *newMarker == "" {
+			break
+		}
+	}
+
+	return list, nil
+}
+
+// blobHistory : Every version of the given blob, along with the blob itself when it was written before versioning was enabled
+func (az *AzStorage) blobHistory(name string) ([]*internal.ObjAttr, error) {
+	history := make([]*internal.ObjAttr, 0)
+	if name == "" || name == "." {
+		return history, nil
+	}
+
+	list, err := az.listAllVersions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range list {
+		if attr.Path == name {
+			history = append(history, attr)
+		}
+	}
+	return history, nil
+}
+
+// blobVersions : Versions of the given blob, oldest first
+func (az *AzStorage) blobVersions(name string) ([]*internal.ObjAttr, error) {
+	history, err := az.blobHistory(name)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*internal.ObjAttr, 0)
+	for _, attr := range history {
+		if !attr.IsDir() && attr.VersionID != "" {
+			versions = append(versions, attr)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].VersionID < versions[j].VersionID
+	})
+	return versions, nil
+}
+
+// versionsDirEntries : Blobs and directories under the given directory, including blobs that only have older versions left
+func (az *AzStorage) versionsDirEntries(dir string) ([]*internal.ObjAttr, error) {
+	list, err := az.listAllVersions(formatListDirName(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*internal.ObjAttr, 0)
+	index := make(map[string]*internal.ObjAttr)
+	for _, attr := range list {
+		if !attr.IsDir() && attr.VersionID == "" {
+			// Blob written before versioning was enabled, it has no version to show
+			continue
+		}
+		if entry, found := index[attr.Path]; found {
+			// Directory of a blob shows the time of its latest version
+			if attr.Mtime.After(entry.Mtime) {
+				entry.Mtime, entry.Atime, entry.Ctime = attr.Mtime, attr.Mtime, attr.Mtime
+			}
+			continue
+		}
+
+		entry := versionsDirAttr(attr.Path, attr.Mtime)
+		index[attr.Path] = entry
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// getVersionsAttr : Attributes of a path under the versions directory
+func (az *AzStorage) getVersionsAttr(mirrored string) (*internal.ObjAttr, error) {
+	if mirrored == "" {
+		return versionsDirAttr("", az.startTime), nil
+	}
+
+	name := filepath.Base(mirrored)
+	if isVersionID(name) {
+		versions, err := az.blobVersions(filepath.Dir(mirrored))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if version.VersionID == name {
+				return versionFileAttr(version), nil
+			}
+		}
+	}
+
+	// Blob with versions, presented as the directory of its versions
+	versions, err := az.blobVersions(mirrored)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 {
+		return versionsDirAttr(mirrored, versions[len(versions)-1].Mtime), nil
+	}
+
+	// Directory with at least one blob or version under it
+	list, _, err := az.storage.ListVersions(formatListDirName(mirrored), nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > 0 {
+		return versionsDirAttr(mirrored, list[0].Mtime), nil
+	}
+
+	return nil, syscall.ENOENT
+}
+
+// readVersionsDir : List a directory under the versions directory
+func (az *AzStorage) readVersionsDir(mirrored string) ([]*internal.ObjAttr, error) {
+	versions, err := az.blobVersions(mirrored)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) > 0 {
+		entries := make([]*internal.ObjAttr, 0, len(versions))
+		for _, version := range versions {
+			entries = append(entries, versionFileAttr(version))
+		}
+		return entries, nil
+	}
+
+	return az.versionsDirEntries(mirrored)
+}
+
+// openVersion : Open a version of a blob for reading
+func (az *AzStorage) openVersion(options internal.OpenFileOptions) (*internal.ObjAttr, error) {
+	if options.Flags&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		return nil, syscall.EROFS
+	}
+
+	mirrored, _ := az.versionsPath(options.Name)
+	attr, err := az.getVersionsAttr(mirrored)
+	if err != nil {
+		return nil, err
+	}
+	if attr.IsDir() {
+		return nil, syscall.EISDIR
+	}
+	return attr, nil
+}
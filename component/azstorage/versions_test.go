@@ -0,0 +1,451 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package azstorage
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeVersion : Version of a blob, blobs written before versioning was enabled have an empty id
+// Versions soft deleted after versioning was disabled carry the time they were deleted at
+type fakeVersion struct {
+	id      string
+	data    []byte
+	current bool
+	deleted string
+}
+
+// fakeVersionServer : Minimal fake of the blob listing and download REST APIs of a container with versioning
+type fakeVersionServer struct {
+	sync.Mutex
+	blobs map[string][]fakeVersion
+}
+
+func versionTime(id string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, id)
+	return t
+}
+
+func (f *fakeVersionServer) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	versions := strings.Contains(query.Get("include"), "versions")
+	deleted := strings.Contains(query.Get("include"), "deleted")
+
+	names := make([]string, 0)
+	for name := range f.blobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var list strings.Builder
+	prefixes := make(map[string]bool)
+	list.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?><EnumerationResults><Blobs>")
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if i := strings.Index(name[len(prefix):], "/"); i >= 0 {
+			dir := name[:len(prefix)+i+1]
+			if !prefixes[dir] {
+				prefixes[dir] = true
+				fmt.Fprintf(&list, "<BlobPrefix><Name>%s</Name></BlobPrefix>", dir)
+			}
+			continue
+		}
+		for _, version := range f.blobs[name] {
+			if !versions && !version.current && !(deleted && version.deleted != "") {
+				continue
+			}
+			list.WriteString("<Blob><Name>" + name + "</Name>")
+			if deleted && version.deleted != "" {
+				list.WriteString("<Deleted>true</Deleted>")
+			}
+			if versions && version.id != "" {
+				fmt.Fprintf(&list, "<VersionId>%s</VersionId><IsCurrentVersion>%t</IsCurrentVersion>", version.id, version.current)
+			}
+			fmt.Fprintf(&list, "<Properties><Last-Modified>%s</Last-Modified><Etag>0x%d</Etag><Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType>",
+				versionTime(version.id).Format(time.RFC1123), len(version.data), len(version.data))
+			if deleted && version.deleted != "" {
+				fmt.Fprintf(&list, "<DeletedTime>%s</DeletedTime>", versionTime(version.deleted).Format(time.RFC1123))
+			}
+			list.WriteString("</Properties></Blob>")
+		}
+	}
+	list.WriteString("</Blobs><NextMarker /></EnumerationResults>")
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(list.String()))
+}
+
+func (f *fakeVersionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.URL.Query().Get("comp") == "list" {
+		f.list(w, r)
+		return
+	}
+
+	name := fakeBlobName(r)
+	versionID := r.URL.Query().Get("versionid")
+	var data []byte
+	found := false
+	for _, version := range f.blobs[name] {
+		if (versionID == "" && version.current) || (versionID != "" && version.id == versionID) {
+			data, found = version.data, true
+		}
+	}
+
+	if !found {
+		fakeError(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	fakeDownload(w, r, data)
+}
+
+const (
+	firstVersion  = "2022-08-01T10:00:00.1234567Z"
+	secondVersion = "2022-08-02T10:00:00.1234567Z"
+	thirdVersion  = "2022-08-03T10:00:00.1234567Z"
+)
+
+type versionsTestSuite struct {
+	suite.Suite
+	assert    *assert.Assertions
+	container *fakeContainer
+	fake      *fakeVersionServer
+	az        *AzStorage
+}
+
+func (s *versionsTestSuite) SetupTest() {
+	s.assert = assert.New(s.T())
+	s.fake = &fakeVersionServer{
+		blobs: map[string][]fakeVersion{
+			"file": {
+				{id: firstVersion, data: []byte("first")},
+				{id: secondVersion, data: []byte("second version"), current: true},
+			},
+			"dir/nested": {
+				{id: firstVersion, data: []byte("nested")},
+				{id: thirdVersion, data: []byte("nested again"), current: true},
+			},
+			"deleted": {
+				{id: secondVersion, data: []byte("deleted")},
+			},
+			"plain": {
+				{data: []byte("written before versioning"), current: true},
+			},
+		},
+	}
+	s.container = newFakeContainer(s.fake)
+	s.az = &AzStorage{storage: s.container.newFakeBlockBlob(), stConfig: AzStorageConfig{showVersions: true}}
+	azStatsCollector = nil
+}
+
+func (s *versionsTestSuite) TearDownTest() {
+	s.container.close()
+}
+
+func names(list []*internal.ObjAttr) []string {
+	result := make([]string, 0)
+	for _, attr := range list {
+		result = append(result, attr.Name)
+	}
+	return result
+}
+
+func (s *versionsTestSuite) TestListVersions() {
+	list, _, err := s.az.storage.List("", nil, 0)
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"file", "plain", "dir"}, names(list))
+
+	list, _, err = s.az.storage.ListVersions("", nil, 0)
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"deleted", "file", "file", "plain", "dir"}, names(list))
+	s.assert.Equal(secondVersion, list[0].VersionID)
+	s.assert.Equal(firstVersion, list[1].VersionID)
+	s.assert.Empty(list[3].VersionID)
+}
+
+func (s *versionsTestSuite) TestVersionsPath() {
+	path, found := s.az.versionsPath(".versions/dir/nested")
+	s.assert.True(found)
+	s.assert.Equal("dir/nested", path)
+	path, found = s.az.versionsPath("/.versions/")
+	s.assert.True(found)
+	s.assert.Empty(path)
+	_, found = s.az.versionsPath(".versionsfile")
+	s.assert.False(found)
+
+	name, versionID := s.az.versionOf(".versions/dir/nested/" + firstVersion)
+	s.assert.Equal("dir/nested", name)
+	s.assert.Equal(firstVersion, versionID)
+	name, versionID = s.az.versionOf("dir/nested")
+	s.assert.Equal("dir/nested", name)
+	s.assert.Empty(versionID)
+
+	s.assert.True(isVersionID(firstVersion))
+	s.assert.False(isVersionID("file"))
+
+	s.az.stConfig.showVersions = false
+	_, found = s.az.versionsPath(".versions/file")
+	s.assert.False(found)
+}
+
+func (s *versionsTestSuite) TestVersionsTree() {
+	attr, err := s.az.GetAttr(internal.GetAttrOptions{Name: ".versions"})
+	s.assert.Nil(err)
+	s.assert.True(attr.IsDir())
+	s.assert.False(attr.IsModeDefault())
+
+	// Deleted blobs are listed from their versions, blobs without versions are left out
+	list, err := s.az.ReadDir(internal.ReadDirOptions{Name: ".versions"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"deleted", "file", "dir"}, names(list))
+	for _, attr := range list {
+		s.assert.True(attr.IsDir())
+	}
+	s.assert.Equal(".versions/file", list[1].Path)
+	s.assert.Equal(versionTime(secondVersion).Truncate(time.Second), list[1].Mtime)
+
+	list, _, err = s.az.StreamDir(internal.StreamDirOptions{Name: ".versions/dir"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"nested"}, names(list))
+
+	list, err = s.az.ReadDir(internal.ReadDirOptions{Name: ".versions/file/"})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{firstVersion, secondVersion}, names(list))
+	s.assert.Equal(".versions/file/"+firstVersion, list[0].Path)
+	s.assert.EqualValues(5, list[0].Size)
+	s.assert.EqualValues(0444, list[0].Mode)
+	s.assert.False(list[0].IsDir())
+
+	for _, name := range []string{".versions/file", ".versions/dir", ".versions/dir/nested", ".versions/deleted"} {
+		attr, err = s.az.GetAttr(internal.GetAttrOptions{Name: name})
+		s.assert.Nil(err, name)
+		s.assert.True(attr.IsDir(), name)
+	}
+
+	attr, err = s.az.GetAttr(internal.GetAttrOptions{Name: ".versions/dir/nested/" + thirdVersion})
+	s.assert.Nil(err)
+	s.assert.EqualValues(len("nested again"), attr.Size)
+	s.assert.Equal(versionTime(thirdVersion).Truncate(time.Second), attr.Mtime)
+
+	for _, name := range []string{".versions/missing", ".versions/plain", ".versions/file/" + thirdVersion, ".versions/file/other"} {
+		_, err = s.az.GetAttr(internal.GetAttrOptions{Name: name})
+		s.assert.Equal(syscall.ENOENT, err, name)
+	}
+
+	s.assert.False(s.az.IsDirEmpty(internal.IsDirEmptyOptions{Name: ".versions/file"}))
+}
+
+func (s *versionsTestSuite) TestReadVersion() {
+	name := ".versions/file/" + firstVersion
+	handle, err := s.az.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDONLY})
+	s.assert.Nil(err)
+	s.assert.EqualValues(5, handle.Size)
+
+	data := make([]byte, 10)
+	n, err := s.az.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 1, Data: data})
+	s.assert.Nil(err)
+	s.assert.Equal("irst", string(data[:n]))
+
+	data, err = s.az.ReadFile(internal.ReadFileOptions{Handle: handle})
+	s.assert.Nil(err)
+	s.assert.Equal("first", string(data))
+
+	f, err := os.CreateTemp(s.T().TempDir(), "version")
+	s.assert.Nil(err)
+	defer f.Close()
+	err = s.az.CopyToFile(internal.CopyToFileOptions{Name: ".versions/dir/nested/" + firstVersion, File: f})
+	s.assert.Nil(err)
+	data, _ = os.ReadFile(f.Name())
+	s.assert.Equal("nested", string(data))
+
+	// Current version is still read from the blob itself
+	handle, err = s.az.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	s.assert.Nil(err)
+	data, err = s.az.ReadFile(internal.ReadFileOptions{Handle: handle})
+	s.assert.Nil(err)
+	s.assert.Equal("second version", string(data))
+
+	_, err = s.az.OpenFile(internal.OpenFileOptions{Name: ".versions/file", Flags: os.O_RDONLY})
+	s.assert.Equal(syscall.EISDIR, err)
+}
+
+func (s *versionsTestSuite) TestVersionsReadOnly() {
+	name := ".versions/file/" + firstVersion
+	_, err := s.az.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDWR})
+	s.assert.Equal(syscall.EROFS, err)
+
+	_, err = s.az.CreateFile(internal.CreateFileOptions{Name: ".versions/file/new"})
+	s.assert.Equal(syscall.EROFS, err)
+	s.assert.Equal(syscall.EROFS, s.az.CreateDir(internal.CreateDirOptions{Name: ".versions/new"}))
+	s.assert.Equal(syscall.EROFS, s.az.DeleteFile(internal.DeleteFileOptions{Name: name}))
+	s.assert.Equal(syscall.EROFS, s.az.DeleteDir(internal.DeleteDirOptions{Name: ".versions/dir"}))
+	s.assert.Equal(syscall.EROFS, s.az.RenameFile(internal.RenameFileOptions{Src: "file", Dst: name}))
+	s.assert.Equal(syscall.EROFS, s.az.RenameDir(internal.RenameDirOptions{Src: ".versions/dir", Dst: "dir2"}))
+	s.assert.Equal(syscall.EROFS, s.az.TruncateFile(internal.TruncateFileOptions{Name: name}))
+	s.assert.Equal(syscall.EROFS, s.az.Chmod(internal.ChmodOptions{Name: name, Mode: 0777}))
+	s.assert.Equal(syscall.EROFS, s.az.SetXattr(internal.SetXattrOptions{Name: name, Attr: "user.test"}))
+
+	_, err = s.az.WriteFile(internal.WriteFileOptions{Handle: handlemap.NewHandle(name), Data: []byte("data")})
+	s.assert.Equal(syscall.EROFS, err)
+	s.assert.Nil(s.az.FlushFile(internal.FlushFileOptions{Handle: handlemap.NewHandle(name)}))
+}
+
+func (s *versionsTestSuite) TestVersionsDisabled() {
+	s.az.stConfig.showVersions = false
+	_, err := s.az.GetAttr(internal.GetAttrOptions{Name: ".versions"})
+	s.assert.Equal(syscall.ENOENT, err)
+}
+
+func (s *versionsTestSuite) TestPointInTime() {
+	s.az.stConfig.showVersions = false
+	s.az.stConfig.pointInTime = versionTime(firstVersion).Add(time.Hour)
+
+	list, err := s.az.ReadDir(internal.ReadDirOptions{Name: ""})
+	s.assert.Nil(err)
+	s.assert.Equal([]string{"file", "plain", "dir"}, names(list))
+	s.assert.EqualValues(len("first"), list[0].Size)
+
+	attr, err := s.az.GetAttr(internal.GetAttrOptions{Name: "dir"})
+	s.assert.Nil(err)
+	s.assert.True(attr.IsDir())
+	attr, err = s.az.GetAttr(internal.GetAttrOptions{Name: "dir/nested"})
+	s.assert.Nil(err)
+	s.assert.Equal(firstVersion, attr.VersionID)
+	_, err = s.az.GetAttr(internal.GetAttrOptions{Name: "deleted"})
+	s.assert.Equal(syscall.ENOENT, err)
+
+	// Reads are served from the version current at that time
+	handle, err := s.az.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	s.assert.Nil(err)
+	data, err := s.az.ReadFile(internal.ReadFileOptions{Handle: handle})
+	s.assert.Nil(err)
+	s.assert.Equal("first", string(data))
+
+	handle, err = s.az.OpenFile(internal.OpenFileOptions{Name: "plain", Flags: os.O_RDONLY})
+	s.assert.Nil(err)
+	data = make([]byte, 7)
+	_, err = s.az.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Data: data})
+	s.assert.Nil(err)
+	s.assert.Equal("written", string(data))
+
+	f, err := os.CreateTemp(s.T().TempDir(), "version")
+	s.assert.Nil(err)
+	defer f.Close()
+	s.assert.Nil(s.az.CopyToFile(internal.CopyToFileOptions{Name: "dir/nested", File: f}))
+	data, _ = os.ReadFile(f.Name())
+	s.assert.Equal("nested", string(data))
+
+	s.az.stConfig.pointInTime = versionTime(thirdVersion)
+	list, err = s.az.ReadDir(internal.ReadDirOptions{Name: "dir"})
+	s.assert.Nil(err)
+	s.assert.Equal(thirdVersion, list[0].VersionID)
+	s.assert.EqualValues(len("nested again"), list[0].Size)
+	s.assert.False(s.az.IsDirEmpty(internal.IsDirEmptyOptions{Name: "dir"}))
+	_, err = s.az.GetAttr(internal.GetAttrOptions{Name: "deleted"})
+	s.assert.Nil(err)
+}
+
+func (s *versionsTestSuite) TestPointInTimeSoftDeleted() {
+	s.az.stConfig.showVersions = false
+	s.fake.blobs["removed"] = []fakeVersion{
+		{id: firstVersion, data: []byte("removed"), deleted: secondVersion},
+	}
+
+	s.az.stConfig.pointInTime = versionTime(firstVersion).Add(time.Hour)
+	list, err := s.az.ReadDir(internal.ReadDirOptions{Name: ""})
+	s.assert.Nil(err)
+	s.assert.Contains(names(list), "removed")
+	_, err = s.az.GetAttr(internal.GetAttrOptions{Name: "removed"})
+	s.assert.Nil(err)
+
+	// Soft deleted before the point in time
+	s.az.stConfig.pointInTime = versionTime(thirdVersion)
+	list, err = s.az.ReadDir(internal.ReadDirOptions{Name: ""})
+	s.assert.Nil(err)
+	s.assert.NotContains(names(list), "removed")
+	_, err = s.az.GetAttr(internal.GetAttrOptions{Name: "removed"})
+	s.assert.Equal(syscall.ENOENT, err)
+
+	// Deleted from the versioned container without a recorded time
+	s.assert.Contains(names(list), "deleted")
+}
+
+func (s *versionsTestSuite) TestPointInTimeReadOnly() {
+	s.az.stConfig.pointInTime = versionTime(thirdVersion)
+
+	_, err := s.az.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_WRONLY})
+	s.assert.Equal(syscall.EROFS, err)
+	_, err = s.az.CreateFile(internal.CreateFileOptions{Name: "new"})
+	s.assert.Equal(syscall.EROFS, err)
+	s.assert.Equal(syscall.EROFS, s.az.CreateDir(internal.CreateDirOptions{Name: "newdir"}))
+	s.assert.Equal(syscall.EROFS, s.az.DeleteFile(internal.DeleteFileOptions{Name: "file"}))
+	s.assert.Equal(syscall.EROFS, s.az.RenameFile(internal.RenameFileOptions{Src: "file", Dst: "other"}))
+	s.assert.Equal(syscall.EROFS, s.az.CopyFromFile(internal.CopyFromFileOptions{Name: "file"}))
+	s.assert.Equal(syscall.EROFS, s.az.CreateLink(internal.CreateLinkOptions{Name: "link", Target: "file"}))
+	s.assert.Equal(syscall.EROFS, s.az.SetTimes(internal.SetTimesOptions{Name: "file"}))
+	s.assert.Equal(syscall.EROFS, s.az.RemoveXattr(internal.RemoveXattrOptions{Name: "file", Attr: "user.test"}))
+	s.assert.Equal(syscall.EROFS, s.az.FallocateFile(internal.FallocateFileOptions{Name: "file", Length: 10}))
+}
+
+func TestVersionsTestSuite(t *testing.T) {
+	suite.Run(t, new(versionsTestSuite))
+}
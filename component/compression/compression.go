@@ -0,0 +1,803 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package compression
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+// Compression : Compresses files on upload and marks them in the metadata of the blob. Blobs without the
+// marker, such as the ones written by other tools, are read and written as they are.
+type Compression struct {
+	internal.BaseComponent
+	codec     codec
+	chunkSize int64
+}
+
+// Structure defining your config parameters
+type CompressionOptions struct {
+	Algorithm   string `config:"algorithm" yaml:"algorithm,omitempty"`
+	Level       int    `config:"level" yaml:"level,omitempty"`
+	ChunkSizeKB uint64 `config:"chunk-size-kb" yaml:"chunk-size-kb,omitempty"`
+}
+
+const compName = "compression"
+
+const defaultAlgorithm = "gzip"
+
+// Reads decompress whole chunks, so smaller chunks make random reads cheaper at the cost of a lower ratio
+const defaultChunkSizeKB = 1024
+const maxChunkSizeKB = 64 * 1024
+
+// compressedHandle : State of a file opened through this component. The handle given to the components
+// above carries the size of the file, the handle of the next component the size of the blob.
+type compressedHandle struct {
+	sync.Mutex
+	inner  *handlemap.Handle
+	layout *layout // nil for an empty file
+
+	// Blobs can not be changed in place, so the file is written to a local copy that is uploaded on flush
+	local *os.File
+	dirty bool
+
+	// Last chunk read, so sequential reads decompress every chunk once
+	cachedIndex int64
+	cachedData  []byte
+}
+
+// Verification to check satisfaction criteria with Component Interface
+var _ internal.Component = &Compression{}
+
+func (c *Compression) Name() string {
+	return compName
+}
+
+func (c *Compression) SetName(name string) {
+	c.BaseComponent.SetName(name)
+}
+
+func (c *Compression) SetNextComponent(nc internal.Component) {
+	c.BaseComponent.SetNextComponent(nc)
+}
+
+// Priority : Same level as attr_cache and encryption, data shall be compressed before it is encrypted
+func (c *Compression) Priority() internal.ComponentPriority {
+	return internal.EComponentPriority.LevelTwo()
+}
+
+// Start : Pipeline calls this method to start the component functionality, this shall not block the call
+func (c *Compression) Start(ctx context.Context) error {
+	log.Trace("Compression::Start : Starting component %s", c.Name())
+	return nil
+}
+
+// Stop : Stop the component functionality and kill all threads started
+func (c *Compression) Stop() error {
+	log.Trace("Compression::Stop : Stopping component %s", c.Name())
+	return nil
+}
+
+// Configure : Pipeline will call this method after constructor so that you can read config and initialize yourself
+func (c *Compression) Configure(_ bool) error {
+	log.Trace("Compression::Configure : %s", c.Name())
+
+	conf := CompressionOptions{}
+	err := config.UnmarshalKey(c.Name(), &conf)
+	if err != nil {
+		log.Err("Compression::Configure : config error [invalid config attributes]")
+		return fmt.Errorf("config error in %s [%s]", c.Name(), err.Error())
+	}
+
+	if conf.Algorithm == "" {
+		conf.Algorithm = defaultAlgorithm
+	}
+	c.codec, err = newCodec(conf.Algorithm, conf.Level)
+	if err != nil {
+		log.Err("Compression::Configure : config error [%s]", err.Error())
+		return fmt.Errorf("config error in %s [%s]", c.Name(), err.Error())
+	}
+
+	c.chunkSize = defaultChunkSizeKB * 1024
+	if config.IsSet(compName + ".chunk-size-kb") {
+		if conf.ChunkSizeKB == 0 || conf.ChunkSizeKB > maxChunkSizeKB {
+			log.Err("Compression::Configure : config error [chunk-size-kb shall be between 1 and %d]", maxChunkSizeKB)
+			return fmt.Errorf("config error in %s [chunk-size-kb shall be between 1 and %d]", c.Name(), maxChunkSizeKB)
+		}
+		c.chunkSize = int64(conf.ChunkSizeKB) * 1024
+	}
+
+	log.Info("Compression::Configure : algorithm %s, level %d, chunk-size-kb %d", c.codec.name(), conf.Level, c.chunkSize/1024)
+
+	return nil
+}
+
+// compressedHandleOf : State of the file, false if the handle is the one of a plain blob
+func compressedHandleOf(handle *handlemap.Handle) (*compressedHandle, bool) {
+	if handle == nil {
+		return nil, false
+	}
+	val, found := handle.GetValue(compName)
+	if !found {
+		return nil, false
+	}
+	return val.(*compressedHandle), true
+}
+
+// innerHandle : Handle of the next component for the given handle
+func innerHandle(handle *handlemap.Handle) *handlemap.Handle {
+	if h, found := compressedHandleOf(handle); found {
+		return h.inner
+	}
+	return handle
+}
+
+// newHandle : Wrap the handle of the next component for a compressed or empty blob
+func newHandle(inner *handlemap.Handle, l *layout) *handlemap.Handle {
+	handle := handlemap.NewHandle(inner.Path)
+	handle.Mtime = inner.Mtime
+	if l != nil {
+		handle.Size = l.size
+	}
+	handle.SetValue(compName, &compressedHandle{inner: inner, layout: l, cachedIndex: -1})
+	return handle
+}
+
+// logicalAttr : Attributes of the object with the size of the file held in it
+func logicalAttr(attr *internal.ObjAttr) *internal.ObjAttr {
+	if attr.IsDir() {
+		return attr
+	}
+	size, found := compressedSize(attr.Metadata)
+	if !found {
+		return attr
+	}
+
+	// Attributes may be cached by the next component, so they are not changed in place
+	logical := *attr
+	logical.Size = size
+	return &logical
+}
+
+// withMetadata : Metadata of the blob marked as compressed, the given map is not modified
+func (c *Compression) withMetadata(metadata map[string]string, size int64) map[string]string {
+	merged := make(map[string]string)
+	for key, value := range metadata {
+		if !isCompressionMeta(key) {
+			merged[key] = value
+		}
+	}
+	merged[codecMeta] = c.codec.name()
+	merged[sizeMeta] = strconv.FormatInt(size, 10)
+	merged[chunkMeta] = strconv.FormatInt(c.chunkSize, 10)
+	return merged
+}
+
+// tempFile : Create a temporary file next to the given one, it is removed as soon as it is created
+func tempFile(near string) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(near), ".blobfuse2-compression-*")
+	if err != nil {
+		f, err = os.CreateTemp("", "blobfuse2-compression-*")
+		if err != nil {
+			return nil, err
+		}
+	}
+	_ = os.Remove(f.Name())
+	return f, nil
+}
+
+// readLayout : Read the index of the compressed blob opened with the given handle of the next component
+func (c *Compression) readLayout(inner *handlemap.Handle, metadata map[string]string) (*layout, error) {
+	name, _ := metadataValue(metadata, codecMeta)
+	blobCodec, err := newCodec(name, 0)
+	if err != nil {
+		log.Err("Compression::readLayout : %s can not be read [%s]", inner.Path, err.Error())
+		return nil, syscall.EIO
+	}
+
+	size, err := tailSize(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if size > inner.Size {
+		log.Err("Compression::readLayout : %s is too small to be compressed", inner.Path)
+		return nil, syscall.EIO
+	}
+
+	tail := make([]byte, size)
+	n, err := c.NextComponent().ReadInBuffer(internal.ReadInBufferOptions{Handle: inner, Offset: inner.Size - size, Data: tail})
+	if err != nil {
+		return nil, err
+	}
+	if n != len(tail) {
+		return nil, syscall.EIO
+	}
+	return decodeLayout(blobCodec, tail, inner.Size)
+}
+
+// readChunk : Read and decompress a chunk of the file
+func (c *Compression) readChunk(inner *handlemap.Handle, l *layout, index int64) ([]byte, error) {
+	frame := make([]byte, l.offsets[index+1]-l.offsets[index])
+	n, err := c.NextComponent().ReadInBuffer(internal.ReadInBufferOptions{Handle: inner, Offset: l.offsets[index], Data: frame})
+	if err != nil {
+		return nil, err
+	}
+	if n != len(frame) {
+		return nil, syscall.EIO
+	}
+
+	data, err := l.codec.decompress(frame, l.chunkLength(index))
+	if err != nil {
+		log.Err("Compression::readChunk : chunk %d of %s failed to decompress [%s]", index, inner.Path, err.Error())
+		return nil, syscall.EIO
+	}
+	return data, nil
+}
+
+// decompress : Write the given range of the file to the local file, from its start
+func (c *Compression) decompress(inner *handlemap.Handle, l *layout, f *os.File, start int64, end int64) error {
+	for pos := start; pos < end; {
+		index := pos / l.chunkSize
+		data, err := c.readChunk(inner, l, index)
+		if err != nil {
+			return err
+		}
+
+		data = data[pos-index*l.chunkSize:]
+		if int64(len(data)) > end-pos {
+			data = data[:end-pos]
+		}
+		_, err = f.WriteAt(data, pos-start)
+		if err != nil {
+			return err
+		}
+		pos += int64(len(data))
+	}
+	return nil
+}
+
+// upload : Compress the local file chunk by chunk and upload it
+func (c *Compression) upload(options internal.CopyFromFileOptions) error {
+	name := options.Name
+	src, size, err := options.Source()
+	if err != nil {
+		return err
+	}
+
+	near := os.TempDir() + "/"
+	if options.File != nil {
+		near = options.File.Name()
+	}
+	staged, err := tempFile(near)
+	if err != nil {
+		return err
+	}
+	defer staged.Close()
+
+	lengths := make([]uint32, 0, chunks(size, c.chunkSize))
+	buf := make([]byte, c.chunkSize)
+	for off := int64(0); off < size; off += c.chunkSize {
+		data := buf
+		if size-off < c.chunkSize {
+			data = buf[:size-off]
+		}
+
+		var frame []byte
+		_, err = src.ReadAt(data, off)
+		if err == nil {
+			frame, err = c.codec.compress(data)
+		}
+		if err == nil {
+			_, err = staged.Write(frame)
+		}
+		if err != nil {
+			log.Err("Compression::upload : failed to compress %s [%s]", name, err.Error())
+			return err
+		}
+		lengths = append(lengths, uint32(len(frame)))
+	}
+
+	_, err = staged.Write(encodeIndex(lengths, size, c.chunkSize))
+	if err != nil {
+		return err
+	}
+	_, err = staged.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	return c.NextComponent().CopyFromFile(internal.CopyFromFileOptions{
+		Name:     name,
+		File:     staged,
+		Metadata: c.withMetadata(options.Metadata, size),
+	})
+}
+
+// ensureLocal : Make the local copy of the file to write to. Caller shall hold the lock of the handle.
+func (c *Compression) ensureLocal(h *compressedHandle) error {
+	if h.local != nil {
+		return nil
+	}
+
+	f, err := tempFile(os.TempDir() + "/")
+	if err != nil {
+		return err
+	}
+
+	if h.layout != nil {
+		err = c.decompress(h.inner, h.layout, f, 0, h.layout.size)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	h.local = f
+	return nil
+}
+
+// flush : Upload the local copy of the file if it was written. Caller shall hold the lock of the handle.
+func (c *Compression) flush(h *compressedHandle) error {
+	if !h.dirty {
+		return nil
+	}
+
+	// Metadata set on the blob since it was opened is kept
+	attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: h.inner.Path, RetrieveMetadata: true})
+	if err != nil {
+		return err
+	}
+
+	err = c.upload(internal.CopyFromFileOptions{Name: h.inner.Path, File: h.local, Metadata: attr.Metadata})
+	if err != nil {
+		log.Err("Compression::flush : failed to upload %s [%s]", h.inner.Path, err.Error())
+		return err
+	}
+	h.dirty = false
+	return nil
+}
+
+// ------------------------- Directory operations -------------------------------------------
+
+func (c *Compression) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAttr, error) {
+	list, err := c.NextComponent().ReadDir(options)
+	for i, attr := range list {
+		list[i] = logicalAttr(attr)
+	}
+	return list, err
+}
+
+func (c *Compression) StreamDir(options internal.StreamDirOptions) ([]*internal.ObjAttr, string, error) {
+	list, token, err := c.NextComponent().StreamDir(options)
+	for i, attr := range list {
+		list[i] = logicalAttr(attr)
+	}
+	return list, token, err
+}
+
+// ------------------------- File operations -------------------------------------------
+
+func (c *Compression) CreateFile(options internal.CreateFileOptions) (*handlemap.Handle, error) {
+	log.Trace("Compression::CreateFile : %s", options.Name)
+
+	inner, err := c.NextComponent().CreateFile(options)
+	if err != nil {
+		return nil, err
+	}
+	return newHandle(inner, nil), nil
+}
+
+func (c *Compression) OpenFile(options internal.OpenFileOptions) (*handlemap.Handle, error) {
+	log.Trace("Compression::OpenFile : %s", options.Name)
+
+	attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+	if err != nil {
+		return nil, err
+	}
+
+	inner, err := c.NextComponent().OpenFile(options)
+	if err != nil {
+		return nil, err
+	}
+
+	_, compressed := compressedSize(attr.Metadata)
+	if !compressed && attr.Size > 0 {
+		// Plain blob, it is read and written as it is
+		return inner, nil
+	}
+
+	var l *layout
+	if compressed {
+		l, err = c.readLayout(inner, attr.Metadata)
+		if err != nil {
+			_ = c.NextComponent().CloseFile(internal.CloseFileOptions{Handle: inner})
+			return nil, err
+		}
+	}
+	return newHandle(inner, l), nil
+}
+
+func (c *Compression) CloseFile(options internal.CloseFileOptions) error {
+	h, found := compressedHandleOf(options.Handle)
+	if !found {
+		return c.NextComponent().CloseFile(options)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	err := c.flush(h)
+	if err != nil {
+		return err
+	}
+	if h.local != nil {
+		h.local.Close()
+		h.local = nil
+	}
+	return c.NextComponent().CloseFile(internal.CloseFileOptions{Handle: h.inner})
+}
+
+// readAt : Read the file through its handle. Caller shall hold the lock of the handle.
+func (c *Compression) readAt(handle *handlemap.Handle, h *compressedHandle, p []byte, off int64) (int, error) {
+	size := atomic.LoadInt64(&handle.Size)
+	if off >= size || len(p) == 0 {
+		return 0, nil
+	}
+	if off+int64(len(p)) > size {
+		p = p[:size-off]
+	}
+
+	if h.local != nil {
+		n, err := h.local.ReadAt(p, off)
+		if err == io.EOF {
+			err = nil
+		}
+		return n, err
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / h.layout.chunkSize
+		if index != h.cachedIndex {
+			data, err := c.readChunk(h.inner, h.layout, index)
+			if err != nil {
+				return n, err
+			}
+			h.cachedIndex, h.cachedData = index, data
+		}
+		n += copy(p[n:], h.cachedData[pos-index*h.layout.chunkSize:])
+	}
+	return n, nil
+}
+
+func (c *Compression) ReadFile(options internal.ReadFileOptions) ([]byte, error) {
+	h, found := compressedHandleOf(options.Handle)
+	if !found {
+		return c.NextComponent().ReadFile(options)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	data := make([]byte, atomic.LoadInt64(&options.Handle.Size))
+	n, err := c.readAt(options.Handle, h, data, 0)
+	return data[:n], err
+}
+
+func (c *Compression) ReadInBuffer(options internal.ReadInBufferOptions) (int, error) {
+	h, found := compressedHandleOf(options.Handle)
+	if !found {
+		return c.NextComponent().ReadInBuffer(options)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	return c.readAt(options.Handle, h, options.Data, options.Offset)
+}
+
+func (c *Compression) WriteFile(options internal.WriteFileOptions) (int, error) {
+	h, found := compressedHandleOf(options.Handle)
+	if !found {
+		return c.NextComponent().WriteFile(options)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	err := c.ensureLocal(h)
+	if err != nil {
+		log.Err("Compression::WriteFile : failed to make local copy of %s [%s]", options.Handle.Path, err.Error())
+		return 0, err
+	}
+
+	n, err := h.local.WriteAt(options.Data, options.Offset)
+	if n > 0 {
+		h.dirty = true
+		if end := options.Offset + int64(n); end > atomic.LoadInt64(&options.Handle.Size) {
+			atomic.StoreInt64(&options.Handle.Size, end)
+		}
+	}
+	return n, err
+}
+
+func (c *Compression) FlushFile(options internal.FlushFileOptions) error {
+	h, found := compressedHandleOf(options.Handle)
+	if !found {
+		return c.NextComponent().FlushFile(options)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	return c.flush(h)
+}
+
+func (c *Compression) SyncFile(options internal.SyncFileOptions) error {
+	if h, found := compressedHandleOf(options.Handle); found {
+		h.Lock()
+		err := c.flush(h)
+		h.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return c.NextComponent().SyncFile(internal.SyncFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (c *Compression) ReleaseFile(options internal.ReleaseFileOptions) error {
+	return c.NextComponent().ReleaseFile(internal.ReleaseFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (c *Compression) LockFile(options internal.LockFileOptions) error {
+	options.Handle = innerHandle(options.Handle)
+	return c.NextComponent().LockFile(options)
+}
+
+func (c *Compression) UnlockFile(options internal.UnlockFileOptions) error {
+	return c.NextComponent().UnlockFile(internal.UnlockFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (c *Compression) CopyObject(options internal.CopyObjectOptions) error {
+	// The copy gets the metadata of the source, so it is read as compressed as well
+	options.SrcHandle = innerHandle(options.SrcHandle)
+	options.DstHandle = innerHandle(options.DstHandle)
+	return c.NextComponent().CopyObject(options)
+}
+
+// TruncateFile : Compressed blobs are decompressed, cut or extended and uploaded again
+func (c *Compression) TruncateFile(options internal.TruncateFileOptions) error {
+	log.Trace("Compression::TruncateFile : %s to %d bytes", options.Name, options.Size)
+
+	attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+	if err != nil {
+		return err
+	}
+
+	_, compressed := compressedSize(attr.Metadata)
+	if options.Size == 0 || (!compressed && attr.Size > 0) {
+		// An empty blob needs no marker and a plain blob stays plain
+		return c.NextComponent().TruncateFile(options)
+	}
+
+	inner, err := c.NextComponent().OpenFile(internal.OpenFileOptions{Name: options.Name, Flags: os.O_RDONLY})
+	if err != nil {
+		return err
+	}
+	defer c.NextComponent().CloseFile(internal.CloseFileOptions{Handle: inner}) //nolint
+
+	h := &compressedHandle{inner: inner, cachedIndex: -1}
+	if compressed {
+		h.layout, err = c.readLayout(inner, attr.Metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.ensureLocal(h)
+	if err != nil {
+		return err
+	}
+	defer h.local.Close()
+
+	err = h.local.Truncate(options.Size)
+	if err != nil {
+		return err
+	}
+
+	err = c.upload(internal.CopyFromFileOptions{Name: options.Name, File: h.local, Metadata: attr.Metadata})
+	if err != nil {
+		log.Err("Compression::TruncateFile : failed to truncate %s [%s]", options.Name, err.Error())
+	}
+	return err
+}
+
+// FallocateFile : Compressed blobs are not sparse, so only a change in size is applied
+func (c *Compression) FallocateFile(options internal.FallocateFileOptions) error {
+	log.Trace("Compression::FallocateFile : %s offset %d, length %d", options.Name, options.Offset, options.Length)
+
+	attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+	if err != nil {
+		return err
+	}
+
+	size, compressed := compressedSize(attr.Metadata)
+	if !compressed && attr.Size > 0 {
+		options.Handle = innerHandle(options.Handle)
+		return c.NextComponent().FallocateFile(options)
+	}
+
+	if options.PunchHole {
+		return syscall.EOPNOTSUPP
+	}
+
+	end := options.Offset + options.Length
+	if options.KeepSize || end <= size {
+		return nil
+	}
+	return c.TruncateFile(internal.TruncateFileOptions{Name: options.Name, Size: end})
+}
+
+// SeekFile : Compressed blobs do not keep the holes of the file, so all of it is data
+func (c *Compression) SeekFile(options internal.SeekFileOptions) (int64, error) {
+	var size int64
+	if _, found := compressedHandleOf(options.Handle); found {
+		size = atomic.LoadInt64(&options.Handle.Size)
+	} else {
+		attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+		if err != nil {
+			return 0, err
+		}
+		var compressed bool
+		size, compressed = compressedSize(attr.Metadata)
+		if !compressed {
+			return c.NextComponent().SeekFile(options)
+		}
+	}
+
+	if options.Offset >= size {
+		return 0, syscall.ENXIO
+	}
+	if options.Whence == common.SeekData {
+		return options.Offset, nil
+	}
+	return size, nil
+}
+
+// GetFileBlockOffsets : Blocks of a compressed blob do not map to the file, so it is reported as a small file
+// which components above read and write as a whole
+func (c *Compression) GetFileBlockOffsets(options internal.GetFileBlockOffsetsOptions) (*common.BlockOffsetList, error) {
+	attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, compressed := compressedSize(attr.Metadata); !compressed {
+		return c.NextComponent().GetFileBlockOffsets(options)
+	}
+
+	bol := &common.BlockOffsetList{}
+	bol.Flags.Set(common.SmallFile)
+	return bol, nil
+}
+
+// CopyToFile : Decompress the blob into the local file
+func (c *Compression) CopyToFile(options internal.CopyToFileOptions) error {
+	log.Trace("Compression::CopyToFile : %s", options.Name)
+
+	attr, err := c.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+	if err != nil {
+		return err
+	}
+	if _, compressed := compressedSize(attr.Metadata); !compressed {
+		return c.NextComponent().CopyToFile(options)
+	}
+
+	inner, err := c.NextComponent().OpenFile(internal.OpenFileOptions{Name: options.Name, Flags: os.O_RDONLY})
+	if err != nil {
+		return err
+	}
+	defer c.NextComponent().CloseFile(internal.CloseFileOptions{Handle: inner}) //nolint
+
+	l, err := c.readLayout(inner, attr.Metadata)
+	if err != nil {
+		return err
+	}
+
+	end := l.size
+	if options.Count > 0 && options.Offset+options.Count < end {
+		end = options.Offset + options.Count
+	}
+	if end < options.Offset {
+		end = options.Offset
+	}
+
+	err = options.File.Truncate(end - options.Offset)
+	if err != nil {
+		return err
+	}
+
+	err = c.decompress(inner, l, options.File, options.Offset, end)
+	if err != nil {
+		log.Err("Compression::CopyToFile : failed to decompress %s [%s]", options.Name, err.Error())
+	}
+	return err
+}
+
+// CopyFromFile : Upload the local file compressed. The compressed copy is staged in a temporary file next to
+// the local one, which is removed as soon as it is created.
+func (c *Compression) CopyFromFile(options internal.CopyFromFileOptions) error {
+	log.Trace("Compression::CopyFromFile : %s", options.Name)
+	return c.upload(options)
+}
+
+// ------------------------- Attribute operations -------------------------------------------
+
+func (c *Compression) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr, error) {
+	attr, err := c.NextComponent().GetAttr(options)
+	if err != nil {
+		return attr, err
+	}
+	return logicalAttr(attr), nil
+}
+
+// ------------------------- Factory -------------------------------------------
+
+// Pipeline will call this method to create your object, initialize your variables here
+// << DO NOT DELETE ANY AUTO GENERATED CODE HERE >>
+func NewCompressionComponent() internal.Component {
+	comp := &Compression{}
+	comp.SetName(compName)
+	return comp
+}
+
+// On init register this component to pipeline and supply your constructor
+func init() {
+	internal.AddComponent(compName, NewCompressionComponent)
+
+	algorithmFlag := config.AddStringFlag("compression-algorithm", defaultAlgorithm, "Algorithm to compress new files with. Only gzip is supported in this build.")
+	config.BindPFlag(compName+".algorithm", algorithmFlag)
+}
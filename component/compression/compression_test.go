@@ -0,0 +1,566 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package compression
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// memStorage : Keeps blobs in memory and, same as the storage, replaces their metadata on every upload
+type memStorage struct {
+	internal.BaseComponent
+	sync.Mutex
+	blobs map[string]*memBlob
+}
+
+type memBlob struct {
+	data     []byte
+	metadata map[string]string
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{blobs: make(map[string]*memBlob)}
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]string)
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (m *memStorage) blob(name string) (*memBlob, error) {
+	blob, found := m.blobs[name]
+	if !found {
+		return nil, syscall.ENOENT
+	}
+	return blob, nil
+}
+
+func (m *memStorage) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &internal.ObjAttr{
+		Path:     options.Name,
+		Name:     filepath.Base(options.Name),
+		Size:     int64(len(blob.data)),
+		Mode:     0644,
+		Flags:    internal.NewFileBitMap(),
+		Metadata: copyMetadata(blob.metadata),
+	}, nil
+}
+
+func (m *memStorage) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAttr, error) {
+	names := make([]string, 0)
+	m.Lock()
+	for name := range m.blobs {
+		names = append(names, name)
+	}
+	m.Unlock()
+	sort.Strings(names)
+
+	list := make([]*internal.ObjAttr, 0)
+	for _, name := range names {
+		attr, _ := m.GetAttr(internal.GetAttrOptions{Name: name})
+		list = append(list, attr)
+	}
+	return list, nil
+}
+
+func (m *memStorage) CreateFile(options internal.CreateFileOptions) (*handlemap.Handle, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.blobs[options.Name] = &memBlob{data: []byte{}}
+	return handlemap.NewHandle(options.Name), nil
+}
+
+func (m *memStorage) OpenFile(options internal.OpenFileOptions) (*handlemap.Handle, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return nil, err
+	}
+	handle := handlemap.NewHandle(options.Name)
+	handle.Size = int64(len(blob.data))
+	return handle, nil
+}
+
+func (m *memStorage) ReadFile(options internal.ReadFileOptions) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Handle.Path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, blob.data...), nil
+}
+
+func (m *memStorage) ReadInBuffer(options internal.ReadInBufferOptions) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Handle.Path)
+	if err != nil {
+		return 0, err
+	}
+	if options.Offset > options.Handle.Size {
+		return 0, syscall.ERANGE
+	}
+	data := blob.data[:options.Handle.Size]
+	return copy(options.Data, data[options.Offset:]), nil
+}
+
+func (m *memStorage) WriteFile(options internal.WriteFileOptions) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Handle.Path)
+	if err != nil {
+		return 0, err
+	}
+	end := options.Offset + int64(len(options.Data))
+	if end > int64(len(blob.data)) {
+		blob.data = append(blob.data, make([]byte, end-int64(len(blob.data)))...)
+	}
+	copy(blob.data[options.Offset:], options.Data)
+	blob.metadata = copyMetadata(options.Metadata)
+	return len(options.Data), nil
+}
+
+func (m *memStorage) TruncateFile(options internal.TruncateFileOptions) error {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return err
+	}
+	if options.Size > int64(len(blob.data)) {
+		blob.data = append(blob.data, make([]byte, options.Size-int64(len(blob.data)))...)
+	}
+	blob.data = blob.data[:options.Size]
+	blob.metadata = nil
+	return nil
+}
+
+func (m *memStorage) CopyFromFile(options internal.CopyFromFileOptions) error {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/self/fd/%d", options.File.Fd()))
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.blobs[options.Name] = &memBlob{data: data, metadata: copyMetadata(options.Metadata)}
+	return nil
+}
+
+func (m *memStorage) CopyToFile(options internal.CopyToFileOptions) error {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return err
+	}
+	err = options.File.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = options.File.WriteAt(blob.data, 0)
+	return err
+}
+
+type compressionTestSuite struct {
+	suite.Suite
+	assert      *assert.Assertions
+	compression *Compression
+	storage     *memStorage
+}
+
+func newTestCompression(next internal.Component, configuration string) (*Compression, error) {
+	_ = config.ReadConfigFromReader(strings.NewReader(configuration))
+	compression := NewCompressionComponent()
+	compression.SetNextComponent(next)
+	err := compression.Configure(true)
+
+	return compression.(*Compression), err
+}
+
+func (suite *compressionTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+	suite.storage = newMemStorage()
+
+	var err error
+	suite.compression, err = newTestCompression(suite.storage, "compression:\n  chunk-size-kb: 4\n")
+	suite.assert.Nil(err)
+}
+
+// logData : Data that compresses well, same as the logs it is meant for
+func logData(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "2022-08-01T10:%02d:%02d INFO request %d served in %d ms\n", i/60%60, i%60, i, mrand.Intn(1000))
+	}
+	return buf.Bytes()[:size]
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+	return data
+}
+
+// upload : Upload the data through the component as file_cache does
+func (suite *compressionTestSuite) upload(name string, data []byte, metadata map[string]string) {
+	f, err := os.CreateTemp(suite.T().TempDir(), "upload")
+	suite.assert.Nil(err)
+	defer f.Close()
+
+	_, err = f.Write(data)
+	suite.assert.Nil(err)
+
+	err = suite.compression.CopyFromFile(internal.CopyFromFileOptions{Name: name, File: f, Metadata: metadata})
+	suite.assert.Nil(err)
+}
+
+// download : Download the file through the component as file_cache does
+func (suite *compressionTestSuite) download(name string) []byte {
+	f, err := os.CreateTemp(suite.T().TempDir(), "download")
+	suite.assert.Nil(err)
+	defer f.Close()
+
+	err = suite.compression.CopyToFile(internal.CopyToFileOptions{Name: name, File: f})
+	suite.assert.Nil(err)
+
+	data, err := os.ReadFile(f.Name())
+	suite.assert.Nil(err)
+	return data
+}
+
+// read : Read the whole file through a handle of the component
+func (suite *compressionTestSuite) read(name string) []byte {
+	handle, err := suite.compression.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDONLY})
+	suite.assert.Nil(err)
+	defer suite.compression.CloseFile(internal.CloseFileOptions{Handle: handle}) //nolint
+
+	data, err := suite.compression.ReadFile(internal.ReadFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	return data
+}
+
+func (suite *compressionTestSuite) TestConfig() {
+	defer suite.cleanupTest()
+	suite.assert.EqualValues("gzip", suite.compression.codec.name())
+	suite.assert.EqualValues(4*1024, suite.compression.chunkSize)
+
+	compression, err := newTestCompression(suite.storage, "compression:\n  level: 9\n")
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(defaultChunkSizeKB*1024, compression.chunkSize)
+
+	_, err = newTestCompression(suite.storage, "compression:\n  algorithm: lz4\n")
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "unsupported algorithm")
+
+	_, err = newTestCompression(suite.storage, "compression:\n  algorithm: zstd\n")
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "not supported by this build")
+
+	_, err = newTestCompression(suite.storage, "compression:\n  level: 12\n")
+	suite.assert.NotNil(err)
+
+	_, err = newTestCompression(suite.storage, "compression:\n  chunk-size-kb: 0\n")
+	suite.assert.NotNil(err)
+}
+
+func (suite *compressionTestSuite) TestUploadDownload() {
+	defer suite.cleanupTest()
+	name := "app.log"
+	data := logData(20*4096 + 100)
+	suite.upload(name, data, map[string]string{"bfxattr_source": "dGVzdA=="})
+
+	// Storage holds the compressed chunks and is marked as compressed
+	blob := suite.storage.blobs[name]
+	suite.assert.Less(len(blob.data), len(data)/2)
+	suite.assert.EqualValues("gzip", blob.metadata[codecMeta])
+	suite.assert.EqualValues(fmt.Sprint(len(data)), blob.metadata[sizeMeta])
+	suite.assert.EqualValues("4096", blob.metadata[chunkMeta])
+	suite.assert.EqualValues("dGVzdA==", blob.metadata["bfxattr_source"])
+
+	attr, err := suite.compression.GetAttr(internal.GetAttrOptions{Name: name})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	list, err := suite.compression.ReadDir(internal.ReadDirOptions{Name: ""})
+	suite.assert.Nil(err)
+	suite.assert.Len(list, 1)
+	suite.assert.EqualValues(len(data), list[0].Size)
+
+	suite.assert.Equal(data, suite.download(name))
+	suite.assert.Equal(data, suite.read(name))
+}
+
+func (suite *compressionTestSuite) TestUploadEmpty() {
+	defer suite.cleanupTest()
+	suite.upload("empty", []byte{}, nil)
+	suite.assert.Len(suite.storage.blobs["empty"].data, footerSize)
+
+	attr, err := suite.compression.GetAttr(internal.GetAttrOptions{Name: "empty"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(0, attr.Size)
+	suite.assert.Len(suite.download("empty"), 0)
+	suite.assert.Len(suite.read("empty"), 0)
+}
+
+func (suite *compressionTestSuite) TestDownloadRange() {
+	defer suite.cleanupTest()
+	data := logData(5 * 4096)
+	suite.upload("file", data, nil)
+
+	f, err := os.CreateTemp(suite.T().TempDir(), "download")
+	suite.assert.Nil(err)
+	defer f.Close()
+
+	err = suite.compression.CopyToFile(internal.CopyToFileOptions{Name: "file", Offset: 5000, Count: 7000, File: f})
+	suite.assert.Nil(err)
+
+	downloaded, err := os.ReadFile(f.Name())
+	suite.assert.Nil(err)
+	suite.assert.Equal(data[5000:12000], downloaded)
+}
+
+func (suite *compressionTestSuite) TestRandomReads() {
+	defer suite.cleanupTest()
+	data := append(logData(10*4096), randomData(10*4096+123)...)
+	suite.upload("file", data, nil)
+
+	handle, err := suite.compression.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), handle.Size)
+
+	for i := 0; i < 200; i++ {
+		off := mrand.Int63n(int64(len(data)))
+		buf := make([]byte, mrand.Intn(3*4096)+1)
+		n, err := suite.compression.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: off, Data: buf})
+		suite.assert.Nil(err)
+
+		end := off + int64(len(buf))
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		suite.assert.EqualValues(end-off, n)
+		suite.assert.Equal(data[off:end], buf[:n], "offset %d, length %d", off, len(buf))
+	}
+
+	n, err := suite.compression.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: int64(len(data)), Data: make([]byte, 10)})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(0, n)
+
+	err = suite.compression.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+}
+
+func (suite *compressionTestSuite) TestPlainBlob() {
+	defer suite.cleanupTest()
+	data := []byte("written by another tool")
+	suite.storage.blobs["plain"] = &memBlob{data: append([]byte{}, data...)}
+
+	attr, err := suite.compression.GetAttr(internal.GetAttrOptions{Name: "plain"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	handle, err := suite.compression.OpenFile(internal.OpenFileOptions{Name: "plain", Flags: os.O_RDWR})
+	suite.assert.Nil(err)
+	_, found := compressedHandleOf(handle)
+	suite.assert.False(found)
+	suite.assert.Equal(data, suite.read("plain"))
+	suite.assert.Equal(data, suite.download("plain"))
+
+	// Writes in place and truncation keep the blob plain
+	_, err = suite.compression.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("WRITTEN")})
+	suite.assert.Nil(err)
+	err = suite.compression.TruncateFile(internal.TruncateFileOptions{Name: "plain", Size: 7})
+	suite.assert.Nil(err)
+	suite.assert.Equal([]byte("WRITTEN"), suite.storage.blobs["plain"].data)
+	suite.assert.Nil(suite.storage.blobs["plain"].metadata)
+}
+
+func (suite *compressionTestSuite) TestWriteThroughHandle() {
+	defer suite.cleanupTest()
+	name := "file"
+	handle, err := suite.compression.CreateFile(internal.CreateFileOptions{Name: name, Mode: 0644})
+	suite.assert.Nil(err)
+
+	data := logData(10 * 4096)
+	n, err := suite.compression.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), n)
+	suite.assert.EqualValues(len(data), handle.Size)
+
+	// Nothing reaches the storage till the file is flushed
+	suite.assert.Len(suite.storage.blobs[name].data, 0)
+	err = suite.compression.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("gzip", suite.storage.blobs[name].metadata[codecMeta])
+	suite.assert.Nil(suite.compression.CloseFile(internal.CloseFileOptions{Handle: handle}))
+	suite.assert.Equal(data, suite.read(name))
+
+	// Metadata set while the file is open is kept
+	suite.storage.blobs[name].metadata["bfxattr_owner"] = "dGVhbQ=="
+
+	handle, err = suite.compression.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDWR})
+	suite.assert.Nil(err)
+	_, err = suite.compression.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 5000, Data: []byte("changed")})
+	suite.assert.Nil(err)
+	_, err = suite.compression.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: int64(len(data)) + 100, Data: []byte("appended")})
+	suite.assert.Nil(err)
+
+	// Reads through the handle see the writes before they are uploaded
+	buf := make([]byte, 7)
+	_, err = suite.compression.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 5000, Data: buf})
+	suite.assert.Nil(err)
+	suite.assert.Equal([]byte("changed"), buf)
+	suite.assert.Nil(suite.compression.CloseFile(internal.CloseFileOptions{Handle: handle}))
+
+	copy(data[5000:], "changed")
+	data = append(data, make([]byte, 100)...)
+	data = append(data, []byte("appended")...)
+	suite.assert.Equal(data, suite.read(name))
+	suite.assert.Equal(data, suite.download(name))
+	suite.assert.EqualValues("dGVhbQ==", suite.storage.blobs[name].metadata["bfxattr_owner"])
+}
+
+func (suite *compressionTestSuite) TestTruncate() {
+	defer suite.cleanupTest()
+	data := logData(5 * 4096)
+	suite.upload("file", data, nil)
+
+	err := suite.compression.TruncateFile(internal.TruncateFileOptions{Name: "file", Size: 6000})
+	suite.assert.Nil(err)
+	suite.assert.Equal(data[:6000], suite.read("file"))
+
+	err = suite.compression.TruncateFile(internal.TruncateFileOptions{Name: "file", Size: 9000})
+	suite.assert.Nil(err)
+	suite.assert.Equal(append(append([]byte{}, data[:6000]...), make([]byte, 3000)...), suite.read("file"))
+	suite.assert.EqualValues("9000", suite.storage.blobs["file"].metadata[sizeMeta])
+
+	// An emptied blob needs no marker, it is compressed again when it grows
+	err = suite.compression.TruncateFile(internal.TruncateFileOptions{Name: "file", Size: 0})
+	suite.assert.Nil(err)
+	suite.assert.Len(suite.storage.blobs["file"].metadata, 0)
+
+	err = suite.compression.TruncateFile(internal.TruncateFileOptions{Name: "file", Size: 100000})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("gzip", suite.storage.blobs["file"].metadata[codecMeta])
+	suite.assert.Less(len(suite.storage.blobs["file"].data), 10000)
+	suite.assert.Equal(make([]byte, 100000), suite.download("file"))
+}
+
+func (suite *compressionTestSuite) TestCorruptBlob() {
+	defer suite.cleanupTest()
+	suite.upload("file", logData(3*4096), nil)
+	suite.storage.blobs["file"].data[20] ^= 0xff
+
+	handle, err := suite.compression.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	suite.assert.Nil(err)
+	_, err = suite.compression.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 0, Data: make([]byte, 100)})
+	suite.assert.Equal(syscall.EIO, err)
+
+	// Blob marked as compressed without the index at its end
+	suite.storage.blobs["other"] = &memBlob{data: logData(100), metadata: map[string]string{codecMeta: "gzip", sizeMeta: "100", chunkMeta: "4096"}}
+	_, err = suite.compression.OpenFile(internal.OpenFileOptions{Name: "other", Flags: os.O_RDONLY})
+	suite.assert.Equal(syscall.EIO, err)
+
+	suite.storage.blobs["other"].metadata[codecMeta] = "unknown"
+	_, err = suite.compression.OpenFile(internal.OpenFileOptions{Name: "other", Flags: os.O_RDONLY})
+	suite.assert.Equal(syscall.EIO, err)
+}
+
+func (suite *compressionTestSuite) TestFallocateAndSeek() {
+	defer suite.cleanupTest()
+	suite.upload("file", logData(5000), nil)
+
+	err := suite.compression.FallocateFile(internal.FallocateFileOptions{Name: "file", Offset: 4000, Length: 4000, PunchHole: true, KeepSize: true})
+	suite.assert.Equal(syscall.EOPNOTSUPP, err)
+
+	err = suite.compression.FallocateFile(internal.FallocateFileOptions{Name: "file", Offset: 0, Length: 9000})
+	suite.assert.Nil(err)
+	attr, _ := suite.compression.GetAttr(internal.GetAttrOptions{Name: "file"})
+	suite.assert.EqualValues(9000, attr.Size)
+
+	offset, err := suite.compression.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 6000, Whence: common.SeekData})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(6000, offset)
+	offset, err = suite.compression.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 10, Whence: common.SeekHole})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(9000, offset)
+	_, err = suite.compression.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 9000, Whence: common.SeekData})
+	suite.assert.Equal(syscall.ENXIO, err)
+
+	bol, err := suite.compression.GetFileBlockOffsets(internal.GetFileBlockOffsetsOptions{Name: "file"})
+	suite.assert.Nil(err)
+	suite.assert.True(bol.SmallFile())
+}
+
+func (suite *compressionTestSuite) cleanupTest() {
+	_ = suite.compression.Stop()
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCompressionTestSuite(t *testing.T) {
+	suite.Run(t, new(compressionTestSuite))
+}
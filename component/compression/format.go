@@ -0,0 +1,239 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+)
+
+// A compressed blob holds the chunks of the file compressed one by one, followed by an index of their
+// compressed lengths and a footer, so any chunk can be read and decompressed on its own:
+//
+//	| frame 0 | frame 1 | ... | frame n-1 | length 0 | ... | length n-1 | size | chunk size | magic |
+//
+// Lengths and the chunk size are 4 bytes, the size of the file 8 bytes, all big endian.
+const (
+	footerSize  = 16
+	indexEntry  = 4
+	footerMagic = 0x4246435a // "BFCZ"
+
+	// Metadata of a compressed blob
+	codecMeta = "bfcomp_codec" // codec the chunks are compressed with, marks the blob as compressed
+	sizeMeta  = "bfcomp_size"  // size of the file held in the blob
+	chunkMeta = "bfcomp_chunk" // size of the chunks the file is split in
+)
+
+// codec : Compresses the chunks of a file
+type codec interface {
+	name() string
+	compress(data []byte) ([]byte, error)
+	decompress(frame []byte, length int64) ([]byte, error)
+}
+
+// newCodec : Codec of the given name, level is used only to compress
+func newCodec(name string, level int) (codec, error) {
+	switch strings.ToLower(name) {
+	case "gzip":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, fmt.Errorf("gzip level shall be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+		}
+		return &gzipCodec{level: level}, nil
+	case "zstd":
+		// The zstd library at hand needs a newer Go than this module is built with
+		return nil, errors.New("algorithm zstd is not supported by this build, only gzip is")
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", name)
+	}
+}
+
+type gzipCodec struct {
+	level int
+}
+
+func (g *gzipCodec) name() string {
+	return "gzip"
+}
+
+func (g *gzipCodec) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.level)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	return buf.Bytes(), err
+}
+
+func (g *gzipCodec) decompress(frame []byte, length int64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// metadataValue : Metadata keys may come back from storage in any case
+func metadataValue(metadata map[string]string, key string) (string, bool) {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// isCompressionMeta : Whether the metadata key is one this component sets
+func isCompressionMeta(key string) bool {
+	return strings.EqualFold(key, codecMeta) || strings.EqualFold(key, sizeMeta) || strings.EqualFold(key, chunkMeta)
+}
+
+// compressedSize : Size of the file held in a compressed blob, false if the blob is not compressed
+func compressedSize(metadata map[string]string) (int64, bool) {
+	if _, found := metadataValue(metadata, codecMeta); !found {
+		return 0, false
+	}
+	value, _ := metadataValue(metadata, sizeMeta)
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// layout : Where the chunks of a file are in its compressed blob
+type layout struct {
+	codec     codec
+	size      int64
+	chunkSize int64
+	offsets   []int64 // offset of every frame in the blob, followed by the offset of the index
+}
+
+// chunks : Number of chunks of a file of the given size
+func chunks(size int64, chunkSize int64) int64 {
+	return (size + chunkSize - 1) / chunkSize
+}
+
+// chunkLength : Length of the chunk in the file
+func (l *layout) chunkLength(index int64) int64 {
+	length := l.size - index*l.chunkSize
+	if length > l.chunkSize {
+		return l.chunkSize
+	}
+	return length
+}
+
+// encodeIndex : Index and footer following the frames of the given lengths
+func encodeIndex(lengths []uint32, size int64, chunkSize int64) []byte {
+	buf := make([]byte, len(lengths)*indexEntry+footerSize)
+	for i, length := range lengths {
+		binary.BigEndian.PutUint32(buf[i*indexEntry:], length)
+	}
+	footer := buf[len(lengths)*indexEntry:]
+	binary.BigEndian.PutUint64(footer, uint64(size))
+	binary.BigEndian.PutUint32(footer[8:], uint32(chunkSize))
+	binary.BigEndian.PutUint32(footer[12:], footerMagic)
+	return buf
+}
+
+// tailSize : Length of the index and footer at the end of the blob holding a file of the given metadata
+func tailSize(metadata map[string]string) (int64, error) {
+	size, _ := compressedSize(metadata)
+	value, _ := metadataValue(metadata, chunkMeta)
+	chunkSize, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || chunkSize <= 0 {
+		log.Err("compression::tailSize : invalid chunk size in metadata [%s]", value)
+		return 0, syscall.EIO
+	}
+	return chunks(size, chunkSize)*indexEntry + footerSize, nil
+}
+
+// decodeLayout : Layout of a blob from its index and footer
+func decodeLayout(c codec, tail []byte, blobSize int64) (*layout, error) {
+	if len(tail) < footerSize {
+		return nil, syscall.EIO
+	}
+
+	footer := tail[len(tail)-footerSize:]
+	if binary.BigEndian.Uint32(footer[12:]) != footerMagic {
+		log.Err("compression::decodeLayout : blob has no compression footer")
+		return nil, syscall.EIO
+	}
+
+	l := &layout{
+		codec:     c,
+		size:      int64(binary.BigEndian.Uint64(footer)),
+		chunkSize: int64(binary.BigEndian.Uint32(footer[8:])),
+	}
+	if l.chunkSize <= 0 {
+		return nil, syscall.EIO
+	}
+
+	n := chunks(l.size, l.chunkSize)
+	if int64(len(tail)) != n*indexEntry+footerSize {
+		log.Err("compression::decodeLayout : index does not match the footer")
+		return nil, syscall.EIO
+	}
+
+	l.offsets = make([]int64, n+1)
+	for i := int64(0); i < n; i++ {
+		l.offsets[i+1] = l.offsets[i] + int64(binary.BigEndian.Uint32(tail[i*indexEntry:]))
+	}
+	if l.offsets[n]+int64(len(tail)) != blobSize {
+		log.Err("compression::decodeLayout : frames do not match the size of the blob")
+		return nil, syscall.EIO
+	}
+	return l, nil
+}
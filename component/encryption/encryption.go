@@ -0,0 +1,762 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+// Encryption : Encrypts files before they reach the storage. Every blob gets its own data key, which is kept
+// in the metadata of the blob wrapped with the configured key. Blobs without that metadata are read as they are.
+type Encryption struct {
+	internal.BaseComponent
+	kek       *keyEncryptionKey
+	chunkSize int64
+}
+
+// Structure defining your config parameters
+type EncryptionOptions struct {
+	KeyFile     string `config:"key-file" yaml:"key-file,omitempty"`
+	ChunkSizeKB uint64 `config:"chunk-size-kb" yaml:"chunk-size-kb,omitempty"`
+}
+
+const compName = "encryption"
+
+// Reads fetch whole chunks, so smaller chunks make random reads cheaper at the cost of more overhead
+const defaultChunkSizeKB = 256
+
+// Copies to and from local files are done in pieces of about this size
+const copyBufferSize = 8 * 1024 * 1024
+
+// encryptedHandle : State of a file opened through this component. The handle given to the components
+// above carries the plain size of the file, the handle of the next component the size of the blob.
+type encryptedHandle struct {
+	sync.RWMutex // a partial write of a chunk reads it, so writes are serialized against reads
+	inner        *handlemap.Handle
+	key          *dataKey // nil till the first write to an empty file
+}
+
+// Verification to check satisfaction criteria with Component Interface
+var _ internal.Component = &Encryption{}
+
+func (e *Encryption) Name() string {
+	return compName
+}
+
+func (e *Encryption) SetName(name string) {
+	e.BaseComponent.SetName(name)
+}
+
+func (e *Encryption) SetNextComponent(nc internal.Component) {
+	e.BaseComponent.SetNextComponent(nc)
+}
+
+// Priority : Same level as attr_cache, so the component can be placed on either side of it above azstorage
+func (e *Encryption) Priority() internal.ComponentPriority {
+	return internal.EComponentPriority.LevelTwo()
+}
+
+// Start : Pipeline calls this method to start the component functionality, this shall not block the call
+func (e *Encryption) Start(ctx context.Context) error {
+	log.Trace("Encryption::Start : Starting component %s", e.Name())
+	return nil
+}
+
+// Stop : Stop the component functionality and kill all threads started
+func (e *Encryption) Stop() error {
+	log.Trace("Encryption::Stop : Stopping component %s", e.Name())
+	return nil
+}
+
+// Configure : Pipeline will call this method after constructor so that you can read config and initialize yourself
+func (e *Encryption) Configure(_ bool) error {
+	log.Trace("Encryption::Configure : %s", e.Name())
+
+	conf := EncryptionOptions{}
+	err := config.UnmarshalKey(e.Name(), &conf)
+	if err != nil {
+		log.Err("Encryption::Configure : config error [invalid config attributes]")
+		return fmt.Errorf("config error in %s [%s]", e.Name(), err.Error())
+	}
+
+	key, err := loadKey(conf.KeyFile)
+	if err != nil {
+		log.Err("Encryption::Configure : config error [%s]", err.Error())
+		return fmt.Errorf("config error in %s [%s]", e.Name(), err.Error())
+	}
+
+	e.kek, err = newKeyEncryptionKey(key)
+	if err != nil {
+		log.Err("Encryption::Configure : config error [%s]", err.Error())
+		return fmt.Errorf("config error in %s [%s]", e.Name(), err.Error())
+	}
+
+	e.chunkSize = defaultChunkSizeKB * 1024
+	if config.IsSet(compName + ".chunk-size-kb") {
+		if conf.ChunkSizeKB == 0 {
+			log.Err("Encryption::Configure : config error [chunk-size-kb shall be more than 0]")
+			return fmt.Errorf("config error in %s [chunk-size-kb shall be more than 0]", e.Name())
+		}
+		e.chunkSize = int64(conf.ChunkSizeKB) * 1024
+	}
+
+	log.Info("Encryption::Configure : key-file %s, chunk-size-kb %d", conf.KeyFile, e.chunkSize/1024)
+
+	return nil
+}
+
+// encryptedHandleOf : State of the file, false if the handle is the one of a plain blob
+func encryptedHandleOf(handle *handlemap.Handle) (*encryptedHandle, bool) {
+	if handle == nil {
+		return nil, false
+	}
+	val, found := handle.GetValue(compName)
+	if !found {
+		return nil, false
+	}
+	return val.(*encryptedHandle), true
+}
+
+// innerHandle : Handle of the next component for the given handle
+func innerHandle(handle *handlemap.Handle) *handlemap.Handle {
+	if h, found := encryptedHandleOf(handle); found {
+		return h.inner
+	}
+	return handle
+}
+
+// newHandle : Wrap the handle of the next component for an encrypted or empty blob
+func newHandle(inner *handlemap.Handle, key *dataKey) *handlemap.Handle {
+	handle := handlemap.NewHandle(inner.Path)
+	handle.Mtime = inner.Mtime
+	if key != nil {
+		handle.Size = key.plainSize(inner.Size)
+	}
+	handle.SetValue(compName, &encryptedHandle{inner: inner, key: key})
+	return handle
+}
+
+// plainAttr : Attributes of the object with the size of the file held in it
+func plainAttr(attr *internal.ObjAttr) *internal.ObjAttr {
+	if attr.IsDir() {
+		return attr
+	}
+	chunkSize, found := chunkSizeOf(attr.Metadata)
+	if !found {
+		return attr
+	}
+
+	// Attributes may be cached by the next component, so they are not changed in place
+	plain := *attr
+	plain.Size = plainSize(attr.Size, chunkSize)
+	return &plain
+}
+
+// blobKey : Attributes and key of the blob, the key is nil if the blob is not encrypted
+func (e *Encryption) blobKey(name string) (*internal.ObjAttr, *dataKey, error) {
+	attr, err := e.NextComponent().GetAttr(internal.GetAttrOptions{Name: name, RetrieveMetadata: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := e.kek.unwrap(attr.Metadata)
+	if err != nil {
+		log.Err("Encryption::blobKey : failed to get key of %s [%s]", name, err.Error())
+		return nil, nil, err
+	}
+	return attr, key, nil
+}
+
+// readAt : Read the plain contents of a file of the given size from the blob
+func (e *Encryption) readAt(inner *handlemap.Handle, key *dataKey, size int64, p []byte, off int64) (int, error) {
+	if off >= size || len(p) == 0 {
+		return 0, nil
+	}
+	if off+int64(len(p)) > size {
+		p = p[:size-off]
+	}
+
+	end := off + int64(len(p))
+	first := off / key.chunkSize
+	last := (end - 1) / key.chunkSize
+	physical := key.physicalChunkSize()
+
+	start := first * physical
+	buf := make([]byte, last*physical+key.chunkLength(last, size)+chunkOverhead-start)
+	n, err := e.NextComponent().ReadInBuffer(internal.ReadInBufferOptions{Handle: inner, Offset: start, Data: buf})
+	if err != nil {
+		return 0, err
+	}
+	if n != len(buf) {
+		log.Err("Encryption::readAt : short read of %s at %d [%d of %d bytes]", inner.Path, start, n, len(buf))
+		return 0, syscall.EIO
+	}
+
+	n = 0
+	for index := first; index <= last; index++ {
+		pos := (index - first) * physical
+		data, err := key.open(index, buf[pos:pos+key.chunkLength(index, size)+chunkOverhead])
+		if err != nil {
+			return n, err
+		}
+
+		if index == first {
+			data = data[off-first*key.chunkSize:]
+		}
+		n += copy(p[n:], data)
+	}
+	return n, nil
+}
+
+// writeAt : Write to a file of the given size, chunks written in part are decrypted and encrypted again.
+// Chunks past the old end of the file are left as holes, which read as zeros. Returns the new size of the file.
+func (e *Encryption) writeAt(inner *handlemap.Handle, key *dataKey, size int64, p []byte, off int64) (int64, error) {
+	if len(p) == 0 {
+		return size, nil
+	}
+
+	if off > size && size%key.chunkSize != 0 && size/key.chunkSize < off/key.chunkSize {
+		// The old last chunk no longer is the last one, so it is filled up with zeros
+		var err error
+		size, err = e.writeAt(inner, key, size, make([]byte, key.chunkSize-size%key.chunkSize), size)
+		if err != nil {
+			return size, err
+		}
+	}
+
+	end := off + int64(len(p))
+	newSize := size
+	if end > newSize {
+		newSize = end
+	}
+
+	first := off / key.chunkSize
+	last := (end - 1) / key.chunkSize
+	start := first * key.chunkSize
+	plain := make([]byte, (last-first)*key.chunkSize+key.chunkLength(last, newSize))
+
+	// Parts of the first and the last chunk that are not written keep their data
+	if off > start && start < size {
+		_, err := e.readAt(inner, key, size, plain[:key.chunkLength(first, size)], start)
+		if err != nil {
+			return size, err
+		}
+	}
+	lastStart := last * key.chunkSize
+	if end < lastStart+key.chunkLength(last, size) && (last != first || off == start) {
+		_, err := e.readAt(inner, key, size, plain[lastStart-start:lastStart-start+key.chunkLength(last, size)], lastStart)
+		if err != nil {
+			return size, err
+		}
+	}
+	copy(plain[off-start:], p)
+
+	stored := make([]byte, 0, int64(len(plain))+(last-first+1)*chunkOverhead)
+	for index := first; index <= last; index++ {
+		from := (index - first) * key.chunkSize
+		var err error
+		stored, err = key.seal(stored, index, plain[from:from+key.chunkLength(index, newSize)])
+		if err != nil {
+			return size, err
+		}
+	}
+
+	// Storage replaces the metadata of the blob on a write, so the key is written along with the data
+	attr, err := e.NextComponent().GetAttr(internal.GetAttrOptions{Name: inner.Path, RetrieveMetadata: true})
+	if err != nil {
+		return size, err
+	}
+
+	_, err = e.NextComponent().WriteFile(internal.WriteFileOptions{
+		Handle:   inner,
+		Offset:   first * key.physicalChunkSize(),
+		Data:     stored,
+		Metadata: key.withMetadata(attr.Metadata),
+	})
+	if err != nil {
+		log.Err("Encryption::writeAt : failed to write %s at %d [%s]", inner.Path, off, err.Error())
+		return size, err
+	}
+
+	atomic.StoreInt64(&inner.Size, key.storedSize(newSize))
+	return newSize, nil
+}
+
+// resize : Change the size of a file of the given size
+func (e *Encryption) resize(inner *handlemap.Handle, key *dataKey, size int64, newSize int64) error {
+	if newSize >= size {
+		// Writing the last byte fills up the old last chunk and leaves the ones in between as holes
+		_, err := e.writeAt(inner, key, size, []byte{0}, newSize-1)
+		return err
+	}
+
+	// The chunk the file now ends in is cut and encrypted again, which also puts back the metadata
+	// storage drops when the blob is cut
+	index := (newSize - 1) / key.chunkSize
+	data := make([]byte, newSize-index*key.chunkSize)
+	_, err := e.readAt(inner, key, size, data, index*key.chunkSize)
+	if err != nil {
+		return err
+	}
+
+	err = e.NextComponent().TruncateFile(internal.TruncateFileOptions{Name: inner.Path, Size: index * key.physicalChunkSize()})
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&inner.Size, index*key.physicalChunkSize())
+
+	_, err = e.writeAt(inner, key, index*key.chunkSize, data, index*key.chunkSize)
+	return err
+}
+
+// ------------------------- Directory operations -------------------------------------------
+
+func (e *Encryption) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAttr, error) {
+	list, err := e.NextComponent().ReadDir(options)
+	for i, attr := range list {
+		list[i] = plainAttr(attr)
+	}
+	return list, err
+}
+
+func (e *Encryption) StreamDir(options internal.StreamDirOptions) ([]*internal.ObjAttr, string, error) {
+	list, token, err := e.NextComponent().StreamDir(options)
+	for i, attr := range list {
+		list[i] = plainAttr(attr)
+	}
+	return list, token, err
+}
+
+// ------------------------- File operations -------------------------------------------
+
+func (e *Encryption) CreateFile(options internal.CreateFileOptions) (*handlemap.Handle, error) {
+	log.Trace("Encryption::CreateFile : %s", options.Name)
+
+	inner, err := e.NextComponent().CreateFile(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key is generated on the first write, so an empty file does not need one
+	return newHandle(inner, nil), nil
+}
+
+func (e *Encryption) OpenFile(options internal.OpenFileOptions) (*handlemap.Handle, error) {
+	log.Trace("Encryption::OpenFile : %s", options.Name)
+
+	attr, key, err := e.blobKey(options.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, err := e.NextComponent().OpenFile(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil && attr.Size > 0 {
+		// Plain blob, it is read and written as it is
+		return inner, nil
+	}
+	return newHandle(inner, key), nil
+}
+
+func (e *Encryption) CloseFile(options internal.CloseFileOptions) error {
+	return e.NextComponent().CloseFile(internal.CloseFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (e *Encryption) ReadFile(options internal.ReadFileOptions) ([]byte, error) {
+	h, found := encryptedHandleOf(options.Handle)
+	if !found {
+		return e.NextComponent().ReadFile(options)
+	}
+
+	h.RLock()
+	defer h.RUnlock()
+
+	data := make([]byte, atomic.LoadInt64(&options.Handle.Size))
+	if h.key == nil {
+		return data, nil
+	}
+
+	n, err := e.readAt(h.inner, h.key, int64(len(data)), data, 0)
+	return data[:n], err
+}
+
+func (e *Encryption) ReadInBuffer(options internal.ReadInBufferOptions) (int, error) {
+	h, found := encryptedHandleOf(options.Handle)
+	if !found {
+		return e.NextComponent().ReadInBuffer(options)
+	}
+
+	h.RLock()
+	defer h.RUnlock()
+
+	if h.key == nil {
+		return 0, nil
+	}
+	return e.readAt(h.inner, h.key, atomic.LoadInt64(&options.Handle.Size), options.Data, options.Offset)
+}
+
+func (e *Encryption) WriteFile(options internal.WriteFileOptions) (int, error) {
+	h, found := encryptedHandleOf(options.Handle)
+	if !found {
+		return e.NextComponent().WriteFile(options)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.key == nil {
+		// The blob may have got a key since it was opened empty, chunks of a blob shall all use the same key
+		attr, key, err := e.blobKey(options.Handle.Path)
+		if err != nil {
+			return 0, err
+		}
+		if key == nil {
+			key, err = e.kek.newDataKey(e.chunkSize)
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			atomic.StoreInt64(&h.inner.Size, attr.Size)
+			atomic.StoreInt64(&options.Handle.Size, key.plainSize(attr.Size))
+		}
+		h.key = key
+	}
+
+	size, err := e.writeAt(h.inner, h.key, atomic.LoadInt64(&options.Handle.Size), options.Data, options.Offset)
+	if err != nil {
+		return 0, err
+	}
+	atomic.StoreInt64(&options.Handle.Size, size)
+
+	return len(options.Data), nil
+}
+
+// FlushFile : Writes reach the storage as they are made, so there is nothing to flush for encrypted files
+func (e *Encryption) FlushFile(options internal.FlushFileOptions) error {
+	if _, found := encryptedHandleOf(options.Handle); found {
+		return nil
+	}
+	return e.NextComponent().FlushFile(options)
+}
+
+func (e *Encryption) SyncFile(options internal.SyncFileOptions) error {
+	return e.NextComponent().SyncFile(internal.SyncFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (e *Encryption) ReleaseFile(options internal.ReleaseFileOptions) error {
+	return e.NextComponent().ReleaseFile(internal.ReleaseFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (e *Encryption) LockFile(options internal.LockFileOptions) error {
+	options.Handle = innerHandle(options.Handle)
+	return e.NextComponent().LockFile(options)
+}
+
+func (e *Encryption) UnlockFile(options internal.UnlockFileOptions) error {
+	return e.NextComponent().UnlockFile(internal.UnlockFileOptions{Handle: innerHandle(options.Handle)})
+}
+
+func (e *Encryption) CopyObject(options internal.CopyObjectOptions) error {
+	// The copy gets the metadata of the source, so it can be decrypted with the same key
+	options.SrcHandle = innerHandle(options.SrcHandle)
+	options.DstHandle = innerHandle(options.DstHandle)
+	return e.NextComponent().CopyObject(options)
+}
+
+func (e *Encryption) TruncateFile(options internal.TruncateFileOptions) error {
+	log.Trace("Encryption::TruncateFile : %s to %d bytes", options.Name, options.Size)
+
+	attr, key, err := e.blobKey(options.Name)
+	if err != nil {
+		return err
+	}
+
+	if options.Size == 0 || (key == nil && attr.Size > 0) {
+		// An empty blob needs no key and a plain blob stays plain
+		return e.NextComponent().TruncateFile(options)
+	}
+
+	if key == nil {
+		key, err = e.kek.newDataKey(e.chunkSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	inner, err := e.NextComponent().OpenFile(internal.OpenFileOptions{Name: options.Name, Flags: os.O_RDWR})
+	if err != nil {
+		return err
+	}
+	defer e.NextComponent().CloseFile(internal.CloseFileOptions{Handle: inner}) //nolint
+
+	err = e.resize(inner, key, key.plainSize(inner.Size), options.Size)
+	if err != nil {
+		log.Err("Encryption::TruncateFile : failed to truncate %s [%s]", options.Name, err.Error())
+	}
+	return err
+}
+
+// FallocateFile : Chunks are not kept sparse in the blob, so only a change in size is applied
+func (e *Encryption) FallocateFile(options internal.FallocateFileOptions) error {
+	log.Trace("Encryption::FallocateFile : %s offset %d, length %d", options.Name, options.Offset, options.Length)
+
+	attr, key, err := e.blobKey(options.Name)
+	if err != nil {
+		return err
+	}
+
+	if key == nil && attr.Size > 0 {
+		options.Handle = innerHandle(options.Handle)
+		return e.NextComponent().FallocateFile(options)
+	}
+
+	if options.PunchHole {
+		return syscall.EOPNOTSUPP
+	}
+
+	size := int64(0)
+	if key != nil {
+		size = key.plainSize(attr.Size)
+	}
+
+	end := options.Offset + options.Length
+	if options.KeepSize || end <= size {
+		return nil
+	}
+	return e.TruncateFile(internal.TruncateFileOptions{Name: options.Name, Size: end})
+}
+
+// SeekFile : Chunks do not keep the holes of the file, so all of an encrypted file is data
+func (e *Encryption) SeekFile(options internal.SeekFileOptions) (int64, error) {
+	var size int64
+	if _, found := encryptedHandleOf(options.Handle); found {
+		size = atomic.LoadInt64(&options.Handle.Size)
+	} else {
+		attr, key, err := e.blobKey(options.Name)
+		if err != nil {
+			return 0, err
+		}
+		if key == nil {
+			return e.NextComponent().SeekFile(options)
+		}
+		size = key.plainSize(attr.Size)
+	}
+
+	if options.Offset >= size {
+		return 0, syscall.ENXIO
+	}
+	if options.Whence == common.SeekData {
+		return options.Offset, nil
+	}
+	return size, nil
+}
+
+// GetFileBlockOffsets : Blocks of an encrypted blob do not map to the file, so it is reported as a small file
+// which components above read and write as a whole
+func (e *Encryption) GetFileBlockOffsets(options internal.GetFileBlockOffsetsOptions) (*common.BlockOffsetList, error) {
+	attr, err := e.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name, RetrieveMetadata: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := chunkSizeOf(attr.Metadata); !found {
+		return e.NextComponent().GetFileBlockOffsets(options)
+	}
+
+	bol := &common.BlockOffsetList{}
+	bol.Flags.Set(common.SmallFile)
+	return bol, nil
+}
+
+// CopyToFile : Decrypt the blob into the local file
+func (e *Encryption) CopyToFile(options internal.CopyToFileOptions) error {
+	log.Trace("Encryption::CopyToFile : %s", options.Name)
+
+	_, key, err := e.blobKey(options.Name)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return e.NextComponent().CopyToFile(options)
+	}
+
+	inner, err := e.NextComponent().OpenFile(internal.OpenFileOptions{Name: options.Name, Flags: os.O_RDONLY})
+	if err != nil {
+		return err
+	}
+	defer e.NextComponent().CloseFile(internal.CloseFileOptions{Handle: inner}) //nolint
+
+	size := key.plainSize(inner.Size)
+	end := size
+	if options.Count > 0 && options.Offset+options.Count < end {
+		end = options.Offset + options.Count
+	}
+	if end < options.Offset {
+		end = options.Offset
+	}
+
+	err = options.File.Truncate(end - options.Offset)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, maxInt64(copyBufferSize/key.chunkSize, 1)*key.chunkSize)
+	for pos := options.Offset; pos < end; {
+		length := int64(len(buf))
+		if end-pos < length {
+			length = end - pos
+		}
+
+		n, err := e.readAt(inner, key, size, buf[:length], pos)
+		if err == nil && n == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			_, err = options.File.WriteAt(buf[:n], pos-options.Offset)
+		}
+		if err != nil {
+			log.Err("Encryption::CopyToFile : failed to copy %s at %d [%s]", options.Name, pos, err.Error())
+			return err
+		}
+		pos += int64(n)
+	}
+
+	return nil
+}
+
+// CopyFromFile : Upload the local file encrypted with a new key. The encrypted copy is staged in a temporary
+// file next to the local one, which is removed as soon as it is created.
+func (e *Encryption) CopyFromFile(options internal.CopyFromFileOptions) error {
+	log.Trace("Encryption::CopyFromFile : %s", options.Name)
+
+	key, err := e.kek.newDataKey(e.chunkSize)
+	if err != nil {
+		return err
+	}
+
+	src, size, err := options.Source()
+	if err != nil {
+		return err
+	}
+
+	near := os.TempDir()
+	if options.File != nil {
+		near = filepath.Dir(options.File.Name())
+	}
+	staged, err := os.CreateTemp(near, ".blobfuse2-encryption-*")
+	if err != nil {
+		staged, err = os.CreateTemp("", "blobfuse2-encryption-*")
+		if err != nil {
+			return err
+		}
+	}
+	_ = os.Remove(staged.Name())
+	defer staged.Close()
+
+	buf := make([]byte, key.chunkSize)
+	sealed := make([]byte, 0, key.physicalChunkSize())
+	for index := int64(0); index*key.chunkSize < size; index++ {
+		data := buf[:key.chunkLength(index, size)]
+		_, err = src.ReadAt(data, index*key.chunkSize)
+		if err == nil {
+			sealed, err = key.seal(sealed[:0], index, data)
+		}
+		if err == nil {
+			_, err = staged.Write(sealed)
+		}
+		if err != nil {
+			log.Err("Encryption::CopyFromFile : failed to encrypt %s [%s]", options.Name, err.Error())
+			return err
+		}
+	}
+
+	_, err = staged.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	return e.NextComponent().CopyFromFile(internal.CopyFromFileOptions{
+		Name:     options.Name,
+		File:     staged,
+		Metadata: key.withMetadata(options.Metadata),
+	})
+}
+
+// ------------------------- Attribute operations -------------------------------------------
+
+func (e *Encryption) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr, error) {
+	attr, err := e.NextComponent().GetAttr(options)
+	if err != nil {
+		return attr, err
+	}
+	return plainAttr(attr), nil
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ------------------------- Factory -------------------------------------------
+
+// Pipeline will call this method to create your object, initialize your variables here
+// << DO NOT DELETE ANY AUTO GENERATED CODE HERE >>
+func NewEncryptionComponent() internal.Component {
+	comp := &Encryption{}
+	comp.SetName(compName)
+	return comp
+}
+
+// On init register this component to pipeline and supply your constructor
+func init() {
+	internal.AddComponent(compName, NewEncryptionComponent)
+}
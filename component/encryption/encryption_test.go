@@ -0,0 +1,648 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// memStorage : Keeps blobs in memory and, same as the storage, replaces their metadata on every write
+type memStorage struct {
+	internal.BaseComponent
+	sync.Mutex
+	blobs map[string]*memBlob
+}
+
+type memBlob struct {
+	data     []byte
+	metadata map[string]string
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{blobs: make(map[string]*memBlob)}
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]string)
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (m *memStorage) blob(name string) (*memBlob, error) {
+	blob, found := m.blobs[name]
+	if !found {
+		return nil, syscall.ENOENT
+	}
+	return blob, nil
+}
+
+func (m *memStorage) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &internal.ObjAttr{
+		Path:     options.Name,
+		Name:     filepath.Base(options.Name),
+		Size:     int64(len(blob.data)),
+		Mode:     0644,
+		Flags:    internal.NewFileBitMap(),
+		Metadata: copyMetadata(blob.metadata),
+	}, nil
+}
+
+func (m *memStorage) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAttr, error) {
+	names := make([]string, 0)
+	m.Lock()
+	for name := range m.blobs {
+		names = append(names, name)
+	}
+	m.Unlock()
+	sort.Strings(names)
+
+	list := make([]*internal.ObjAttr, 0)
+	for _, name := range names {
+		attr, _ := m.GetAttr(internal.GetAttrOptions{Name: name})
+		list = append(list, attr)
+	}
+	return list, nil
+}
+
+func (m *memStorage) CreateFile(options internal.CreateFileOptions) (*handlemap.Handle, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.blobs[options.Name] = &memBlob{data: []byte{}}
+	return handlemap.NewHandle(options.Name), nil
+}
+
+func (m *memStorage) OpenFile(options internal.OpenFileOptions) (*handlemap.Handle, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return nil, err
+	}
+	handle := handlemap.NewHandle(options.Name)
+	handle.Size = int64(len(blob.data))
+	return handle, nil
+}
+
+func (m *memStorage) ReadInBuffer(options internal.ReadInBufferOptions) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Handle.Path)
+	if err != nil {
+		return 0, err
+	}
+	if options.Offset > options.Handle.Size {
+		return 0, syscall.ERANGE
+	}
+	data := blob.data[:options.Handle.Size]
+	return copy(options.Data, data[options.Offset:]), nil
+}
+
+func (m *memStorage) ReadFile(options internal.ReadFileOptions) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Handle.Path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, blob.data...), nil
+}
+
+func (m *memStorage) WriteFile(options internal.WriteFileOptions) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Handle.Path)
+	if err != nil {
+		return 0, err
+	}
+	end := options.Offset + int64(len(options.Data))
+	if end > int64(len(blob.data)) {
+		blob.data = append(blob.data, make([]byte, end-int64(len(blob.data)))...)
+	}
+	copy(blob.data[options.Offset:], options.Data)
+	blob.metadata = copyMetadata(options.Metadata)
+	return len(options.Data), nil
+}
+
+func (m *memStorage) TruncateFile(options internal.TruncateFileOptions) error {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return err
+	}
+	if options.Size > int64(len(blob.data)) {
+		blob.data = append(blob.data, make([]byte, options.Size-int64(len(blob.data)))...)
+	}
+	blob.data = blob.data[:options.Size]
+	blob.metadata = nil
+	return nil
+}
+
+func (m *memStorage) CopyFromFile(options internal.CopyFromFileOptions) error {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/self/fd/%d", options.File.Fd()))
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.blobs[options.Name] = &memBlob{data: data, metadata: copyMetadata(options.Metadata)}
+	return nil
+}
+
+func (m *memStorage) CopyToFile(options internal.CopyToFileOptions) error {
+	m.Lock()
+	defer m.Unlock()
+	blob, err := m.blob(options.Name)
+	if err != nil {
+		return err
+	}
+	err = options.File.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = options.File.WriteAt(blob.data, 0)
+	return err
+}
+
+type encryptionTestSuite struct {
+	suite.Suite
+	assert     *assert.Assertions
+	encryption *Encryption
+	storage    *memStorage
+	keyFile    string
+}
+
+const testKey = "0123456789abcdef0123456789abcdef"
+
+func newTestEncryption(next internal.Component, configuration string) (*Encryption, error) {
+	_ = config.ReadConfigFromReader(strings.NewReader(configuration))
+	encryption := NewEncryptionComponent()
+	encryption.SetNextComponent(next)
+	err := encryption.Configure(true)
+
+	return encryption.(*Encryption), err
+}
+
+func (suite *encryptionTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+
+	suite.keyFile = filepath.Join(suite.T().TempDir(), "kek")
+	err := os.WriteFile(suite.keyFile, []byte(testKey+"\n"), 0600)
+	suite.assert.Nil(err)
+
+	suite.storage = newMemStorage()
+	suite.encryption, err = newTestEncryption(suite.storage, suite.config(4))
+	suite.assert.Nil(err)
+}
+
+func (suite *encryptionTestSuite) config(chunkSizeKB int) string {
+	return fmt.Sprintf("encryption:\n  key-file: %s\n  chunk-size-kb: %d\n", suite.keyFile, chunkSizeKB)
+}
+
+func randomData(size int) []byte {
+	data := make([]byte, size)
+	_, _ = rand.Read(data)
+	return data
+}
+
+// upload : Upload the data through the component as file_cache does
+func (suite *encryptionTestSuite) upload(name string, data []byte, metadata map[string]string) {
+	f, err := os.CreateTemp(suite.T().TempDir(), "upload")
+	suite.assert.Nil(err)
+	defer f.Close()
+
+	_, err = f.Write(data)
+	suite.assert.Nil(err)
+	_, err = f.Seek(0, 0)
+	suite.assert.Nil(err)
+
+	err = suite.encryption.CopyFromFile(internal.CopyFromFileOptions{Name: name, File: f, Metadata: metadata})
+	suite.assert.Nil(err)
+}
+
+// download : Download the file through the component as file_cache does
+func (suite *encryptionTestSuite) download(name string) []byte {
+	f, err := os.CreateTemp(suite.T().TempDir(), "download")
+	suite.assert.Nil(err)
+	defer f.Close()
+
+	err = suite.encryption.CopyToFile(internal.CopyToFileOptions{Name: name, File: f})
+	suite.assert.Nil(err)
+
+	data, err := os.ReadFile(f.Name())
+	suite.assert.Nil(err)
+	return data
+}
+
+// read : Read the whole file through a handle of the component
+func (suite *encryptionTestSuite) read(name string) []byte {
+	handle, err := suite.encryption.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDONLY})
+	suite.assert.Nil(err)
+	defer suite.encryption.CloseFile(internal.CloseFileOptions{Handle: handle}) //nolint
+
+	data, err := suite.encryption.ReadFile(internal.ReadFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	return data
+}
+
+func (suite *encryptionTestSuite) TestConfigNoKey() {
+	defer suite.cleanupTest()
+	_, err := newTestEncryption(suite.storage, "encryption:\n  chunk-size-kb: 4\n")
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "no key")
+}
+
+func (suite *encryptionTestSuite) TestConfigInvalidKey() {
+	defer suite.cleanupTest()
+	err := os.WriteFile(suite.keyFile, []byte("short"), 0600)
+	suite.assert.Nil(err)
+
+	_, err = newTestEncryption(suite.storage, suite.config(4))
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "16, 24 or 32 bytes")
+}
+
+func (suite *encryptionTestSuite) TestConfigChunkSize() {
+	defer suite.cleanupTest()
+	suite.assert.EqualValues(4*1024, suite.encryption.chunkSize)
+
+	_, err := newTestEncryption(suite.storage, suite.config(0))
+	suite.assert.NotNil(err)
+
+	encryption, err := newTestEncryption(suite.storage, fmt.Sprintf("encryption:\n  key-file: %s\n", suite.keyFile))
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(defaultChunkSizeKB*1024, encryption.chunkSize)
+}
+
+func (suite *encryptionTestSuite) TestSizes() {
+	defer suite.cleanupTest()
+	key, err := suite.encryption.kek.newDataKey(4096)
+	suite.assert.Nil(err)
+
+	for _, size := range []int64{0, 1, 4095, 4096, 4097, 8192, 10000} {
+		suite.assert.EqualValues(size, key.plainSize(key.storedSize(size)), size)
+	}
+	suite.assert.EqualValues(4096+chunkOverhead+1+chunkOverhead, key.storedSize(4097))
+}
+
+func (suite *encryptionTestSuite) TestUploadDownload() {
+	defer suite.cleanupTest()
+	name := "file"
+	data := randomData(10*4096 + 100)
+	suite.upload(name, data, map[string]string{"bfxattr_source": "dGVzdA=="})
+
+	// Storage holds the encrypted chunks along with the wrapped key
+	blob := suite.storage.blobs[name]
+	suite.assert.EqualValues(11*(4096+chunkOverhead)-4096+100, len(blob.data))
+	suite.assert.False(bytes.Contains(blob.data, data[:64]))
+	suite.assert.Contains(blob.metadata, wrappedKeyMeta)
+	suite.assert.EqualValues("4096", blob.metadata[chunkSizeMeta])
+	suite.assert.EqualValues(suite.encryption.kek.id, blob.metadata[keyIDMeta])
+	suite.assert.EqualValues("dGVzdA==", blob.metadata["bfxattr_source"])
+
+	attr, err := suite.encryption.GetAttr(internal.GetAttrOptions{Name: name})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	suite.assert.Equal(data, suite.download(name))
+	suite.assert.Equal(data, suite.read(name))
+
+	// Every upload gets a new key
+	wrapped := blob.metadata[wrappedKeyMeta]
+	suite.upload(name, data, nil)
+	suite.assert.NotEqual(wrapped, suite.storage.blobs[name].metadata[wrappedKeyMeta])
+	suite.assert.Equal(data, suite.download(name))
+}
+
+func (suite *encryptionTestSuite) TestUploadEmpty() {
+	defer suite.cleanupTest()
+	suite.upload("empty", []byte{}, nil)
+	suite.assert.Len(suite.storage.blobs["empty"].data, 0)
+	suite.assert.Contains(suite.storage.blobs["empty"].metadata, wrappedKeyMeta)
+
+	suite.assert.Len(suite.download("empty"), 0)
+	suite.assert.Len(suite.read("empty"), 0)
+}
+
+func (suite *encryptionTestSuite) TestDownloadRange() {
+	defer suite.cleanupTest()
+	data := randomData(5 * 4096)
+	suite.upload("file", data, nil)
+
+	f, err := os.CreateTemp(suite.T().TempDir(), "download")
+	suite.assert.Nil(err)
+	defer f.Close()
+
+	err = suite.encryption.CopyToFile(internal.CopyToFileOptions{Name: "file", Offset: 5000, Count: 7000, File: f})
+	suite.assert.Nil(err)
+
+	downloaded, err := os.ReadFile(f.Name())
+	suite.assert.Nil(err)
+	suite.assert.Equal(data[5000:12000], downloaded)
+}
+
+func (suite *encryptionTestSuite) TestRandomReads() {
+	defer suite.cleanupTest()
+	data := randomData(20*4096 + 123)
+	suite.upload("file", data, nil)
+
+	handle, err := suite.encryption.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), handle.Size)
+
+	for i := 0; i < 200; i++ {
+		off := mrand.Int63n(int64(len(data)))
+		buf := make([]byte, mrand.Intn(3*4096)+1)
+		n, err := suite.encryption.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: off, Data: buf})
+		suite.assert.Nil(err)
+
+		end := off + int64(len(buf))
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		suite.assert.EqualValues(end-off, n)
+		suite.assert.Equal(data[off:end], buf[:n], "offset %d, length %d", off, len(buf))
+	}
+
+	n, err := suite.encryption.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: int64(len(data)), Data: make([]byte, 10)})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(0, n)
+
+	err = suite.encryption.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+}
+
+func (suite *encryptionTestSuite) TestRandomWrites() {
+	defer suite.cleanupTest()
+	name := "file"
+	handle, err := suite.encryption.CreateFile(internal.CreateFileOptions{Name: name, Mode: 0644})
+	suite.assert.Nil(err)
+	suite.assert.Len(suite.storage.blobs[name].metadata, 0)
+
+	model := make([]byte, 0)
+	for i := 0; i < 100; i++ {
+		switch mrand.Intn(4) {
+		case 0:
+			// Truncate, storage drops the metadata of the blob when it is cut
+			size := mrand.Int63n(12 * 4096)
+			err = suite.encryption.TruncateFile(internal.TruncateFileOptions{Name: name, Size: size})
+			suite.assert.Nil(err)
+			if size > int64(len(model)) {
+				model = append(model, make([]byte, size-int64(len(model)))...)
+			}
+			model = model[:size]
+
+			// Handles opened before see the old size, so the file is written through a new one
+			suite.assert.Nil(suite.encryption.CloseFile(internal.CloseFileOptions{Handle: handle}))
+			handle, err = suite.encryption.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDWR})
+			suite.assert.Nil(err)
+		default:
+			// Write anywhere, including past the end of the file
+			off := mrand.Int63n(10 * 4096)
+			data := randomData(mrand.Intn(2*4096) + 1)
+			n, err := suite.encryption.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: off, Data: data})
+			suite.assert.Nil(err)
+			suite.assert.EqualValues(len(data), n)
+
+			end := off + int64(len(data))
+			if end > int64(len(model)) {
+				model = append(model, make([]byte, end-int64(len(model)))...)
+			}
+			copy(model[off:], data)
+		}
+
+		suite.assert.EqualValues(len(model), handle.Size)
+		attr, err := suite.encryption.GetAttr(internal.GetAttrOptions{Name: name})
+		suite.assert.Nil(err)
+		suite.assert.EqualValues(len(model), attr.Size, "step %d", i)
+		suite.assert.Equal(model, suite.read(name), "step %d", i)
+	}
+
+	suite.assert.Nil(suite.encryption.CloseFile(internal.CloseFileOptions{Handle: handle}))
+	suite.assert.Equal(model, suite.download(name))
+}
+
+func (suite *encryptionTestSuite) TestHolesReadAsZeros() {
+	defer suite.cleanupTest()
+	handle, err := suite.encryption.CreateFile(internal.CreateFileOptions{Name: "file", Mode: 0644})
+	suite.assert.Nil(err)
+
+	_, err = suite.encryption.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("head")})
+	suite.assert.Nil(err)
+	_, err = suite.encryption.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 10 * 4096, Data: []byte("tail")})
+	suite.assert.Nil(err)
+
+	// Only the chunks written are in storage, the ones in between are zeros
+	blob := suite.storage.blobs["file"]
+	suite.assert.True(bytes.Equal(blob.data[2*(4096+chunkOverhead):10*(4096+chunkOverhead)], make([]byte, 8*(4096+chunkOverhead))))
+
+	expected := make([]byte, 10*4096+4)
+	copy(expected, "head")
+	copy(expected[10*4096:], "tail")
+	suite.assert.Equal(expected, suite.read("file"))
+}
+
+func (suite *encryptionTestSuite) TestWriteGetsKeyOfBlob() {
+	defer suite.cleanupTest()
+	handle, err := suite.encryption.CreateFile(internal.CreateFileOptions{Name: "file", Mode: 0644})
+	suite.assert.Nil(err)
+
+	// File gets a key through another path while this handle is open
+	err = suite.encryption.TruncateFile(internal.TruncateFileOptions{Name: "file", Size: 10})
+	suite.assert.Nil(err)
+
+	_, err = suite.encryption.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 10, Data: []byte("data")})
+	suite.assert.Nil(err)
+	suite.assert.Equal(append(make([]byte, 10), []byte("data")...), suite.read("file"))
+}
+
+func (suite *encryptionTestSuite) TestPlainBlob() {
+	defer suite.cleanupTest()
+	data := []byte("stored before encryption was turned on")
+	suite.storage.blobs["plain"] = &memBlob{data: append([]byte{}, data...)}
+
+	attr, err := suite.encryption.GetAttr(internal.GetAttrOptions{Name: "plain"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	handle, err := suite.encryption.OpenFile(internal.OpenFileOptions{Name: "plain", Flags: os.O_RDWR})
+	suite.assert.Nil(err)
+	_, found := encryptedHandleOf(handle)
+	suite.assert.False(found)
+	suite.assert.Equal(data, suite.read("plain"))
+	suite.assert.Equal(data, suite.download("plain"))
+
+	// Writes in place keep the blob plain, a new upload encrypts it
+	_, err = suite.encryption.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("STORED")})
+	suite.assert.Nil(err)
+	suite.assert.True(bytes.HasPrefix(suite.storage.blobs["plain"].data, []byte("STORED")))
+
+	suite.upload("plain", data, nil)
+	suite.assert.False(bytes.Equal(data, suite.storage.blobs["plain"].data))
+	suite.assert.Equal(data, suite.read("plain"))
+}
+
+func (suite *encryptionTestSuite) TestReadDir() {
+	defer suite.cleanupTest()
+	suite.upload("a", randomData(5000), nil)
+	suite.storage.blobs["b"] = &memBlob{data: randomData(300)}
+	suite.upload("c", randomData(3*4096), nil)
+
+	list, err := suite.encryption.ReadDir(internal.ReadDirOptions{Name: ""})
+	suite.assert.Nil(err)
+	suite.assert.Len(list, 3)
+	suite.assert.EqualValues(5000, list[0].Size)
+	suite.assert.EqualValues(300, list[1].Size)
+	suite.assert.EqualValues(3*4096, list[2].Size)
+
+	// Attributes of the next component are left as they are
+	suite.assert.EqualValues(3*(4096+chunkOverhead), len(suite.storage.blobs["c"].data))
+}
+
+func (suite *encryptionTestSuite) TestWrongKey() {
+	defer suite.cleanupTest()
+	data := randomData(5000)
+	suite.upload("file", data, nil)
+
+	err := os.WriteFile(suite.keyFile, []byte("fedcba9876543210fedcba9876543210"), 0600)
+	suite.assert.Nil(err)
+	other, err := newTestEncryption(suite.storage, suite.config(4))
+	suite.assert.Nil(err)
+
+	// Size is known without the key, the contents are not
+	attr, err := other.GetAttr(internal.GetAttrOptions{Name: "file"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	_, err = other.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	suite.assert.Equal(syscall.EACCES, err)
+
+	f, err := os.CreateTemp(suite.T().TempDir(), "download")
+	suite.assert.Nil(err)
+	defer f.Close()
+	err = other.CopyToFile(internal.CopyToFileOptions{Name: "file", File: f})
+	suite.assert.Equal(syscall.EACCES, err)
+}
+
+func (suite *encryptionTestSuite) TestTamperedBlob() {
+	defer suite.cleanupTest()
+	suite.upload("file", randomData(3*4096), nil)
+	suite.storage.blobs["file"].data[4096+chunkOverhead+100] ^= 1
+
+	handle, err := suite.encryption.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	suite.assert.Nil(err)
+
+	// Only the chunk that was changed fails
+	buf := make([]byte, 100)
+	_, err = suite.encryption.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 0, Data: buf})
+	suite.assert.Nil(err)
+	_, err = suite.encryption.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 4096, Data: buf})
+	suite.assert.Equal(syscall.EIO, err)
+
+	// Layout of the blob is authenticated along with its key
+	suite.upload("file", randomData(3*4096), nil)
+	suite.storage.blobs["file"].metadata[chunkSizeMeta] = "2048"
+	_, err = suite.encryption.OpenFile(internal.OpenFileOptions{Name: "file", Flags: os.O_RDONLY})
+	suite.assert.Equal(syscall.EIO, err)
+}
+
+func (suite *encryptionTestSuite) TestFallocateAndSeek() {
+	defer suite.cleanupTest()
+	suite.upload("file", randomData(5000), nil)
+
+	err := suite.encryption.FallocateFile(internal.FallocateFileOptions{Name: "file", Offset: 4000, Length: 4000, PunchHole: true, KeepSize: true})
+	suite.assert.Equal(syscall.EOPNOTSUPP, err)
+
+	err = suite.encryption.FallocateFile(internal.FallocateFileOptions{Name: "file", Offset: 0, Length: 9000, KeepSize: true})
+	suite.assert.Nil(err)
+	attr, _ := suite.encryption.GetAttr(internal.GetAttrOptions{Name: "file"})
+	suite.assert.EqualValues(5000, attr.Size)
+
+	err = suite.encryption.FallocateFile(internal.FallocateFileOptions{Name: "file", Offset: 0, Length: 9000})
+	suite.assert.Nil(err)
+	attr, _ = suite.encryption.GetAttr(internal.GetAttrOptions{Name: "file"})
+	suite.assert.EqualValues(9000, attr.Size)
+
+	offset, err := suite.encryption.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 6000, Whence: common.SeekData})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(6000, offset)
+	offset, err = suite.encryption.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 10, Whence: common.SeekHole})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(9000, offset)
+	_, err = suite.encryption.SeekFile(internal.SeekFileOptions{Name: "file", Offset: 9000, Whence: common.SeekData})
+	suite.assert.Equal(syscall.ENXIO, err)
+
+	bol, err := suite.encryption.GetFileBlockOffsets(internal.GetFileBlockOffsetsOptions{Name: "file"})
+	suite.assert.Nil(err)
+	suite.assert.True(bol.SmallFile())
+}
+
+func (suite *encryptionTestSuite) cleanupTest() {
+	_ = suite.encryption.Stop()
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestEncryptionTestSuite(t *testing.T) {
+	suite.Run(t, new(encryptionTestSuite))
+}
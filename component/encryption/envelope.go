@@ -0,0 +1,317 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+)
+
+const (
+	// Every chunk in the blob is prefixed by its nonce and followed by its authentication tag
+	nonceSize     = 12
+	chunkOverhead = nonceSize + 16
+
+	dataKeySize = 32
+
+	// Metadata of an encrypted blob
+	wrappedKeyMeta = "bfenc_key"   // data key of the blob, encrypted with the key encryption key
+	keyIDMeta      = "bfenc_kid"   // identifies the key encryption key the data key is wrapped with
+	chunkSizeMeta  = "bfenc_chunk" // size of the plain chunks the blob is encrypted in
+)
+
+// keyEncryptionKey : Wraps the data keys of the blobs, it never encrypts data by itself
+type keyEncryptionKey struct {
+	aead cipher.AEAD
+	id   string // stored along with the wrapped keys, without revealing the key
+}
+
+// dataKey : Key a blob is encrypted with chunk by chunk using AES-GCM.
+// Chunks are authenticated along with their index so they can not be reordered within a blob.
+type dataKey struct {
+	aead      cipher.AEAD
+	chunkSize int64
+	metadata  map[string]string // metadata the blob needs to be decrypted again
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newKeyEncryptionKey(key []byte) (*keyEncryptionKey, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(compName))
+	return &keyEncryptionKey{aead: aead, id: hex.EncodeToString(mac.Sum(nil)[:8])}, nil
+}
+
+// loadKey : Key encryption key from the key file, it shall be 16, 24 or 32 bytes.
+// Without a key file the passphrase of the secure config is used.
+func loadKey(keyFile string) ([]byte, error) {
+	key := []byte(config.SecurePassphrase())
+	if keyFile != "" {
+		data, err := os.ReadFile(common.ExpandPath(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file [%s]", err.Error())
+		}
+		key = bytes.TrimRight(data, "\r\n")
+	} else if len(key) == 0 {
+		return nil, fmt.Errorf("no key, set key-file or use a secure config")
+	}
+
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, fmt.Errorf("key shall be 16, 24 or 32 bytes")
+	}
+	return key, nil
+}
+
+// metadataValue : Metadata keys may come back from storage in any case
+func metadataValue(metadata map[string]string, key string) (string, bool) {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// chunkSizeOf : Size of the plain chunks of an encrypted blob, false if the blob is not encrypted
+func chunkSizeOf(metadata map[string]string) (int64, bool) {
+	value, found := metadataValue(metadata, chunkSizeMeta)
+	if !found {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// plainSize : Size of the file held in an encrypted blob of the given size
+func plainSize(stored int64, chunkSize int64) int64 {
+	physical := chunkSize + chunkOverhead
+	size := (stored / physical) * chunkSize
+	if rem := stored % physical; rem > chunkOverhead {
+		size += rem - chunkOverhead
+	}
+	return size
+}
+
+// newDataKey : Generate the key of a blob and wrap it for its metadata
+func (kek *keyEncryptionKey) newDataKey(chunkSize int64) (*dataKey, error) {
+	key := make([]byte, dataKeySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The chunk size is authenticated with the key, so the layout of the blob can not be altered
+	layout := strconv.FormatInt(chunkSize, 10)
+	wrapped := make([]byte, nonceSize, nonceSize+len(key)+kek.aead.Overhead())
+	_, err = io.ReadFull(rand.Reader, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	wrapped = kek.aead.Seal(wrapped, wrapped[:nonceSize], key, []byte(layout))
+
+	return &dataKey{
+		aead:      aead,
+		chunkSize: chunkSize,
+		metadata: map[string]string{
+			wrappedKeyMeta: base64.StdEncoding.EncodeToString(wrapped),
+			keyIDMeta:      kek.id,
+			chunkSizeMeta:  layout,
+		},
+	}, nil
+}
+
+// unwrap : Key of the blob with the given metadata, nil if the blob is not encrypted
+func (kek *keyEncryptionKey) unwrap(metadata map[string]string) (*dataKey, error) {
+	chunkSize, found := chunkSizeOf(metadata)
+	if !found {
+		return nil, nil
+	}
+
+	id, _ := metadataValue(metadata, keyIDMeta)
+	if id != kek.id {
+		log.Err("keyEncryptionKey::unwrap : blob is encrypted with a different key [%s]", id)
+		return nil, syscall.EACCES
+	}
+
+	value, _ := metadataValue(metadata, wrappedKeyMeta)
+	wrapped, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(wrapped) < nonceSize {
+		log.Err("keyEncryptionKey::unwrap : invalid data key in metadata")
+		return nil, syscall.EIO
+	}
+
+	layout := strconv.FormatInt(chunkSize, 10)
+	key, err := kek.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], []byte(layout))
+	if err != nil {
+		log.Err("keyEncryptionKey::unwrap : data key failed authentication [%s]", err.Error())
+		return nil, syscall.EIO
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dataKey{
+		aead:      aead,
+		chunkSize: chunkSize,
+		metadata: map[string]string{
+			wrappedKeyMeta: value,
+			keyIDMeta:      id,
+			chunkSizeMeta:  layout,
+		},
+	}, nil
+}
+
+// physicalChunkSize : Size a chunk takes in the blob
+func (k *dataKey) physicalChunkSize() int64 {
+	return k.chunkSize + chunkOverhead
+}
+
+// plainSize : Size of the file held in a blob of the given size
+func (k *dataKey) plainSize(stored int64) int64 {
+	return plainSize(stored, k.chunkSize)
+}
+
+// storedSize : Size of the blob holding a file of the given size
+func (k *dataKey) storedSize(size int64) int64 {
+	stored := (size / k.chunkSize) * k.physicalChunkSize()
+	if rem := size % k.chunkSize; rem > 0 {
+		stored += rem + chunkOverhead
+	}
+	return stored
+}
+
+// chunkLength : Length of the chunk in a file of the given size
+func (k *dataKey) chunkLength(index int64, size int64) int64 {
+	length := size - index*k.chunkSize
+	if length > k.chunkSize {
+		return k.chunkSize
+	} else if length < 0 {
+		return 0
+	}
+	return length
+}
+
+// withMetadata : Metadata of the blob with the one of the key added, the given map is not modified
+func (k *dataKey) withMetadata(metadata map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for key, value := range metadata {
+		if strings.EqualFold(key, wrappedKeyMeta) || strings.EqualFold(key, keyIDMeta) || strings.EqualFold(key, chunkSizeMeta) {
+			continue
+		}
+		merged[key] = value
+	}
+	for key, value := range k.metadata {
+		merged[key] = value
+	}
+	return merged
+}
+
+// seal : Encrypt the chunk with a fresh nonce and append it to dst
+func (k *dataKey) seal(dst []byte, index int64, data []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, nonceSize)...)
+	_, err := io.ReadFull(rand.Reader, dst[start:])
+	if err != nil {
+		return nil, err
+	}
+
+	var aad [8]byte
+	binary.BigEndian.PutUint64(aad[:], uint64(index))
+	return k.aead.Seal(dst, dst[start:start+nonceSize], data, aad[:]), nil
+}
+
+// open : Decrypt the chunk. Chunks left as holes by extending the blob read as zeros.
+func (k *dataKey) open(index int64, buf []byte) ([]byte, error) {
+	if len(buf) < chunkOverhead {
+		return nil, syscall.EIO
+	}
+
+	hole := true
+	for _, b := range buf {
+		if b != 0 {
+			hole = false
+			break
+		}
+	}
+	if hole {
+		return make([]byte, len(buf)-chunkOverhead), nil
+	}
+
+	var aad [8]byte
+	binary.BigEndian.PutUint64(aad[:], uint64(index))
+	data, err := k.aead.Open(nil, buf[:nonceSize], buf[nonceSize:], aad[:])
+	if err != nil {
+		log.Err("dataKey::open : chunk %d failed authentication [%s]", index, err.Error())
+		return nil, syscall.EIO
+	}
+	return data, nil
+}
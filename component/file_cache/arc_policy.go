@@ -0,0 +1,139 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+)
+
+// arcLists : Adaptive Replacement Cache (Megiddo and Modha)
+// Files used once are kept in t1 and files used again in t2. Evicted files are remembered in the ghost lists b1 and b2,
+// a hit in b1 grows the share of t1 and a hit in b2 grows the share of t2. The cache size is the number of cached files,
+// as eviction itself is driven by disk usage and timeout.
+type arcLists struct {
+	t1, t2 *policyList
+	b1, b2 *policyList
+
+	target int  // number of files t1 should hold
+	hitB2  bool // last ghost hit was in b2
+}
+
+var _ ghostLists = &arcLists{}
+
+func NewARCPolicy(cfg cachePolicyConfig) cachePolicy {
+	lists := &arcLists{
+		t1: newPolicyList("t1"),
+		t2: newPolicyList("t2"),
+		b1: newPolicyList("b1"),
+		b2: newPolicyList("b2"),
+	}
+	return newGhostPolicy("arc", lists, cfg)
+}
+
+func (a *arcLists) size() int {
+	return a.t1.len() + a.t2.len()
+}
+
+func (a *arcLists) access(name string) string {
+	if a.t1.remove(name) || a.t2.contains(name) {
+		a.t2.pushFront(name)
+		return ""
+	}
+
+	if a.b1.remove(name) {
+		a.target = minInt(a.size()+1, a.target+maxInt(a.b2.len()/maxInt(a.b1.len(), 1), 1))
+		a.hitB2 = false
+		a.t2.pushFront(name)
+		return "b1"
+	}
+
+	if a.b2.remove(name) {
+		a.target = maxInt(0, a.target-maxInt(a.b1.len()/maxInt(a.b2.len(), 1), 1))
+		a.hitB2 = true
+		a.t2.pushFront(name)
+		return "b2"
+	}
+
+	a.t1.pushFront(name)
+	return ""
+}
+
+func (a *arcLists) remove(name string) {
+	_ = a.t1.remove(name) || a.t2.remove(name) || a.b1.remove(name) || a.b2.remove(name)
+}
+
+func (a *arcLists) victim() string {
+	if a.t1.len() > 0 && (a.t1.len() > a.target || (a.hitB2 && a.t1.len() == a.target) || a.t2.len() == 0) {
+		return a.t1.back()
+	}
+	return a.t2.back()
+}
+
+func (a *arcLists) evict(name string) {
+	if a.t1.remove(name) {
+		a.b1.pushFront(name)
+	} else if a.t2.remove(name) {
+		a.b2.pushFront(name)
+	}
+
+	// Ghost lists remember as many files as are cached
+	for a.b1.len()+a.b2.len() > maxInt(a.size(), minGhostEntries) {
+		if a.b1.len() > a.b2.len() {
+			a.b1.popBack()
+		} else {
+			a.b2.popBack()
+		}
+	}
+}
+
+func (a *arcLists) restore(name string, usage uint64) {
+	if usage > 1 {
+		a.t2.pushFront(name)
+	} else {
+		a.t1.pushFront(name)
+	}
+}
+
+// cached : Files of t2 and t1, t1 being emptied first unless b1 hits grew its share
+func (a *arcLists) cached() ([]string, []string) {
+	return a.t2.names(), a.t1.names()
+}
+
+func (a *arcLists) trace() {
+	log.Debug(" ==> arc target size of t1 : %d", a.target)
+	a.t1.trace()
+	a.t2.trace()
+	a.b1.trace()
+	a.b2.trace()
+}
@@ -0,0 +1,228 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type arcPolicyTestSuite struct {
+	suite.Suite
+	assert *assert.Assertions
+	policy *ghostPolicy
+	lists  *arcLists
+}
+
+func (suite *arcPolicyTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+
+	os.Mkdir(cache_path, fs.FileMode(0777))
+
+	config := cachePolicyConfig{
+		tmpPath:       cache_path,
+		cacheTimeout:  0,
+		maxEviction:   defaultMaxEviction,
+		maxSizeMB:     0,
+		highThreshold: defaultMaxThreshold,
+		lowThreshold:  defaultMinThreshold,
+		fileLocks:     &common.LockMap{},
+	}
+
+	suite.setupTestHelper(config)
+}
+
+func (suite *arcPolicyTestSuite) setupTestHelper(config cachePolicyConfig) {
+	suite.policy = NewARCPolicy(config).(*ghostPolicy)
+	suite.lists = suite.policy.lists.(*arcLists)
+	suite.policy.StartPolicy()
+}
+
+func (suite *arcPolicyTestSuite) cleanupTest() {
+	suite.policy.ShutdownPolicy()
+
+	os.RemoveAll(cache_path)
+}
+
+func (suite *arcPolicyTestSuite) TestDefault() {
+	defer suite.cleanupTest()
+	suite.assert.EqualValues("arc", suite.policy.Name())
+	suite.assert.EqualValues(0, suite.policy.cacheTimeout)
+	suite.assert.EqualValues(defaultMaxEviction, suite.policy.maxEviction)
+	suite.assert.EqualValues(defaultMaxThreshold, suite.policy.highThreshold)
+	suite.assert.EqualValues(defaultMinThreshold, suite.policy.lowThreshold)
+}
+
+func (suite *arcPolicyTestSuite) TestCacheValid() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("temp")
+	suite.assert.True(suite.policy.IsCached("temp"))
+	suite.assert.True(suite.lists.t1.contains("temp"))
+
+	// A second use moves the file to the frequently used list
+	suite.policy.CacheValid("temp")
+	suite.assert.False(suite.lists.t1.contains("temp"))
+	suite.assert.True(suite.lists.t2.contains("temp"))
+	suite.assert.EqualValues(2, suite.policy.entries["temp"].usage)
+}
+
+func (suite *arcPolicyTestSuite) TestCachePurge() {
+	defer suite.cleanupTest()
+	f, _ := os.Create(filepath.Join(cache_path, "temp"))
+	f.Close()
+	suite.policy.CacheValid(f.Name())
+	suite.policy.CachePurge(f.Name())
+
+	suite.assert.False(suite.policy.IsCached(f.Name()))
+	time.Sleep(100 * time.Millisecond)
+	suite.assert.NoFileExists(f.Name())
+
+	// A purged file is not remembered
+	suite.policy.CacheValid(f.Name())
+	suite.assert.Empty(suite.policy.ghostHits)
+}
+
+func (suite *arcPolicyTestSuite) TestScanResistance() {
+	defer suite.cleanupTest()
+	for i := 0; i < 3; i++ {
+		suite.policy.CacheValid("hot" + fmt.Sprint(i))
+		suite.policy.CacheValid("hot" + fmt.Sprint(i))
+	}
+	for i := 0; i < 10; i++ {
+		suite.policy.CacheValid("scan" + fmt.Sprint(i))
+	}
+
+	// Files read once by the scan are evicted before the ones used again
+	suite.policy.maxEviction = 10
+	suite.assert.Equal(10, suite.policy.evict(suite.policy.victims()))
+	for i := 0; i < 3; i++ {
+		suite.assert.True(suite.policy.IsCached("hot" + fmt.Sprint(i)))
+	}
+	for i := 0; i < 10; i++ {
+		suite.assert.False(suite.policy.IsCached("scan" + fmt.Sprint(i)))
+		suite.assert.True(suite.lists.b1.contains("scan" + fmt.Sprint(i)))
+	}
+}
+
+func (suite *arcPolicyTestSuite) TestGhostHit() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("file1")
+	suite.policy.CacheValid("file2")
+	suite.policy.CacheValid("file2")
+
+	suite.policy.maxEviction = 1
+	suite.assert.Equal(1, suite.policy.evict(suite.policy.victims()))
+	suite.assert.False(suite.policy.IsCached("file1"))
+
+	// A file used again soon after it was evicted grows the share of files used once
+	suite.policy.CacheValid("file1")
+	suite.assert.True(suite.policy.IsCached("file1"))
+	suite.assert.True(suite.lists.t2.contains("file1"))
+	suite.assert.EqualValues(1, suite.policy.ghostHits["b1"])
+	suite.assert.EqualValues(1, suite.lists.target)
+
+	suite.assert.Equal(1, suite.policy.evict(suite.policy.victims()))
+	suite.assert.False(suite.policy.IsCached("file2"))
+	suite.policy.CacheValid("file2")
+	suite.assert.EqualValues(1, suite.policy.ghostHits["b2"])
+	suite.assert.EqualValues(0, suite.lists.target)
+}
+
+func (suite *arcPolicyTestSuite) TestEvictInUse() {
+	defer suite.cleanupTest()
+	f, _ := os.Create(filepath.Join(cache_path, "temp"))
+	f.Close()
+	suite.policy.CacheValid(f.Name())
+	suite.policy.fileLocks.Get("temp").Inc()
+
+	// A file with open handles stays cached and is not a ghost
+	suite.assert.Equal(0, suite.policy.evict(suite.policy.victims()))
+	suite.assert.True(suite.policy.IsCached(f.Name()))
+	suite.assert.True(suite.lists.t1.contains(f.Name()))
+	suite.assert.Equal(0, suite.lists.b1.len())
+	suite.assert.FileExists(f.Name())
+}
+
+func (suite *arcPolicyTestSuite) TestTimeout() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+
+	config := cachePolicyConfig{
+		tmpPath:       cache_path,
+		cacheTimeout:  1,
+		maxEviction:   defaultMaxEviction,
+		maxSizeMB:     0,
+		highThreshold: defaultMaxThreshold,
+		lowThreshold:  defaultMinThreshold,
+		fileLocks:     &common.LockMap{},
+	}
+
+	suite.setupTestHelper(config)
+
+	suite.policy.CacheValid("temp")
+
+	time.Sleep(3 * time.Second) // Wait for time > cacheTimeout, the file should no longer be cached
+
+	suite.assert.False(suite.policy.IsCached("temp"))
+}
+
+func (suite *arcPolicyTestSuite) TestExportImportState() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("file1")
+	suite.policy.CacheValid("file2")
+	suite.policy.CacheValid("file1")
+
+	state := suite.policy.ExportState()
+	suite.assert.Equal([]policyState{{name: "file1", usage: 2}, {name: "file2", usage: 1}}, state)
+
+	suite.cleanupTest()
+	suite.SetupTest()
+	suite.policy.ImportState(state)
+	suite.assert.True(suite.lists.t2.contains("file1"))
+	suite.assert.True(suite.lists.t1.contains("file2"))
+	suite.assert.Equal(state, suite.policy.ExportState())
+}
+
+func TestARCPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(arcPolicyTestSuite))
+}
@@ -0,0 +1,271 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+const (
+	// A full upload is cheaper once most of the file has changed
+	maxModifiedBlockRatio = 0.5
+
+	// Modified blocks are held in memory till they are committed
+	maxModifiedBlockBytes = 256 * MB
+)
+
+// handle value holding the byte ranges written through the handle
+const modifiedRangesKey = "modifiedRanges"
+
+var errFullUpload = errors.New("block level update not possible")
+
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// modifiedRanges : Byte ranges written through a handle, sorted and merged, and what storage had before they were written
+type modifiedRanges struct {
+	sync.Mutex
+	base   cacheIndexEntry
+	ranges []byteRange
+}
+
+// add : Record a write, overlapping and adjacent ranges are merged
+func (m *modifiedRanges) add(offset int64, length int64) {
+	if length <= 0 {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	r := byteRange{start: offset, end: offset + length}
+	i := sort.Search(len(m.ranges), func(i int) bool { return m.ranges[i].end >= r.start })
+	j := i
+	for j < len(m.ranges) && m.ranges[j].start <= r.end {
+		if m.ranges[j].start < r.start {
+			r.start = m.ranges[j].start
+		}
+		if m.ranges[j].end > r.end {
+			r.end = m.ranges[j].end
+		}
+		j++
+	}
+
+	m.ranges = append(m.ranges[:i], append([]byteRange{r}, m.ranges[j:]...)...)
+}
+
+// take : Ranges written so far, later writes are recorded afresh
+func (m *modifiedRanges) take() []byteRange {
+	m.Lock()
+	defer m.Unlock()
+
+	ranges := m.ranges
+	m.ranges = nil
+	return ranges
+}
+
+// restore : The ranges could not be uploaded, keep them for the next flush
+func (m *modifiedRanges) restore(ranges []byteRange) {
+	for _, r := range ranges {
+		m.add(r.start, r.end-r.start)
+	}
+}
+
+// trackModifiedRanges : Record writes through the handle so that only the blocks they touch are uploaded on flush
+func (fc *FileCache) trackModifiedRanges(handle *handlemap.Handle, baseline *internal.ObjAttr) {
+	if _, dirty := fc.dirty.Load(handle.Path); dirty || fc.isUploadPending(handle.Path) {
+		// The local copy already has changes that are not known by range
+		return
+	}
+
+	ranges := &modifiedRanges{}
+	if baseline != nil {
+		ranges.base = cacheIndexEntry{Name: handle.Path, ETag: baseline.ETag, Size: baseline.Size, Mtime: baseline.Mtime}
+	} else {
+		// Served from the local cache, which carries the size and last modified time of the file downloaded
+		info, err := os.Stat(filepath.Join(fc.tmpPath, handle.Path))
+		if err != nil {
+			return
+		}
+		ranges.base = cacheIndexEntry{Name: handle.Path, Size: fc.cipher.localSize(info.Size()), Mtime: info.ModTime()}
+	}
+
+	handle.SetValue(modifiedRangesKey, ranges)
+}
+
+// recordModifiedRange : Remember the range written through the handle
+func recordModifiedRange(handle *handlemap.Handle, offset int64, length int64) {
+	if val, found := handle.GetValue(modifiedRangesKey); found {
+		val.(*modifiedRanges).add(offset, length)
+	}
+}
+
+// resetModifiedRanges : The local copy is in storage, later writes are compared against what storage has now
+func (fc *FileCache) resetModifiedRanges(handle *handlemap.Handle) {
+	val, found := handle.GetValue(modifiedRangesKey)
+	if !found {
+		return
+	}
+	ranges := val.(*modifiedRanges)
+
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: handle.Path})
+	if err != nil {
+		handle.RemoveValue(modifiedRangesKey)
+		return
+	}
+
+	ranges.Lock()
+	ranges.base = cacheIndexEntry{Name: handle.Path, ETag: attr.ETag, Size: attr.Size, Mtime: attr.Mtime}
+	ranges.ranges = nil
+	ranges.Unlock()
+}
+
+// uploadModifiedBlocks : Stage only the blocks of the file touched by writes through the handle and commit the block list.
+// errFullUpload is returned when the block list in storage does not allow it.
+func (fc *FileCache) uploadModifiedBlocks(handle *handlemap.Handle) error {
+	val, found := handle.GetValue(modifiedRangesKey)
+	if !found {
+		return errFullUpload
+	}
+	ranges := val.(*modifiedRanges)
+
+	written := ranges.take()
+	err := fc.stageModifiedBlocks(handle.Path, ranges.base, written)
+	if err != nil {
+		ranges.restore(written)
+	}
+	return err
+}
+
+func (fc *FileCache) stageModifiedBlocks(name string, base cacheIndexEntry, written []byteRange) error {
+	localPath := filepath.Join(fc.tmpPath, name)
+	info, err := os.Stat(localPath)
+	if err != nil || len(written) == 0 || fc.cipher.localSize(info.Size()) < base.Size {
+		return errFullUpload
+	}
+	size := fc.cipher.localSize(info.Size())
+
+	// Blocks can only be replaced in the blob the local copy was made from
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: name})
+	if err != nil || !base.matches(attr) {
+		return errFullUpload
+	}
+
+	bol, err := fc.NextComponent().GetFileBlockOffsets(internal.GetFileBlockOffsetsOptions{Name: name})
+	if err != nil || bol.SmallFile() || len(bol.BlockList) == 0 || bol.BlockList[len(bol.BlockList)-1].EndIndex != base.Size {
+		return errFullUpload
+	}
+
+	modified := make([]*common.Block, 0)
+	for _, r := range written {
+		if r.start >= base.Size {
+			break
+		}
+		blocks, _ := bol.FindBlocks(r.start, r.end-r.start-1)
+		for _, blk := range blocks {
+			if !blk.Dirty() {
+				blk.Flags.Set(common.DirtyBlock)
+				modified = append(modified, blk)
+			}
+		}
+	}
+
+	// Data written past the end of the blob goes in new blocks of the size the blob already uses
+	blockSize := bol.BlockList[0].EndIndex - bol.BlockList[0].StartIndex
+	for offset := base.Size; offset < size; offset += blockSize {
+		blk := &common.Block{
+			Id:         base64.StdEncoding.EncodeToString(common.NewUUIDWithLength(bol.BlockIdLength)),
+			StartIndex: offset,
+			EndIndex:   offset + blockSize,
+		}
+		if blk.EndIndex > size {
+			blk.EndIndex = size
+		}
+		blk.Flags.Set(common.DirtyBlock)
+		bol.BlockList = append(bol.BlockList, blk)
+		modified = append(modified, blk)
+	}
+
+	modifiedBytes := int64(0)
+	for _, blk := range modified {
+		modifiedBytes += blk.EndIndex - blk.StartIndex
+	}
+	if modifiedBytes > maxModifiedBlockBytes || float64(modifiedBytes) > maxModifiedBlockRatio*float64(size) {
+		return errFullUpload
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return errFullUpload
+	}
+	defer f.Close()
+
+	for _, blk := range modified {
+		blk.Data = make([]byte, blk.EndIndex-blk.StartIndex)
+		n, err := fc.cipher.readAt(int(f.Fd()), blk.Data, blk.StartIndex)
+		if err == nil && n != len(blk.Data) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			log.Err("FileCache::stageModifiedBlocks : failed to read %s at %d [%s]", name, blk.StartIndex, err.Error())
+			return err
+		}
+	}
+
+	uploadHandle := handlemap.NewHandle(name)
+	uploadHandle.CacheObj = &handlemap.Cache{BlockOffsetList: bol}
+	uploadHandle.Size = size
+
+	err = fc.NextComponent().FlushFile(internal.FlushFileOptions{Handle: uploadHandle})
+	if err != nil {
+		log.Err("FileCache::stageModifiedBlocks : failed to commit modified blocks of %s [%s]", name, err.Error())
+		return err
+	}
+
+	log.Debug("FileCache::stageModifiedBlocks : %s uploaded %d of %d bytes in %d blocks", name, modifiedBytes, size, len(modified))
+	return nil
+}
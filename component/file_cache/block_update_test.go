@@ -0,0 +1,213 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockStore : Presents the files of the loopback component as blobs of fixed size blocks and applies staged blocks to them
+type blockStore struct {
+	internal.BaseComponent
+	path      string
+	blockSize int64
+	staged    []int64 // start of the blocks staged by the last flush
+}
+
+func (bs *blockStore) GetFileBlockOffsets(options internal.GetFileBlockOffsetsOptions) (*common.BlockOffsetList, error) {
+	info, err := os.Stat(filepath.Join(bs.path, options.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	bol := &common.BlockOffsetList{BlockIdLength: 16}
+	for offset := int64(0); offset < info.Size(); offset += bs.blockSize {
+		blk := &common.Block{
+			Id:         base64.StdEncoding.EncodeToString(common.NewUUIDWithLength(16)),
+			StartIndex: offset,
+			EndIndex:   offset + bs.blockSize,
+		}
+		if blk.EndIndex > info.Size() {
+			blk.EndIndex = info.Size()
+		}
+		bol.BlockList = append(bol.BlockList, blk)
+	}
+	return bol, nil
+}
+
+func (bs *blockStore) FlushFile(options internal.FlushFileOptions) error {
+	f, err := os.OpenFile(filepath.Join(bs.path, options.Handle.Path), os.O_WRONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bs.staged = nil
+	for _, blk := range options.Handle.CacheObj.BlockList {
+		if blk.Dirty() {
+			bs.staged = append(bs.staged, blk.StartIndex)
+			_, err = f.WriteAt(blk.Data, blk.StartIndex)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setupBlockStore : Put a blob of the given size in storage and have file cache upload through the block store
+func (suite *fileCacheTestSuite) setupBlockStore(path string, size int) (*blockStore, []byte) {
+	// Restart the pipeline with the block store between file cache and loopback
+	suite.loopback.Stop()
+	suite.assert.Nil(suite.fileCache.Stop())
+	store := &blockStore{path: suite.fake_storage_path, blockSize: 8}
+	suite.loopback = newLoopbackFS()
+	store.SetNextComponent(suite.loopback)
+	suite.fileCache = newTestFileCache(store)
+	suite.loopback.Start(context.Background())
+	suite.assert.Nil(suite.fileCache.Start(context.Background()))
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	os.MkdirAll(suite.fake_storage_path, 0777)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), data, 0777)
+	return store, data
+}
+
+func (suite *fileCacheTestSuite) TestUploadModifiedBlocks() {
+	defer suite.cleanupTest()
+	path := "file"
+	store, data := suite.setupBlockStore(path, 40)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 10, Data: []byte("XY")})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 26, Data: []byte("ZZZ")})
+
+	err = suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Equal([]int64{8, 24}, store.staged)
+
+	copy(data[10:], "XY")
+	copy(data[26:], "ZZZ")
+	storageData, _ := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Equal(data, storageData)
+
+	// Later writes are compared against the blob as committed
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("W")})
+	err = suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Equal([]int64{0}, store.staged)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestUploadModifiedBlocksAppend() {
+	defer suite.cleanupTest()
+	path := "file"
+	store, data := suite.setupBlockStore(path, 40)
+
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 40, Data: []byte("appended data")})
+
+	err := suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Equal([]int64{40, 48}, store.staged)
+
+	storageData, _ := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Equal(append(data, []byte("appended data")...), storageData)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestUploadModifiedBlocksFallback() {
+	defer suite.cleanupTest()
+	path := "file"
+	store, data := suite.setupBlockStore(path, 40)
+
+	// Most of the file changed
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: make([]byte, 30)})
+	err := suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Empty(store.staged)
+	storageData, _ := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Equal(append(make([]byte, 30), data[30:]...), storageData)
+
+	// Blob changed in storage since the local copy was made
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), data, 0777)
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("X")})
+	err = suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Empty(store.staged)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Truncated on open, nothing is known about what changed
+	handle, _ = suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR | os.O_TRUNC, Mode: 0777})
+	_, found := handle.GetValue(modifiedRangesKey)
+	suite.assert.False(found)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func TestModifiedRangesMerge(t *testing.T) {
+	assert := assert.New(t)
+	m := &modifiedRanges{}
+
+	m.add(10, 5)
+	m.add(30, 5)
+	m.add(0, 2)
+	m.add(0, 0)
+	assert.Equal([]byteRange{{0, 2}, {10, 15}, {30, 35}}, m.ranges)
+
+	// Adjacent and overlapping ranges are merged
+	m.add(15, 3)
+	m.add(12, 20)
+	assert.Equal([]byteRange{{0, 2}, {10, 35}}, m.ranges)
+
+	taken := m.take()
+	assert.Empty(m.ranges)
+	m.add(50, 1)
+	m.restore(taken)
+	assert.Equal(fmt.Sprint([]byteRange{{0, 2}, {10, 35}, {50, 51}}), fmt.Sprint(m.ranges))
+}
@@ -0,0 +1,244 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+// Version of the on-disk index, an index of any other version is ignored on start
+const cacheIndexVersion = 1
+
+// Interval at which the index is written to disk while mounted
+const indexSaveInterval = 60 * time.Second
+
+// cacheIndexEntry : Remote properties of a cached file at the time it was downloaded
+type cacheIndexEntry struct {
+	Name  string    `json:"name"`
+	ETag  string    `json:"etag,omitempty"`
+	Size  int64     `json:"size"`
+	Mtime time.Time `json:"lmt"`
+	Usage uint64    `json:"usage"` // usage of the file as kept by the cache policy
+}
+
+// cacheIndex : Files in the local cache that can be trusted on the next mount, in the order kept by the cache policy
+type cacheIndex struct {
+	Version int               `json:"version"`
+	Policy  string            `json:"policy"`
+	Key     string            `json:"key,omitempty"` // encryption key of the local copies, if encrypted
+	Files   []cacheIndexEntry `json:"files"`
+}
+
+// matches : Whether the remote object is still the one that was downloaded
+func (e *cacheIndexEntry) matches(attr *internal.ObjAttr) bool {
+	if e.ETag != "" && attr.ETag != "" {
+		return e.ETag == attr.ETag
+	}
+	return e.Size == attr.Size && e.Mtime.Equal(attr.Mtime)
+}
+
+// recordRemoteAttr : Remember the remote properties of a file just downloaded to the local cache
+func (fc *FileCache) recordRemoteAttr(name string, attr *internal.ObjAttr) {
+	if !fc.persistIndex || attr == nil {
+		return
+	}
+
+	fc.remoteAttrs.Store(name, &cacheIndexEntry{
+		Name:  name,
+		ETag:  attr.ETag,
+		Size:  attr.Size,
+		Mtime: attr.Mtime,
+	})
+}
+
+// forgetRemoteAttr : The local copy of the file is changing so it no longer matches what was downloaded
+func (fc *FileCache) forgetRemoteAttr(name string) {
+	if fc.persistIndex {
+		fc.remoteAttrs.Delete(name)
+	}
+}
+
+// revalidateLocalCopy : Check with storage that the local copy of a file is still current so it can be served without a download.
+// Caller shall hold the file lock.
+func (fc *FileCache) revalidateLocalCopy(name string, localPath string) bool {
+	val, found := fc.remoteAttrs.Load(name)
+	if !found {
+		return false
+	}
+	entry := val.(*cacheIndexEntry)
+
+	finfo, err := os.Stat(localPath)
+	if err != nil || fc.cipher.localSize(finfo.Size()) != entry.Size {
+		fc.remoteAttrs.Delete(name)
+		return false
+	}
+
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: name})
+	if err != nil || !entry.matches(attr) {
+		log.Debug("FileCache::revalidateLocalCopy : %s changed in storage", name)
+		fc.remoteAttrs.Delete(name)
+		return false
+	}
+
+	// Changing the times resets the last change time, so the local copy is valid for another cache timeout
+	err = os.Chtimes(localPath, attr.Atime, attr.Mtime)
+	if err != nil {
+		log.Err("FileCache::revalidateLocalCopy : Failed to change times of file %s [%s]", name, err.Error())
+		return false
+	}
+
+	log.Debug("FileCache::revalidateLocalCopy : %s unchanged in storage", name)
+	return true
+}
+
+// saveIndex : Write the remote properties of the files kept by the cache policy to disk
+func (fc *FileCache) saveIndex() error {
+	index := cacheIndex{
+		Version: cacheIndexVersion,
+		Policy:  fc.policy.Name(),
+		Key:     fc.cipher.keyID(),
+		Files:   make([]cacheIndexEntry, 0),
+	}
+
+	kept := make(map[string]bool)
+	for _, state := range fc.policy.ExportState() {
+		name := strings.TrimPrefix(strings.TrimPrefix(state.name, fc.tmpPath), "/")
+		val, found := fc.remoteAttrs.Load(name)
+		if !found {
+			continue
+		}
+
+		entry := *val.(*cacheIndexEntry)
+		entry.Usage = state.usage
+		index.Files = append(index.Files, entry)
+		kept[name] = true
+	}
+
+	// Files evicted by the policy are not worth remembering any more
+	fc.remoteAttrs.Range(func(key, _ interface{}) bool {
+		if !kept[key.(string)] {
+			fc.remoteAttrs.Delete(key)
+		}
+		return true
+	})
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash never leaves a partial index behind
+	tmpIndex := fc.indexPath + ".tmp"
+	err = os.WriteFile(tmpIndex, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	log.Debug("FileCache::saveIndex : %d files saved to %s", len(index.Files), fc.indexPath)
+	return os.Rename(tmpIndex, fc.indexPath)
+}
+
+// loadIndex : Trust the files left in the local cache by a previous mount as per the index on disk.
+// The files are served again after checking with storage that they did not change.
+func (fc *FileCache) loadIndex() error {
+	data, err := os.ReadFile(fc.indexPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	index := cacheIndex{}
+	err = json.Unmarshal(data, &index)
+	if err != nil {
+		return err
+	}
+
+	if index.Version != cacheIndexVersion {
+		log.Warn("FileCache::loadIndex : ignoring index of version %d", index.Version)
+		return nil
+	}
+
+	if index.Key != fc.cipher.keyID() {
+		// Local copies written in plain or with another key can not be served
+		log.Warn("FileCache::loadIndex : ignoring index of files encrypted differently")
+		return nil
+	}
+
+	state := make([]policyState, 0, len(index.Files))
+	for i := range index.Files {
+		entry := index.Files[i]
+		localPath := filepath.Join(fc.tmpPath, entry.Name)
+
+		// A local copy that does not match the recorded size was changed or partially written
+		finfo, err := os.Stat(localPath)
+		if err != nil || finfo.IsDir() || fc.cipher.localSize(finfo.Size()) != entry.Size {
+			continue
+		}
+
+		fc.remoteAttrs.Store(entry.Name, &entry)
+		state = append(state, policyState{name: localPath, usage: entry.Usage})
+	}
+
+	fc.policy.ImportState(state)
+
+	log.Info("FileCache::loadIndex : %d of %d files restored from %s", len(state), len(index.Files), fc.indexPath)
+	return nil
+}
+
+// saveIndexPeriodically : Keep the index on disk current so a crash loses only the recent downloads
+func (fc *FileCache) saveIndexPeriodically(stop <-chan bool) {
+	ticker := time.NewTicker(indexSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := fc.saveIndex()
+			if err != nil {
+				log.Err("FileCache::saveIndexPeriodically : failed to save index [%s]", err.Error())
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+func (suite *fileCacheTestSuite) setupPersistedCache() string {
+	suite.cleanupTest()
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  timeout-sec: 120\n  persist-index: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+	return config
+}
+
+// remount : Stop the pipeline leaving the cache on disk and start it again
+func (suite *fileCacheTestSuite) remount(config string) {
+	suite.loopback.Stop()
+	err := suite.fileCache.Stop()
+	suite.assert.Nil(err)
+	suite.setupTestHelper(config)
+}
+
+func (suite *fileCacheTestSuite) TestConfigPersistIndex() {
+	defer suite.cleanupTest()
+	suite.assert.False(suite.fileCache.persistIndex)
+
+	suite.setupPersistedCache()
+	suite.assert.True(suite.fileCache.persistIndex)
+	suite.assert.True(suite.fileCache.allowNonEmpty)
+	suite.assert.Equal(filepath.Join(suite.cache_path, stateDirName, "index"), suite.fileCache.indexPath)
+}
+
+func (suite *fileCacheTestSuite) TestPersistIndexRemount() {
+	defer suite.cleanupTest()
+	config := suite.setupPersistedCache()
+
+	paths := []string{"a", "dir/b"}
+	for _, path := range paths {
+		os.MkdirAll(filepath.Dir(filepath.Join(suite.fake_storage_path, path)), 0777)
+		os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("data"), 0777)
+		handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+		suite.assert.Nil(err)
+		suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	}
+
+	suite.remount(config)
+	suite.assert.FileExists(suite.fileCache.indexPath)
+	for _, path := range paths {
+		localPath := filepath.Join(suite.cache_path, path)
+		suite.assert.FileExists(localPath)
+		suite.assert.True(suite.fileCache.policy.IsCached(localPath))
+		_, found := suite.fileCache.remoteAttrs.Load(path)
+		suite.assert.True(found)
+	}
+
+	// Most recently used file is still first
+	state := suite.fileCache.policy.ExportState()
+	suite.assert.Len(state, 2)
+	suite.assert.Equal(filepath.Join(suite.cache_path, "dir/b"), state[0].name)
+}
+
+func (suite *fileCacheTestSuite) TestPersistIndexSkipsChangedLocalCopy() {
+	defer suite.cleanupTest()
+	config := suite.setupPersistedCache()
+
+	path := "file"
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("data"), 0777)
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	suite.loopback.Stop()
+	suite.fileCache.Stop()
+	os.WriteFile(filepath.Join(suite.cache_path, path), []byte("partial"), 0777)
+	suite.setupTestHelper(config)
+
+	_, found := suite.fileCache.remoteAttrs.Load(path)
+	suite.assert.False(found)
+	suite.assert.False(suite.fileCache.policy.IsCached(filepath.Join(suite.cache_path, path)))
+}
+
+func (suite *fileCacheTestSuite) TestRevalidateLocalCopy() {
+	defer suite.cleanupTest()
+	suite.setupPersistedCache()
+
+	path := "file"
+	localPath := filepath.Join(suite.cache_path, path)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("data"), 0777)
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	suite.assert.True(suite.fileCache.revalidateLocalCopy(path, localPath))
+
+	// Changed by another writer
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("new data"), 0777)
+	suite.assert.False(suite.fileCache.revalidateLocalCopy(path, localPath))
+	_, found := suite.fileCache.remoteAttrs.Load(path)
+	suite.assert.False(found)
+}
+
+func (suite *fileCacheTestSuite) TestPersistIndexForgetsWrittenFile() {
+	defer suite.cleanupTest()
+	suite.setupPersistedCache()
+
+	path := "file"
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("data"), 0777)
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	_, found := suite.fileCache.remoteAttrs.Load(path)
+	suite.assert.True(found)
+
+	handle, _ = suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	_, found = suite.fileCache.remoteAttrs.Load(path)
+	suite.assert.False(found)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestStateDirNotCached() {
+	defer suite.cleanupTest()
+	suite.assert.DirExists(stateDir(suite.cache_path))
+	suite.assert.True(isCacheEmpty(suite.cache_path))
+
+	// State is neither counted nor cleaned up as a cached file
+	os.WriteFile(filepath.Join(stateDir(suite.cache_path), "index"), make([]byte, 2*MB), 0644)
+	os.WriteFile(filepath.Join(suite.cache_path, "file"), []byte("data"), 0644)
+	suite.assert.Less(getUsage(suite.cache_path), float64(1))
+	suite.assert.False(isCacheEmpty(suite.cache_path))
+
+	suite.fileCache.TempCacheCleanup()
+	suite.assert.NoFileExists(filepath.Join(suite.cache_path, "file"))
+	suite.assert.FileExists(filepath.Join(stateDir(suite.cache_path), "index"))
+	suite.assert.True(isCacheEmpty(suite.cache_path))
+}
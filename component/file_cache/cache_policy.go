@@ -40,6 +40,8 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Azure/azure-storage-fuse/v2/common"
 	"github.com/Azure/azure-storage-fuse/v2/common/log"
@@ -58,10 +60,46 @@ type cachePolicyConfig struct {
 	lowThreshold  float64
 
 	fileLocks *common.LockMap
+	pinned    *sync.Map // local paths of the files pinned in cache
+	uploading *sync.Map // local paths of the files waiting for a background upload
+
+	pinnedBytes *int64 // size of the pinned files, accounted apart from the files the policy may evict
 
 	policyTrace bool
 }
 
+// isPinned : Pinned files are not evicted by the cache policy till they are unpinned
+func (c *cachePolicyConfig) isPinned(name string) bool {
+	if c.pinned == nil {
+		return false
+	}
+	_, found := c.pinned.Load(name)
+	return found
+}
+
+// pinnedMB : Size of the pinned files in MB
+func (c *cachePolicyConfig) pinnedMB() float64 {
+	if c.pinnedBytes == nil {
+		return 0
+	}
+	return float64(atomic.LoadInt64(c.pinnedBytes)) / MB
+}
+
+// policyState : A file kept by the cache policy and how much it was used
+type policyState struct {
+	name  string
+	usage uint64
+}
+
+// isUploading : Files waiting for a background upload only exist in the local cache
+func (c *cachePolicyConfig) isUploading(name string) bool {
+	if c.uploading == nil {
+		return false
+	}
+	_, found := c.uploading.Load(name)
+	return found
+}
+
 type cachePolicy interface {
 	StartPolicy() error
 	ShutdownPolicy() error
@@ -74,6 +112,9 @@ type cachePolicy interface {
 
 	IsCached(name string) bool // Whether or not the cache policy considers this file cached
 
+	ExportState() []policyState      // Files kept by the policy, the ones it would evict last first
+	ImportState(state []policyState) // Restore files exported earlier by a policy
+
 	Name() string // The name of the policy
 }
 
@@ -88,7 +129,7 @@ func getUsage(path string) float64 {
 	// https://man7.org/linux/man-pages/man1/du.1.html
 	// Note: We cannot just pass -BM as a parameter here since it will result in less accurate estimates of the size of the path
 	// (i.e. du will round up to 1M if the path is smaller than 1M).
-	cmd := exec.Command("du", "-sh", path)
+	cmd := exec.Command("du", "-sh", "--exclude="+stateDirName, path)
 	cmd.Stdout = &out
 
 	err := cmd.Run()
@@ -125,13 +166,20 @@ func getUsage(path string) float64 {
 }
 
 // getUsagePercentage:  The current cache usage as a percentage of the maxSize
-func getUsagePercentage(path string, maxSize float64) float64 {
+// Pinned files cannot be evicted, so they are left out of both the usage and the maxSize
+func getUsagePercentage(path string, maxSize float64, pinnedMB float64) float64 {
 	if maxSize == 0 {
 		return 0
 	}
 
 	currSize := getUsage(path)
-	usagePercent := (currSize / float64(maxSize)) * 100
+	usagePercent := float64(100)
+	if maxSize > pinnedMB {
+		usagePercent = ((currSize - pinnedMB) / (maxSize - pinnedMB)) * 100
+	}
+	if usagePercent < 0 {
+		usagePercent = 0
+	}
 	log.Debug("cachePolicy::getUsagePercentage : current cache usage : %f%%", usagePercent)
 
 	fileCacheStatsCollector.UpdateStats(stats_manager.Replace, cacheUsage, fmt.Sprintf("%f MB", currSize))
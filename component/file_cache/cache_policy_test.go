@@ -78,12 +78,26 @@ func (suite *cachePolicyTestSuite) TestGetUsagePercentage() {
 	f, _ := os.Create(cache_path + "/test")
 	data := make([]byte, 1024*1024)
 	f.Write(data)
-	result := getUsagePercentage(cache_path, 4)
+	result := getUsagePercentage(cache_path, 4, 0)
 	// since the value might defer a little distro to distro
 	suite.assert.GreaterOrEqual(result, float64(25))
 	suite.assert.LessOrEqual(result, float64(30))
 }
 
+func (suite *cachePolicyTestSuite) TestGetUsagePercentagePinned() {
+	defer suite.cleanupTest()
+	f, _ := os.Create(cache_path + "/test")
+	data := make([]byte, 1024*1024)
+	f.Write(data)
+
+	// Pinned files are left out of both the usage and the size
+	result := getUsagePercentage(cache_path, 4, 1)
+	suite.assert.LessOrEqual(result, float64(10))
+
+	result = getUsagePercentage(cache_path, 1, 1)
+	suite.assert.EqualValues(100, result)
+}
+
 func (suite *cachePolicyTestSuite) TestDeleteFile() {
 	defer suite.cleanupTest()
 	f, _ := os.Create(cache_path + "/test")
@@ -0,0 +1,437 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+const (
+	// Local copies are encrypted in chunks of 64KB, so a random read decrypts only the chunks it touches
+	encryptionChunkSize = 64 * 1024
+
+	// Every chunk on disk is prefixed by its nonce and followed by its authentication tag
+	encryptionNonceSize = 12
+	encryptionOverhead  = encryptionNonceSize + 16
+	encryptedChunkSize  = encryptionChunkSize + encryptionOverhead
+)
+
+// cacheCipher : Encrypts the local copies of files with AES-GCM, chunk by chunk.
+// Chunks are authenticated along with their index so they can not be reordered within a file.
+// A nil cipher reads and writes the local copies as they are.
+type cacheCipher struct {
+	sync.RWMutex // a partial write of a chunk reads it, so writes are serialized against reads
+	aead         cipher.AEAD
+	id           string // identifies the key in the index, without revealing it
+}
+
+func newCacheCipher(key []byte) (*cacheCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(compName))
+	return &cacheCipher{aead: aead, id: hex.EncodeToString(mac.Sum(nil)[:8])}, nil
+}
+
+// loadCacheKey : Key from the key file, same as a passphrase it shall be 16, 24 or 32 bytes.
+// Without a key file the passphrase of the secure config is used.
+func loadCacheKey(keyFile string) ([]byte, error) {
+	key := []byte(config.SecurePassphrase())
+	if keyFile != "" {
+		data, err := os.ReadFile(common.ExpandPath(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key [%s]", err.Error())
+		}
+		key = bytes.TrimRight(data, "\r\n")
+	} else if len(key) == 0 {
+		return nil, fmt.Errorf("no encryption key, set encryption-key-file or use a secure config")
+	}
+
+	if !validKeySize(len(key)) {
+		return nil, fmt.Errorf("encryption key shall be 16, 24 or 32 bytes")
+	}
+	return key, nil
+}
+
+func validKeySize(size int) bool {
+	return size == 16 || size == 24 || size == 32
+}
+
+// encryptedPlainSize : Size of the file held in an encrypted local copy of the given size
+func encryptedPlainSize(physical int64) int64 {
+	size := (physical / encryptedChunkSize) * encryptionChunkSize
+	if rem := physical % encryptedChunkSize; rem > encryptionOverhead {
+		size += rem - encryptionOverhead
+	}
+	return size
+}
+
+// encryptedSize : Size on disk of the encrypted local copy of a file of the given size
+func encryptedSize(size int64) int64 {
+	physical := (size / encryptionChunkSize) * encryptedChunkSize
+	if rem := size % encryptionChunkSize; rem > 0 {
+		physical += rem + encryptionOverhead
+	}
+	return physical
+}
+
+// chunkLength : Length of the chunk in a file of the given size
+func chunkLength(index int64, size int64) int {
+	length := size - index*encryptionChunkSize
+	if length > encryptionChunkSize {
+		return encryptionChunkSize
+	} else if length < 0 {
+		return 0
+	}
+	return int(length)
+}
+
+// keyID : Identifies the key the local copies are encrypted with, empty if they are plain
+func (c *cacheCipher) keyID() string {
+	if c == nil {
+		return ""
+	}
+	return c.id
+}
+
+// localSize : Size of the file held in a local copy of the given size on disk
+func (c *cacheCipher) localSize(physical int64) int64 {
+	if c == nil {
+		return physical
+	}
+	return encryptedPlainSize(physical)
+}
+
+// size : Size of the file held in the local copy
+func (c *cacheCipher) size(fd int) (int64, error) {
+	var stat syscall.Stat_t
+	err := syscall.Fstat(fd, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return c.localSize(stat.Size), nil
+}
+
+// readChunk : Decrypt the chunk of the given length. Chunks left as holes by extending the file read as zeros.
+func (c *cacheCipher) readChunk(fd int, index int64, length int) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	buf := make([]byte, length+encryptionOverhead)
+	n, err := syscall.Pread(fd, buf, index*encryptedChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(buf) {
+		return nil, syscall.EIO
+	}
+
+	hole := true
+	for _, b := range buf {
+		if b != 0 {
+			hole = false
+			break
+		}
+	}
+	if hole {
+		return make([]byte, length), nil
+	}
+
+	var aad [8]byte
+	binary.BigEndian.PutUint64(aad[:], uint64(index))
+	ciphertext := buf[encryptionNonceSize:]
+	data, err := c.aead.Open(ciphertext[:0], buf[:encryptionNonceSize], ciphertext, aad[:])
+	if err != nil {
+		log.Err("cacheCipher::readChunk : chunk %d failed authentication [%s]", index, err.Error())
+		return nil, syscall.EIO
+	}
+	return data, nil
+}
+
+// writeChunk : Encrypt the chunk with a fresh nonce
+func (c *cacheCipher) writeChunk(fd int, index int64, data []byte) error {
+	buf := make([]byte, encryptionNonceSize, len(data)+encryptionOverhead)
+	_, err := io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return err
+	}
+
+	var aad [8]byte
+	binary.BigEndian.PutUint64(aad[:], uint64(index))
+	buf = c.aead.Seal(buf, buf[:encryptionNonceSize], data, aad[:])
+
+	n, err := syscall.Pwrite(fd, buf, index*encryptedChunkSize)
+	if err != nil {
+		return err
+	}
+	if n != len(buf) {
+		return syscall.EIO
+	}
+	return nil
+}
+
+// readAt : Read the file from its local copy
+func (c *cacheCipher) readAt(fd int, p []byte, off int64) (int, error) {
+	if c == nil {
+		return syscall.Pread(fd, p, off)
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	size, err := c.size(fd)
+	if err != nil {
+		return 0, err
+	}
+	if off >= size {
+		return 0, nil
+	}
+	if off+int64(len(p)) > size {
+		p = p[:size-off]
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / encryptionChunkSize
+		data, err := c.readChunk(fd, index, chunkLength(index, size))
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos-index*encryptionChunkSize:])
+	}
+	return n, nil
+}
+
+// writeAt : Write to the local copy of the file, chunks written in part are decrypted and encrypted again
+func (c *cacheCipher) writeAt(fd int, p []byte, off int64) (int, error) {
+	if c == nil {
+		return syscall.Pwrite(fd, p, off)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	size, err := c.size(fd)
+	if err != nil {
+		return 0, err
+	}
+	if off > size {
+		err = c.resize(fd, size, off)
+		if err != nil {
+			return 0, err
+		}
+		size = off
+	}
+
+	end := off + int64(len(p))
+	if end < size {
+		end = size
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / encryptionChunkSize
+		from := int(pos - index*encryptionChunkSize)
+		length := chunkLength(index, end)
+		count := length - from
+		if count > len(p)-n {
+			count = len(p) - n
+		}
+
+		data := p[n : n+count]
+		if from != 0 || count != length {
+			data, err = c.readChunk(fd, index, chunkLength(index, size))
+			if err != nil {
+				return n, err
+			}
+			data = append(data, make([]byte, length-len(data))...)
+			copy(data[from:], p[n:n+count])
+		}
+
+		err = c.writeChunk(fd, index, data)
+		if err != nil {
+			return n, err
+		}
+		n += count
+	}
+	return n, nil
+}
+
+// truncate : Change the size of the local copy of the file
+func (c *cacheCipher) truncate(fd int, newSize int64) error {
+	if c == nil {
+		return syscall.Ftruncate(fd, newSize)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	size, err := c.size(fd)
+	if err != nil {
+		return err
+	}
+	return c.resize(fd, size, newSize)
+}
+
+// resize : Change the size of the local copy. Chunks past the old end are left as holes, which read as zeros.
+// Caller shall hold the lock.
+func (c *cacheCipher) resize(fd int, size int64, newSize int64) error {
+	if (newSize < size && newSize%encryptionChunkSize != 0) || (newSize > size && size%encryptionChunkSize != 0) {
+		// The chunk the file now ends in, or that no longer is the last one, is encrypted again at its new length
+		index := newSize / encryptionChunkSize
+		if size < newSize {
+			index = size / encryptionChunkSize
+		}
+
+		data, err := c.readChunk(fd, index, chunkLength(index, size))
+		if err != nil {
+			return err
+		}
+
+		length := chunkLength(index, newSize)
+		if length < len(data) {
+			data = data[:length]
+		} else {
+			data = append(data, make([]byte, length-len(data))...)
+		}
+
+		err = c.writeChunk(fd, index, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return syscall.Ftruncate(fd, encryptedSize(newSize))
+}
+
+// plainReader : Reads the plain contents of a local copy, decrypting only the chunks a read touches
+type plainReader struct {
+	cipher *cacheCipher
+	fd     int
+}
+
+func (r plainReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.cipher.readAt(r.fd, p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// uploadOptions : Options to upload the local copy opened as f. The plain contents of an encrypted copy are
+// decrypted chunk by chunk as they are uploaded, so they never reach the disk nor are held in full.
+func (c *cacheCipher) uploadOptions(name string, f *os.File) (internal.CopyFromFileOptions, error) {
+	if c == nil {
+		return internal.CopyFromFileOptions{Name: name, File: f}, nil
+	}
+
+	size, err := c.size(int(f.Fd()))
+	if err != nil {
+		return internal.CopyFromFileOptions{}, err
+	}
+	return internal.CopyFromFileOptions{Name: name, Reader: plainReader{cipher: c, fd: int(f.Fd())}, Size: size}, nil
+}
+
+// downloadFile : Download the file from storage to its local copy. Without encryption storage writes to the
+// local copy directly, else the file is read in blocks and encrypted on the way.
+func (fc *FileCache) downloadFile(name string, f *os.File, size int64) error {
+	if fc.cipher == nil {
+		return fc.NextComponent().CopyToFile(
+			internal.CopyToFileOptions{
+				Name:   name,
+				Offset: 0,
+				Count:  size,
+				File:   f,
+			})
+	}
+
+	err := f.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	remote, err := fc.NextComponent().OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDONLY, Mode: fc.defaultPermission})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fc.NextComponent().CloseFile(internal.CloseFileOptions{Handle: remote})
+	}()
+
+	buf := make([]byte, defaultProgressiveBlockSizeMB*MB)
+	for off := int64(0); ; {
+		n, err := fc.NextComponent().ReadInBuffer(internal.ReadInBufferOptions{Handle: remote, Offset: off, Data: buf})
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n > 0 {
+			_, err = fc.cipher.writeAt(int(f.Fd()), buf[:n], off)
+			if err != nil {
+				return err
+			}
+			off += int64(n)
+		}
+		if n < len(buf) || (size > 0 && off >= size) {
+			return nil
+		}
+	}
+}
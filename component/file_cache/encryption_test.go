@@ -0,0 +1,387 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type cacheCipherTestSuite struct {
+	suite.Suite
+	assert *assert.Assertions
+	cipher *cacheCipher
+	file   *os.File
+}
+
+func (suite *cacheCipherTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+	var err error
+	suite.cipher, err = newCacheCipher([]byte("0123456789abcdef0123456789abcdef"))
+	suite.assert.Nil(err)
+	suite.file, err = os.Create(filepath.Join(home_dir, "encrypted"+randomString(8)))
+	suite.assert.Nil(err)
+}
+
+func (suite *cacheCipherTestSuite) TearDownTest() {
+	suite.file.Close()
+	os.Remove(suite.file.Name())
+}
+
+func TestCacheCipher(t *testing.T) {
+	suite.Run(t, new(cacheCipherTestSuite))
+}
+
+func (suite *cacheCipherTestSuite) fd() int {
+	return int(suite.file.Fd())
+}
+
+// readAll : Plain contents of the local copy
+func (suite *cacheCipherTestSuite) readAll() []byte {
+	size, err := suite.cipher.size(suite.fd())
+	suite.assert.Nil(err)
+	data := make([]byte, size)
+	n, err := suite.cipher.readAt(suite.fd(), data, 0)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(size, n)
+	return data
+}
+
+func (suite *cacheCipherTestSuite) TestSizes() {
+	for _, size := range []int64{0, 1, encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1, 5*encryptionChunkSize + 7} {
+		suite.assert.Equal(size, encryptedPlainSize(encryptedSize(size)))
+	}
+	suite.assert.EqualValues(2*encryptedChunkSize+1+encryptionOverhead, encryptedSize(2*encryptionChunkSize+1))
+
+	var c *cacheCipher
+	suite.assert.EqualValues(100, c.localSize(100))
+}
+
+func (suite *cacheCipherTestSuite) TestReadWrite() {
+	data := make([]byte, 3*encryptionChunkSize+100)
+	rand.Read(data)
+
+	n, err := suite.cipher.writeAt(suite.fd(), data, 0)
+	suite.assert.Nil(err)
+	suite.assert.Equal(len(data), n)
+	suite.assert.Equal(data, suite.readAll())
+
+	// Reads spanning chunks, and past the end
+	buf := make([]byte, 1000)
+	n, err = suite.cipher.readAt(suite.fd(), buf, encryptionChunkSize-500)
+	suite.assert.Nil(err)
+	suite.assert.Equal(1000, n)
+	suite.assert.Equal(data[encryptionChunkSize-500:encryptionChunkSize+500], buf)
+
+	n, err = suite.cipher.readAt(suite.fd(), buf, int64(len(data)-10))
+	suite.assert.Nil(err)
+	suite.assert.Equal(10, n)
+
+	n, err = suite.cipher.readAt(suite.fd(), buf, int64(len(data)))
+	suite.assert.Nil(err)
+	suite.assert.Equal(0, n)
+
+	// Nothing of the plain contents is on disk
+	physical, err := os.ReadFile(suite.file.Name())
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(encryptedSize(int64(len(data))), len(physical))
+	suite.assert.False(bytes.Contains(physical, data[:64]))
+}
+
+func (suite *cacheCipherTestSuite) TestRandomOperations() {
+	model := make([]byte, 0)
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		if rnd.Intn(4) == 0 {
+			size := rnd.Intn(4 * encryptionChunkSize)
+			err := suite.cipher.truncate(suite.fd(), int64(size))
+			suite.assert.Nil(err)
+			if size < len(model) {
+				model = model[:size]
+			} else {
+				model = append(model, make([]byte, size-len(model))...)
+			}
+		} else {
+			off := rnd.Intn(4 * encryptionChunkSize)
+			data := make([]byte, rnd.Intn(2*encryptionChunkSize)+1)
+			rnd.Read(data)
+			_, err := suite.cipher.writeAt(suite.fd(), data, int64(off))
+			suite.assert.Nil(err)
+			if off+len(data) > len(model) {
+				model = append(model, make([]byte, off+len(data)-len(model))...)
+			}
+			copy(model[off:], data)
+		}
+	}
+
+	suite.assert.Equal(model, suite.readAll())
+}
+
+func (suite *cacheCipherTestSuite) TestHolesReadAsZeros() {
+	_, err := suite.cipher.writeAt(suite.fd(), []byte("data"), 0)
+	suite.assert.Nil(err)
+
+	// Writing past the end leaves the chunks in between as holes
+	_, err = suite.cipher.writeAt(suite.fd(), []byte("end"), 3*encryptionChunkSize)
+	suite.assert.Nil(err)
+
+	expected := make([]byte, 3*encryptionChunkSize+3)
+	copy(expected, "data")
+	copy(expected[3*encryptionChunkSize:], "end")
+	suite.assert.Equal(expected, suite.readAll())
+}
+
+func (suite *cacheCipherTestSuite) TestTamperedChunk() {
+	data := make([]byte, 2*encryptionChunkSize)
+	rand.Read(data)
+	_, err := suite.cipher.writeAt(suite.fd(), data, 0)
+	suite.assert.Nil(err)
+
+	_, err = suite.file.WriteAt([]byte{0xff}, encryptedChunkSize+100)
+	suite.assert.Nil(err)
+
+	buf := make([]byte, 10)
+	_, err = suite.cipher.readAt(suite.fd(), buf, 0)
+	suite.assert.Nil(err)
+	_, err = suite.cipher.readAt(suite.fd(), buf, encryptionChunkSize)
+	suite.assert.Equal(syscall.EIO, err)
+}
+
+func (suite *cacheCipherTestSuite) TestWrongKey() {
+	_, err := suite.cipher.writeAt(suite.fd(), []byte("secret"), 0)
+	suite.assert.Nil(err)
+
+	other, err := newCacheCipher([]byte("fedcba9876543210"))
+	suite.assert.Nil(err)
+	suite.assert.NotEqual(suite.cipher.keyID(), other.keyID())
+
+	buf := make([]byte, 6)
+	_, err = other.readAt(suite.fd(), buf, 0)
+	suite.assert.Equal(syscall.EIO, err)
+}
+
+func (suite *cacheCipherTestSuite) TestUploadOptions() {
+	data := make([]byte, 3*encryptionChunkSize+10)
+	rand.Read(data)
+	_, err := suite.cipher.writeAt(suite.fd(), data, 0)
+	suite.assert.Nil(err)
+
+	options, err := suite.cipher.uploadOptions("file", suite.file)
+	suite.assert.Nil(err)
+	suite.assert.Nil(options.File)
+
+	src, size, err := options.Source()
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), size)
+	plain, err := io.ReadAll(io.NewSectionReader(src, 0, size))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, plain)
+
+	// Reads past the end are short
+	buf := make([]byte, 20)
+	n, err := src.ReadAt(buf, size-10)
+	suite.assert.Equal(io.EOF, err)
+	suite.assert.Equal(10, n)
+	suite.assert.Equal(data[size-10:], buf[:n])
+}
+
+func (suite *cacheCipherTestSuite) TestLoadKey() {
+	keyFile := filepath.Join(home_dir, "cachekey"+randomString(8))
+	defer os.Remove(keyFile)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	_ = os.WriteFile(keyFile, key, 0600)
+	loaded, err := loadCacheKey(keyFile)
+	suite.assert.Nil(err)
+	suite.assert.Equal(key, loaded)
+
+	// Line ending of the key file is not part of the key
+	_ = os.WriteFile(keyFile, append(key, '\n'), 0600)
+	loaded, err = loadCacheKey(keyFile)
+	suite.assert.Nil(err)
+	suite.assert.Equal(key, loaded)
+
+	_ = os.WriteFile(keyFile, []byte("short"), 0600)
+	_, err = loadCacheKey(keyFile)
+	suite.assert.NotNil(err)
+
+	// Without a key file the config shall be secure
+	_, err = loadCacheKey("")
+	suite.assert.NotNil(err)
+}
+
+// setupEncryptedCache : File cache encrypting the local copies with a key file
+func (suite *fileCacheTestSuite) setupEncryptedCache(extra string) string {
+	suite.cleanupTest()
+	keyFile := suite.cache_path + ".key"
+	err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600)
+	suite.assert.Nil(err)
+	suite.T().Cleanup(func() { os.Remove(keyFile) })
+
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  timeout-sec: 120\n  encryption: true\n  encryption-key-file: %s\n%s\nloopbackfs:\n  path: %s",
+		suite.cache_path, keyFile, extra, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+	return config
+}
+
+func (suite *fileCacheTestSuite) TestConfigEncryption() {
+	defer suite.cleanupTest()
+	suite.assert.Nil(suite.fileCache.cipher)
+
+	suite.setupEncryptedCache("")
+	suite.assert.NotNil(suite.fileCache.cipher)
+	suite.assert.True(suite.fileCache.offloadIO)
+}
+
+func (suite *fileCacheTestSuite) TestConfigEncryptionNoKey() {
+	defer suite.cleanupTest()
+	configuration := fmt.Sprintf("file_cache:\n  path: %s\n  encryption: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+
+	fileCache := NewFileCacheComponent()
+	config.ReadConfigFromReader(strings.NewReader(configuration))
+	err := fileCache.Configure(true)
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "encryption key")
+}
+
+func (suite *fileCacheTestSuite) TestEncryptedReadWrite() {
+	defer suite.cleanupTest()
+	suite.setupEncryptedCache("")
+
+	path := "file"
+	data := suite.createStorageFile(path, 3*encryptionChunkSize+10)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), handle.Size)
+	suite.assert.False(handle.Cached())
+
+	buf := make([]byte, 100)
+	n, err := suite.fileCache.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: encryptionChunkSize - 50, Data: buf})
+	suite.assert.Nil(err)
+	suite.assert.Equal(100, n)
+	suite.assert.Equal(data[encryptionChunkSize-50:encryptionChunkSize+50], buf)
+
+	// The local copy is not in plain
+	physical, err := os.ReadFile(filepath.Join(suite.cache_path, path))
+	suite.assert.Nil(err)
+	suite.assert.False(bytes.Contains(physical, data[:64]))
+
+	attr, err := suite.fileCache.GetAttr(internal.GetAttrOptions{Name: path})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	// Storage gets the plain contents on flush
+	_, err = suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 10, Data: []byte("changed")})
+	suite.assert.Nil(err)
+	copy(data[10:], "changed")
+	err = suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+
+	stored, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, stored)
+}
+
+func (suite *fileCacheTestSuite) TestEncryptedTruncate() {
+	defer suite.cleanupTest()
+	suite.setupEncryptedCache("")
+
+	path := "file"
+	data := suite.createStorageFile(path, encryptionChunkSize+10)
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+
+	err = suite.fileCache.TruncateFile(internal.TruncateFileOptions{Name: path, Size: 100})
+	suite.assert.Nil(err)
+
+	read, err := suite.fileCache.ReadFile(internal.ReadFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Equal(data[:100], read)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestEncryptedProgressiveOpen() {
+	defer suite.cleanupTest()
+	suite.setupEncryptedCache("  progressive-open: true\n  progressive-block-size-mb: 1\n")
+
+	path := "file"
+	data := suite.createStorageFile(path, 3*MB+10)
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.assert.Nil(err)
+
+	buf := make([]byte, 100)
+	n, err := suite.fileCache.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 2 * MB, Data: buf})
+	suite.assert.Nil(err)
+	suite.assert.Equal(100, n)
+	suite.assert.Equal(data[2*MB:2*MB+100], buf)
+
+	read, err := suite.fileCache.ReadFile(internal.ReadFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, read)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestEncryptedPersistIndex() {
+	defer suite.cleanupTest()
+	config := suite.setupEncryptedCache("  persist-index: true\n")
+
+	path := "file"
+	localPath := filepath.Join(suite.cache_path, path)
+	suite.createStorageFile(path, 1000)
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.assert.Nil(err)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	suite.remount(config)
+	suite.assert.True(suite.fileCache.policy.IsCached(localPath))
+
+	// Local copies encrypted with a key are not served without it
+	suite.remount(fmt.Sprintf("file_cache:\n  path: %s\n  timeout-sec: 120\n  persist-index: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path))
+	suite.assert.False(suite.fileCache.policy.IsCached(localPath))
+}
@@ -40,6 +40,7 @@ import (
 	"io/fs"
 	"math"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -70,12 +71,39 @@ type FileCache struct {
 	cleanupOnStart  bool
 	policyTrace     bool
 	missedChmodList sync.Map
+	missedTimesList sync.Map
+	pinned          sync.Map
+	pinPatterns     []string
+	pinLock         sync.Mutex
+	pinnedSizes     map[string]int64 // local path of pinned file -> size accounted for it
+	pinnedBytes     int64
 	mountPath       string
 	allowOther      bool
 	offloadIO       bool
 	syncToFlush     bool
 	maxCacheSize    float64
 
+	persistIndex bool
+	indexPath    string
+	remoteAttrs  sync.Map // name of cached file -> *cacheIndexEntry
+	stopIndex    chan bool
+
+	writeBack     bool
+	uploadWorkers uint32
+	journalPath   string
+	uploads       *uploadQueue
+	uploading     sync.Map // local paths of the files waiting for a background upload
+
+	dirtyPath string
+	dirty     sync.Map // name of file -> *dirtyMarker of local changes not yet in storage
+
+	progressiveOpen      bool
+	progressiveBlockSize int64
+	progressiveWorkers   uint32
+	downloads            sync.Map // name of file -> *progressiveDownload still running
+
+	cipher *cacheCipher // nil when local copies are kept in plain
+
 	defaultPermission os.FileMode
 }
 
@@ -99,6 +127,22 @@ type FileCacheOptions struct {
 	EnablePolicyTrace bool `config:"policy-trace" yaml:"policy-trace,omitempty"`
 	OffloadIO         bool `config:"offload-io" yaml:"offload-io,omitempty"`
 
+	Pin []string `config:"pin" yaml:"pin,omitempty"`
+
+	PersistIndex bool   `config:"persist-index" yaml:"persist-index,omitempty"`
+	IndexPath    string `config:"index-path" yaml:"index-path,omitempty"`
+
+	WriteBack     bool   `config:"write-back" yaml:"write-back,omitempty"`
+	UploadWorkers uint32 `config:"upload-workers" yaml:"upload-workers,omitempty"`
+	JournalPath   string `config:"journal-path" yaml:"journal-path,omitempty"`
+
+	ProgressiveOpen        bool   `config:"progressive-open" yaml:"progressive-open,omitempty"`
+	ProgressiveBlockSizeMB uint32 `config:"progressive-block-size-mb" yaml:"progressive-block-size-mb,omitempty"`
+	ProgressiveWorkers     uint32 `config:"progressive-workers" yaml:"progressive-workers,omitempty"`
+
+	Encryption        bool   `config:"encryption" yaml:"encryption,omitempty"`
+	EncryptionKeyFile string `config:"encryption-key-file" yaml:"encryption-key-file,omitempty"`
+
 	// v1 support
 	V1Timeout     uint32 `config:"file-cache-timeout-in-seconds" yaml:"-"`
 	EmptyDirCheck bool   `config:"empty-dir-check" yaml:"-"`
@@ -141,6 +185,30 @@ func (c *FileCache) Priority() internal.ComponentPriority {
 func (c *FileCache) Start(ctx context.Context) error {
 	log.Trace("Starting component : %s", c.Name())
 
+	if c.writeBack {
+		c.uploads = newUploadQueue(c.journalPath, c.uploadFile, c.holdForUpload, c.releaseUpload)
+	}
+
+	// Files changed before the previous mount went away are reconciled with storage before anything is cleaned up or served
+	unresolved, err := c.recoverDirtyFiles()
+	if err != nil {
+		log.Err("FileCache::Start : failed to read dirty markers %s [%s]", c.dirtyPath, err.Error())
+		unresolved++
+	}
+	if c.cleanupOnStart && unresolved > 0 {
+		log.Warn("FileCache::Start : %d changed files were not recovered, skipping cleanup of temp cache", unresolved)
+		c.cleanupOnStart = false
+	}
+
+	if c.cleanupOnStart && c.writeBack {
+		// Files not uploaded before the last unmount only exist in the temp path
+		pending, err := readJournal(c.journalPath)
+		if err == nil && len(pending) > 0 {
+			log.Warn("FileCache::Start : %d files were not uploaded, skipping cleanup of temp cache", len(pending))
+			c.cleanupOnStart = false
+		}
+	}
+
 	if c.cleanupOnStart {
 		err := c.TempCacheCleanup()
 		if err != nil {
@@ -152,7 +220,13 @@ func (c *FileCache) Start(ctx context.Context) error {
 		return fmt.Errorf("config error in %s error [cache policy missing]", c.Name())
 	}
 
-	err := c.policy.StartPolicy()
+	// Pinned files are never evicted, so a set larger than the cache would keep it over its limit
+	err = c.checkPinnedSet()
+	if err != nil {
+		return fmt.Errorf("config error in %s error [%s]", c.Name(), err.Error())
+	}
+
+	err = c.policy.StartPolicy()
 	if err != nil {
 		return fmt.Errorf("config error in %s error [fail to start policy]", c.Name())
 	}
@@ -160,6 +234,33 @@ func (c *FileCache) Start(ctx context.Context) error {
 	// create stats collector for file cache
 	fileCacheStatsCollector = stats_manager.NewStatsCollector(c.Name())
 
+	if c.persistIndex {
+		if c.cleanupOnStart {
+			_ = os.Remove(c.indexPath)
+		} else {
+			err = c.loadIndex()
+			if err != nil {
+				// Files left behind are downloaded again, same as without an index
+				log.Err("FileCache::Start : failed to load index %s [%s]", c.indexPath, err.Error())
+			}
+		}
+
+		c.stopIndex = make(chan bool, 1)
+		go c.saveIndexPeriodically(c.stopIndex)
+	}
+
+	c.pinCachedFiles()
+
+	if c.uploads != nil {
+		err = c.uploads.start(c.uploadWorkers)
+		if err != nil {
+			return fmt.Errorf("error in %s error [fail to start upload queue]", c.Name())
+		}
+	}
+
+	// Local copies of files changed in storage by other writers are dropped
+	internal.AddInvalidationHandler(c.Name(), c.invalidateRemoteChange)
+
 	return nil
 }
 
@@ -167,8 +268,40 @@ func (c *FileCache) Start(ctx context.Context) error {
 func (c *FileCache) Stop() error {
 	log.Trace("Stopping component : %s", c.Name())
 
-	_ = c.policy.ShutdownPolicy()
-	_ = c.TempCacheCleanup()
+	internal.RemoveInvalidationHandler(c.Name())
+
+	// Local copies still being downloaded are incomplete
+	c.downloads.Range(func(key, value interface{}) bool {
+		if value.(*progressiveDownload).cancel() {
+			_ = deleteFile(filepath.Join(c.tmpPath, key.(string)))
+		}
+		return true
+	})
+
+	if c.uploads != nil {
+		// Files flushed in write-back mode are uploaded before unmount
+		c.uploads.stop()
+	}
+
+	if c.persistIndex {
+		// Keep the cached files for the next mount
+		c.stopIndex <- true
+		err := c.saveIndex()
+		if err != nil {
+			log.Err("FileCache::Stop : failed to save index %s [%s]", c.indexPath, err.Error())
+		}
+		_ = c.policy.ShutdownPolicy()
+	} else {
+		_ = c.policy.ShutdownPolicy()
+		if (c.uploads != nil && c.uploads.hasPending()) || c.hasDirtyFiles() {
+			log.Err("FileCache::Stop : some files have changes not in storage, keeping them in %s", c.tmpPath)
+		} else {
+			_ = c.TempCacheCleanup()
+		}
+	}
+
+	// Only removed when no file is left to recover
+	_ = os.Remove(c.dirtyPath)
 
 	fileCacheStatsCollector.Destroy()
 
@@ -177,7 +310,7 @@ func (c *FileCache) Stop() error {
 
 func (c *FileCache) TempCacheCleanup() error {
 	// TODO : Cleanup temp cache dir before exit
-	if !isLocalDirEmpty(c.tmpPath) {
+	if !isCacheEmpty(c.tmpPath) {
 		log.Err("FileCache::TempCacheCleanup : Cleaning up temp directory %s", c.tmpPath)
 
 		dirents, err := os.ReadDir(c.tmpPath)
@@ -186,6 +319,9 @@ func (c *FileCache) TempCacheCleanup() error {
 		}
 
 		for _, entry := range dirents {
+			if entry.Name() == stateDirName {
+				continue
+			}
 			os.RemoveAll(filepath.Join(c.tmpPath, entry.Name()))
 		}
 	}
@@ -230,6 +366,72 @@ func (c *FileCache) Configure(_ bool) error {
 		return fmt.Errorf("config error in %s error [tmp-path not set]", c.Name())
 	}
 
+	c.dirtyPath = filepath.Join(stateDir(c.tmpPath), "dirty")
+	if hasMarkers(c.dirtyPath) {
+		// Files changed before the previous mount went away are recovered on start
+		log.Info("FileCache::Configure : %s has files changed by the previous mount", c.tmpPath)
+		c.allowNonEmpty = true
+	}
+
+	c.writeBack = conf.WriteBack
+	if c.writeBack {
+		// Files not uploaded by the previous mount are expected in the temp path
+		c.allowNonEmpty = true
+		c.journalPath = common.ExpandPath(conf.JournalPath)
+		if c.journalPath == "" {
+			c.journalPath = filepath.Join(stateDir(c.tmpPath), "journal")
+		}
+		c.uploadWorkers = conf.UploadWorkers
+		if c.uploadWorkers == 0 {
+			c.uploadWorkers = defaultUploadWorkers
+		}
+	}
+
+	c.progressiveOpen = conf.ProgressiveOpen
+	if c.progressiveOpen {
+		c.progressiveBlockSize = int64(conf.ProgressiveBlockSizeMB) * MB
+		if c.progressiveBlockSize == 0 {
+			c.progressiveBlockSize = defaultProgressiveBlockSizeMB * MB
+		}
+		c.progressiveWorkers = conf.ProgressiveWorkers
+		if c.progressiveWorkers == 0 {
+			c.progressiveWorkers = defaultProgressiveWorkers
+		}
+	}
+
+	if conf.Encryption {
+		key, err := loadCacheKey(conf.EncryptionKeyFile)
+		if err == nil {
+			c.cipher, err = newCacheCipher(key)
+		}
+		if err != nil {
+			log.Err("FileCache::Configure : failed to set up encryption [%s]", err.Error())
+			return fmt.Errorf("config error in %s [%s]", c.Name(), err.Error())
+		}
+		// Reads and writes come here to be decrypted, the kernel can not be handed the local copy
+		c.offloadIO = true
+	}
+
+	c.pinnedSizes = make(map[string]int64)
+	c.pinPatterns = make([]string, 0, len(conf.Pin))
+	for _, pattern := range conf.Pin {
+		if _, err := path.Match(pattern, ""); err != nil {
+			log.Err("FileCache::Configure : invalid pin pattern %s [%s]", pattern, err.Error())
+			return fmt.Errorf("config error in %s [invalid pin pattern %s]", c.Name(), pattern)
+		}
+		c.pinPatterns = append(c.pinPatterns, strings.Trim(pattern, "/"))
+	}
+
+	c.persistIndex = conf.PersistIndex
+	if c.persistIndex {
+		// Files of the previous mount are expected in the temp path
+		c.allowNonEmpty = true
+		c.indexPath = common.ExpandPath(conf.IndexPath)
+		if c.indexPath == "" {
+			c.indexPath = filepath.Join(stateDir(c.tmpPath), "index")
+		}
+	}
+
 	err = config.UnmarshalKey("mount-path", &c.mountPath)
 	if err == nil && c.mountPath == c.tmpPath {
 		log.Err("FileCache: config error [tmp-path is same as mount path]")
@@ -247,11 +449,17 @@ func (c *FileCache) Configure(_ bool) error {
 		}
 	}
 
-	if !isLocalDirEmpty(c.tmpPath) && !c.allowNonEmpty {
+	if !isCacheEmpty(c.tmpPath) && !c.allowNonEmpty {
 		log.Err("FileCache: config error %s directory is not empty", c.tmpPath)
 		return fmt.Errorf("config error in %s [%s]", c.Name(), "temp directory not empty")
 	}
 
+	err = createStateDir(c.tmpPath)
+	if err != nil {
+		log.Err("FileCache: config error creating state directory [%s]", err.Error())
+		return fmt.Errorf("config error in %s [%s]", c.Name(), err.Error())
+	}
+
 	err = config.UnmarshalKey("allow-other", &c.allowOther)
 	if err != nil {
 		log.Err("FileCache::Configure : config error [unable to obtain allow-other]")
@@ -271,6 +479,10 @@ func (c *FileCache) Configure(_ bool) error {
 		c.policy = NewLRUPolicy(cacheConfig)
 	case "lfu":
 		c.policy = NewLFUPolicy(cacheConfig)
+	case "arc":
+		c.policy = NewARCPolicy(cacheConfig)
+	case "2q":
+		c.policy = New2QPolicy(cacheConfig)
 	default:
 		log.Info("FileCache::Configure : Using default eviction policy")
 		c.policy = NewLRUPolicy(cacheConfig)
@@ -294,8 +506,8 @@ func (c *FileCache) Configure(_ bool) error {
 		log.Warn("Sync will upload current contents of file.")
 	}
 
-	log.Info("FileCache::Configure : create-empty %t, cache-timeout %d, tmp-path %s, max-size-mb %d, high-mark %d, low-mark %d",
-		c.createEmptyFile, int(c.cacheTimeout), c.tmpPath, int(cacheConfig.maxSizeMB), int(cacheConfig.highThreshold), int(cacheConfig.lowThreshold))
+	log.Info("FileCache::Configure : create-empty %t, cache-timeout %d, tmp-path %s, max-size-mb %d, high-mark %d, low-mark %d, persist-index %t, write-back %t, upload-workers %d, progressive-open %t, encryption %t",
+		c.createEmptyFile, int(c.cacheTimeout), c.tmpPath, int(cacheConfig.maxSizeMB), int(cacheConfig.highThreshold), int(cacheConfig.lowThreshold), c.persistIndex, c.writeBack, c.uploadWorkers, c.progressiveOpen, c.cipher != nil)
 
 	return nil
 }
@@ -311,7 +523,7 @@ func (c *FileCache) OnConfigChange() {
 	c.createEmptyFile = conf.CreateEmptyFile
 	c.cacheTimeout = float64(conf.Timeout)
 	c.policyTrace = conf.EnablePolicyTrace
-	c.offloadIO = conf.OffloadIO
+	c.offloadIO = conf.OffloadIO || c.cipher != nil
 	c.maxCacheSize = conf.MaxSizeMB
 	_ = c.policy.UpdateConfig(c.GetPolicyConfig(conf))
 }
@@ -360,19 +572,41 @@ func (c *FileCache) GetPolicyConfig(conf FileCacheOptions) cachePolicyConfig {
 		cacheTimeout:  uint32(conf.Timeout),
 		maxSizeMB:     conf.MaxSizeMB,
 		fileLocks:     c.fileLocks,
+		pinned:        &c.pinned,
+		uploading:     &c.uploading,
+		pinnedBytes:   &c.pinnedBytes,
 		policyTrace:   conf.EnablePolicyTrace,
 	}
 
 	return cacheConfig
 }
 
-// isLocalDirEmpty: Whether or not the local directory is empty.
-func isLocalDirEmpty(path string) bool {
-	f, _ := os.Open(path)
+// stateDir : Directory in the temp path that keeps the cache index, the upload journal and the dirty markers across mounts.
+// Scans of the temp path and the cache usage skip it, so the state is never served, pinned or evicted as a cached file.
+func stateDir(tmpPath string) string {
+	return filepath.Join(tmpPath, stateDirName)
+}
+
+// createStateDir : Create the state directory in the temp path if it does not exist yet
+func createStateDir(tmpPath string) error {
+	return os.MkdirAll(stateDir(tmpPath), 0755)
+}
+
+// isCacheEmpty : Whether the temp path has no cached files, the state directory does not count
+func isCacheEmpty(tmpPath string) bool {
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return true
+	}
 	defer f.Close()
 
-	_, err := f.Readdirnames(1)
-	return err == io.EOF
+	names, _ := f.Readdirnames(2)
+	for _, name := range names {
+		if name != stateDirName {
+			return false
+		}
+	}
+	return true
 }
 
 // invalidateDirectory: Recursively invalidates a directory in the file cache.
@@ -390,6 +624,9 @@ func (fc *FileCache) invalidateDirectory(name string) {
 	}
 	// TODO : wouldn't this cause a race condition? a thread might get the lock before we purge - and the file would be non-existent
 	err = filepath.WalkDir(localPath, func(path string, d fs.DirEntry, err error) error {
+		if path == stateDir(fc.tmpPath) {
+			return filepath.SkipDir
+		}
 		if err == nil && d != nil {
 			log.Debug("FileCache::invalidateDirectory : %s (%d) getting removed from cache", path, d.IsDir())
 			if !d.IsDir() {
@@ -417,7 +654,12 @@ func (fc *FileCache) invalidateDirectory(name string) {
 func (fc *FileCache) DeleteDir(options internal.DeleteDirOptions) error {
 	log.Trace("FileCache::DeleteDir : %s", options.Name)
 
-	err := fc.NextComponent().DeleteDir(options)
+	err := fc.waitForUploads(options.Name)
+	if err != nil {
+		return err
+	}
+
+	err = fc.NextComponent().DeleteDir(options)
 	if err != nil {
 		log.Err("FileCache::DeleteDir : %s failed", options.Name)
 		// There is a chance that meta file for directory was not created in which case
@@ -428,13 +670,13 @@ func (fc *FileCache) DeleteDir(options internal.DeleteDirOptions) error {
 	return err
 }
 
-// Creates a new object attribute
-func newObjAttr(path string, info fs.FileInfo) *internal.ObjAttr {
+// Creates a new object attribute from the local copy
+func (fc *FileCache) newObjAttr(path string, info fs.FileInfo) *internal.ObjAttr {
 	stat := info.Sys().(*syscall.Stat_t)
 	attrs := &internal.ObjAttr{
 		Path:  path,
 		Name:  info.Name(),
-		Size:  info.Size(),
+		Size:  fc.cipher.localSize(info.Size()),
 		Mode:  info.Mode(),
 		Mtime: time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec),
 		Atime: time.Unix(stat.Atim.Sec, stat.Atim.Nsec),
@@ -495,12 +737,12 @@ func (fc *FileCache) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAt
 					// If file is under download then taking size or mod time from it will be incorrect.
 					if !fc.fileLocks.Locked(entryPath) {
 						log.Debug("FileCache::ReadDir : updating %s from local cache", entryPath)
-						attrs[idx].Size = info.Size()
+						attrs[idx].Size = fc.cipher.localSize(info.Size())
 						attrs[idx].Mtime = info.ModTime()
 					}
 				} else if !fc.createEmptyFile { // Case 2 (file only in local cache) so create a new attributes and add them to the storage attributes
 					log.Debug("FileCache::ReadDir : serving %s from local cache", entryPath)
-					attr := newObjAttr(entryPath, info)
+					attr := fc.newObjAttr(entryPath, info)
 					attrs = append(attrs, attr)
 					pathToIndex[attr.Path] = len(attrs) - 1 // append adds to the end of an array
 				}
@@ -517,6 +759,17 @@ func (fc *FileCache) ReadDir(options internal.ReadDirOptions) ([]*internal.ObjAt
 func (fc *FileCache) StreamDir(options internal.StreamDirOptions) ([]*internal.ObjAttr, string, error) {
 	attrs, token, err := fc.NextComponent().StreamDir(options)
 
+	// Storage has an older version of the files still to be uploaded
+	for _, attr := range attrs {
+		if fc.isUploadPending(attr.Path) {
+			info, err := os.Stat(filepath.Join(fc.tmpPath, attr.Path))
+			if err == nil {
+				attr.Size = fc.cipher.localSize(info.Size())
+				attr.Mtime = info.ModTime()
+			}
+		}
+	}
+
 	if token == "" {
 		// This is the last set of objects retrieved from container so we need to add local files here
 		localPath := filepath.Join(fc.tmpPath, options.Name)
@@ -540,7 +793,7 @@ func (fc *FileCache) StreamDir(options internal.StreamDirOptions) ([]*internal.O
 					_, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: entryPath})
 					if err != nil && (err == syscall.ENOENT || os.IsNotExist(err)) {
 						log.Debug("FileCache::StreamDir : serving %s from local cache", entryPath)
-						attr := newObjAttr(entryPath, info)
+						attr := fc.newObjAttr(entryPath, info)
 						attrs = append(attrs, attr)
 					}
 				}
@@ -592,7 +845,13 @@ func (fc *FileCache) IsDirEmpty(options internal.IsDirEmptyOptions) bool {
 func (fc *FileCache) RenameDir(options internal.RenameDirOptions) error {
 	log.Trace("FileCache::RenameDir : src=%s, dst=%s", options.Src, options.Dst)
 
-	err := fc.NextComponent().RenameDir(options)
+	// Files still to be uploaded would be missed by the rename in storage
+	err := fc.waitForUploads(options.Src)
+	if err != nil {
+		return err
+	}
+
+	err = fc.NextComponent().RenameDir(options)
 	if err != nil {
 		log.Err("FileCache::RenameDir : error %s [%s]", options.Src, err.Error())
 		return err
@@ -613,6 +872,8 @@ func (fc *FileCache) CreateFile(options internal.CreateFileOptions) (*handlemap.
 	flock.Lock()
 	defer flock.Unlock()
 
+	fc.forgetRemoteAttr(options.Name)
+
 	// createEmptyFile was added to optionally support immutable containers. If customers do not care about immutability they can set this to true.
 	if fc.createEmptyFile {
 		// We tried moving CreateFile to a separate thread for better perf.
@@ -649,6 +910,8 @@ func (fc *FileCache) CreateFile(options internal.CreateFileOptions) (*handlemap.
 	// Increment the handle count in this lock item as there is one handle open for this now
 	flock.Inc()
 
+	fc.pinByRule(options.Name, localPath)
+
 	handle := handlemap.NewHandle(options.Name)
 	handle.UnixFD = uint64(f.Fd())
 
@@ -662,6 +925,10 @@ func (fc *FileCache) CreateFile(options internal.CreateFileOptions) (*handlemap.
 	// If an empty file is created in storage then there is no need to upload if FlushFile is called immediately after CreateFile.
 	if !fc.createEmptyFile {
 		handle.Flags.Set(handlemap.HandleFlagDirty)
+
+		// Not known to be in storage, the file is saved aside on recovery if storage has one by then
+		handle.SetValue(remoteBaseline, (*internal.ObjAttr)(nil))
+		fc.markDirty(handle)
 	}
 
 	return handle, nil
@@ -711,19 +978,29 @@ func (fc *FileCache) DeleteFile(options internal.DeleteFileOptions) error {
 	flock.Lock()
 	defer flock.Unlock()
 
+	fc.forgetRemoteAttr(options.Name)
+	fc.cancelDownload(options.Name)
+
+	// A file deleted before its upload started may never have reached storage
+	pending := fc.isUploadPending(options.Name)
+	fc.cancelUpload(options.Name)
+
 	err := fc.NextComponent().DeleteFile(options)
-	err = fc.validateStorageError(options.Name, err, "DeleteFile", false)
+	err = fc.validateStorageError(options.Name, err, "DeleteFile", pending)
 	if err != nil {
 		log.Err("FileCache::DeleteFile : error  %s [%s]", options.Name, err.Error())
 		return err
 	}
 
+	fc.clearDirty(options.Name)
+
 	localPath := filepath.Join(fc.tmpPath, options.Name)
 	err = deleteFile(localPath)
 	if err != nil && !os.IsNotExist(err) {
 		log.Err("FileCache::DeleteFile : failed to delete local file %s [%s]", localPath, err.Error())
 	}
 
+	fc.unpinFile(localPath)
 	fc.policy.CachePurge(localPath)
 
 	return nil
@@ -779,6 +1056,7 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 	localPath := filepath.Join(fc.tmpPath, options.Name)
 	var f *os.File
 	var err error
+	var baseline *internal.ObjAttr
 
 	flock := fc.fileLocks.Get(options.Name)
 	flock.Lock()
@@ -788,6 +1066,22 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 
 	downloadRequired, fileExists := fc.isDownloadRequired(localPath)
 
+	if downloadRequired && fileExists && fc.isUploadPending(options.Name) {
+		// The local copy is newer than storage till its upload commits
+		downloadRequired = false
+	}
+
+	if downloadRequired && fileExists && flock.Count() == 0 && fc.revalidateLocalCopy(options.Name, localPath) {
+		// The local copy, possibly left by a previous mount, is still what storage has
+		downloadRequired = false
+	}
+
+	// What the local copy was downloaded from, as recorded in the cache index
+	recorded, _ := fc.remoteAttrs.Load(options.Name)
+	if options.Flags&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC) != 0 {
+		fc.forgetRemoteAttr(options.Name)
+	}
+
 	if fileExists && flock.Count() > 0 {
 		// file exists in local cache and there is already an handle open for it
 		// In this case we can not redownload the file from container
@@ -832,15 +1126,18 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 			fileSize = int64(attr.Size)
 		}
 
-		if !attrReceived || fileSize > 0 {
+		progressive := fc.progressiveOpen && attrReceived && fileSize > fc.progressiveBlockSize && options.Flags&os.O_TRUNC == 0
+		if progressive {
+			// Reads wait only for the blocks they need while the rest of the file is downloaded in the background
+			err = fc.startDownload(options.Name, f, attr, options.Flags&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC) == 0)
+			if err != nil {
+				_ = f.Close()
+				_ = os.Remove(localPath)
+				return nil, err
+			}
+		} else if !attrReceived || fileSize > 0 {
 			// Download/Copy the file from storage to the local file.
-			err = fc.NextComponent().CopyToFile(
-				internal.CopyToFileOptions{
-					Name:   options.Name,
-					Offset: 0,
-					Count:  fileSize,
-					File:   f,
-				})
+			err = fc.downloadFile(options.Name, f, fileSize)
 			if err != nil {
 				// File was created locally and now download has failed so we need to delete it back from local cache
 				log.Err("FileCache::OpenFile : error downloading file from storage %s [%s]", options.Name, err.Error())
@@ -850,8 +1147,10 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 			}
 		}
 
-		log.Debug("FileCache::OpenFile : Download of %s is complete", options.Name)
-		f.Close()
+		if !progressive {
+			log.Debug("FileCache::OpenFile : Download of %s is complete", options.Name)
+			f.Close()
+		}
 
 		// After downloading the file, update the modified times and mode of the file.
 		fileMode := fc.defaultPermission
@@ -867,9 +1166,19 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 		// TODO: When chown is supported should we update that?
 
 		// chtimes shall be the last api otherwise calling chmod/chown will update the last change time
-		err = os.Chtimes(localPath, attr.Atime, attr.Mtime)
-		if err != nil {
-			log.Err("FileCache::OpenFile : Failed to change times of file %s [%s]", options.Name, err.Error())
+		// A file downloaded in the background gets its times once complete
+		if !progressive {
+			err = os.Chtimes(localPath, attr.Atime, attr.Mtime)
+			if err != nil {
+				log.Err("FileCache::OpenFile : Failed to change times of file %s [%s]", options.Name, err.Error())
+			}
+		}
+
+		if !progressive && attrReceived && options.Flags&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC) == 0 {
+			fc.recordRemoteAttr(options.Name, attr)
+		}
+		if attrReceived {
+			baseline = attr
 		}
 
 		fileCacheStatsCollector.UpdateStats(stats_manager.Increment, dlFiles, (int64)(1))
@@ -877,10 +1186,21 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 	} else {
 		log.Debug("FileCache::OpenFile : %s will be served from cache", options.Name)
 		fileCacheStatsCollector.UpdateStats(stats_manager.Increment, cacheServed, (int64)(1))
+
+		if recorded != nil {
+			entry := recorded.(*cacheIndexEntry)
+			baseline = &internal.ObjAttr{Path: options.Name, Name: filepath.Base(options.Name), ETag: entry.ETag, Size: entry.Size, Mtime: entry.Mtime}
+		}
+	}
+
+	flags := options.Flags
+	if fc.cipher != nil && flags&(os.O_WRONLY|os.O_RDWR) != 0 {
+		// Partial writes of a chunk read it first, and chunks are written at their own offset
+		flags = flags&^(os.O_WRONLY|os.O_APPEND) | os.O_RDWR
 	}
 
 	// Open the file and grab a shared lock to prevent deletion by the cache policy.
-	f, err = os.OpenFile(localPath, options.Flags, options.Mode)
+	f, err = os.OpenFile(localPath, flags, options.Mode)
 	if err != nil {
 		log.Err("FileCache::OpenFile : error opening cached file %s [%s]", options.Name, err.Error())
 		return nil, err
@@ -892,17 +1212,29 @@ func (fc *FileCache) OpenFile(options internal.OpenFileOptions) (*handlemap.Hand
 	handle := handlemap.NewHandle(options.Name)
 	inf, err := f.Stat()
 	if err == nil {
-		handle.Size = inf.Size()
+		handle.Size = fc.cipher.localSize(inf.Size())
 	}
 
+	fc.pinByRule(options.Name, localPath)
+
 	handle.UnixFD = uint64(f.Fd())
-	if !fc.offloadIO {
+	if !fc.offloadIO && fc.downloadInProgress(options.Name) == nil {
+		// Reads of a file still downloading come here to wait for their blocks
 		handle.Flags.Set(handlemap.HandleFlagCached)
 	}
 
 	log.Info("FileCache::OpenFile : file=%s, fd=%d", options.Name, f.Fd())
 	handle.SetFileObject(f)
 
+	if baseline != nil {
+		// What the local copy is made from, to check storage against if changes have to be recovered
+		handle.SetValue(remoteBaseline, baseline)
+	}
+
+	if options.Flags&(os.O_WRONLY|os.O_RDWR) != 0 && options.Flags&os.O_TRUNC == 0 {
+		fc.trackModifiedRanges(handle, baseline)
+	}
+
 	return handle, nil
 }
 
@@ -939,9 +1271,22 @@ func (fc *FileCache) CloseFile(options internal.CloseFileOptions) error {
 	}
 	flock.Dec()
 
-	// If it is an fsync op then purge the file
-	if options.Handle.Fsynced() {
-		log.Trace("FileCache::CloseFile : fsync/sync op, purging %s", options.Handle.Path)
+	if flock.Count() == 0 && fc.cancelDownload(options.Handle.Path) {
+		// Nobody reads the rest of the file, and a partial local copy cannot be served later
+		log.Debug("FileCache::CloseFile : download of %s stopped, purging local copy", options.Handle.Path)
+		localPath := filepath.Join(fc.tmpPath, options.Handle.Path)
+		err = deleteFile(localPath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Err("FileCache::CloseFile : failed to delete local file %s [%s]", localPath, err.Error())
+		}
+		fc.policy.CachePurge(localPath)
+		return nil
+	}
+
+	// If it is an fsync op, or eviction was asked for and this was the last handle, then purge the file
+	_, evict := options.Handle.GetValue(evictOnClose)
+	if (options.Handle.Fsynced() || (evict && flock.Count() == 0)) && !fc.isUploadPending(options.Handle.Path) {
+		log.Trace("FileCache::CloseFile : fsync/sync op or eviction, purging %s", options.Handle.Path)
 		localPath := filepath.Join(fc.tmpPath, options.Handle.Path)
 
 		err = deleteFile(localPath)
@@ -969,16 +1314,21 @@ func (fc *FileCache) ReadFile(options internal.ReadFileOptions) ([]byte, error)
 		return nil, syscall.EBADF
 	}
 
+	err := fc.waitForDownload(options.Handle.Path)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get file info so we know the size of data we expect to read.
-	info, err := f.Stat()
+	size, err := fc.cipher.size(int(f.Fd()))
 	if err != nil {
 		log.Err("FileCache::ReadFile : error stat %s [%s] ", options.Handle.Path, err.Error())
 		return nil, err
 	}
-	data := make([]byte, info.Size())
-	bytesRead, err := f.Read(data)
+	data := make([]byte, size)
+	bytesRead, err := fc.cipher.readAt(int(f.Fd()), data, 0)
 
-	if int64(bytesRead) != info.Size() {
+	if int64(bytesRead) != size {
 		log.Err("FileCache::ReadFile : error [couldn't read entire file] %s", options.Handle.Path)
 		return nil, syscall.EIO
 	}
@@ -1004,9 +1354,15 @@ func (fc *FileCache) ReadInBuffer(options internal.ReadInBufferOptions) (int, er
 		fc.policy.CacheValid(localPath)
 	}
 
+	err := fc.waitForRange(options.Handle, options.Offset, int64(len(options.Data)))
+	if err != nil {
+		log.Err("FileCache::ReadInBuffer : failed to download %s at %d [%s]", options.Handle.Path, options.Offset, err.Error())
+		return 0, err
+	}
+
 	// Removing f.ReadAt as it involves lot of house keeping and then calls syscall.Pread
 	// Instead we will call syscall directly for better perf
-	return syscall.Pread(options.Handle.FD(), options.Data, options.Offset)
+	return fc.cipher.readAt(options.Handle.FD(), options.Data, options.Offset)
 }
 
 // WriteFile: Write to the local file
@@ -1027,13 +1383,25 @@ func (fc *FileCache) WriteFile(options internal.WriteFileOptions) (int, error) {
 		fc.policy.CacheValid(localPath)
 	}
 
+	// A block downloaded after the write would overwrite it
+	err := fc.waitForRange(options.Handle, options.Offset, int64(len(options.Data)))
+	if err != nil {
+		log.Err("FileCache::WriteFile : failed to download %s at %d [%s]", options.Handle.Path, options.Offset, err.Error())
+		return 0, err
+	}
+
 	// Removing f.WriteAt as it involves lot of house keeping and then calls syscall.Pwrite
 	// Instead we will call syscall directly for better perf
-	bytesWritten, err := syscall.Pwrite(options.Handle.FD(), options.Data, options.Offset)
+	bytesWritten, err := fc.cipher.writeAt(options.Handle.FD(), options.Data, options.Offset)
 
 	if err == nil {
 		// Mark the handle dirty so the file is written back to storage on FlushFile.
 		options.Handle.Flags.Set(handlemap.HandleFlagDirty)
+		fc.markDirty(options.Handle)
+		recordModifiedRange(options.Handle, options.Offset, int64(bytesWritten))
+
+		// Times set before this write no longer apply once it is uploaded
+		fc.missedTimesList.Delete(options.Handle.Path)
 
 	} else {
 		log.Err("FileCache::WriteFile : failed to write %s [%s]", options.Handle.Path, err.Error())
@@ -1043,6 +1411,12 @@ func (fc *FileCache) WriteFile(options internal.WriteFileOptions) (int, error) {
 }
 
 func (fc *FileCache) SyncFile(options internal.SyncFileOptions) error {
+	// Changes flushed earlier shall be in storage once sync returns
+	err := fc.waitForUpload(options.Handle.Path)
+	if err != nil {
+		return err
+	}
+
 	if fc.syncToFlush {
 		options.Handle.Flags.Set(handlemap.HandleFlagDirty)
 	} else {
@@ -1090,6 +1464,12 @@ func (fc *FileCache) FlushFile(options internal.FlushFileOptions) error {
 			return syscall.EBADF
 		}
 
+		// The whole file is uploaded from the local copy
+		err := fc.waitForDownload(options.Handle.Path)
+		if err != nil {
+			return err
+		}
+
 		// Flush all data to disk that has been buffered by the kernel.
 		// We cannot close the incoming handle since the user called flush, note close and flush can be called on the same handle multiple times.
 		// To ensure the data is flushed to disk before writing to storage, we duplicate the handle and close that handle.
@@ -1106,52 +1486,88 @@ func (fc *FileCache) FlushFile(options internal.FlushFileOptions) error {
 			return syscall.EIO
 		}
 
-		// Write to storage
-		// Create a new handle for the SDK to use to upload (read local file)
-		// The local handle can still be used for read and write.
-		uploadHandle, err := os.Open(localPath)
-		if err != nil {
-			log.Err("FileCache::FlushFile : error [unable to open upload handle] %s [%s]", options.Handle.Path, err.Error())
-			return nil
+		localPath := filepath.Join(fc.tmpPath, options.Handle.Path)
+		if info, err := os.Stat(localPath); err == nil {
+			fc.resizePin(localPath, info.Size())
 		}
 
-		err = fc.NextComponent().CopyFromFile(
-			internal.CopyFromFileOptions{
-				Name: options.Handle.Path,
-				File: uploadHandle,
-			})
+		if fc.writeBack && !options.Handle.Fsynced() {
+			// Upload in the background, the local copy keeps serving reads meanwhile
+			fc.uploads.add(options.Handle.Path)
+			options.Handle.Flags.Clear(handlemap.HandleFlagDirty)
+			return nil
+		}
 
-		uploadHandle.Close()
+		// Only the blocks touched by writes are uploaded when the blob allows it
+		err = fc.uploadModifiedBlocks(options.Handle)
+		if err != nil {
+			err = fc.uploadFile(options.Handle.Path)
+		}
 		if err != nil {
-			log.Err("FileCache::FlushFile : %s upload failed [%s]", options.Handle.Path, err.Error())
 			return err
 		}
 
 		options.Handle.Flags.Clear(handlemap.HandleFlagDirty)
+		fc.clearDirty(options.Handle.Path)
+		fc.resetModifiedRanges(options.Handle)
+	}
 
-		// If chmod was done on the file before it was uploaded to container then setting up mode would have been missed
-		// Such file names are added to this map and here post upload we try to set the mode correctly
-		_, found := fc.missedChmodList.Load(options.Handle.Path)
-		if found {
-			// If file is found in map it means last chmod was missed on this
-			// Delete the entry from map so that any further flush do not try to update the mode again
-			fc.missedChmodList.Delete(options.Handle.Path)
-
-			// When chmod on container was missed, local file was updated with correct mode
-			// Here take the mode from local cache and update the container accordingly
-			localPath := filepath.Join(fc.tmpPath, options.Handle.Path)
-			info, err := os.Lstat(localPath)
-			if err == nil {
-				err = fc.Chmod(internal.ChmodOptions{Name: options.Handle.Path, Mode: info.Mode()})
-				if err != nil {
-					// chmod was missed earlier for this file and doing it now also
-					// resulted in error so ignore this one and proceed for flush handling
-					log.Err("FileCache::FlushFile : %s chmod failed [%s]", options.Handle.Path, err.Error())
-				}
+	return nil
+}
+
+// uploadFile: Upload the local copy of the file to storage
+func (fc *FileCache) uploadFile(name string) error {
+	localPath := filepath.Join(fc.tmpPath, name)
+
+	// Write to storage
+	// Create a new handle for the SDK to use to upload (read local file)
+	// The local handle can still be used for read and write.
+	uploadHandle, err := os.Open(localPath)
+	if err != nil {
+		log.Err("FileCache::uploadFile : error [unable to open upload handle] %s [%s]", name, err.Error())
+		return nil
+	}
+
+	options, err := fc.cipher.uploadOptions(name, uploadHandle)
+	if err == nil {
+		err = fc.NextComponent().CopyFromFile(options)
+	}
+
+	uploadHandle.Close()
+	if err != nil {
+		log.Err("FileCache::uploadFile : %s upload failed [%s]", name, err.Error())
+		return err
+	}
+
+	// If chmod was done on the file before it was uploaded to container then setting up mode would have been missed
+	// Such file names are added to this map and here post upload we try to set the mode correctly
+	_, found := fc.missedChmodList.Load(name)
+	if found {
+		// If file is found in map it means last chmod was missed on this
+		// Delete the entry from map so that any further flush do not try to update the mode again
+		fc.missedChmodList.Delete(name)
+
+		// When chmod on container was missed, local file was updated with correct mode
+		// Here take the mode from local cache and update the container accordingly
+		info, err := os.Lstat(localPath)
+		if err == nil {
+			err = fc.Chmod(internal.ChmodOptions{Name: name, Mode: info.Mode()})
+			if err != nil {
+				// chmod was missed earlier for this file and doing it now also
+				// resulted in error so ignore this one and proceed for flush handling
+				log.Err("FileCache::uploadFile : %s chmod failed [%s]", name, err.Error())
 			}
 		}
 	}
 
+	// Same as chmod, times set on the file before it was uploaded to container need to be set again post upload
+	value, found := fc.missedTimesList.LoadAndDelete(name)
+	if found {
+		err = fc.NextComponent().SetTimes(value.(internal.SetTimesOptions))
+		if err != nil {
+			log.Err("FileCache::uploadFile : %s set times failed [%s]", name, err.Error())
+		}
+	}
 	return nil
 }
 
@@ -1189,7 +1605,7 @@ func (fc *FileCache) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr
 			// If file is under download then taking size or mod time from it will be incorrect.
 			if !fc.fileLocks.Locked(options.Name) {
 				log.Debug("FileCache::GetAttr : updating %s from local cache", options.Name)
-				attrs.Size = info.Size()
+				attrs.Size = fc.cipher.localSize(info.Size())
 				attrs.Mtime = info.ModTime()
 			} else {
 				log.Debug("FileCache::GetAttr : %s is locked, use storage attributes", options.Name)
@@ -1201,7 +1617,7 @@ func (fc *FileCache) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr
 			} else {
 				log.Debug("FileCache::GetAttr : serving %s attr from local cache", options.Name)
 				exists = true
-				attrs = newObjAttr(options.Name, info)
+				attrs = fc.newObjAttr(options.Name, info)
 			}
 		}
 	}
@@ -1213,68 +1629,200 @@ func (fc *FileCache) GetAttr(options internal.GetAttrOptions) (*internal.ObjAttr
 	return attrs, nil
 }
 
-// RenameFile: Invalidate the file in local cache.
-func (fc *FileCache) RenameFile(options internal.RenameFileOptions) error {
-	log.Trace("FileCache::RenameFile : src=%s, dst=%s", options.Src, options.Dst)
+// CopyObject: Copy the file in storage and drop the stale local copy of the destination
+func (fc *FileCache) CopyObject(options internal.CopyObjectOptions) error {
+	log.Trace("FileCache::CopyObject : src=%s, dst=%s", options.Src, options.Dst)
 
-	sflock := fc.fileLocks.Get(options.Src)
-	sflock.Lock()
-	defer sflock.Unlock()
+	// Changes to the source that are only in the local cache would be missed by a copy in storage
+	err := fc.waitForUpload(options.Src)
+	if err != nil {
+		return err
+	}
+	if hasDirtyHandle(options.Src, options.SrcHandle) {
+		log.Info("FileCache::CopyObject : %s has changes not yet uploaded", options.Src)
+		return syscall.ENOTSUP
+	}
 
-	dflock := fc.fileLocks.Get(options.Dst)
-	dflock.Lock()
-	defer dflock.Unlock()
+	flock := fc.fileLocks.Get(options.Dst)
+	flock.Lock()
+	defer flock.Unlock()
 
-	err := fc.NextComponent().RenameFile(options)
-	err = fc.validateStorageError(options.Src, err, "RenameFile", false)
+	fc.forgetRemoteAttr(options.Dst)
+	fc.cancelUpload(options.Dst)
+
+	// Other handles of the destination would keep serving the local copy
+	openHandles := flock.Count()
+	if options.DstHandle != nil {
+		openHandles--
+	}
+	if openHandles > 0 {
+		log.Info("FileCache::CopyObject : %s is open elsewhere", options.Dst)
+		return syscall.ENOTSUP
+	}
+
+	err = fc.NextComponent().CopyObject(options)
 	if err != nil {
-		log.Err("FileCache::RenameFile : %s failed to rename file [%s]", options.Src, err.Error())
+		log.Err("FileCache::CopyObject : failed to copy %s to %s [%s]", options.Src, options.Dst, err.Error())
 		return err
 	}
 
-	localSrcPath := filepath.Join(fc.tmpPath, options.Src)
-	localDstPath := filepath.Join(fc.tmpPath, options.Dst)
+	localPath := filepath.Join(fc.tmpPath, options.Dst)
 
-	// in case of git clone multiple rename requests come for which destination files already exists in system
-	// if we do not perform rename operation locally and those destination files are cached then next time they are read
-	// we will be serving the wrong content (as we did not rename locally, we still be having older destination files with
-	// stale content). We either need to remove dest file as well from cache or just run rename to replace the content.
-	err = os.Rename(localSrcPath, localDstPath)
-	if err != nil && !os.IsNotExist(err) {
-		log.Err("FileCache::RenameFile : %s failed to rename local file %s [%s]", localSrcPath, err.Error())
+	// Mode set on create could not be applied while the destination existed only in the local cache
+	if _, found := fc.missedChmodList.LoadAndDelete(options.Dst); found {
+		if info, err := os.Lstat(localPath); err == nil {
+			err = fc.NextComponent().Chmod(internal.ChmodOptions{Name: options.Dst, Mode: info.Mode()})
+			if err != nil {
+				log.Err("FileCache::CopyObject : %s chmod failed [%s]", options.Dst, err.Error())
+			}
+		}
 	}
+	fc.missedTimesList.Delete(options.Dst)
 
-	if err != nil {
-		// If there was a problem in local rename then delete the destination file
-		// it might happen that dest file was already there and local rename failed
-		// so deleting local dest file ensures next open of that will get the updated file from container
-		err = deleteFile(localDstPath)
+	if options.DstHandle == nil {
+		// Next open downloads the copy
+		err = deleteFile(localPath)
 		if err != nil && !os.IsNotExist(err) {
-			log.Err("FileCache::RenameFile : %s failed to delete local file %s [%s]", localDstPath, err.Error())
+			log.Err("FileCache::CopyObject : failed to delete local file %s [%s]", localPath, err.Error())
 		}
-
-		fc.policy.CachePurge(localDstPath)
+		fc.policy.CachePurge(localPath)
+		return nil
 	}
 
-	err = deleteFile(localSrcPath)
-	if err != nil && !os.IsNotExist(err) {
-		log.Err("FileCache::RenameFile : %s failed to delete local file %s [%s]", localSrcPath, err.Error())
+	// Nothing written through the handle so far is worth uploading over the copy
+	options.DstHandle.Flags.Clear(handlemap.HandleFlagDirty)
+	fc.clearDirty(options.Dst)
+
+	// The handle goes on with its descriptor, so the copy is downloaded into the same local file
+	err = fc.reloadLocalCopy(options.DstHandle, localPath)
+	if err != nil {
+		log.Err("FileCache::CopyObject : failed to download %s to the open local file [%s]", options.Dst, err.Error())
+		options.DstHandle.SetValue(evictOnClose, true)
+		return err
 	}
 
-	fc.policy.CachePurge(localSrcPath)
 	return nil
 }
 
-// TruncateFile: Update the file with its new size.
-func (fc *FileCache) TruncateFile(options internal.TruncateFileOptions) error {
-	log.Trace("FileCache::TruncateFile : name=%s, size=%d", options.Name, options.Size)
-
-	flock := fc.fileLocks.Get(options.Name)
-	flock.Lock()
-	defer flock.Unlock()
+// reloadLocalCopy: Download the file over its local copy in place, open descriptors of the local copy read the new content.
+func (fc *FileCache) reloadLocalCopy(handle *handlemap.Handle, localPath string) error {
+	fc.cancelDownload(handle.Path)
 
-	err := fc.NextComponent().TruncateFile(options)
-	err = fc.validateStorageError(options.Name, err, "TruncateFile", true)
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: handle.Path})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(localPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = f.Truncate(0)
+	if err == nil && attr.Size > 0 {
+		err = fc.downloadFile(handle.Path, f, attr.Size)
+	}
+	if err == nil {
+		err = os.Chtimes(localPath, attr.Atime, attr.Mtime)
+	}
+	if err != nil {
+		return err
+	}
+
+	handle.Size = attr.Size
+	handle.SetValue(remoteBaseline, attr)
+	fc.resetModifiedRanges(handle)
+	fc.policy.CacheValid(localPath)
+	fileCacheStatsCollector.UpdateStats(stats_manager.Increment, dlFiles, (int64)(1))
+	return nil
+}
+
+// RenameFile: Invalidate the file in local cache.
+func (fc *FileCache) RenameFile(options internal.RenameFileOptions) error {
+	log.Trace("FileCache::RenameFile : src=%s, dst=%s", options.Src, options.Dst)
+
+	sflock := fc.fileLocks.Get(options.Src)
+	sflock.Lock()
+	defer sflock.Unlock()
+
+	dflock := fc.fileLocks.Get(options.Dst)
+	dflock.Lock()
+	defer dflock.Unlock()
+
+	fc.forgetRemoteAttr(options.Src)
+	fc.forgetRemoteAttr(options.Dst)
+
+	// Storage shall have the latest source before it is renamed, and the old destination is replaced
+	err := fc.waitForDownload(options.Src)
+	if err != nil {
+		return err
+	}
+	err = fc.waitForUpload(options.Src)
+	if err != nil {
+		return err
+	}
+	fc.cancelUpload(options.Dst)
+
+	err = fc.NextComponent().RenameFile(options)
+	err = fc.validateStorageError(options.Src, err, "RenameFile", false)
+	if err != nil {
+		log.Err("FileCache::RenameFile : %s failed to rename file [%s]", options.Src, err.Error())
+		return err
+	}
+
+	localSrcPath := filepath.Join(fc.tmpPath, options.Src)
+	localDstPath := filepath.Join(fc.tmpPath, options.Dst)
+
+	// in case of git clone multiple rename requests come for which destination files already exists in system
+	// if we do not perform rename operation locally and those destination files are cached then next time they are read
+	// we will be serving the wrong content (as we did not rename locally, we still be having older destination files with
+	// stale content). We either need to remove dest file as well from cache or just run rename to replace the content.
+	err = os.Rename(localSrcPath, localDstPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Err("FileCache::RenameFile : %s failed to rename local file %s [%s]", localSrcPath, err.Error())
+	}
+
+	if err != nil {
+		// If there was a problem in local rename then delete the destination file
+		// it might happen that dest file was already there and local rename failed
+		// so deleting local dest file ensures next open of that will get the updated file from container
+		err = deleteFile(localDstPath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Err("FileCache::RenameFile : %s failed to delete local file %s [%s]", localDstPath, err.Error())
+		}
+
+		fc.policy.CachePurge(localDstPath)
+	}
+
+	err = deleteFile(localSrcPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Err("FileCache::RenameFile : %s failed to delete local file %s [%s]", localSrcPath, err.Error())
+	}
+
+	fc.movePin(options.Src, options.Dst)
+	fc.moveDirty(options.Src, options.Dst)
+	fc.policy.CachePurge(localSrcPath)
+	return nil
+}
+
+// TruncateFile: Update the file with its new size.
+func (fc *FileCache) TruncateFile(options internal.TruncateFileOptions) error {
+	log.Trace("FileCache::TruncateFile : name=%s, size=%d", options.Name, options.Size)
+
+	flock := fc.fileLocks.Get(options.Name)
+	flock.Lock()
+	defer flock.Unlock()
+
+	fc.forgetRemoteAttr(options.Name)
+
+	err := fc.waitForDownload(options.Name)
+	if err != nil {
+		return err
+	}
+
+	err = fc.NextComponent().TruncateFile(options)
+	err = fc.validateStorageError(options.Name, err, "TruncateFile", true)
 	if err != nil {
 		log.Err("FileCache::TruncateFile : %s failed to truncate [%s]", options.Name, err.Error())
 		return err
@@ -1286,18 +1834,394 @@ func (fc *FileCache) TruncateFile(options internal.TruncateFileOptions) error {
 	if err == nil || os.IsExist(err) {
 		fc.policy.CacheValid(localPath)
 
-		if info.Size() != options.Size {
-			err = os.Truncate(localPath, options.Size)
+		if fc.cipher.localSize(info.Size()) != options.Size {
+			err = fc.truncateLocalFile(localPath, options.Size)
 			if err != nil {
 				log.Err("FileCache::TruncateFile : error truncating cached file %s [%s]", localPath, err.Error())
 				return err
 			}
+			fc.resizePin(localPath, options.Size)
+		}
+	}
+
+	return nil
+}
+
+// FallocateFile: Allocate or zero out a range of the file in storage and in the local cache.
+func (fc *FileCache) FallocateFile(options internal.FallocateFileOptions) error {
+	log.Trace("FileCache::FallocateFile : name=%s, offset=%d, length=%d", options.Name, options.Offset, options.Length)
+
+	flock := fc.fileLocks.Get(options.Name)
+	flock.Lock()
+	defer flock.Unlock()
+
+	fc.forgetRemoteAttr(options.Name)
+
+	err := fc.waitForDownload(options.Name)
+	if err != nil {
+		return err
+	}
+
+	err = fc.NextComponent().FallocateFile(options)
+	err = fc.validateStorageError(options.Name, err, "FallocateFile", true)
+	if err != nil {
+		log.Err("FileCache::FallocateFile : %s failed to fallocate [%s]", options.Name, err.Error())
+		return err
+	}
+
+	// Apply the same change to the file in the local cache
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+	_, err = os.Stat(localPath)
+	if err == nil || os.IsExist(err) {
+		fc.policy.CacheValid(localPath)
+
+		err = fallocateLocalFile(localPath, options, fc.cipher)
+		if err != nil {
+			log.Err("FileCache::FallocateFile : error allocating cached file %s [%s]", localPath, err.Error())
+			return err
+		}
+	}
+
+	if options.Handle != nil {
+		// The local copy open through the handle changed, so it is written back on flush
+		options.Handle.Flags.Set(handlemap.HandleFlagDirty)
+	}
+
+	return nil
+}
+
+// SeekFile: Find next data or hole in the file, answered from the local copy when the file is open.
+func (fc *FileCache) SeekFile(options internal.SeekFileOptions) (int64, error) {
+	log.Trace("FileCache::SeekFile : name=%s, offset=%d, whence=%d", options.Name, options.Offset, options.Whence)
+
+	if options.Handle == nil || options.Handle.GetFileObject() == nil {
+		return fc.NextComponent().SeekFile(options)
+	}
+
+	// Blocks not downloaded yet are holes in the local copy
+	if d := fc.downloadInProgress(options.Handle.Path); d != nil && options.Offset >= 0 {
+		err := d.waitRange(options.Offset, d.size-options.Offset)
+		if err != nil {
+			log.Err("FileCache::SeekFile : failed to download %s from %d [%s]", options.Name, options.Offset, err.Error())
+			return 0, err
 		}
 	}
 
+	f := options.Handle.GetFileObject()
+	if fc.cipher != nil {
+		// Chunks do not keep the holes of the file, so all of it is data
+		size, err := fc.cipher.size(int(f.Fd()))
+		if err != nil {
+			return 0, err
+		}
+		if options.Offset >= size {
+			return 0, syscall.ENXIO
+		}
+		if options.Whence == common.SeekData {
+			return options.Offset, nil
+		}
+		return size, nil
+	}
+
+	offset, err := syscall.Seek(int(f.Fd()), options.Offset, options.Whence)
+	if err != nil {
+		log.Err("FileCache::SeekFile : error seeking cached file %s [%s]", options.Name, err.Error())
+		return 0, err
+	}
+	return offset, nil
+}
+
+// CacheControl: Pin, unpin, evict or re-download the local copy of the file, or report its state.
+func (fc *FileCache) CacheControl(options internal.CacheControlOptions) (uint32, error) {
+	log.Trace("FileCache::CacheControl : name=%s, command=%x", options.Name, options.Command)
+
+	// Let the components below act first so that a re-download does not get stale attributes
+	status, err := fc.NextComponent().CacheControl(options)
+	if err != nil {
+		return status, err
+	}
+
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+	switch options.Command {
+	case common.CacheControlPin:
+		err = fc.pinFile(localPath, fc.cachedSize(options.Name))
+		if err == nil {
+			// The command does not open the file, so nothing may have downloaded it yet
+			err = fc.fetchFile(options.Name)
+			if err != nil {
+				fc.unpinFile(localPath)
+			}
+		}
+		if err == nil {
+			fc.policy.CacheValid(localPath)
+		}
+
+	case common.CacheControlUnpin:
+		fc.unpinFile(localPath)
+
+	case common.CacheControlEvict:
+		fc.unpinFile(localPath)
+		err = fc.evictFile(options)
+
+	case common.CacheControlRefresh:
+		err = fc.refreshFile(options)
+
+	case common.CacheControlPrefetch:
+		err = fc.prefetchFile(options)
+
+	case common.CacheControlStatus:
+		status |= fc.cacheStatus(options)
+	}
+
+	if err != nil {
+		log.Err("FileCache::CacheControl : command %x failed for %s [%s]", options.Command, options.Name, err.Error())
+	}
+	return status, err
+}
+
+// evictFile: Remove the local copy of the file, or mark the handle to do so on close.
+func (fc *FileCache) evictFile(options internal.CacheControlOptions) error {
+	flock := fc.fileLocks.Get(options.Name)
+	flock.Lock()
+	defer flock.Unlock()
+
+	// Open handles go on with the local copy, and local changes not yet uploaded would be lost
+	if flock.Count() > 0 || fc.isUploadPending(options.Name) {
+		return syscall.EBUSY
+	}
+
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+	err := deleteFile(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fc.policy.CachePurge(localPath)
 	return nil
 }
 
+// refreshFile: Download the file again in place of its local copy.
+func (fc *FileCache) refreshFile(options internal.CacheControlOptions) error {
+	flock := fc.fileLocks.Get(options.Name)
+	flock.Lock()
+	defer flock.Unlock()
+
+	// Open handles would go on with the local copy that is replaced, and local changes not yet uploaded would be lost
+	if flock.Count() > 0 || fc.isUploadPending(options.Name) {
+		return syscall.EBUSY
+	}
+
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+	info, err := os.Stat(localPath)
+	if os.IsNotExist(err) {
+		// Nothing cached, next open downloads the file anyway
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: options.Name})
+	if err != nil {
+		return err
+	}
+
+	// The download goes to a file next to the local copy, which is replaced only once the download completes
+	f, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".refresh-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = f.Chmod(info.Mode())
+	if err == nil {
+		err = fc.downloadFile(options.Name, f, attr.Size)
+	}
+	if err == nil {
+		err = os.Chtimes(f.Name(), attr.Atime, attr.Mtime)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), localPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	fc.policy.CacheValid(localPath)
+	fileCacheStatsCollector.UpdateStats(stats_manager.Increment, dlFiles, (int64)(1))
+	return nil
+}
+
+// prefetchFile: Download the file to the local cache ahead of its use, fails with ENOSPC if it does not fit in max-size-mb.
+func (fc *FileCache) prefetchFile(options internal.CacheControlOptions) error {
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+	if fc.maxCacheSize > 0 && !fc.policy.IsCached(localPath) {
+		size := fc.cachedSize(options.Name)
+		if getUsage(fc.tmpPath)+float64(size)/MB > fc.maxCacheSize {
+			return syscall.ENOSPC
+		}
+	}
+
+	return fc.fetchFile(options.Name)
+}
+
+// fetchFile: Download the file to the local cache, if it is not there already, by opening it.
+func (fc *FileCache) fetchFile(name string) error {
+	handle, err := fc.OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDONLY, Mode: fc.defaultPermission})
+	if err != nil {
+		return err
+	}
+
+	err = fc.waitForDownload(name)
+	closeErr := fc.CloseFile(internal.CloseFileOptions{Handle: handle})
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// cacheStatus: State of the file in the local cache.
+func (fc *FileCache) cacheStatus(options internal.CacheControlOptions) uint32 {
+	var status uint32
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+
+	if _, err := os.Stat(localPath); err == nil {
+		status |= common.CacheStatusCached
+	}
+
+	if _, pinned := fc.pinned.Load(localPath); pinned {
+		status |= common.CacheStatusPinned
+	}
+
+	if hasDirtyHandle(options.Name, nil) || fc.isUploadPending(options.Name) {
+		status |= common.CacheStatusDirty
+	}
+
+	return status
+}
+
+// invalidateRemoteChange: Drop the local copy of a file that was changed in storage outside this mount.
+func (fc *FileCache) invalidateRemoteChange(name string, isDir bool) {
+	if isDir {
+		// Directory listings are not cached here
+		return
+	}
+
+	flock := fc.fileLocks.Get(name)
+	flock.Lock()
+	defer flock.Unlock()
+
+	if fc.isUploadPending(name) {
+		log.Warn("FileCache::invalidateRemoteChange : %s changed in storage while its local changes are being uploaded", name)
+		return
+	}
+
+	if flock.Count() > 0 {
+		// Local changes win as they get uploaded on close, otherwise the copy is dropped once the last handle is closed
+		if hasDirtyHandle(name, nil) {
+			log.Warn("FileCache::invalidateRemoteChange : %s changed in storage while it has local changes", name)
+			return
+		}
+
+		handlemap.GetHandles().Range(func(_, value interface{}) bool {
+			handle := value.(*handlemap.Handle)
+			if handle.Path == name {
+				handle.SetValue(evictOnClose, true)
+			}
+			return true
+		})
+		return
+	}
+
+	localPath := filepath.Join(fc.tmpPath, name)
+	err := deleteFile(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Err("FileCache::invalidateRemoteChange : failed to delete local file %s [%s]", localPath, err.Error())
+	}
+	fc.policy.CachePurge(localPath)
+}
+
+// hasDirtyHandle: Whether the file has changes in the local cache that are not uploaded yet
+// handle is checked in addition to the handle map as callers may hold one that is not registered there
+func hasDirtyHandle(name string, handle *handlemap.Handle) bool {
+	if handle != nil && handle.Dirty() {
+		return true
+	}
+
+	dirty := false
+	handlemap.GetHandles().Range(func(_, value interface{}) bool {
+		h := value.(*handlemap.Handle)
+		if h.Path == name && h.Dirty() {
+			dirty = true
+			return false
+		}
+		return true
+	})
+	return dirty
+}
+
+// fallocateLocalFile : fallocate on the cached file, emulated where the local file system does not support the mode
+// or the local copy is encrypted
+func fallocateLocalFile(localPath string, options internal.FallocateFileOptions, c *cacheCipher) error {
+	f, err := os.OpenFile(localPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if c == nil {
+		var mode uint32
+		if options.KeepSize {
+			mode |= fallocKeepSize
+		}
+		if options.PunchHole {
+			if options.KeepSize {
+				mode |= fallocPunchHole
+			} else {
+				mode |= fallocZeroRange
+			}
+		}
+
+		err = syscall.Fallocate(int(f.Fd()), mode, options.Offset, options.Length)
+		if err != syscall.EOPNOTSUPP {
+			return err
+		}
+	}
+
+	size, err := c.size(int(f.Fd()))
+	if err != nil {
+		return err
+	}
+	end := options.Offset + options.Length
+	if options.PunchHole && options.Offset < size {
+		zeroEnd := int64(math.Min(float64(end), float64(size)))
+		zeroes := make([]byte, int64(math.Min(float64(zeroEnd-options.Offset), float64(MB))))
+		for off := options.Offset; off < zeroEnd; off += int64(len(zeroes)) {
+			_, err = c.writeAt(int(f.Fd()), zeroes[:int64(math.Min(float64(len(zeroes)), float64(zeroEnd-off)))], off)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !options.KeepSize && end > size {
+		return c.truncate(int(f.Fd()), end)
+	}
+	return nil
+}
+
+// truncateLocalFile : Change the size of the cached file
+func (fc *FileCache) truncateLocalFile(localPath string, size int64) error {
+	if fc.cipher == nil {
+		return os.Truncate(localPath, size)
+	}
+
+	f, err := os.OpenFile(localPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fc.cipher.truncate(int(f.Fd()), size)
+}
+
 // Chmod : Update the file with its new permissions
 func (fc *FileCache) Chmod(options internal.ChmodOptions) error {
 	log.Trace("FileCache::Chmod : Change mode of path %s", options.Name)
@@ -1360,6 +2284,110 @@ func (fc *FileCache) Chown(options internal.ChownOptions) error {
 	return nil
 }
 
+// SetTimes : Update the file with its new access and modification times
+func (fc *FileCache) SetTimes(options internal.SetTimesOptions) error {
+	log.Trace("FileCache::SetTimes : Set times of path %s", options.Name)
+
+	// Update the file in storage
+	err := fc.NextComponent().SetTimes(options)
+	err = fc.validateStorageError(options.Name, err, "SetTimes", false)
+	if err != nil {
+		if err != syscall.EIO {
+			log.Err("FileCache::SetTimes : %s failed to set times [%s]", options.Name, err.Error())
+			return err
+		} else {
+			fc.missedTimesList.Store(options.Name, options)
+		}
+	} else if hasDirtyHandle(options.Name, nil) || fc.isUploadPending(options.Name) {
+		// Uploading the local changes resets the times in storage, so they are set again post upload
+		fc.missedTimesList.Store(options.Name, options)
+	}
+
+	// Update the times of the file in the local cache
+	localPath := filepath.Join(fc.tmpPath, options.Name)
+	info, err := os.Stat(localPath)
+	if err == nil || os.IsExist(err) {
+		fc.policy.CacheValid(localPath)
+
+		atime, mtime := options.Atime, options.Mtime
+		if atime.IsZero() {
+			stat := info.Sys().(*syscall.Stat_t)
+			atime = time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+		}
+		if mtime.IsZero() {
+			mtime = info.ModTime()
+		}
+
+		err = os.Chtimes(localPath, atime, mtime)
+		if err != nil {
+			log.Err("FileCache::SetTimes : error changing times on the cached path %s [%s]", localPath, err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetXattr : Update the extended attribute of the path in storage
+func (fc *FileCache) SetXattr(options internal.SetXattrOptions) error {
+	log.Trace("FileCache::SetXattr : Set xattr %s of path %s", options.Attr, options.Name)
+
+	err := fc.NextComponent().SetXattr(options)
+	err = fc.validateStorageError(options.Name, err, "SetXattr", false)
+	if err != nil {
+		log.Err("FileCache::SetXattr : %s failed to set xattr %s [%s]", options.Name, options.Attr, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// GetXattr : Get the extended attribute of the path from storage
+func (fc *FileCache) GetXattr(options internal.GetXattrOptions) ([]byte, error) {
+	log.Trace("FileCache::GetXattr : Get xattr %s of path %s", options.Attr, options.Name)
+
+	value, err := fc.NextComponent().GetXattr(options)
+	if err == syscall.ENOENT && fc.isCachedLocally(options.Name) {
+		// File has not been uploaded yet so it cannot carry any extended attribute
+		return nil, syscall.ENODATA
+	}
+
+	return value, err
+}
+
+// ListXattr : List the extended attributes of the path from storage
+func (fc *FileCache) ListXattr(options internal.ListXattrOptions) ([]string, error) {
+	log.Trace("FileCache::ListXattr : List xattrs of path %s", options.Name)
+
+	attrs, err := fc.NextComponent().ListXattr(options)
+	if err == syscall.ENOENT && fc.isCachedLocally(options.Name) {
+		// File has not been uploaded yet so it cannot carry any extended attribute
+		return []string{}, nil
+	}
+
+	return attrs, err
+}
+
+// RemoveXattr : Remove the extended attribute of the path in storage
+func (fc *FileCache) RemoveXattr(options internal.RemoveXattrOptions) error {
+	log.Trace("FileCache::RemoveXattr : Remove xattr %s of path %s", options.Attr, options.Name)
+
+	err := fc.NextComponent().RemoveXattr(options)
+	err = fc.validateStorageError(options.Name, err, "RemoveXattr", false)
+	if err != nil {
+		log.Err("FileCache::RemoveXattr : %s failed to remove xattr %s [%s]", options.Name, options.Attr, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// isCachedLocally : Check whether the path exists in the local cache
+func (fc *FileCache) isCachedLocally(name string) bool {
+	_, err := os.Stat(filepath.Join(fc.tmpPath, name))
+	return err == nil
+}
+
 func (fc *FileCache) FileUsed(name string) error {
 	// Update the owner and group of the file in the local cache
 	localPath := filepath.Join(fc.tmpPath, name)
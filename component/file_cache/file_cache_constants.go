@@ -38,4 +38,18 @@ const (
 	usgPer      = "Usage Percent"
 	dlFiles     = "Files Downloaded"
 	cacheServed = "Files served from cache"
+	ghostHits   = "Ghost List Hits"
+)
+
+// hidden directory in the temp path for the state of the cache, it is not a cached file or directory
+const stateDirName = ".blobfuse2"
+
+// handle value set when eviction of the file is asked for while it is open
+const evictOnClose = "evictOnClose"
+
+// fallocate mode flags from linux/falloc.h
+const (
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+	fallocZeroRange = 0x10
 )
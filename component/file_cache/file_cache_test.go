@@ -220,6 +220,26 @@ func (suite *fileCacheTestSuite) TestConfigZero() {
 	suite.assert.Equal(suite.fileCache.cleanupOnStart, cleanupOnStart)
 }
 
+func (suite *fileCacheTestSuite) TestConfigGhostPolicies() {
+	defer suite.cleanupTest()
+	for _, policy := range []string{"arc", "2q"} {
+		suite.cleanupTest() // teardown the file cache generated earlier
+		maxSizeMb := 1024
+		maxDeletion := 10
+		highThreshold := 90
+		lowThreshold := 10
+		config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  policy: %s\n  max-size-mb: %d\n  max-eviction: %d\n  high-threshold: %d\n  low-threshold: %d",
+			suite.cache_path, policy, maxSizeMb, maxDeletion, highThreshold, lowThreshold)
+		suite.setupTestHelper(config)
+
+		suite.assert.Equal(suite.fileCache.policy.Name(), policy)
+		suite.assert.EqualValues(suite.fileCache.policy.(*ghostPolicy).maxSizeMB, maxSizeMb)
+		suite.assert.EqualValues(suite.fileCache.policy.(*ghostPolicy).maxEviction, maxDeletion)
+		suite.assert.EqualValues(suite.fileCache.policy.(*ghostPolicy).highThreshold, highThreshold)
+		suite.assert.EqualValues(suite.fileCache.policy.(*ghostPolicy).lowThreshold, lowThreshold)
+	}
+}
+
 // Tests CreateDir
 func (suite *fileCacheTestSuite) TestCreateDir() {
 	defer suite.cleanupTest()
@@ -786,6 +806,138 @@ func (suite *fileCacheTestSuite) TestCloseFileTimeout() {
 	suite.assert.True(err == nil || os.IsExist(err))
 }
 
+func (suite *fileCacheTestSuite) TestCacheControlPin() {
+	defer suite.cleanupTest()
+	suite.cleanupTest() // teardown the default file cache generated
+	cacheTimeout := 1
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  timeout-sec: %d\n\nloopbackfs:\n  path: %s",
+		suite.cache_path, cacheTimeout, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	_, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlPin})
+	suite.assert.Nil(err)
+	err = suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+
+	status, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlStatus})
+	suite.assert.Nil(err)
+	suite.assert.Equal(common.CacheStatusCached|common.CacheStatusPinned, status)
+
+	// Pinned file outlives the cache timeout
+	time.Sleep(time.Duration(cacheTimeout*3) * time.Second)
+	_, err = os.Stat(suite.cache_path + "/" + path)
+	suite.assert.Nil(err)
+
+	// Once unpinned it is evicted as usual
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlUnpin})
+	suite.assert.Nil(err)
+	_, err = os.Stat(suite.cache_path + "/" + path)
+	for i := 0; i < (cacheTimeout*5) && !os.IsNotExist(err); i++ {
+		time.Sleep(time.Second)
+		_, err = os.Stat(suite.cache_path + "/" + path)
+	}
+	suite.assert.True(os.IsNotExist(err))
+}
+
+func (suite *fileCacheTestSuite) TestCacheControlEvict() {
+	defer suite.cleanupTest()
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	handlemap.Add(handle)
+	defer handlemap.Delete(handle.ID)
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("data")})
+
+	status, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlStatus})
+	suite.assert.Nil(err)
+	suite.assert.Equal(common.CacheStatusCached|common.CacheStatusDirty, status)
+
+	// An open handle keeps the file in cache
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlEvict})
+	suite.assert.Equal(syscall.EBUSY, err)
+	err = suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlEvict})
+	suite.assert.Nil(err)
+	_, err = os.Stat(suite.cache_path + "/" + path)
+	suite.assert.True(os.IsNotExist(err))
+
+	// Data was uploaded before the eviction
+	data, _ := os.ReadFile(suite.fake_storage_path + "/" + path)
+	suite.assert.Equal("data", string(data))
+}
+
+func (suite *fileCacheTestSuite) TestCacheControlRefresh() {
+	defer suite.cleanupTest()
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("data")})
+
+	// Dirty handle can not be refreshed
+	_, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlRefresh})
+	suite.assert.Equal(syscall.EBUSY, err)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Changes made directly in storage show up in the cached copy
+	os.WriteFile(suite.fake_storage_path+"/"+path, []byte("new data"), 0777)
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlRefresh})
+	suite.assert.Nil(err)
+	data, _ := os.ReadFile(suite.cache_path + "/" + path)
+	suite.assert.Equal("new data", string(data))
+
+	// An open handle would keep reading the replaced copy
+	handle, _ = suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlRefresh})
+	suite.assert.Equal(syscall.EBUSY, err)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestCacheControlRefreshFailed() {
+	defer suite.cleanupTest()
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("data")})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Storage fails to read the file, the local copy is kept as it was
+	os.Remove(suite.fake_storage_path + "/" + path)
+	os.Mkdir(suite.fake_storage_path+"/"+path, 0777)
+	_, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlRefresh})
+	suite.assert.NotNil(err)
+	data, _ := os.ReadFile(suite.cache_path + "/" + path)
+	suite.assert.Equal("data", string(data))
+
+	suite.assert.False(isCacheEmpty(suite.cache_path))
+	entries, _ := os.ReadDir(suite.cache_path)
+	suite.assert.Len(entries, 2) // the file and the state directory
+}
+
+func (suite *fileCacheTestSuite) TestCacheControlPrefetch() {
+	defer suite.cleanupTest()
+	suite.cleanupTest() // teardown the default file cache generated
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  timeout-sec: 120\n  max-size-mb: 1\n\nloopbackfs:\n  path: %s",
+		suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+
+	os.WriteFile(suite.fake_storage_path+"/small", make([]byte, 512*1024), 0777)
+	os.WriteFile(suite.fake_storage_path+"/large", make([]byte, 1024*1024), 0777)
+
+	// Prefetch downloads the file without an open handle
+	_, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: "small", Command: common.CacheControlPrefetch})
+	suite.assert.Nil(err)
+	status, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: "small", Command: common.CacheControlStatus})
+	suite.assert.Nil(err)
+	suite.assert.Equal(common.CacheStatusCached, status)
+
+	// File that does not fit in max-size-mb is not downloaded
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: "large", Command: common.CacheControlPrefetch})
+	suite.assert.Equal(syscall.ENOSPC, err)
+	_, err = os.Stat(suite.cache_path + "/large")
+	suite.assert.True(os.IsNotExist(err))
+}
+
 func (suite *fileCacheTestSuite) TestReadFileEmpty() {
 	defer suite.cleanupTest()
 	// Setup
@@ -1226,6 +1378,169 @@ func (suite *fileCacheTestSuite) TestTruncateFileInCache() {
 	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: openHandle})
 }
 
+func (suite *fileCacheTestSuite) TestFallocateFileInCache() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	createHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0666})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: createHandle})
+	openHandle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Mode: 0666})
+
+	// Fallocate
+	size := 4096
+	err := suite.fileCache.FallocateFile(internal.FallocateFileOptions{Name: path, Offset: 0, Length: int64(size)})
+	suite.assert.Nil(err)
+	// Path in fake storage and file cache should be updated
+	info, _ := os.Stat(suite.cache_path + "/" + path)
+	suite.assert.EqualValues(size, info.Size())
+	info, _ = os.Stat(suite.fake_storage_path + "/" + path)
+	suite.assert.EqualValues(size, info.Size())
+
+	// Allocation with keep size should not change the size
+	err = suite.fileCache.FallocateFile(internal.FallocateFileOptions{Name: path, Offset: 0, Length: int64(2 * size), KeepSize: true})
+	suite.assert.Nil(err)
+	info, _ = os.Stat(suite.cache_path + "/" + path)
+	suite.assert.EqualValues(size, info.Size())
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: openHandle})
+}
+
+func (suite *fileCacheTestSuite) TestFallocateFileHandleDirty() {
+	defer suite.cleanupTest()
+	path := "file"
+	createHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0666})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: createHandle})
+	openHandle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Mode: 0666})
+	suite.assert.False(openHandle.Dirty())
+
+	err := suite.fileCache.FallocateFile(internal.FallocateFileOptions{Handle: openHandle, Name: path, Offset: 0, Length: 4096})
+	suite.assert.Nil(err)
+	suite.assert.True(openHandle.Dirty())
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: openHandle})
+}
+
+func (suite *fileCacheTestSuite) TestSeekFile() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	data := []byte("data")
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+
+	// Open file is answered from the local copy
+	offset, err := suite.fileCache.SeekFile(internal.SeekFileOptions{Handle: handle, Name: path, Offset: 1, Whence: common.SeekData})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(1, offset)
+	offset, err = suite.fileCache.SeekFile(internal.SeekFileOptions{Handle: handle, Name: path, Offset: 1, Whence: common.SeekHole})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), offset)
+	_, err = suite.fileCache.SeekFile(internal.SeekFileOptions{Handle: handle, Name: path, Offset: int64(len(data)), Whence: common.SeekData})
+	suite.assert.Equal(syscall.ENXIO, err)
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Without a handle the query goes to storage
+	offset, err = suite.fileCache.SeekFile(internal.SeekFileOptions{Name: path, Offset: 1, Whence: common.SeekHole})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), offset)
+}
+
+func (suite *fileCacheTestSuite) TestCopyObject() {
+	defer suite.cleanupTest()
+	src := "src"
+	dst := "dst"
+	srcHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: src, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: srcHandle, Offset: 0, Data: []byte("data")})
+	dstHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: dst, Mode: 0777})
+
+	// Source changes are not uploaded yet
+	options := internal.CopyObjectOptions{SrcHandle: srcHandle, DstHandle: dstHandle, Src: src, Dst: dst}
+	err := suite.fileCache.CopyObject(options)
+	suite.assert.Equal(syscall.ENOTSUP, err)
+
+	suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: srcHandle})
+	err = suite.fileCache.CopyObject(options)
+	suite.assert.Nil(err)
+
+	// The open handle of the destination reads the copy and closing it does not upload its old content over the copy
+	data := make([]byte, 10)
+	n, err := suite.fileCache.ReadInBuffer(internal.ReadInBufferOptions{Handle: dstHandle, Offset: 0, Data: data})
+	suite.assert.Nil(err)
+	suite.assert.Equal("data", string(data[:n]))
+	suite.assert.False(dstHandle.Dirty())
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: dstHandle})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: srcHandle})
+
+	data, _ = os.ReadFile(suite.fake_storage_path + "/" + dst)
+	suite.assert.Equal("data", string(data))
+	data, _ = os.ReadFile(suite.cache_path + "/" + dst)
+	suite.assert.Equal("data", string(data))
+}
+
+func (suite *fileCacheTestSuite) TestCopyObjectWriteAfterCopy() {
+	defer suite.cleanupTest()
+	src := "src"
+	dst := "dst"
+	os.WriteFile(suite.fake_storage_path+"/"+src, []byte("data"), 0777)
+	dstHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: dst, Mode: 0777})
+
+	err := suite.fileCache.CopyObject(internal.CopyObjectOptions{DstHandle: dstHandle, Src: src, Dst: dst})
+	suite.assert.Nil(err)
+
+	// Writes after the copy go to the local file the handle has open and are uploaded on close
+	_, err = suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: dstHandle, Offset: 4, Data: []byte(" more")})
+	suite.assert.Nil(err)
+	suite.assert.Nil(suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: dstHandle}))
+
+	data, _ := os.ReadFile(suite.fake_storage_path + "/" + dst)
+	suite.assert.Equal("data more", string(data))
+}
+
+func (suite *fileCacheTestSuite) TestCopyObjectDestinationOpen() {
+	defer suite.cleanupTest()
+	src := "src"
+	dst := "dst"
+	os.WriteFile(suite.fake_storage_path+"/"+src, []byte("data"), 0777)
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: dst, Mode: 0777})
+	defer suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Handle other than the one the copy goes through keeps the local copy in use
+	err := suite.fileCache.CopyObject(internal.CopyObjectOptions{Src: src, Dst: dst})
+	suite.assert.Equal(syscall.ENOTSUP, err)
+}
+
+func (suite *fileCacheTestSuite) TestInvalidateRemoteChange() {
+	defer suite.cleanupTest()
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	_, err := os.Stat(suite.cache_path + "/" + path)
+	suite.assert.Nil(err)
+
+	internal.InvalidatePath(path, false)
+	_, err = os.Stat(suite.cache_path + "/" + path)
+	suite.assert.True(os.IsNotExist(err))
+}
+
+func (suite *fileCacheTestSuite) TestInvalidateRemoteChangeOpenFile() {
+	defer suite.cleanupTest()
+	path := "file"
+	os.WriteFile(suite.fake_storage_path+"/"+path, []byte("data"), 0777)
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	handlemap.Add(handle)
+	defer handlemap.Delete(handle.ID)
+
+	// Open handle keeps reading its copy till it is closed
+	internal.InvalidatePath(path, false)
+	_, err := os.Stat(suite.cache_path + "/" + path)
+	suite.assert.Nil(err)
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	_, err = os.Stat(suite.cache_path + "/" + path)
+	suite.assert.True(os.IsNotExist(err))
+}
+
 func (suite *fileCacheTestSuite) TestTruncateFileCase2() {
 	defer suite.cleanupTest()
 	// Default is to not create empty files on create file to support immutable storage.
@@ -1340,6 +1655,134 @@ func (suite *fileCacheTestSuite) TestChmodCase2() {
 	suite.assert.EqualValues(attr.Mode, newMode)
 }
 
+func (suite *fileCacheTestSuite) TestSetTimesInCache() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	createHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: createHandle})
+	openHandle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Mode: 0777})
+
+	mtime := time.Unix(1600000000, 0)
+	err := suite.fileCache.SetTimes(internal.SetTimesOptions{Name: path, Mtime: mtime})
+	suite.assert.Nil(err)
+
+	// Path in fake storage and file cache should be updated
+	info, err := os.Stat(suite.cache_path + "/" + path)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(mtime, info.ModTime())
+	info, err = os.Stat(suite.fake_storage_path + "/" + path)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(mtime, info.ModTime())
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: openHandle})
+}
+
+func (suite *fileCacheTestSuite) TestSetTimesNotInStorage() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+
+	mtime := time.Unix(1600000000, 0)
+	err := suite.fileCache.SetTimes(internal.SetTimesOptions{Name: path, Mtime: mtime})
+	suite.assert.Nil(err)
+	_, found := suite.fileCache.missedTimesList.Load(path)
+	suite.assert.True(found)
+
+	// Times are set in storage once the file is uploaded
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	info, err := os.Stat(suite.fake_storage_path + "/" + path)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(mtime, info.ModTime())
+	_, found = suite.fileCache.missedTimesList.Load(path)
+	suite.assert.False(found)
+}
+
+func (suite *fileCacheTestSuite) TestSetTimesDirty() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	createHandle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: createHandle})
+	handle, _ := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	handlemap.Add(handle)
+	data := []byte("changed")
+	_, err := suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+	suite.assert.Nil(err)
+
+	mtime := time.Unix(1600000000, 0)
+	err = suite.fileCache.SetTimes(internal.SetTimesOptions{Name: path, Mtime: mtime})
+	suite.assert.Nil(err)
+
+	// Uploading the local changes keeps the times set while the file was dirty
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	handlemap.Delete(handle.ID)
+	info, err := os.Stat(suite.fake_storage_path + "/" + path)
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), info.Size())
+	suite.assert.EqualValues(mtime, info.ModTime())
+	_, found := suite.fileCache.missedTimesList.Load(path)
+	suite.assert.False(found)
+
+	// A later write discards them
+	handle, _ = suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	err = suite.fileCache.SetTimes(internal.SetTimesOptions{Name: path, Mtime: mtime})
+	suite.assert.Nil(err)
+	_, err = suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+	suite.assert.Nil(err)
+	_, found = suite.fileCache.missedTimesList.Load(path)
+	suite.assert.False(found)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestXattrInStorage() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	err := suite.fileCache.SetXattr(internal.SetXattrOptions{Name: path, Attr: "user.origin", Value: []byte("pipeline")})
+	suite.assert.Nil(err)
+
+	value, err := suite.fileCache.GetXattr(internal.GetXattrOptions{Name: path, Attr: "user.origin"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("pipeline", string(value))
+
+	attrs, err := suite.fileCache.ListXattr(internal.ListXattrOptions{Name: path})
+	suite.assert.Nil(err)
+	suite.assert.Contains(attrs, "user.origin")
+
+	err = suite.fileCache.RemoveXattr(internal.RemoveXattrOptions{Name: path, Attr: "user.origin"})
+	suite.assert.Nil(err)
+
+	_, err = suite.fileCache.GetXattr(internal.GetXattrOptions{Name: path, Attr: "user.origin"})
+	suite.assert.Equal(syscall.ENODATA, err)
+}
+
+func (suite *fileCacheTestSuite) TestXattrNotInStorage() {
+	defer suite.cleanupTest()
+	// Setup
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	// Path should not be in fake storage until the handle is closed
+	_, err := os.Stat(suite.fake_storage_path + "/" + path)
+	suite.assert.True(os.IsNotExist(err))
+
+	_, err = suite.fileCache.GetXattr(internal.GetXattrOptions{Name: path, Attr: "user.origin"})
+	suite.assert.Equal(syscall.ENODATA, err)
+
+	attrs, err := suite.fileCache.ListXattr(internal.ListXattrOptions{Name: path})
+	suite.assert.Nil(err)
+	suite.assert.Empty(attrs)
+
+	err = suite.fileCache.SetXattr(internal.SetXattrOptions{Name: path, Attr: "user.origin", Value: []byte("pipeline")})
+	suite.assert.Equal(syscall.EIO, err)
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
 func (suite *fileCacheTestSuite) TestChownNotInCache() {
 	defer suite.cleanupTest()
 	// Setup
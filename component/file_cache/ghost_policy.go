@@ -0,0 +1,489 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"container/list"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal/stats_manager"
+)
+
+// Ghost lists remember at least this many evicted files, even when few files are cached
+const minGhostEntries = 1000
+
+// ghostLists : Lists of a policy that remembers the files it evicted, so a file used again soon after is kept longer
+type ghostLists interface {
+	access(name string) string         // Record a use of the file, returns the ghost list it was found in if any
+	remove(name string)                // Forget the file without keeping a ghost entry
+	victim() string                    // Cached file to evict next, empty if none
+	evict(name string)                 // Move the cached file to a ghost list
+	restore(name string, usage uint64) // Add a cached file, files used more than once go in the list evicted last
+	cached() (frequent, once []string) // Cached files used again and used once, the ones to evict last first
+	trace()                            // Log the lists
+}
+
+// ghostEntry : A cached file
+type ghostEntry struct {
+	usage    uint64
+	lastUsed time.Time
+	evicting bool // moved to a ghost list, local copy not deleted yet
+}
+
+// ghostPolicy : Eviction by usage threshold and timeout, in the order given by the ghost lists of ARC or 2Q
+type ghostPolicy struct {
+	sync.Mutex
+	cachePolicyConfig
+
+	name      string
+	lists     ghostLists
+	entries   map[string]*ghostEntry
+	ghostHits map[string]uint64 // ghost list -> number of files used again after being evicted from it
+
+	// Channel to close main channel select loop
+	closeSignal chan int
+
+	// Channel to contain files that needs to be deleted immediately
+	deleteEvent chan string
+
+	// Channel to check disk usage is within the limits configured or not
+	diskUsageMonitor <-chan time.Time
+
+	// Channel to check for file eviction based on file-cache timeout
+	cacheTimeoutMonitor <-chan time.Time
+}
+
+var _ cachePolicy = &ghostPolicy{}
+
+func newGhostPolicy(name string, lists ghostLists, cfg cachePolicyConfig) *ghostPolicy {
+	return &ghostPolicy{
+		cachePolicyConfig: cfg,
+		name:              name,
+		lists:             lists,
+		entries:           make(map[string]*ghostEntry),
+		ghostHits:         make(map[string]uint64),
+	}
+}
+
+func (p *ghostPolicy) StartPolicy() error {
+	log.Trace("ghostPolicy::StartPolicy : %s", p.name)
+
+	p.closeSignal = make(chan int)
+	p.deleteEvent = make(chan string, 1000)
+
+	p.diskUsageMonitor = time.Tick(time.Duration(DiskUsageCheckInterval * time.Minute))
+
+	// If timeout is zero files are deleted on invalidate so there is nothing to expire
+	if p.cacheTimeout != 0 {
+		p.cacheTimeoutMonitor = time.Tick(time.Duration(p.cacheTimeout) * time.Second)
+	}
+
+	go p.clearCache()
+	return nil
+}
+
+func (p *ghostPolicy) ShutdownPolicy() error {
+	log.Trace("ghostPolicy::ShutdownPolicy : %s", p.name)
+	p.closeSignal <- 1
+	return nil
+}
+
+func (p *ghostPolicy) UpdateConfig(c cachePolicyConfig) error {
+	log.Trace("ghostPolicy::UpdateConfig : %s", p.name)
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.maxSizeMB = c.maxSizeMB
+	p.highThreshold = c.highThreshold
+	p.lowThreshold = c.lowThreshold
+	p.maxEviction = c.maxEviction
+	p.policyTrace = c.policyTrace
+	return nil
+}
+
+func (p *ghostPolicy) CacheValid(name string) {
+	p.Lock()
+	defer p.Unlock()
+
+	ghost := p.lists.access(name)
+	if ghost != "" {
+		p.ghostHits[ghost]++
+		fileCacheStatsCollector.UpdateStats(stats_manager.Increment, ghostHits, (int64)(1))
+		fileCacheStatsCollector.UpdateStats(stats_manager.Increment, ghostHits+" "+ghost, (int64)(1))
+		if p.policyTrace {
+			log.Debug("ghostPolicy::CacheValid : %s found in %s ghost list %s, %d hits", name, p.name, ghost, p.ghostHits[ghost])
+		}
+	}
+
+	entry, found := p.entries[name]
+	if !found {
+		entry = &ghostEntry{}
+		p.entries[name] = entry
+	}
+	entry.usage++
+	entry.lastUsed = time.Now()
+	entry.evicting = false
+}
+
+func (p *ghostPolicy) CacheInvalidate(name string) {
+	log.Trace("ghostPolicy::CacheInvalidate : %s", name)
+
+	// A file not in the lists may still be on disk when its last handle is closed, so it is deleted as well
+	if p.cacheTimeout == 0 || !p.IsCached(name) {
+		p.CachePurge(name)
+	}
+}
+
+func (p *ghostPolicy) CachePurge(name string) {
+	log.Trace("ghostPolicy::CachePurge : %s", name)
+
+	p.Lock()
+	p.lists.remove(name)
+	delete(p.entries, name)
+	p.Unlock()
+
+	p.deleteEvent <- name
+}
+
+func (p *ghostPolicy) IsCached(name string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	_, found := p.entries[name]
+	log.Trace("ghostPolicy::IsCached : %s, found %t", name, found)
+	return found
+}
+
+// ExportState : Cached files, the ones the policy would evict last first
+func (p *ghostPolicy) ExportState() []policyState {
+	p.Lock()
+	defer p.Unlock()
+
+	frequent, once := p.lists.cached()
+	state := make([]policyState, 0, len(frequent)+len(once))
+	for _, name := range frequent {
+		usage := p.entries[name].usage
+		if usage < 2 {
+			usage = 2
+		}
+		state = append(state, policyState{name: name, usage: usage})
+	}
+	for _, name := range once {
+		// The lists count uses of a file not used again yet as one
+		state = append(state, policyState{name: name, usage: 1})
+	}
+	return state
+}
+
+// ImportState : Add the files to the lists, the ones to evict first go in first
+func (p *ghostPolicy) ImportState(state []policyState) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i := len(state) - 1; i >= 0; i-- {
+		if _, found := p.entries[state[i].name]; found {
+			continue
+		}
+		p.lists.restore(state[i].name, state[i].usage)
+		p.entries[state[i].name] = &ghostEntry{usage: state[i].usage, lastUsed: time.Now()}
+	}
+}
+
+func (p *ghostPolicy) Name() string {
+	return p.name
+}
+
+func (p *ghostPolicy) clearCache() {
+	log.Trace("ghostPolicy::clearCache : %s", p.name)
+
+	for {
+		select {
+		case name := <-p.deleteEvent:
+			// we are asked to delete file explicitly
+			if !p.deleteItem(name) {
+				p.CacheValid(name)
+			}
+
+		case <-p.cacheTimeoutMonitor:
+			// File cache timeout has hit so delete all unused files for past N seconds
+			p.printNodes()
+			p.evict(p.expiredVictims())
+
+		case <-p.diskUsageMonitor:
+			p.checkUsage()
+
+		case <-p.closeSignal:
+			return
+		}
+	}
+}
+
+// checkUsage : Evict files till usage is below the low threshold once it crosses the high threshold
+func (p *ghostPolicy) checkUsage() {
+	pUsage := getUsagePercentage(p.tmpPath, p.maxSizeMB, p.pinnedMB())
+	if pUsage <= p.highThreshold {
+		return
+	}
+
+	log.Info("ghostPolicy::checkUsage : High threshold reached %f > %f", pUsage, p.highThreshold)
+	p.printNodes()
+
+	for cleanupCount := 0; cleanupCount < 3 && pUsage >= p.lowThreshold; cleanupCount++ {
+		if p.evict(p.victims()) == 0 {
+			break
+		}
+		pUsage = getUsagePercentage(p.tmpPath, p.maxSizeMB, p.pinnedMB())
+	}
+	log.Info("ghostPolicy::checkUsage : Threshold stablized %f > %f", pUsage, p.lowThreshold)
+}
+
+// victims : Next files to evict, at most max-eviction of them, moved to the ghost lists
+func (p *ghostPolicy) victims() []string {
+	p.Lock()
+	defer p.Unlock()
+
+	names := make([]string, 0)
+	for uint32(len(names)) < p.maxEviction {
+		name := p.lists.victim()
+		if name == "" {
+			break
+		}
+		p.lists.evict(name)
+		p.entries[name].evicting = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// expiredVictims : Files not used within the timeout, at most max-eviction of them, moved to the ghost lists
+func (p *ghostPolicy) expiredVictims() []string {
+	p.Lock()
+	defer p.Unlock()
+
+	expiry := time.Now().Add(-time.Duration(p.cacheTimeout) * time.Second)
+	names := make([]string, 0)
+	for name, entry := range p.entries {
+		if uint32(len(names)) >= p.maxEviction {
+			log.Debug("ghostPolicy::expiredVictims : Max deletion count hit")
+			break
+		}
+		if !entry.evicting && entry.lastUsed.Before(expiry) {
+			p.lists.evict(name)
+			entry.evicting = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// evict : Delete the local copies of the files, the ones that cannot be deleted are cached again, returns the number deleted
+func (p *ghostPolicy) evict(names []string) int {
+	count := 0
+	for _, name := range names {
+		deleted := p.deleteItem(name)
+
+		p.Lock()
+		entry, found := p.entries[name]
+		if found && entry.evicting {
+			if deleted {
+				delete(p.entries, name)
+			} else {
+				// The file stays where it was, an eviction that did not happen is not a ghost hit
+				entry.evicting = false
+				p.lists.remove(name)
+				p.lists.restore(name, entry.usage)
+			}
+		}
+		p.Unlock()
+
+		if deleted {
+			count++
+		}
+	}
+	return count
+}
+
+// deleteItem : Delete the local copy of the file, returns false if it is in use and was kept
+func (p *ghostPolicy) deleteItem(name string) bool {
+	log.Trace("ghostPolicy::deleteItem : Deleting %s", name)
+
+	azPath := strings.TrimPrefix(name, p.tmpPath)
+	if azPath[0] == '/' {
+		azPath = azPath[1:]
+	}
+
+	flock := p.fileLocks.Get(azPath)
+	if p.fileLocks.Locked(azPath) {
+		log.Warn("ghostPolicy::deleteItem : File in under download %s", azPath)
+		return false
+	}
+
+	flock.Lock()
+	defer flock.Unlock()
+
+	// Check if there are any open handles to this file or not
+	if flock.Count() > 0 {
+		log.Warn("ghostPolicy::deleteItem : File in use %s", name)
+		return false
+	}
+
+	if p.isPinned(name) {
+		log.Debug("ghostPolicy::deleteItem : File pinned %s", name)
+		return false
+	}
+
+	if p.isUploading(name) {
+		log.Debug("ghostPolicy::deleteItem : File waiting for upload %s", name)
+		return false
+	}
+
+	// There are no open handles for this file so its safe to remove this
+	err := deleteFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		log.Err("ghostPolicy::deleteItem : failed to delete local file %s [%s]", name, err.Error())
+	}
+	return true
+}
+
+func (p *ghostPolicy) printNodes() {
+	if !p.policyTrace {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	log.Debug("ghostPolicy::printNodes : %s Starts", p.name)
+	p.lists.trace()
+	for ghost, hits := range p.ghostHits {
+		log.Debug(" ==> ghost list %s : %d hits", ghost, hits)
+	}
+	log.Debug("ghostPolicy::printNodes : %s Ends", p.name)
+}
+
+// policyList : Files in use order, most recently used at the front
+type policyList struct {
+	name     string
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newPolicyList(name string) *policyList {
+	return &policyList{
+		name:     name,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (l *policyList) len() int {
+	return l.order.Len()
+}
+
+func (l *policyList) contains(name string) bool {
+	_, found := l.elements[name]
+	return found
+}
+
+// pushFront : Add the file as the most recently used one
+func (l *policyList) pushFront(name string) {
+	if elem, found := l.elements[name]; found {
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.elements[name] = l.order.PushFront(name)
+}
+
+func (l *policyList) remove(name string) bool {
+	elem, found := l.elements[name]
+	if !found {
+		return false
+	}
+	l.order.Remove(elem)
+	delete(l.elements, name)
+	return true
+}
+
+// back : Least recently used file, empty if the list is empty
+func (l *policyList) back() string {
+	elem := l.order.Back()
+	if elem == nil {
+		return ""
+	}
+	return elem.Value.(string)
+}
+
+// popBack : Remove the least recently used file
+func (l *policyList) popBack() string {
+	name := l.back()
+	if name != "" {
+		l.remove(name)
+	}
+	return name
+}
+
+// names : Files of the list, most recently used first
+func (l *policyList) names() []string {
+	names := make([]string, 0, l.order.Len())
+	for elem := l.order.Front(); elem != nil; elem = elem.Next() {
+		names = append(names, elem.Value.(string))
+	}
+	return names
+}
+
+func (l *policyList) trace() {
+	log.Debug(" ==> list %s : %d files", l.name, l.order.Len())
+	for i, name := range l.names() {
+		log.Debug(" ==> (%d) %s", i, name)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
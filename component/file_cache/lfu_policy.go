@@ -126,6 +126,31 @@ func (l *lfuPolicy) IsCached(name string) bool {
 	}
 }
 
+// ExportState : Files in the LFU list, most frequently used first
+func (l *lfuPolicy) ExportState() []policyState {
+	l.list.Lock()
+	defer l.list.Unlock()
+
+	state := make([]policyState, 0, len(l.list.dataNodeMap))
+	for freqNode := l.list.last; freqNode != nil; freqNode = freqNode.prev {
+		for node := freqNode.list.last; node != nil; node = node.prev {
+			state = append(state, policyState{name: node.key, usage: node.frequency})
+		}
+	}
+
+	return state
+}
+
+// ImportState : Add the files to the LFU list with their frequencies
+func (l *lfuPolicy) ImportState(state []policyState) {
+	l.list.Lock()
+	defer l.list.Unlock()
+
+	for i := len(state) - 1; i >= 0; i-- {
+		l.list.restore(state[i].name, state[i].usage)
+	}
+}
+
 func (l *lfuPolicy) Name() string {
 	return "lfu"
 }
@@ -153,6 +178,18 @@ func (l *lfuPolicy) clearItemFromCache(path string) {
 		return
 	}
 
+	if l.isPinned(path) {
+		log.Debug("lfuPolicy::clearItemFromCache : File pinned %s", path)
+		l.CacheValid(path)
+		return
+	}
+
+	if l.isUploading(path) {
+		log.Debug("lfuPolicy::clearItemFromCache : File waiting for upload %s", path)
+		l.CacheValid(path)
+		return
+	}
+
 	// There are no open handles for this file so its safe to remove this
 	err := deleteFile(path)
 	if err != nil && !os.IsNotExist(err) {
@@ -187,6 +224,7 @@ func NewLFUPolicy(cfg cachePolicyConfig) cachePolicy {
 		closeChan:         make(chan int, 10),
 	}
 	pol.list = newLFUList(cfg.maxSizeMB, cfg.lowThreshold, cfg.highThreshold, pol.removeFiles, cfg.tmpPath, cfg.cacheTimeout)
+	pol.list.pinnedMB = pol.cachePolicyConfig.pinnedMB
 	return pol
 }
 
@@ -306,6 +344,7 @@ type lfuList struct {
 	cachePath    string
 	cacheAge     uint64
 	cacheTimeout uint32
+	pinnedMB     func() float64
 }
 
 func (list *lfuList) deleteFrequency(freq uint64) {
@@ -412,7 +451,7 @@ func (list *lfuList) put(key string) {
 		list.promote(node)
 		list.setTimerIfValid(node)
 	} else {
-		if usage := getUsagePercentage(list.cachePath, list.maxSizeMB); usage > list.upperThresh {
+		if usage := getUsagePercentage(list.cachePath, list.maxSizeMB, list.pinnedMB()); usage > list.upperThresh {
 			for usage > list.lowerThresh && list.first != nil {
 				toDeletePath := list.first.list.first.key
 				list.first.pop()
@@ -420,7 +459,7 @@ func (list *lfuList) put(key string) {
 				if list.first.list.size == 0 {
 					list.deleteFrequency(list.first.frequency)
 					list.size--
-					usage = getUsagePercentage(list.cachePath, list.maxSizeMB)
+					usage = getUsagePercentage(list.cachePath, list.maxSizeMB, list.pinnedMB())
 				}
 				list.deleteFiles <- toDeletePath
 			}
@@ -439,6 +478,28 @@ func (list *lfuList) put(key string) {
 	}
 }
 
+//Requires Lock()
+func (list *lfuList) restore(key string, frequency uint64) {
+	if _, ok := list.dataNodeMap[key]; ok {
+		return
+	}
+	if frequency == 0 {
+		frequency = 1
+	}
+
+	newNode := newDataNode(key)
+	newNode.frequency = frequency
+	list.dataNodeMap[key] = newNode
+	if freqNode, ok := list.freqNodeMap[frequency]; ok {
+		freqNode.push(newNode)
+	} else {
+		freqNode := newFrequencyNode(frequency)
+		freqNode.push(newNode)
+		list.addFrequency(frequency, freqNode, nil)
+	}
+	list.setTimerIfValid(newNode)
+}
+
 //Requires Lock()
 func (list *lfuList) delete(key string) {
 	if node, ok := list.dataNodeMap[key]; ok {
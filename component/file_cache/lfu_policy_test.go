@@ -281,6 +281,24 @@ func (suite *lfuPolicyTestSuite) TestMaxEviction() {
 	}
 }
 
+func (suite *lfuPolicyTestSuite) TestExportImportState() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("file1")
+	suite.policy.CacheValid("file2")
+	suite.policy.CacheValid("file2")
+	suite.policy.CacheValid("file3")
+
+	state := suite.policy.ExportState()
+	suite.assert.Len(state, 3)
+	suite.assert.Equal(policyState{name: "file2", usage: 2}, state[0])
+
+	suite.cleanupTest()
+	suite.SetupTest()
+	suite.policy.ImportState(state)
+	suite.assert.Equal(state, suite.policy.ExportState())
+	suite.assert.True(suite.policy.IsCached("file3"))
+}
+
 func TestLFUPolicyTestSuite(t *testing.T) {
 	suite.Run(t, new(lfuPolicyTestSuite))
 }
@@ -200,6 +200,36 @@ func (p *lruPolicy) Name() string {
 	return "lru"
 }
 
+// ExportState : Files in the LRU list, most recently used first
+func (p *lruPolicy) ExportState() []policyState {
+	p.Lock()
+	defer p.Unlock()
+
+	state := make([]policyState, 0)
+	for node := p.head; node != nil; node = node.next {
+		if node == p.currMarker || node == p.lastMarker || node.deleted {
+			continue
+		}
+		state = append(state, policyState{name: node.name, usage: uint64(node.usage)})
+	}
+
+	return state
+}
+
+// ImportState : Add the files to the LRU list keeping their order, least recently used goes in first
+func (p *lruPolicy) ImportState(state []policyState) {
+	for i := len(state) - 1; i >= 0; i-- {
+		p.cacheValidate(state[i].name)
+
+		val, found := p.nodeMap.Load(state[i].name)
+		if found {
+			p.Lock()
+			val.(*lruNode).usage = int(state[i].usage)
+			p.Unlock()
+		}
+	}
+}
+
 //  On validate name of the file was pushed on this channel so now update the LRU list
 func (p *lruPolicy) asyncCacheValid() {
 	for {
@@ -277,7 +307,7 @@ func (p *lruPolicy) clearCache() {
 		case <-p.diskUsageMonitor:
 			// File cache timeout has not occurred so just monitor the cache usage
 			cleanupCount := 0
-			pUsage := getUsagePercentage(p.tmpPath, p.maxSizeMB)
+			pUsage := getUsagePercentage(p.tmpPath, p.maxSizeMB, p.pinnedMB())
 			if pUsage > p.highThreshold {
 				continueDeletion := true
 				for continueDeletion {
@@ -288,7 +318,7 @@ func (p *lruPolicy) clearCache() {
 					p.printNodes()
 					p.deleteExpiredNodes()
 
-					pUsage := getUsagePercentage(p.tmpPath, p.maxSizeMB)
+					pUsage := getUsagePercentage(p.tmpPath, p.maxSizeMB, p.pinnedMB())
 					if pUsage < p.lowThreshold || cleanupCount >= 3 {
 						log.Info("lruPolicy::ClearCache : Threshold stablized %f > %f", pUsage, p.lowThreshold)
 						continueDeletion = false
@@ -432,6 +462,18 @@ func (p *lruPolicy) deleteItem(name string) {
 		return
 	}
 
+	if p.isPinned(name) {
+		log.Debug("lruPolicy::DeleteItem : File pinned %s", name)
+		p.CacheValid(name)
+		return
+	}
+
+	if p.isUploading(name) {
+		log.Debug("lruPolicy::DeleteItem : File waiting for upload %s", name)
+		p.CacheValid(name)
+		return
+	}
+
 	// There are no open handles for this file so its safe to remove this
 	err := deleteFile(name)
 	if err != nil && !os.IsNotExist(err) {
@@ -267,6 +267,24 @@ func (suite *lruPolicyTestSuite) TestMaxEviction() {
 	}
 }
 
+func (suite *lruPolicyTestSuite) TestExportImportState() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("file1")
+	suite.policy.CacheValid("file2")
+	suite.policy.CacheValid("file1")
+	time.Sleep(100 * time.Millisecond)
+
+	state := suite.policy.ExportState()
+	suite.assert.Equal([]policyState{{name: "file1", usage: 2}, {name: "file2", usage: 1}}, state)
+
+	suite.cleanupTest()
+	suite.SetupTest()
+	suite.policy.ImportState(state)
+	suite.assert.True(suite.policy.IsCached("file1"))
+	suite.assert.True(suite.policy.IsCached("file2"))
+	suite.assert.Equal(state, suite.policy.ExportState())
+}
+
 func TestLRUPolicyTestSuite(t *testing.T) {
 	suite.Run(t, new(lruPolicyTestSuite))
 }
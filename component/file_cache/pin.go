@@ -0,0 +1,237 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/stats_manager"
+)
+
+const pinnedUsage = "Pinned Usage"
+
+// matchesPinRule : Whether the file is pinned by one of the configured patterns
+func (fc *FileCache) matchesPinRule(name string) bool {
+	for _, pattern := range fc.pinPatterns {
+		if common.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pinFile : Keep the local copy of the file in cache, fails if the pinned files would not fit in max-size-mb
+func (fc *FileCache) pinFile(localPath string, size int64) error {
+	fc.pinLock.Lock()
+	defer fc.pinLock.Unlock()
+
+	total := fc.pinnedBytes - fc.pinnedSizes[localPath] + size
+	if fc.maxCacheSize > 0 && float64(total) > fc.maxCacheSize*MB {
+		log.Err("FileCache::pinFile : pinning %s needs %d bytes, more than max-size-mb %f", localPath, total, fc.maxCacheSize)
+		return syscall.ENOSPC
+	}
+
+	fc.pinnedSizes[localPath] = size
+	fc.setPinnedBytes(total)
+	fc.pinned.Store(localPath, true)
+	return nil
+}
+
+// unpinFile : Let the cache policy evict the local copy of the file again
+func (fc *FileCache) unpinFile(localPath string) {
+	fc.pinLock.Lock()
+	defer fc.pinLock.Unlock()
+
+	if size, found := fc.pinnedSizes[localPath]; found {
+		delete(fc.pinnedSizes, localPath)
+		fc.setPinnedBytes(fc.pinnedBytes - size)
+	}
+	fc.pinned.Delete(localPath)
+}
+
+// resizePin : Account the new size of the local copy if the file is pinned
+func (fc *FileCache) resizePin(localPath string, size int64) {
+	fc.pinLock.Lock()
+	defer fc.pinLock.Unlock()
+
+	if old, found := fc.pinnedSizes[localPath]; found {
+		fc.pinnedSizes[localPath] = size
+		fc.setPinnedBytes(fc.pinnedBytes - old + size)
+	}
+}
+
+// movePin : A renamed file stays pinned, and a file renamed to a pinned path gets pinned
+func (fc *FileCache) movePin(src string, dst string) {
+	localSrcPath := filepath.Join(fc.tmpPath, src)
+	localDstPath := filepath.Join(fc.tmpPath, dst)
+
+	fc.pinLock.Lock()
+	size, pinned := fc.pinnedSizes[localSrcPath]
+	fc.pinLock.Unlock()
+
+	fc.unpinFile(localSrcPath)
+	fc.unpinFile(localDstPath)
+	if pinned || fc.matchesPinRule(dst) {
+		_ = fc.pinFile(localDstPath, size)
+	}
+}
+
+// pinByRule : Pin the local copy of the file if it matches a configured pattern
+func (fc *FileCache) pinByRule(name string, localPath string) {
+	if !fc.matchesPinRule(name) {
+		return
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return
+	}
+
+	err = fc.pinFile(localPath, info.Size())
+	if err != nil {
+		log.Warn("FileCache::pinByRule : %s matches a pin pattern but does not fit in cache, leaving it unpinned", name)
+	}
+}
+
+// Caller shall hold pinLock
+func (fc *FileCache) setPinnedBytes(total int64) {
+	atomic.StoreInt64(&fc.pinnedBytes, total)
+	fileCacheStatsCollector.UpdateStats(stats_manager.Replace, pinnedUsage, fmt.Sprintf("%f MB", float64(total)/MB))
+}
+
+// pinnedSetSize : Size of the files in storage matching the pin patterns
+func (fc *FileCache) pinnedSetSize() (int64, error) {
+	files := make(map[string]int64)
+
+	for _, pattern := range fc.pinPatterns {
+		err := fc.walkStorage(common.GlobBase(pattern), func(attr *internal.ObjAttr) {
+			if common.MatchGlob(pattern, attr.Path) {
+				files[attr.Path] = attr.Size
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	total := int64(0)
+	for _, size := range files {
+		total += size
+	}
+	return total, nil
+}
+
+// walkStorage : Call the function for every file under the directory in storage
+func (fc *FileCache) walkStorage(dir string, fn func(attr *internal.ObjAttr)) error {
+	entries, err := fc.NextComponent().ReadDir(internal.ReadDirOptions{Name: dir})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, attr := range entries {
+		if attr.IsDir() {
+			err = fc.walkStorage(attr.Path, fn)
+			if err != nil {
+				return err
+			}
+		} else {
+			fn(attr)
+		}
+	}
+	return nil
+}
+
+// checkPinnedSet : The files matching the pin patterns shall fit in max-size-mb
+func (fc *FileCache) checkPinnedSet() error {
+	if len(fc.pinPatterns) == 0 || fc.maxCacheSize == 0 {
+		return nil
+	}
+
+	size, err := fc.pinnedSetSize()
+	if err != nil {
+		log.Err("FileCache::checkPinnedSet : failed to list pinned files [%s]", err.Error())
+		return err
+	}
+
+	log.Info("FileCache::checkPinnedSet : pinned files need %d bytes", size)
+	if float64(size) > fc.maxCacheSize*MB {
+		return fmt.Errorf("pinned files need %.2f MB, more than max-size-mb %.2f", float64(size)/MB, fc.maxCacheSize)
+	}
+	return nil
+}
+
+// pinCachedFiles : Pin the files kept in cache from an earlier mount that match a pin pattern
+func (fc *FileCache) pinCachedFiles() {
+	if len(fc.pinPatterns) == 0 {
+		return
+	}
+
+	_ = filepath.Walk(fc.tmpPath, func(localPath string, info os.FileInfo, err error) error {
+		if localPath == stateDir(fc.tmpPath) {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(fc.tmpPath, localPath)
+		if err == nil {
+			fc.pinByRule(filepath.ToSlash(name), localPath)
+		}
+		return nil
+	})
+}
+
+// cachedSize : Size of the local copy of the file, or of the file in storage if it is not cached
+func (fc *FileCache) cachedSize(name string) int64 {
+	info, err := os.Stat(filepath.Join(fc.tmpPath, name))
+	if err == nil {
+		return info.Size()
+	}
+
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: name})
+	if err != nil {
+		return 0
+	}
+	return attr.Size
+}
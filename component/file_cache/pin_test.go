@@ -0,0 +1,168 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/config"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+func (suite *fileCacheTestSuite) setupPinCache(maxSizeMB int) {
+	suite.cleanupTest()
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  timeout-sec: 0\n  max-size-mb: %d\n  pin:\n    - models/**\n\nloopbackfs:\n  path: %s",
+		suite.cache_path, maxSizeMB, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+}
+
+func (suite *fileCacheTestSuite) TestConfigPin() {
+	defer suite.cleanupTest()
+	suite.setupPinCache(10)
+	suite.assert.Equal([]string{"models/**"}, suite.fileCache.pinPatterns)
+}
+
+func (suite *fileCacheTestSuite) TestPinRule() {
+	defer suite.cleanupTest()
+	suite.setupPinCache(10)
+	suite.fileCache.CreateDir(internal.CreateDirOptions{Name: "models", Mode: 0777})
+	path := "models/run1.ckpt"
+	localPath := filepath.Join(suite.cache_path, path)
+
+	handle, err := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.assert.Nil(err)
+	_, err = suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: make([]byte, 1000)})
+	suite.assert.Nil(err)
+	err = suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+
+	// Pinned by the rule, and kept after close though the timeout is zero
+	status, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlStatus})
+	suite.assert.Nil(err)
+	suite.assert.Equal(common.CacheStatusCached|common.CacheStatusPinned, status)
+	suite.assert.EqualValues(1000, suite.fileCache.pinnedBytes)
+
+	// A file not matching the rule is not pinned
+	handle, err = suite.fileCache.CreateFile(internal.CreateFileOptions{Name: "other", Mode: 0777})
+	suite.assert.Nil(err)
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	_, pinned := suite.fileCache.pinned.Load(filepath.Join(suite.cache_path, "other"))
+	suite.assert.False(pinned)
+
+	// A renamed file stays pinned
+	err = suite.fileCache.RenameFile(internal.RenameFileOptions{Src: path, Dst: "run1.ckpt"})
+	suite.assert.Nil(err)
+	_, pinned = suite.fileCache.pinned.Load(localPath)
+	suite.assert.False(pinned)
+	_, pinned = suite.fileCache.pinned.Load(filepath.Join(suite.cache_path, "run1.ckpt"))
+	suite.assert.True(pinned)
+	suite.assert.EqualValues(1000, suite.fileCache.pinnedBytes)
+
+	err = suite.fileCache.DeleteFile(internal.DeleteFileOptions{Name: "run1.ckpt"})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(0, suite.fileCache.pinnedBytes)
+}
+
+func (suite *fileCacheTestSuite) TestPinNoSpace() {
+	defer suite.cleanupTest()
+	suite.setupPinCache(1)
+	path := "file"
+	err := os.WriteFile(filepath.Join(suite.fake_storage_path, path), make([]byte, 2*MB), 0777)
+	suite.assert.Nil(err)
+
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlPin})
+	suite.assert.Equal(syscall.ENOSPC, err)
+	_, pinned := suite.fileCache.pinned.Load(filepath.Join(suite.cache_path, path))
+	suite.assert.False(pinned)
+	suite.assert.EqualValues(0, suite.fileCache.pinnedBytes)
+}
+
+func (suite *fileCacheTestSuite) TestPinWithoutHandle() {
+	defer suite.cleanupTest()
+	suite.setupPinCache(10)
+	path := "file"
+	err := os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("pinned data"), 0777)
+	suite.assert.Nil(err)
+
+	// Pinned by path the file is downloaded, and kept though the timeout is zero
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlPin})
+	suite.assert.Nil(err)
+	data, err := os.ReadFile(filepath.Join(suite.cache_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal("pinned data", string(data))
+
+	status, err := suite.fileCache.CacheControl(internal.CacheControlOptions{Name: path, Command: common.CacheControlStatus})
+	suite.assert.Nil(err)
+	suite.assert.Equal(common.CacheStatusCached|common.CacheStatusPinned, status)
+
+	// A missing file is not left pinned
+	_, err = suite.fileCache.CacheControl(internal.CacheControlOptions{Name: "missing", Command: common.CacheControlPin})
+	suite.assert.NotNil(err)
+	_, pinned := suite.fileCache.pinned.Load(filepath.Join(suite.cache_path, "missing"))
+	suite.assert.False(pinned)
+}
+
+func (suite *fileCacheTestSuite) TestPinnedSetTooLarge() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+	err := os.MkdirAll(filepath.Join(suite.fake_storage_path, "models", "run1"), 0777)
+	suite.assert.Nil(err)
+	err = os.WriteFile(filepath.Join(suite.fake_storage_path, "models", "run1", "a.ckpt"), make([]byte, MB), 0777)
+	suite.assert.Nil(err)
+	err = os.WriteFile(filepath.Join(suite.fake_storage_path, "models", "b.ckpt"), make([]byte, MB), 0777)
+	suite.assert.Nil(err)
+
+	configuration := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  max-size-mb: 1\n  pin:\n    - models/**\n\nloopbackfs:\n  path: %s",
+		suite.cache_path, suite.fake_storage_path)
+	config.ReadConfigFromReader(strings.NewReader(configuration))
+	loopback := newLoopbackFS()
+	fileCache := newTestFileCache(loopback)
+	loopback.Start(context.Background())
+
+	// The mount does not start when the pinned files cannot fit
+	err = fileCache.Start(context.Background())
+	suite.assert.NotNil(err)
+	suite.assert.Contains(err.Error(), "pinned files need 2.00 MB")
+	loopback.Stop()
+
+	// They fit once the cache is large enough
+	configuration = strings.Replace(configuration, "max-size-mb: 1", "max-size-mb: 3", 1)
+	suite.setupTestHelper(configuration)
+}
@@ -0,0 +1,364 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+const (
+	// By default files are downloaded in blocks of 8MB, 4 blocks at a time, in progressive mode
+	defaultProgressiveBlockSizeMB = 8
+	defaultProgressiveWorkers     = 4
+
+	// A block failing to download is tried this many times before the readers waiting on it are failed
+	progressiveBlockAttempts = 3
+)
+
+// State of a block of a file being downloaded
+const (
+	blockMissing = iota
+	blockFetching
+	blockPresent
+	blockFailed
+)
+
+// progressiveDownload : Download of a file in blocks in the background, reads wait only for the blocks they need
+type progressiveDownload struct {
+	sync.Mutex
+	cond *sync.Cond
+
+	name      string
+	file      *os.File     // local copy the blocks are written to
+	cipher    *cacheCipher // encrypts the blocks, nil if the local copy is plain
+	size      int64
+	blockSize int64
+	state     []int
+	attempts  []int         // failed downloads of each block since it was last asked for
+	errs      map[int]error // last error of the blocks that failed to download
+	present   int
+	next      int   // lowest block that may still be missing
+	urgent    []int // blocks waited for by readers, fetched before the rest
+	cancelled bool
+	completed bool
+	running   uint32 // workers still fetching blocks
+	limit     uint32 // workers to run at most
+	workers   sync.WaitGroup
+
+	read     func(offset int64, data []byte) error // read a range of the file from storage
+	complete func()                                // called once all the blocks are in the local copy
+	release  func()                                // called once the download is over, complete or not
+}
+
+func newProgressiveDownload(name string, file *os.File, size int64, blockSize int64) *progressiveDownload {
+	d := &progressiveDownload{
+		name:      name,
+		file:      file,
+		size:      size,
+		blockSize: blockSize,
+		state:     make([]int, (size+blockSize-1)/blockSize),
+		attempts:  make([]int, (size+blockSize-1)/blockSize),
+		errs:      make(map[int]error),
+	}
+	d.cond = sync.NewCond(d)
+	return d
+}
+
+// start : Download the blocks in order with the given number of workers
+func (d *progressiveDownload) start(workers uint32) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.limit = workers
+	for i := uint32(0); i < workers; i++ {
+		d.startWorker()
+	}
+}
+
+// startWorker : Run one more worker
+// Caller shall hold the lock
+func (d *progressiveDownload) startWorker() {
+	d.running++
+	d.workers.Add(1)
+	go d.worker()
+}
+
+// nextBlock : Block to fetch next, -1 if none is missing
+// Caller shall hold the lock
+func (d *progressiveDownload) nextBlock() int {
+	for len(d.urgent) > 0 {
+		block := d.urgent[0]
+		d.urgent = d.urgent[1:]
+		if d.state[block] == blockMissing {
+			return block
+		}
+	}
+
+	for ; d.next < len(d.state); d.next++ {
+		if d.state[d.next] == blockMissing {
+			d.next++
+			return d.next - 1
+		}
+	}
+	return -1
+}
+
+func (d *progressiveDownload) worker() {
+	defer d.workers.Done()
+	buf := make([]byte, d.blockSize)
+
+	for {
+		d.Lock()
+		block := -1
+		if !d.cancelled {
+			block = d.nextBlock()
+		}
+		if block < 0 {
+			d.running--
+			d.Unlock()
+			return
+		}
+		d.state[block] = blockFetching
+		d.Unlock()
+
+		offset := int64(block) * d.blockSize
+		data := buf[:d.blockSize]
+		if offset+d.blockSize > d.size {
+			data = buf[:d.size-offset]
+		}
+
+		err := d.read(offset, data)
+		if err == nil {
+			_, err = d.cipher.writeAt(int(d.file.Fd()), data, offset)
+		}
+
+		d.Lock()
+		if err != nil {
+			log.Err("progressiveDownload::worker : failed to download %s at %d [%s]", d.name, offset, err.Error())
+			d.attempts[block]++
+			if d.attempts[block] < progressiveBlockAttempts {
+				// Try again, the rest of the file is not held up by the block
+				d.state[block] = blockMissing
+				if block < d.next {
+					d.next = block
+				}
+			} else {
+				d.state[block] = blockFailed
+				d.errs[block] = err
+			}
+		} else {
+			d.state[block] = blockPresent
+			d.present++
+		}
+		last := d.present == len(d.state) && !d.cancelled
+		if last {
+			d.completed = true
+		}
+		d.cond.Broadcast()
+		d.Unlock()
+
+		if last {
+			d.complete()
+			d.release()
+			return
+		}
+	}
+}
+
+// waitRange : Block till the range is in the local copy, missing blocks of the range are fetched first
+func (d *progressiveDownload) waitRange(offset int64, length int64) error {
+	if offset+length > d.size {
+		length = d.size - offset
+	}
+	if length <= 0 {
+		return nil
+	}
+
+	first := int(offset / d.blockSize)
+	last := int((offset + length - 1) / d.blockSize)
+
+	d.Lock()
+	defer d.Unlock()
+
+	urgent := make([]int, 0)
+	for block := first; block <= last; block++ {
+		if d.state[block] == blockFailed {
+			// Blocks that failed for earlier readers are tried again for this one
+			d.state[block] = blockMissing
+			d.attempts[block] = 0
+			delete(d.errs, block)
+		}
+		if d.state[block] == blockMissing {
+			urgent = append(urgent, block)
+		}
+	}
+	if len(urgent) > 0 {
+		d.urgent = append(urgent, d.urgent...)
+		// Workers stop once no block is missing, so they may all be gone by now
+		for i := 0; i < len(urgent) && d.running < d.limit && !d.cancelled; i++ {
+			d.startWorker()
+		}
+		d.cond.Broadcast()
+	}
+
+	for block := first; block <= last; {
+		if d.state[block] == blockPresent {
+			block++
+			continue
+		}
+		if d.state[block] == blockFailed {
+			return d.errs[block]
+		}
+		if d.cancelled {
+			return syscall.EIO
+		}
+		d.cond.Wait()
+	}
+	return nil
+}
+
+// wait : Block till the whole file is in the local copy
+func (d *progressiveDownload) wait() error {
+	return d.waitRange(0, d.size)
+}
+
+// cancel : Stop the download, returns false if the local copy was already complete
+func (d *progressiveDownload) cancel() bool {
+	d.Lock()
+	d.cancelled = true
+	d.cond.Broadcast()
+	d.Unlock()
+
+	d.workers.Wait()
+
+	if d.completed {
+		return false
+	}
+	d.release()
+	return true
+}
+
+// startDownload : Download the file to the local copy in the background, the local copy is sized to the file right away
+func (fc *FileCache) startDownload(name string, f *os.File, attr *internal.ObjAttr, recordAttr bool) error {
+	remote, err := fc.NextComponent().OpenFile(internal.OpenFileOptions{Name: name, Flags: os.O_RDONLY, Mode: fc.defaultPermission})
+	if err != nil {
+		log.Err("FileCache::startDownload : failed to open %s in storage [%s]", name, err.Error())
+		return err
+	}
+
+	err = fc.cipher.truncate(int(f.Fd()), attr.Size)
+	if err != nil {
+		log.Err("FileCache::startDownload : failed to size local copy of %s [%s]", name, err.Error())
+		_ = fc.NextComponent().CloseFile(internal.CloseFileOptions{Handle: remote})
+		return err
+	}
+
+	d := newProgressiveDownload(name, f, attr.Size, fc.progressiveBlockSize)
+	d.cipher = fc.cipher
+	d.read = func(offset int64, data []byte) error {
+		n, err := fc.NextComponent().ReadInBuffer(internal.ReadInBufferOptions{Handle: remote, Offset: offset, Data: data})
+		if err == io.EOF && n == len(data) {
+			err = nil
+		}
+		if err == nil && n != len(data) {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	d.complete = func() {
+		localPath := filepath.Join(fc.tmpPath, name)
+		log.Debug("FileCache::startDownload : download of %s is complete", name)
+
+		// Changes written while downloading keep their own times
+		if _, dirty := fc.dirty.Load(name); !dirty {
+			err := os.Chtimes(localPath, attr.Atime, attr.Mtime)
+			if err != nil {
+				log.Err("FileCache::startDownload : Failed to change times of file %s [%s]", name, err.Error())
+			}
+			if recordAttr {
+				fc.recordRemoteAttr(name, attr)
+			}
+		}
+	}
+	d.release = func() {
+		fc.downloads.Delete(name)
+		_ = f.Close()
+		_ = fc.NextComponent().CloseFile(internal.CloseFileOptions{Handle: remote})
+	}
+
+	fc.downloads.Store(name, d)
+	d.start(fc.progressiveWorkers)
+	return nil
+}
+
+// downloadInProgress : Download of the file still running in the background, nil if none
+func (fc *FileCache) downloadInProgress(name string) *progressiveDownload {
+	val, found := fc.downloads.Load(name)
+	if !found {
+		return nil
+	}
+	return val.(*progressiveDownload)
+}
+
+// waitForDownload : The local copy of the file shall be complete before it is operated upon as a whole
+func (fc *FileCache) waitForDownload(name string) error {
+	if d := fc.downloadInProgress(name); d != nil {
+		return d.wait()
+	}
+	return nil
+}
+
+// waitForRange : The range of the local copy shall be downloaded before it is read or written
+func (fc *FileCache) waitForRange(handle *handlemap.Handle, offset int64, length int64) error {
+	if d := fc.downloadInProgress(handle.Path); d != nil {
+		return d.waitRange(offset, length)
+	}
+	return nil
+}
+
+// cancelDownload : Stop the download of the file, returns true if the local copy was left incomplete
+func (fc *FileCache) cancelDownload(name string) bool {
+	if d := fc.downloadInProgress(name); d != nil {
+		return d.cancel()
+	}
+	return false
+}
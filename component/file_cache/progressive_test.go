@@ -0,0 +1,358 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type progressiveDownloadTestSuite struct {
+	suite.Suite
+	assert *assert.Assertions
+	file   *os.File
+
+	lock     sync.Mutex
+	offsets  []int64
+	gate     chan bool
+	fail     bool
+	failures map[int64]int // reads of each offset still to fail
+	released bool
+}
+
+func (suite *progressiveDownloadTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+	f, err := os.Create(filepath.Join(home_dir, "progressive"+randomString(8)))
+	suite.assert.Nil(err)
+	suite.file = f
+	suite.offsets = make([]int64, 0)
+	suite.gate = make(chan bool)
+	suite.fail = false
+	suite.failures = make(map[int64]int)
+	suite.released = false
+}
+
+func (suite *progressiveDownloadTestSuite) cleanupTest() {
+	os.Remove(suite.file.Name())
+}
+
+// newDownload : Download of a file of the given number of 4 byte blocks, reads are held till the gate is closed
+func (suite *progressiveDownloadTestSuite) newDownload(blocks int64) *progressiveDownload {
+	d := newProgressiveDownload("file", suite.file, blocks*4, 4)
+	d.read = func(offset int64, data []byte) error {
+		suite.lock.Lock()
+		suite.offsets = append(suite.offsets, offset)
+		suite.lock.Unlock()
+
+		<-suite.gate
+		suite.lock.Lock()
+		fail := suite.fail || suite.failures[offset] > 0
+		suite.failures[offset]--
+		suite.lock.Unlock()
+		if fail {
+			return errors.New("read failed")
+		}
+		copy(data, fmt.Sprintf("%04d", offset))
+		return nil
+	}
+	d.complete = func() {}
+	d.release = func() {
+		suite.lock.Lock()
+		suite.released = true
+		suite.lock.Unlock()
+	}
+	return d
+}
+
+// waitForReads : Block till the given number of reads were issued
+func (suite *progressiveDownloadTestSuite) waitForReads(count int) {
+	suite.assert.Eventually(func() bool {
+		suite.lock.Lock()
+		defer suite.lock.Unlock()
+		return len(suite.offsets) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (suite *progressiveDownloadTestSuite) TestDownload() {
+	defer suite.cleanupTest()
+	d := suite.newDownload(5)
+	close(suite.gate)
+	d.start(2)
+
+	suite.assert.Nil(d.wait())
+	d.workers.Wait()
+	suite.assert.True(d.completed)
+	suite.assert.True(suite.released)
+	suite.assert.False(d.cancel())
+
+	data, err := os.ReadFile(suite.file.Name())
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("00000004000800120016", string(data))
+}
+
+func (suite *progressiveDownloadTestSuite) TestReadAheadFetchedFirst() {
+	defer suite.cleanupTest()
+	d := suite.newDownload(8)
+	d.start(1)
+	suite.waitForReads(1)
+
+	waited := make(chan error)
+	go func() {
+		waited <- d.waitRange(21, 2)
+	}()
+	suite.assert.Eventually(func() bool {
+		d.Lock()
+		defer d.Unlock()
+		return len(d.urgent) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(suite.gate)
+	suite.assert.Nil(<-waited)
+	suite.assert.Nil(d.wait())
+	d.workers.Wait()
+	suite.assert.EqualValues([]int64{0, 20, 4, 8, 12, 16, 24, 28}, suite.offsets)
+}
+
+func (suite *progressiveDownloadTestSuite) TestReadError() {
+	defer suite.cleanupTest()
+	d := suite.newDownload(4)
+	suite.fail = true
+	d.start(1)
+	close(suite.gate)
+
+	suite.assert.NotNil(d.waitRange(8, 4))
+	suite.assert.NotNil(d.wait())
+
+	// A failed download leaves the local copy incomplete
+	suite.assert.True(d.cancel())
+	suite.assert.True(suite.released)
+}
+
+func (suite *progressiveDownloadTestSuite) TestReadRetried() {
+	defer suite.cleanupTest()
+	d := suite.newDownload(4)
+	suite.failures[4] = progressiveBlockAttempts - 1
+	close(suite.gate)
+	d.start(1)
+
+	suite.assert.Nil(d.wait())
+	d.workers.Wait()
+	suite.assert.True(d.completed)
+	suite.assert.ElementsMatch([]int64{0, 4, 4, 4, 8, 12}, suite.offsets)
+}
+
+func (suite *progressiveDownloadTestSuite) TestReadErrorOfBlock() {
+	defer suite.cleanupTest()
+	d := suite.newDownload(4)
+	suite.failures[8] = progressiveBlockAttempts
+	close(suite.gate)
+	d.start(2)
+
+	// Only readers of the failed block are failed
+	suite.assert.NotNil(d.waitRange(8, 4))
+	suite.assert.Nil(d.waitRange(0, 8))
+	suite.assert.Nil(d.waitRange(12, 4))
+	d.workers.Wait()
+	suite.assert.False(d.completed)
+
+	// The block is tried again for the next reader
+	suite.assert.Nil(d.waitRange(9, 2))
+	suite.assert.Nil(d.wait())
+	d.workers.Wait()
+	suite.assert.True(d.completed)
+	suite.assert.True(suite.released)
+
+	data, err := os.ReadFile(suite.file.Name())
+	suite.assert.Nil(err)
+	suite.assert.EqualValues("0000000400080012", string(data))
+}
+
+func (suite *progressiveDownloadTestSuite) TestCancel() {
+	defer suite.cleanupTest()
+	d := suite.newDownload(4)
+	d.start(1)
+	suite.waitForReads(1)
+
+	waited := make(chan error)
+	go func() {
+		waited <- d.waitRange(12, 4)
+	}()
+
+	cancelled := make(chan bool)
+	go func() {
+		cancelled <- d.cancel()
+	}()
+	suite.assert.Eventually(func() bool {
+		d.Lock()
+		defer d.Unlock()
+		return d.cancelled
+	}, 5*time.Second, 10*time.Millisecond)
+	close(suite.gate)
+
+	suite.assert.True(<-cancelled)
+	suite.assert.NotNil(<-waited)
+	suite.assert.True(suite.released)
+	suite.assert.False(d.completed)
+}
+
+func TestProgressiveDownloadTestSuite(t *testing.T) {
+	suite.Run(t, new(progressiveDownloadTestSuite))
+}
+
+func (suite *fileCacheTestSuite) setupProgressiveCache() {
+	suite.cleanupTest()
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  timeout-sec: 120\n  progressive-open: true\n  progressive-block-size-mb: 1\n  progressive-workers: 2\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+}
+
+// createStorageFile : Create a file of random data directly in storage
+func (suite *fileCacheTestSuite) createStorageFile(path string, size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	err := os.WriteFile(filepath.Join(suite.fake_storage_path, path), data, 0777)
+	suite.assert.Nil(err)
+	return data
+}
+
+func (suite *fileCacheTestSuite) TestConfigProgressiveOpen() {
+	defer suite.cleanupTest()
+	suite.assert.False(suite.fileCache.progressiveOpen)
+
+	suite.setupProgressiveCache()
+	suite.assert.True(suite.fileCache.progressiveOpen)
+	suite.assert.EqualValues(MB, suite.fileCache.progressiveBlockSize)
+	suite.assert.EqualValues(2, suite.fileCache.progressiveWorkers)
+}
+
+func (suite *fileCacheTestSuite) TestProgressiveOpenRead() {
+	defer suite.cleanupTest()
+	suite.setupProgressiveCache()
+	path := "file"
+	data := suite.createStorageFile(path, 5*MB+100)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), handle.Size)
+
+	// A read far ahead gets its data without waiting for the blocks before it
+	buf := make([]byte, 200)
+	n, err := suite.fileCache.ReadInBuffer(internal.ReadInBufferOptions{Handle: handle, Offset: 4*MB + 50, Data: buf})
+	suite.assert.Nil(err)
+	suite.assert.Equal(len(buf), n)
+	suite.assert.True(bytes.Equal(data[4*MB+50:4*MB+250], buf))
+
+	suite.assert.Nil(suite.fileCache.waitForDownload(path))
+	suite.assert.Nil(suite.fileCache.downloadInProgress(path))
+
+	local, err := os.ReadFile(filepath.Join(suite.cache_path, path))
+	suite.assert.Nil(err)
+	suite.assert.True(bytes.Equal(data, local))
+
+	// A complete local copy is kept after close
+	err = suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.FileExists(filepath.Join(suite.cache_path, path))
+}
+
+func (suite *fileCacheTestSuite) TestProgressiveSeek() {
+	defer suite.cleanupTest()
+	suite.setupProgressiveCache()
+	path := "file"
+	size := 16*MB + 100
+	suite.createStorageFile(path, size)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.assert.Nil(err)
+
+	// Blocks still downloading are data, not holes
+	offset, err := suite.fileCache.SeekFile(internal.SeekFileOptions{Handle: handle, Name: path, Offset: MB, Whence: common.SeekHole})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(size, offset)
+	offset, err = suite.fileCache.SeekFile(internal.SeekFileOptions{Handle: handle, Name: path, Offset: 8 * MB, Whence: common.SeekData})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(8*MB, offset)
+
+	suite.assert.Nil(suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle}))
+}
+
+func (suite *fileCacheTestSuite) TestProgressiveOpenSmallFile() {
+	defer suite.cleanupTest()
+	suite.setupProgressiveCache()
+	path := "file"
+	data := suite.createStorageFile(path, 1000)
+
+	// A file within a single block is downloaded before open returns
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.assert.Nil(err)
+	suite.assert.Nil(suite.fileCache.downloadInProgress(path))
+
+	local, err := os.ReadFile(filepath.Join(suite.cache_path, path))
+	suite.assert.Nil(err)
+	suite.assert.True(bytes.Equal(data, local))
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+}
+
+func (suite *fileCacheTestSuite) TestProgressiveOpenWrite() {
+	defer suite.cleanupTest()
+	suite.setupProgressiveCache()
+	path := "file"
+	data := suite.createStorageFile(path, 3*MB)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+
+	// A write is not overwritten by the block it lands in
+	_, err = suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 2 * MB, Data: []byte("new data")})
+	suite.assert.Nil(err)
+	err = suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+
+	copy(data[2*MB:], "new data")
+	remote, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.True(bytes.Equal(data, remote))
+}
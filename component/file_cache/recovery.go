@@ -0,0 +1,352 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+// Suffix of the copy saved in storage when a recovered file was changed there by someone else
+const conflictSuffix = ".conflict"
+
+// Layout of the time added to the name of the copy when an earlier one is in the way
+const conflictTimeLayout = "20060102-150405"
+
+// handle value holding the remote properties of the file when it was opened
+const remoteBaseline = "remoteBaseline"
+
+// dirtyMarker : Recorded when the local copy of a file is first changed, removed once the change is in storage
+type dirtyMarker struct {
+	Name    string           `json:"name"`
+	Remote  *cacheIndexEntry `json:"remote,omitempty"` // nil when the file was not in storage
+	Changed time.Time        `json:"changed"`
+}
+
+// PendingFile : A file in the temp path whose local changes may not be in storage
+type PendingFile struct {
+	Name      string
+	Size      int64
+	Changed   time.Time
+	Journaled bool // flushed in write-back mode and waiting for upload
+	Missing   bool // local copy is gone, nothing can be recovered
+}
+
+// markerFile : Names of files can be longer than what a single path component allows, so markers are named by a hash
+func markerFile(dirtyPath string, name string) string {
+	return filepath.Join(dirtyPath, fmt.Sprintf("%x", sha256.Sum256([]byte(name))))
+}
+
+// readMarkers : Read all the markers left in the directory
+func readMarkers(dirtyPath string) ([]*dirtyMarker, error) {
+	entries, err := os.ReadDir(dirtyPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	markers := make([]*dirtyMarker, 0, len(entries))
+	for _, entry := range entries {
+		path := filepath.Join(dirtyPath, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Err("FileCache::readMarkers : failed to read %s [%s]", path, err.Error())
+			continue
+		}
+
+		marker := &dirtyMarker{}
+		err = json.Unmarshal(data, marker)
+		if err != nil || marker.Name == "" {
+			// A marker torn by a crash is written again on the next change
+			log.Err("FileCache::readMarkers : invalid marker %s", path)
+			_ = os.Remove(path)
+			continue
+		}
+		markers = append(markers, marker)
+	}
+
+	sort.Slice(markers, func(i, j int) bool { return markers[i].Name < markers[j].Name })
+	return markers, nil
+}
+
+// hasMarkers : Whether files were left with changes by the previous mount
+func hasMarkers(dirtyPath string) bool {
+	entries, err := os.ReadDir(dirtyPath)
+	return err == nil && len(entries) > 0
+}
+
+// markDirty : Persist that the local copy of the file has changes not yet in storage
+func (fc *FileCache) markDirty(handle *handlemap.Handle) {
+	if _, found := fc.dirty.Load(handle.Path); found {
+		return
+	}
+
+	marker := &dirtyMarker{Name: handle.Path, Changed: time.Now()}
+
+	if val, found := handle.GetValue(remoteBaseline); found {
+		if attr := val.(*internal.ObjAttr); attr != nil {
+			marker.Remote = &cacheIndexEntry{Name: handle.Path, ETag: attr.ETag, Size: attr.Size, Mtime: attr.Mtime}
+		}
+	} else if val, found := fc.remoteAttrs.Load(handle.Path); found {
+		entry := *val.(*cacheIndexEntry)
+		marker.Remote = &entry
+	} else if info, err := os.Stat(filepath.Join(fc.tmpPath, handle.Path)); err == nil {
+		// Served from the local cache, which carries the size and last modified time of the file downloaded.
+		// Storage may have changed since, so it is not asked for what the local copy was made from.
+		marker.Remote = &cacheIndexEntry{Name: handle.Path, Size: fc.cipher.localSize(info.Size()), Mtime: info.ModTime()}
+	}
+
+	fc.writeMarker(marker)
+}
+
+// writeMarker : Store the marker on disk
+func (fc *FileCache) writeMarker(marker *dirtyMarker) {
+	fc.dirty.Store(marker.Name, marker)
+
+	data, err := json.Marshal(marker)
+	if err == nil {
+		err = os.MkdirAll(fc.dirtyPath, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(markerFile(fc.dirtyPath, marker.Name), data, 0644)
+	}
+	if err != nil {
+		log.Err("FileCache::writeMarker : failed to mark %s dirty [%s]", marker.Name, err.Error())
+	}
+}
+
+// clearDirty : Changes of the file are in storage or not needed any more
+func (fc *FileCache) clearDirty(name string) {
+	if fc.uploads != nil && fc.uploads.isPending(name) {
+		// Cleared once the background upload commits
+		return
+	}
+
+	if _, found := fc.dirty.LoadAndDelete(name); !found {
+		return
+	}
+
+	err := os.Remove(markerFile(fc.dirtyPath, name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Err("FileCache::clearDirty : failed to remove marker of %s [%s]", name, err.Error())
+	}
+	fc.uploading.Delete(filepath.Join(fc.tmpPath, name))
+}
+
+// hasDirtyFiles : Whether some local changes are not in storage yet
+func (fc *FileCache) hasDirtyFiles() bool {
+	found := false
+	fc.dirty.Range(func(_, _ interface{}) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// moveDirty : The local copy of the file was renamed along with its changes
+func (fc *FileCache) moveDirty(src string, dst string) {
+	fc.clearDirty(dst)
+
+	val, found := fc.dirty.LoadAndDelete(src)
+	if !found {
+		return
+	}
+	_ = os.Remove(markerFile(fc.dirtyPath, src))
+
+	marker := *val.(*dirtyMarker)
+	marker.Name = dst
+	fc.writeMarker(&marker)
+
+	if _, held := fc.uploading.LoadAndDelete(filepath.Join(fc.tmpPath, src)); held {
+		fc.holdForUpload(dst)
+	}
+}
+
+// recoverDirtyFiles : Reconcile the files left with changes by the previous mount with storage
+func (fc *FileCache) recoverDirtyFiles() (int, error) {
+	markers, err := readMarkers(fc.dirtyPath)
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := make(map[string]bool)
+	unresolved := 0
+	for _, marker := range markers {
+		recovered[marker.Name] = true
+
+		err = fc.reconcileDirtyFile(marker)
+		if err != nil {
+			// Kept for the next mount, the local copy is not evicted or downloaded over meanwhile
+			log.Err("FileCache::recoverDirtyFiles : failed to recover %s [%s]", marker.Name, err.Error())
+			fc.dirty.Store(marker.Name, marker)
+			fc.holdForUpload(marker.Name)
+			unresolved++
+			continue
+		}
+
+		_ = os.Remove(markerFile(fc.dirtyPath, marker.Name))
+	}
+
+	if fc.writeBack && len(recovered) > 0 {
+		// Recovered files shall not be uploaded again without checking storage
+		err = dropFromJournal(fc.journalPath, recovered)
+		if err != nil {
+			log.Err("FileCache::recoverDirtyFiles : failed to update journal %s [%s]", fc.journalPath, err.Error())
+		}
+	}
+
+	return unresolved, nil
+}
+
+// reconcileDirtyFile : Upload the local changes if storage still has what they were made on, else save them aside
+func (fc *FileCache) reconcileDirtyFile(marker *dirtyMarker) error {
+	localPath := filepath.Join(fc.tmpPath, marker.Name)
+	_, err := os.Stat(localPath)
+	if err != nil {
+		log.Warn("FileCache::reconcileDirtyFile : %s is not in the local cache any more", marker.Name)
+		return nil
+	}
+
+	attr, err := fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: marker.Name})
+	if err != nil && err != syscall.ENOENT && !os.IsNotExist(err) {
+		return err
+	}
+
+	var changed bool
+	if marker.Remote == nil {
+		changed = attr != nil && err == nil
+	} else {
+		changed = err != nil || !marker.Remote.matches(attr)
+	}
+
+	if !changed {
+		log.Info("FileCache::reconcileDirtyFile : uploading changes of %s made before the last unmount", marker.Name)
+		return fc.uploadFile(marker.Name)
+	}
+
+	conflictName := marker.Name + conflictSuffix
+	if _, err = fc.NextComponent().GetAttr(internal.GetAttrOptions{Name: conflictName}); err == nil {
+		// Changes saved aside on an earlier recovery are kept as well
+		conflictName += "-" + time.Now().UTC().Format(conflictTimeLayout)
+	}
+	log.Warn("FileCache::reconcileDirtyFile : %s changed in storage, saving local changes as %s", marker.Name, conflictName)
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	options, err := fc.cipher.uploadOptions(conflictName, f)
+	if err == nil {
+		err = fc.NextComponent().CopyFromFile(options)
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// The local copy does not match storage, so the next open downloads the file again
+	err = deleteFile(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Err("FileCache::reconcileDirtyFile : failed to delete local file %s [%s]", localPath, err.Error())
+	}
+	return nil
+}
+
+// ListPendingFiles : Files left with changes in the temp path that the next mount will reconcile with storage
+func ListPendingFiles(opt FileCacheOptions) ([]PendingFile, error) {
+	tmpPath := common.ExpandPath(opt.TmpPath)
+	if tmpPath == "" {
+		return nil, fmt.Errorf("tmp-path not set")
+	}
+
+	journalPath := common.ExpandPath(opt.JournalPath)
+	if journalPath == "" {
+		journalPath = filepath.Join(stateDir(tmpPath), "journal")
+	}
+
+	markers, err := readMarkers(filepath.Join(stateDir(tmpPath), "dirty"))
+	if err != nil {
+		return nil, err
+	}
+
+	journaled, err := readJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]PendingFile, 0, len(markers))
+	found := make(map[string]int)
+	for _, marker := range markers {
+		found[marker.Name] = len(files)
+		files = append(files, PendingFile{Name: marker.Name, Changed: marker.Changed})
+	}
+	for _, name := range journaled {
+		if i, ok := found[name]; ok {
+			files[i].Journaled = true
+			continue
+		}
+		found[name] = len(files)
+		files = append(files, PendingFile{Name: name, Journaled: true})
+	}
+
+	for i := range files {
+		info, err := os.Stat(filepath.Join(tmpPath, files[i].Name))
+		if err != nil {
+			files[i].Missing = true
+			continue
+		}
+		files[i].Size = info.Size()
+		if opt.Encryption {
+			files[i].Size = encryptedPlainSize(info.Size())
+		}
+		if files[i].Changed.IsZero() {
+			files[i].Changed = info.ModTime()
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
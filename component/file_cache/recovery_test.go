@@ -0,0 +1,231 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
+)
+
+// writeAndCrash : Change the file without flushing it and stop the pipeline as a killed mount would leave it
+func (suite *fileCacheTestSuite) writeAndCrash(handle *handlemap.Handle, data []byte) string {
+	_, err := suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+	suite.assert.Nil(err)
+	handle.GetFileObject().Close()
+
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.loopback.Stop()
+	suite.assert.Nil(suite.fileCache.Stop())
+	return config
+}
+
+func (suite *fileCacheTestSuite) TestDirtyMarker() {
+	defer suite.cleanupTest()
+
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("data")})
+
+	markers, err := readMarkers(suite.fileCache.dirtyPath)
+	suite.assert.Nil(err)
+	suite.assert.Len(markers, 1)
+	suite.assert.Equal(path, markers[0].Name)
+	suite.assert.Nil(markers[0].Remote)
+
+	// Cleared once the change is in storage
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	markers, err = readMarkers(suite.fileCache.dirtyPath)
+	suite.assert.Nil(err)
+	suite.assert.Empty(markers)
+}
+
+func (suite *fileCacheTestSuite) TestRecoverUnchangedFile() {
+	defer suite.cleanupTest()
+
+	path := "file"
+	os.MkdirAll(suite.fake_storage_path, 0777)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("old"), 0777)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	data := []byte("changed before crash")
+	config := suite.writeAndCrash(handle, data)
+
+	suite.assert.True(hasMarkers(filepath.Join(stateDir(suite.cache_path), "dirty")))
+	files, err := ListPendingFiles(FileCacheOptions{TmpPath: suite.cache_path})
+	suite.assert.Nil(err)
+	suite.assert.Len(files, 1)
+	suite.assert.Equal(path, files[0].Name)
+	suite.assert.EqualValues(len(data), files[0].Size)
+	suite.assert.False(files[0].Missing)
+
+	// Storage still has what the changes were made on, so they are uploaded
+	suite.setupTestHelper(config)
+	suite.assert.True(suite.fileCache.allowNonEmpty)
+	suite.assert.False(hasMarkers(suite.fileCache.dirtyPath))
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, storageData)
+}
+
+func (suite *fileCacheTestSuite) TestRecoverChangedFile() {
+	defer suite.cleanupTest()
+
+	path := "file"
+	remote := []byte("changed by another writer")
+	os.MkdirAll(suite.fake_storage_path, 0777)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("old"), 0777)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	data := []byte("changed before crash")
+	config := suite.writeAndCrash(handle, data)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), remote, 0777)
+
+	// Local changes are saved aside and storage is left as is
+	suite.setupTestHelper(config)
+	suite.assert.False(hasMarkers(suite.fileCache.dirtyPath))
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(remote, storageData)
+	conflictData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path+conflictSuffix))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, conflictData)
+	suite.assert.NoFileExists(filepath.Join(suite.cache_path, path))
+}
+
+func (suite *fileCacheTestSuite) TestRecoverChangedBeforeWrite() {
+	defer suite.cleanupTest()
+
+	path := "file"
+	os.MkdirAll(suite.fake_storage_path, 0777)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("old"), 0777)
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDONLY, Mode: 0777})
+	suite.assert.Nil(err)
+	suite.assert.Nil(suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle}))
+
+	// Changed in storage while the local copy is still served from the cache
+	remote := []byte("changed by another writer")
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), remote, 0777)
+	handle, err = suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	data := []byte("changed before crash")
+	config := suite.writeAndCrash(handle, data)
+
+	// The changes were made on the old copy, so the remote change is not overwritten
+	suite.setupTestHelper(config)
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(remote, storageData)
+	conflictData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path+conflictSuffix))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, conflictData)
+}
+
+func (suite *fileCacheTestSuite) TestRecoverKeepsEarlierConflict() {
+	defer suite.cleanupTest()
+
+	path := "file"
+	earlier := []byte("saved aside earlier")
+	os.MkdirAll(suite.fake_storage_path, 0777)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("old"), 0777)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path+conflictSuffix), earlier, 0777)
+
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	data := []byte("changed before crash")
+	config := suite.writeAndCrash(handle, data)
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("changed by another writer"), 0777)
+
+	// Saved under a name with the time of the recovery
+	suite.setupTestHelper(config)
+	conflictData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path+conflictSuffix))
+	suite.assert.Nil(err)
+	suite.assert.Equal(earlier, conflictData)
+	matches, err := filepath.Glob(filepath.Join(suite.fake_storage_path, path+conflictSuffix+"-*"))
+	suite.assert.Nil(err)
+	suite.assert.Len(matches, 1)
+	conflictData, err = os.ReadFile(matches[0])
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, conflictData)
+}
+
+func (suite *fileCacheTestSuite) TestRecoverNewFile() {
+	defer suite.cleanupTest()
+
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	data := []byte("created before crash")
+	config := suite.writeAndCrash(handle, data)
+
+	// Not in storage yet, so it is uploaded
+	suite.setupTestHelper(config)
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, storageData)
+	suite.assert.NoFileExists(filepath.Join(suite.fake_storage_path, path+conflictSuffix))
+}
+
+func (suite *fileCacheTestSuite) TestRecoverSkipsJournal() {
+	defer suite.cleanupTest()
+	suite.setupWriteBackCache()
+
+	path := "file"
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), []byte("old"), 0777)
+	handle, err := suite.fileCache.OpenFile(internal.OpenFileOptions{Name: path, Flags: os.O_RDWR, Mode: 0777})
+	suite.assert.Nil(err)
+	journalPath := suite.fileCache.journalPath
+	suite.writeAndCrash(handle, []byte("flushed before crash"))
+
+	// The crash came after the flush was journaled but before the upload
+	os.WriteFile(journalPath, []byte(fmt.Sprintf("+ %q\n", path)), 0644)
+	remote := []byte("changed by another writer")
+	os.WriteFile(filepath.Join(suite.fake_storage_path, path), remote, 0777)
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  write-back: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+
+	// Saved aside instead of being replayed from the journal over the remote change
+	suite.assert.Nil(suite.fileCache.waitForUpload(path))
+	names, err := readJournal(journalPath)
+	suite.assert.Nil(err)
+	suite.assert.Empty(names)
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(remote, storageData)
+	suite.assert.FileExists(filepath.Join(suite.fake_storage_path, path+conflictSuffix))
+}
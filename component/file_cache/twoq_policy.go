@@ -0,0 +1,124 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+// 2Q keeps a quarter of the cached files in a1in, and remembers evicted files of a1in as many as half the cached files
+const (
+	twoQInPercent  = 25
+	twoQOutPercent = 50
+)
+
+// twoQLists : 2Q (Johnson and Shasha)
+// New files go in the FIFO a1in and files evicted from it are remembered in the ghost list a1out. Only a file used
+// again while in a1out is moved to the LRU list am, so files read once by a scan do not push out the hot ones.
+type twoQLists struct {
+	a1in  *policyList
+	a1out *policyList
+	am    *policyList
+}
+
+var _ ghostLists = &twoQLists{}
+
+func New2QPolicy(cfg cachePolicyConfig) cachePolicy {
+	lists := &twoQLists{
+		a1in:  newPolicyList("a1in"),
+		a1out: newPolicyList("a1out"),
+		am:    newPolicyList("am"),
+	}
+	return newGhostPolicy("2q", lists, cfg)
+}
+
+func (q *twoQLists) access(name string) string {
+	if q.am.contains(name) {
+		q.am.pushFront(name)
+		return ""
+	}
+
+	if q.a1in.contains(name) {
+		// Uses of a file soon after it was added are one use, as a1in is a FIFO
+		return ""
+	}
+
+	if q.a1out.remove(name) {
+		q.am.pushFront(name)
+		return "a1out"
+	}
+
+	q.a1in.pushFront(name)
+	return ""
+}
+
+func (q *twoQLists) remove(name string) {
+	_ = q.a1in.remove(name) || q.am.remove(name) || q.a1out.remove(name)
+}
+
+func (q *twoQLists) victim() string {
+	size := q.a1in.len() + q.am.len()
+	if q.a1in.len() > 0 && (q.a1in.len()*100 > size*twoQInPercent || q.am.len() == 0) {
+		return q.a1in.back()
+	}
+	return q.am.back()
+}
+
+func (q *twoQLists) evict(name string) {
+	if q.a1in.remove(name) {
+		q.a1out.pushFront(name)
+	} else {
+		// Files evicted from am are not remembered
+		q.am.remove(name)
+	}
+
+	for q.a1out.len() > maxInt((q.a1in.len()+q.am.len())*twoQOutPercent/100, minGhostEntries) {
+		q.a1out.popBack()
+	}
+}
+
+func (q *twoQLists) restore(name string, usage uint64) {
+	if usage > 1 {
+		q.am.pushFront(name)
+	} else {
+		q.a1in.pushFront(name)
+	}
+}
+
+// cached : Files of am and a1in, a1in being emptied first once it holds more than its share
+func (q *twoQLists) cached() ([]string, []string) {
+	return q.am.names(), q.a1in.names()
+}
+
+func (q *twoQLists) trace() {
+	q.a1in.trace()
+	q.am.trace()
+	q.a1out.trace()
+}
@@ -0,0 +1,181 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type twoQPolicyTestSuite struct {
+	suite.Suite
+	assert *assert.Assertions
+	policy *ghostPolicy
+	lists  *twoQLists
+}
+
+func (suite *twoQPolicyTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+
+	os.Mkdir(cache_path, fs.FileMode(0777))
+
+	config := cachePolicyConfig{
+		tmpPath:       cache_path,
+		cacheTimeout:  0,
+		maxEviction:   defaultMaxEviction,
+		maxSizeMB:     0,
+		highThreshold: defaultMaxThreshold,
+		lowThreshold:  defaultMinThreshold,
+		fileLocks:     &common.LockMap{},
+	}
+
+	suite.setupTestHelper(config)
+}
+
+func (suite *twoQPolicyTestSuite) setupTestHelper(config cachePolicyConfig) {
+	suite.policy = New2QPolicy(config).(*ghostPolicy)
+	suite.lists = suite.policy.lists.(*twoQLists)
+	suite.policy.StartPolicy()
+}
+
+func (suite *twoQPolicyTestSuite) cleanupTest() {
+	suite.policy.ShutdownPolicy()
+
+	os.RemoveAll(cache_path)
+}
+
+func (suite *twoQPolicyTestSuite) TestDefault() {
+	defer suite.cleanupTest()
+	suite.assert.EqualValues("2q", suite.policy.Name())
+	suite.assert.EqualValues(0, suite.policy.cacheTimeout)
+	suite.assert.EqualValues(defaultMaxEviction, suite.policy.maxEviction)
+}
+
+func (suite *twoQPolicyTestSuite) TestCacheValid() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("temp")
+	suite.policy.CacheValid("temp")
+
+	// Uses while the file is in a1in count as one
+	suite.assert.True(suite.policy.IsCached("temp"))
+	suite.assert.True(suite.lists.a1in.contains("temp"))
+	suite.assert.Equal(0, suite.lists.am.len())
+}
+
+func (suite *twoQPolicyTestSuite) TestScanResistance() {
+	defer suite.cleanupTest()
+	for i := 0; i < 3; i++ {
+		suite.policy.CacheValid("hot" + fmt.Sprint(i))
+	}
+	suite.policy.maxEviction = 3
+	suite.assert.Equal(3, suite.policy.evict(suite.policy.victims()))
+	for i := 0; i < 3; i++ {
+		suite.policy.CacheValid("hot" + fmt.Sprint(i))
+		suite.assert.True(suite.lists.am.contains("hot" + fmt.Sprint(i)))
+	}
+	suite.assert.EqualValues(3, suite.policy.ghostHits["a1out"])
+
+	for i := 0; i < 10; i++ {
+		suite.policy.CacheValid("scan" + fmt.Sprint(i))
+	}
+
+	// Files read once by the scan are evicted before the ones used again, till a1in is back to its share
+	suite.policy.maxEviction = 9
+	suite.assert.Equal(9, suite.policy.evict(suite.policy.victims()))
+	for i := 0; i < 3; i++ {
+		suite.assert.True(suite.policy.IsCached("hot" + fmt.Sprint(i)))
+	}
+	for i := 0; i < 9; i++ {
+		suite.assert.False(suite.policy.IsCached("scan" + fmt.Sprint(i)))
+		suite.assert.True(suite.lists.a1out.contains("scan" + fmt.Sprint(i)))
+	}
+	suite.assert.True(suite.policy.IsCached("scan9"))
+
+	// Files evicted from am are not remembered
+	suite.policy.maxEviction = 1
+	suite.assert.Equal(1, suite.policy.evict(suite.policy.victims()))
+	suite.assert.False(suite.policy.IsCached("hot0"))
+	suite.assert.False(suite.lists.a1out.contains("hot0"))
+}
+
+func (suite *twoQPolicyTestSuite) TestTimeout() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+
+	config := cachePolicyConfig{
+		tmpPath:       cache_path,
+		cacheTimeout:  1,
+		maxEviction:   defaultMaxEviction,
+		maxSizeMB:     0,
+		highThreshold: defaultMaxThreshold,
+		lowThreshold:  defaultMinThreshold,
+		fileLocks:     &common.LockMap{},
+	}
+
+	suite.setupTestHelper(config)
+
+	suite.policy.CacheValid("temp")
+
+	time.Sleep(3 * time.Second) // Wait for time > cacheTimeout, the file should no longer be cached
+
+	suite.assert.False(suite.policy.IsCached("temp"))
+	suite.assert.True(suite.lists.a1out.contains("temp"))
+}
+
+func (suite *twoQPolicyTestSuite) TestExportImportState() {
+	defer suite.cleanupTest()
+	suite.policy.CacheValid("file1")
+	suite.policy.CacheValid("file2")
+	suite.policy.CacheValid("file1")
+
+	state := suite.policy.ExportState()
+	suite.assert.Equal([]policyState{{name: "file2", usage: 1}, {name: "file1", usage: 1}}, state)
+
+	suite.cleanupTest()
+	suite.SetupTest()
+	suite.policy.ImportState(state)
+	suite.assert.Equal(state, suite.policy.ExportState())
+}
+
+func TestTwoQPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(twoQPolicyTestSuite))
+}
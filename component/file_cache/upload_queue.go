@@ -0,0 +1,480 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+)
+
+const (
+	// By default 4 files are uploaded in parallel in write-back mode
+	defaultUploadWorkers = 4
+
+	// Failed uploads are retried a few times before the file is given up on
+	maxUploadRetries    = 3
+	uploadRetryInterval = 5 * time.Second
+)
+
+// State of a file waiting to be uploaded
+type uploadState struct {
+	queued  bool // waiting for a worker
+	running bool // a worker is uploading the file
+	again   bool // flushed again while being uploaded, so it needs another upload
+	retries int
+	done    chan struct{} // closed once the file is no longer pending
+}
+
+// uploadQueue : Uploads flushed files in the background. Every file pending upload is recorded in a journal
+// so that a crash before the upload committed does not lose it.
+type uploadQueue struct {
+	sync.Mutex
+	cond    *sync.Cond
+	pending map[string]*uploadState
+	failed  map[string]bool // files given up on, they stay in the journal for the next mount
+	queue   []string
+	closed  bool
+
+	journalPath string
+	journal     *os.File
+
+	upload  func(name string) error // upload the local copy of the file
+	hold    func(name string)       // file became pending
+	release func(name string)       // file is no longer pending
+
+	workers sync.WaitGroup
+}
+
+func newUploadQueue(journalPath string, upload func(string) error, hold func(string), release func(string)) *uploadQueue {
+	q := &uploadQueue{
+		pending:     make(map[string]*uploadState),
+		failed:      make(map[string]bool),
+		queue:       make([]string, 0),
+		journalPath: journalPath,
+		upload:      upload,
+		hold:        hold,
+		release:     release,
+	}
+	q.cond = sync.NewCond(&q.Mutex)
+	return q
+}
+
+// readJournal : Files that were flushed but not uploaded as per the journal, in the order they were flushed
+func readJournal(journalPath string) ([]string, error) {
+	f, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	order := make([]string, 0)
+	pending := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 3 {
+			continue
+		}
+
+		// A line torn by a crash is skipped
+		name, err := strconv.Unquote(line[2:])
+		if err != nil {
+			continue
+		}
+
+		switch line[0] {
+		case '+':
+			if !pending[name] {
+				order = append(order, name)
+			}
+			pending[name] = true
+		case '-':
+			pending[name] = false
+		}
+	}
+
+	names := make([]string, 0)
+	for _, name := range order {
+		if pending[name] {
+			names = append(names, name)
+			pending[name] = false
+		}
+	}
+
+	return names, scanner.Err()
+}
+
+// dropFromJournal : Record in the journal that the files are no longer pending
+func dropFromJournal(journalPath string, names map[string]bool) error {
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for name := range names {
+		_, err = f.WriteString(fmt.Sprintf("- %s\n", strconv.Quote(name)))
+		if err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+// start : Open the journal, queue the files it says are pending and start the workers
+func (q *uploadQueue) start(workers uint32) error {
+	names, err := readJournal(q.journalPath)
+	if err != nil {
+		return err
+	}
+
+	// Rewrite the journal with only the files still pending
+	q.journal, err = os.OpenFile(q.journalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		log.Info("uploadQueue::start : %s was not uploaded before the last unmount", name)
+		q.add(name)
+	}
+
+	for i := uint32(0); i < workers; i++ {
+		q.workers.Add(1)
+		go q.worker()
+	}
+
+	return nil
+}
+
+// stop : Upload everything still pending and stop the workers
+func (q *uploadQueue) stop() {
+	q.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.Unlock()
+
+	q.workers.Wait()
+
+	if q.journal != nil {
+		q.journal.Close()
+		q.journal = nil
+	}
+}
+
+// writeJournal : Record a change in the set of pending files
+// Caller shall hold the queue lock
+func (q *uploadQueue) writeJournal(op byte, name string, sync bool) {
+	if q.journal == nil {
+		return
+	}
+
+	_, err := q.journal.WriteString(fmt.Sprintf("%c %s\n", op, strconv.Quote(name)))
+	if err == nil && sync {
+		err = q.journal.Sync()
+	}
+	if err != nil {
+		log.Err("uploadQueue::writeJournal : failed to record %s [%s]", name, err.Error())
+	}
+}
+
+// add : Queue the file for upload, a file already queued is uploaded only once
+func (q *uploadQueue) add(name string) {
+	q.Lock()
+
+	state, found := q.pending[name]
+	if found {
+		if state.running {
+			state.again = true
+		}
+		q.Unlock()
+		return
+	}
+
+	q.pending[name] = &uploadState{queued: true, done: make(chan struct{})}
+	q.queue = append(q.queue, name)
+	q.writeJournal('+', name, true)
+	q.cond.Signal()
+	q.Unlock()
+
+	q.hold(name)
+}
+
+// isPending : Whether the file is waiting to be uploaded or being uploaded
+func (q *uploadQueue) isPending(name string) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	_, found := q.pending[name]
+	return found
+}
+
+// hasPending : Whether any file flushed during this mount is not in storage yet
+func (q *uploadQueue) hasPending() bool {
+	q.Lock()
+	defer q.Unlock()
+
+	return len(q.pending) > 0 || len(q.failed) > 0
+}
+
+// finish : The file is no longer pending
+// Caller shall hold the queue lock, and call release after unlocking
+func (q *uploadQueue) finish(name string, state *uploadState) {
+	delete(q.pending, name)
+	delete(q.failed, name)
+	q.writeJournal('-', name, false)
+	close(state.done)
+
+	if len(q.pending) == 0 && len(q.failed) == 0 && q.journal != nil {
+		// Nothing pending so the journal can start over
+		_ = q.journal.Truncate(0)
+		_, _ = q.journal.Seek(0, 0)
+	}
+}
+
+// removeQueued : Take the file out of the queue
+// Caller shall hold the queue lock
+func (q *uploadQueue) removeQueued(name string) {
+	for i, queued := range q.queue {
+		if queued == name {
+			q.queue = append(q.queue[:i], q.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// wait : Upload the file now if it is queued, or wait for the upload in progress to commit
+func (q *uploadQueue) wait(name string) error {
+	q.Lock()
+	state, found := q.pending[name]
+	if !found {
+		q.Unlock()
+		return nil
+	}
+
+	if state.queued {
+		q.removeQueued(name)
+		state.queued = false
+		state.running = true
+		q.Unlock()
+		return q.run(name, state)
+	}
+
+	q.Unlock()
+	<-state.done
+	return nil
+}
+
+// waitPrefix : Upload or wait for all the pending files under the directory
+func (q *uploadQueue) waitPrefix(prefix string) error {
+	q.Lock()
+	names := make([]string, 0)
+	for name := range q.pending {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	q.Unlock()
+
+	for _, name := range names {
+		err := q.wait(name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancel : The file is gone, drop it from the queue or wait for the upload in progress
+func (q *uploadQueue) cancel(name string) {
+	q.Lock()
+	state, found := q.pending[name]
+	if !found {
+		failed := q.failed[name]
+		if failed {
+			delete(q.failed, name)
+			q.writeJournal('-', name, false)
+		}
+		q.Unlock()
+		if failed {
+			q.release(name)
+		}
+		return
+	}
+
+	if state.queued {
+		q.removeQueued(name)
+		q.finish(name, state)
+		q.Unlock()
+		q.release(name)
+		return
+	}
+
+	state.again = false
+	q.Unlock()
+	<-state.done
+}
+
+// run : Upload the file and update its state
+func (q *uploadQueue) run(name string, state *uploadState) error {
+	err := q.upload(name)
+
+	q.Lock()
+	state.running = false
+	if err != nil {
+		state.retries++
+		if state.retries < maxUploadRetries && !q.closed {
+			log.Err("uploadQueue::run : %s upload failed, will retry [%s]", name, err.Error())
+			state.queued = true
+			q.Unlock()
+			time.AfterFunc(uploadRetryInterval, func() { q.requeue(name, state) })
+			return err
+		}
+
+		// The local copy is kept and not released, so it is neither evicted nor forgotten by the journal
+		log.Err("uploadQueue::run : %s upload failed, leaving it for the next mount [%s]", name, err.Error())
+		delete(q.pending, name)
+		q.failed[name] = true
+		close(state.done)
+		q.Unlock()
+		return err
+	} else if state.again {
+		// Flushed again while uploading, so upload once more
+		state.again = false
+		state.retries = 0
+		state.queued = true
+		q.queue = append(q.queue, name)
+		q.cond.Signal()
+		q.Unlock()
+		return nil
+	}
+
+	q.finish(name, state)
+	q.Unlock()
+	q.release(name)
+	return err
+}
+
+// requeue : Put the file back in the queue for another attempt
+func (q *uploadQueue) requeue(name string, state *uploadState) {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.pending[name] == state && state.queued {
+		q.queue = append(q.queue, name)
+		q.cond.Signal()
+	}
+}
+
+// worker : Upload queued files till the queue is stopped and empty
+func (q *uploadQueue) worker() {
+	defer q.workers.Done()
+
+	for {
+		q.Lock()
+		for len(q.queue) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+
+		if len(q.queue) == 0 {
+			q.Unlock()
+			return
+		}
+
+		name := q.queue[0]
+		q.queue = q.queue[1:]
+		state := q.pending[name]
+		state.queued = false
+		state.running = true
+		q.Unlock()
+
+		_ = q.run(name, state)
+	}
+}
+
+// isUploadPending : Whether the local copy of the file has changes that are not in storage yet
+func (fc *FileCache) isUploadPending(name string) bool {
+	_, found := fc.uploading.Load(filepath.Join(fc.tmpPath, name))
+	return found
+}
+
+// waitForUpload : Storage shall have the latest local changes of the file before it is operated upon
+func (fc *FileCache) waitForUpload(name string) error {
+	if fc.uploads == nil {
+		return nil
+	}
+	return fc.uploads.wait(name)
+}
+
+// waitForUploads : Storage shall have the latest local changes of all the files under the directory
+func (fc *FileCache) waitForUploads(dir string) error {
+	if fc.uploads == nil {
+		return nil
+	}
+	return fc.uploads.waitPrefix(internal.ExtendDirName(dir))
+}
+
+// cancelUpload : Local changes of the file are not needed any more
+func (fc *FileCache) cancelUpload(name string) {
+	if fc.uploads != nil {
+		fc.uploads.cancel(name)
+	}
+}
+
+// holdForUpload : Keep the local copy of the file till it is uploaded
+func (fc *FileCache) holdForUpload(name string) {
+	fc.uploading.Store(filepath.Join(fc.tmpPath, name), true)
+}
+
+// releaseUpload : The file is in storage, so the cache policy can evict the local copy
+func (fc *FileCache) releaseUpload(name string) {
+	localPath := filepath.Join(fc.tmpPath, name)
+	fc.uploading.Delete(localPath)
+	fc.clearDirty(name)
+	fc.policy.CacheInvalidate(localPath)
+}
@@ -0,0 +1,365 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package file_cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-fuse/v2/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type uploadQueueTestSuite struct {
+	suite.Suite
+	assert      *assert.Assertions
+	journalPath string
+
+	lock     sync.Mutex
+	uploaded []string
+	held     map[string]bool
+	block    chan bool
+	fail     bool
+}
+
+func (suite *uploadQueueTestSuite) SetupTest() {
+	suite.assert = assert.New(suite.T())
+	suite.journalPath = filepath.Join(home_dir, "upload_journal"+randomString(8))
+	suite.uploaded = make([]string, 0)
+	suite.held = make(map[string]bool)
+	suite.block = nil
+	suite.fail = false
+}
+
+func (suite *uploadQueueTestSuite) cleanupTest() {
+	os.Remove(suite.journalPath)
+}
+
+func (suite *uploadQueueTestSuite) newQueue() *uploadQueue {
+	upload := func(name string) error {
+		if suite.block != nil {
+			<-suite.block
+		}
+		suite.lock.Lock()
+		defer suite.lock.Unlock()
+		if suite.fail {
+			return errors.New("upload failed")
+		}
+		suite.uploaded = append(suite.uploaded, name)
+		return nil
+	}
+	hold := func(name string) {
+		suite.lock.Lock()
+		suite.held[name] = true
+		suite.lock.Unlock()
+	}
+	release := func(name string) {
+		suite.lock.Lock()
+		delete(suite.held, name)
+		suite.lock.Unlock()
+	}
+	return newUploadQueue(suite.journalPath, upload, hold, release)
+}
+
+func (suite *uploadQueueTestSuite) TestUpload() {
+	defer suite.cleanupTest()
+	q := suite.newQueue()
+	suite.assert.Nil(q.start(2))
+
+	q.add("a")
+	q.add("b")
+	q.stop()
+
+	suite.assert.ElementsMatch([]string{"a", "b"}, suite.uploaded)
+	suite.assert.Empty(suite.held)
+	suite.assert.False(q.hasPending())
+
+	// Nothing left in the journal once everything is uploaded
+	names, err := readJournal(suite.journalPath)
+	suite.assert.Nil(err)
+	suite.assert.Empty(names)
+}
+
+func (suite *uploadQueueTestSuite) TestCoalesce() {
+	defer suite.cleanupTest()
+	q := suite.newQueue()
+
+	// No workers yet, so repeated closes find the file still queued
+	q.add("a")
+	q.add("a")
+	q.add("a")
+	suite.assert.True(q.isPending("a"))
+	suite.assert.True(suite.held["a"])
+
+	suite.assert.Nil(q.start(1))
+	q.stop()
+	suite.assert.Equal([]string{"a"}, suite.uploaded)
+}
+
+func (suite *uploadQueueTestSuite) TestFlushedAgainWhileUploading() {
+	defer suite.cleanupTest()
+	suite.block = make(chan bool)
+	q := suite.newQueue()
+	suite.assert.Nil(q.start(1))
+
+	q.add("a")
+	for {
+		q.Lock()
+		running := q.pending["a"].running
+		q.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	q.add("a")
+	close(suite.block)
+	q.stop()
+	suite.assert.Equal([]string{"a", "a"}, suite.uploaded)
+}
+
+func (suite *uploadQueueTestSuite) TestJournalReplay() {
+	defer suite.cleanupTest()
+	q := suite.newQueue()
+	q.journal, _ = os.OpenFile(suite.journalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+
+	// Crash before any worker ran
+	q.add("a")
+	q.add("b")
+	q.add("c")
+	q.cancel("b")
+	q.journal.Close()
+
+	names, err := readJournal(suite.journalPath)
+	suite.assert.Nil(err)
+	suite.assert.Equal([]string{"a", "c"}, names)
+
+	q = suite.newQueue()
+	suite.assert.Nil(q.start(1))
+	q.stop()
+	suite.assert.Equal([]string{"a", "c"}, suite.uploaded)
+}
+
+func (suite *uploadQueueTestSuite) TestJournalTornLine() {
+	defer suite.cleanupTest()
+	os.WriteFile(suite.journalPath, []byte("+ \"a\"\n+ \"b\"\n- \"a\"\n+ \"c"), 0644)
+
+	names, err := readJournal(suite.journalPath)
+	suite.assert.Nil(err)
+	suite.assert.Equal([]string{"b"}, names)
+}
+
+func (suite *uploadQueueTestSuite) TestWait() {
+	defer suite.cleanupTest()
+	q := suite.newQueue()
+
+	// Queued file is uploaded right away
+	q.add("a")
+	suite.assert.Nil(q.wait("a"))
+	suite.assert.Equal([]string{"a"}, suite.uploaded)
+	suite.assert.False(q.isPending("a"))
+	suite.assert.Nil(q.wait("b"))
+}
+
+func (suite *uploadQueueTestSuite) TestFailedUploadKept() {
+	defer suite.cleanupTest()
+	suite.fail = true
+	q := suite.newQueue()
+	suite.assert.Nil(q.start(1))
+
+	q.add("a")
+	q.stop()
+
+	// Still held and journaled for the next mount
+	suite.assert.True(suite.held["a"])
+	suite.assert.True(q.hasPending())
+	names, err := readJournal(suite.journalPath)
+	suite.assert.Nil(err)
+	suite.assert.Equal([]string{"a"}, names)
+}
+
+func TestUploadQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(uploadQueueTestSuite))
+}
+
+func (suite *fileCacheTestSuite) setupWriteBackCache() {
+	suite.cleanupTest()
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  timeout-sec: 120\n  write-back: true\n  upload-workers: 2\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+}
+
+// holdUploads : Replace the upload queue with one without workers, so flushed files stay pending till waited for
+func (suite *fileCacheTestSuite) holdUploads() {
+	fc := suite.fileCache
+	fc.uploads.stop()
+	fc.uploads = newUploadQueue(fc.journalPath, fc.uploadFile, fc.holdForUpload, fc.releaseUpload)
+}
+
+func (suite *fileCacheTestSuite) TestConfigWriteBack() {
+	defer suite.cleanupTest()
+	suite.assert.False(suite.fileCache.writeBack)
+	suite.assert.Nil(suite.fileCache.uploads)
+
+	suite.setupWriteBackCache()
+	suite.assert.True(suite.fileCache.writeBack)
+	suite.assert.True(suite.fileCache.allowNonEmpty)
+	suite.assert.EqualValues(2, suite.fileCache.uploadWorkers)
+	suite.assert.Equal(filepath.Join(suite.cache_path, stateDirName, "journal"), suite.fileCache.journalPath)
+}
+
+func (suite *fileCacheTestSuite) TestWriteBackFlush() {
+	defer suite.cleanupTest()
+	suite.setupWriteBackCache()
+
+	path := "file"
+	data := []byte("write back data")
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+
+	suite.holdUploads()
+	err := suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.assert.Nil(err)
+	suite.assert.False(handle.Dirty())
+	suite.assert.True(suite.fileCache.isUploadPending(path))
+
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Local copy keeps serving attributes till the upload commits
+	attr, err := suite.fileCache.GetAttr(internal.GetAttrOptions{Name: path})
+	suite.assert.Nil(err)
+	suite.assert.EqualValues(len(data), attr.Size)
+
+	suite.assert.Nil(suite.fileCache.waitForUpload(path))
+	suite.assert.False(suite.fileCache.isUploadPending(path))
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, storageData)
+}
+
+func (suite *fileCacheTestSuite) TestWriteBackStopUploads() {
+	defer suite.cleanupTest()
+	suite.setupWriteBackCache()
+
+	path := "file"
+	data := []byte("uploaded on unmount")
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	suite.loopback.Stop()
+	suite.assert.Nil(suite.fileCache.Stop())
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, storageData)
+
+	// Nothing pending so the journal is empty
+	names, err := readJournal(suite.fileCache.journalPath)
+	suite.assert.Nil(err)
+	suite.assert.Empty(names)
+
+	// Start again so the deferred cleanup has a pipeline to stop
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+}
+
+func (suite *fileCacheTestSuite) TestWriteBackRecoverFromJournal() {
+	defer suite.cleanupTest()
+	suite.cleanupTest()
+
+	// A previous mount crashed after the file was flushed
+	path := "file"
+	data := []byte("not uploaded before crash")
+	createStateDir(suite.cache_path)
+	os.WriteFile(filepath.Join(suite.cache_path, path), data, 0777)
+	os.WriteFile(filepath.Join(stateDir(suite.cache_path), "journal"), []byte(fmt.Sprintf("+ %q\n", path)), 0644)
+
+	config := fmt.Sprintf("file_cache:\n  path: %s\n  offload-io: true\n  cleanup-on-start: true\n  write-back: true\n\nloopbackfs:\n  path: %s", suite.cache_path, suite.fake_storage_path)
+	suite.setupTestHelper(config)
+
+	suite.assert.Nil(suite.fileCache.waitForUpload(path))
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, path))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, storageData)
+}
+
+func (suite *fileCacheTestSuite) TestWriteBackDeletePending() {
+	defer suite.cleanupTest()
+	suite.setupWriteBackCache()
+
+	path := "file"
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: path, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: []byte("data")})
+
+	suite.holdUploads()
+	suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+	suite.assert.True(suite.fileCache.isUploadPending(path))
+
+	// Never uploaded as it is gone
+	err := suite.fileCache.DeleteFile(internal.DeleteFileOptions{Name: path})
+	suite.assert.Nil(err)
+	suite.assert.False(suite.fileCache.isUploadPending(path))
+	suite.assert.NoFileExists(filepath.Join(suite.cache_path, path))
+	suite.assert.NoFileExists(filepath.Join(suite.fake_storage_path, path))
+}
+
+func (suite *fileCacheTestSuite) TestWriteBackRenamePending() {
+	defer suite.cleanupTest()
+	suite.setupWriteBackCache()
+
+	src, dst := "src", "dst"
+	data := []byte("renamed before upload")
+	handle, _ := suite.fileCache.CreateFile(internal.CreateFileOptions{Name: src, Mode: 0777})
+	suite.fileCache.WriteFile(internal.WriteFileOptions{Handle: handle, Offset: 0, Data: data})
+
+	suite.holdUploads()
+	suite.fileCache.FlushFile(internal.FlushFileOptions{Handle: handle})
+	suite.fileCache.CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	// Source is uploaded first so the rename in storage carries the data
+	err := suite.fileCache.RenameFile(internal.RenameFileOptions{Src: src, Dst: dst})
+	suite.assert.Nil(err)
+	suite.assert.False(suite.fileCache.isUploadPending(src))
+	storageData, err := os.ReadFile(filepath.Join(suite.fake_storage_path, dst))
+	suite.assert.Nil(err)
+	suite.assert.Equal(data, storageData)
+}
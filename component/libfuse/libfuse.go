@@ -36,6 +36,9 @@ package libfuse
 import (
 	"context"
 	"fmt"
+	"math"
+	"strconv"
+	"syscall"
 
 	"github.com/Azure/azure-storage-fuse/v2/common"
 	"github.com/Azure/azure-storage-fuse/v2/common/config"
@@ -69,6 +72,7 @@ type Libfuse struct {
 	disableWritebackCache bool
 	ignoreOpenFlags       bool
 	nonEmptyMount         bool
+	fileLocks             bool
 	lsFlags               common.BitMap16
 }
 
@@ -97,6 +101,7 @@ type LibfuseOptions struct {
 	nonEmptyMount           bool   `config:"nonempty" yaml:"nonempty,omitempty"`
 	Uid                     uint32 `config:"uid" yaml:"uid,omitempty"`
 	Gid                     uint32 `config:"gid" yaml:"uid,omitempty"`
+	FileLocks               bool   `config:"file-locks" yaml:"file-locks,omitempty"`
 }
 
 const compName = "libfuse"
@@ -146,6 +151,9 @@ func (lf *Libfuse) Start(ctx context.Context) error {
 	// This marks the global fuse object so shall be the first statement
 	fuseFS = lf
 
+	// Paths changed in storage by other writers are dropped from the kernel caches
+	internal.AddInvalidationHandler(lf.Name(), lf.invalidateKernelCache)
+
 	// This starts the libfuse process and hence shall always be the last statement
 	err := lf.initFuse()
 	if err != nil {
@@ -159,6 +167,7 @@ func (lf *Libfuse) Start(ctx context.Context) error {
 // Stop : Stop the component functionality and kill all threads started
 func (lf *Libfuse) Stop() error {
 	log.Trace("Libfuse::Stop : Stopping component %s", lf.Name())
+	internal.RemoveInvalidationHandler(lf.Name())
 	_ = lf.destroyFuse()
 	libfuseStatsCollector.Destroy()
 	return nil
@@ -174,6 +183,7 @@ func (lf *Libfuse) Validate(opt *LibfuseOptions) error {
 	lf.disableWritebackCache = opt.DisableWritebackCache
 	lf.ignoreOpenFlags = opt.IgnoreOpenFlags
 	lf.nonEmptyMount = opt.nonEmptyMount
+	lf.fileLocks = opt.FileLocks
 
 	if opt.allowOther {
 		lf.dirPermission = uint(common.DefaultAllowOtherPermissionBits)
@@ -268,8 +278,8 @@ func (lf *Libfuse) Configure(_ bool) error {
 		return fmt.Errorf("%s config error %s", lf.Name(), err.Error())
 	}
 
-	log.Info("Libfuse::Configure : read-only %t, allow-other %t, default-perm %d, entry-timeout %d, attr-time %d, negative-timeout %d, ignore-open-flags: %t, nonempty %t",
-		lf.readOnly, lf.allowOther, lf.filePermission, lf.entryExpiration, lf.attributeExpiration, lf.negativeTimeout, lf.ignoreOpenFlags, lf.nonEmptyMount)
+	log.Info("Libfuse::Configure : read-only %t, allow-other %t, default-perm %d, entry-timeout %d, attr-time %d, negative-timeout %d, ignore-open-flags: %t, nonempty %t, file-locks %t",
+		lf.readOnly, lf.allowOther, lf.filePermission, lf.entryExpiration, lf.attributeExpiration, lf.negativeTimeout, lf.ignoreOpenFlags, lf.nonEmptyMount, lf.fileLocks)
 
 	return nil
 }
@@ -278,6 +288,55 @@ func (lf *Libfuse) Configure(_ bool) error {
 func (lf *Libfuse) OnConfigChange() {
 }
 
+// getOwner : Owner reported for the path, storage may return one otherwise the mount owner is used
+func (lf *Libfuse) getOwner(attr *internal.ObjAttr) (uint32, uint32) {
+	if attr.IsOwnerSet() {
+		return attr.Uid, attr.Gid
+	}
+	return lf.ownerUID, lf.ownerGID
+}
+
+// resolveOwner : Replace an owner or group of -1 (leave unchanged) by the current value so storage always gets both
+func (lf *Libfuse) resolveOwner(name string, uid uint32, gid uint32) (int, int, error) {
+	if uid != math.MaxUint32 && gid != math.MaxUint32 {
+		return int(uid), int(gid), nil
+	}
+
+	attr, err := lf.NextComponent().GetAttr(internal.GetAttrOptions{Name: name})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	curUID, curGID := lf.getOwner(attr)
+	if uid == math.MaxUint32 {
+		uid = curUID
+	}
+	if gid == math.MaxUint32 {
+		gid = curGID
+	}
+	return int(uid), int(gid), nil
+}
+
+// setCacheControlXattr : Run the cache control command named by the value of the cache control xattr
+func setCacheControlXattr(name string, value []byte) error {
+	command, found := common.CacheControlCommands[string(value)]
+	if !found {
+		return syscall.EINVAL
+	}
+
+	_, err := fuseFS.NextComponent().CacheControl(internal.CacheControlOptions{Name: name, Command: command})
+	return err
+}
+
+// getCacheControlXattr : Cache status of the file as the value of the cache control xattr
+func getCacheControlXattr(name string) ([]byte, error) {
+	status, err := fuseFS.NextComponent().CacheControl(internal.CacheControlOptions{Name: name, Command: common.CacheControlStatus})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strconv.FormatUint(uint64(status), 10)), nil
+}
+
 // ------------------------- Factory -------------------------------------------
 
 // Pipeline will call this method to create your object, initialize your variables here
@@ -307,6 +366,9 @@ func init() {
 	disableWritebackCache := config.AddBoolFlag("disable-writeback-cache", false, "Disallow libfuse to buffer write requests if you must strictly open files in O_WRONLY or O_APPEND mode.")
 	config.BindPFlag(compName+".disable-writeback-cache", disableWritebackCache)
 
+	fileLocks := config.AddBoolFlag("file-locks", false, "Enforce flock/fcntl locks through the storage, across mounts when blob leases are used.")
+	config.BindPFlag(compName+".file-locks", fileLocks)
+
 	debug := config.AddBoolPFlag("d", false, "Mount with foreground and FUSE logs on.")
 	config.BindPFlag(compName+".fuse-trace", debug)
 	debug.Hidden = true
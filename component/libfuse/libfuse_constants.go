@@ -34,18 +34,24 @@
 package libfuse
 
 const (
-	createDir    = "CreateDir"
-	deleteDir    = "DeleteDir"
-	createFile   = "CreateFile"
-	truncateFile = "TruncateFile"
-	deleteFile   = "DeleteFile"
-	renameDir    = "RenameDir"
-	renameFile   = "RenameFile"
-	createLink   = "CreateLink"
-	readLink     = "ReadLink"
-	syncFile     = "SyncFile"
-	syncDir      = "SyncDir"
-	chmod        = "Chmod"
+	createDir     = "CreateDir"
+	deleteDir     = "DeleteDir"
+	createFile    = "CreateFile"
+	truncateFile  = "TruncateFile"
+	fallocateFile = "FallocateFile"
+	deleteFile    = "DeleteFile"
+	renameDir     = "RenameDir"
+	renameFile    = "RenameFile"
+	copyObject    = "CopyObject"
+	createLink    = "CreateLink"
+	readLink      = "ReadLink"
+	syncFile      = "SyncFile"
+	syncDir       = "SyncDir"
+	chmod         = "Chmod"
+	chown         = "Chown"
+	setTimes      = "SetTimes"
+	setXattr      = "SetXattr"
+	removeXattr   = "RemoveXattr"
 
 	openHandles = "OpenFileHandles"
 	md          = "Mode"
@@ -53,4 +59,10 @@ const (
 	source      = "Src"
 	dest        = "Dest"
 	trgt        = "Target"
+	xattr       = "Xattr"
+	modTime     = "Mtime"
+	uidKey      = "Uid"
+	gidKey      = "Gid"
+	offset      = "Offset"
+	length      = "Length"
 )
@@ -114,8 +114,18 @@ extern int libfuse_readlink(char *path, char *buf, size_t size);
 extern int libfuse_fsync(char *path, int, fuse_file_info_t *fi);
 extern int libfuse_fsyncdir(char *path, int, fuse_file_info_t *);
 
+extern int libfuse_fallocate(char *path, int mode, off_t off, off_t len, fuse_file_info_t *fi);
+
+extern int libfuse_flock(char *path, fuse_file_info_t *fi, int op);
+extern int libfuse_lock(char *path, fuse_file_info_t *fi, int cmd, struct flock *lock);
+
 // chmod, chown and utimens are lib version specific so defined later
 
+extern int libfuse_setxattr(char *path, char *name, char *value, size_t size, int flags);
+extern int libfuse_getxattr(char *path, char *name, char *value, size_t size);
+extern int libfuse_listxattr(char *path, char *list, size_t size);
+extern int libfuse_removexattr(char *path, char *name);
+
 #ifdef __FUSE2__
 extern void *libfuse2_init(fuse_conn_info_t *conn);
 extern int libfuse2_getattr(char *path, stat_t *stbuf);
@@ -134,6 +144,9 @@ extern int libfuse_rename(char *src, char *dst, unsigned int flags);
 extern int libfuse_chmod(char *path, mode_t mode, fuse_file_info_t *fi);
 extern int libfuse_chown(char *path, uid_t uid, gid_t gid, fuse_file_info_t *fi);
 extern int libfuse_utimens(char *path, timespec_t tv[2], fuse_file_info_t *fi);
+extern off_t libfuse_lseek(char *path, off_t off, int whence, fuse_file_info_t *fi);
+extern ssize_t libfuse_copy_file_range(char *path_in, fuse_file_info_t *fi_in, off_t off_in, char *path_out, 
+                                       fuse_file_info_t *fi_out, off_t off_out, size_t size, int flags);
 #endif
 
 // Methods that needs handling in the CGo wrapper for better performance
@@ -148,21 +161,12 @@ static int native_flush_file(char *path, fuse_file_info_t *fi);
 
 // extern int libfuse_mknod(char *path, mode_t mode, dev_t dev);
 // extern int libfuse_link(char *from, char *to);
-// extern int libfuse_setxattr(char *path, char *name, char *value, size_t size, int flags);
-// extern int libfuse_getxattr(char *path, char *name, char *value, size_t size);
-// extern int libfuse_listxattr(char* path, char *list, size_t size);
-// extern int libfuse_removexattr(char *path, char *name);
 // extern int libfuse_access(char *path, int mask);
-// extern int libfuse_lock
 // extern int libfuse_bmap
 // extern int libfuse_ioctl
 // extern int libfuse_poll
 // extern int libfuse_write_buf
 // extern int libfuse_read_buf
-// extern int libfuse_flock
-// extern int libfuse_fallocate
-// extern int libfuse_copyfilerange
-// extern int libfuse_lseek
 // -------------------------------------------------------------------------------------------------------------
 
 
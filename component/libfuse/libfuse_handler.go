@@ -50,7 +50,9 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/Azure/azure-storage-fuse/v2/common"
@@ -145,6 +147,9 @@ func (lf *Libfuse) initFuse() error {
 		// Get our callback table
 		my_operations := C.fuse_operations_t{}
 		C.populate_callbacks(&my_operations)
+		if lf.fileLocks {
+			C.populate_lock_callbacks(&my_operations)
+		}
 
 		// Send our callback table to the extension
 		errc = C.register_callback_to_extension(&my_operations)
@@ -158,6 +163,9 @@ func (lf *Libfuse) initFuse() error {
 		// Populate our methods to be registered to libfuse
 		log.Trace("Libfuse::initFuse : Registering fuse callbacks")
 		C.populate_callbacks(&operations)
+		if lf.fileLocks {
+			C.populate_lock_callbacks(&operations)
+		}
 	}
 
 	log.Trace("Libfuse::initFuse : Populating fuse arguments")
@@ -233,6 +241,18 @@ func populateFuseArgs(opts *C.fuse_options_t, args *C.fuse_args_t) (*C.fuse_opti
 	return opts, 0
 }
 
+// invalidateKernelCache drops what the kernel caches for a path that was changed in storage outside this mount
+func (lf *Libfuse) invalidateKernelCache(name string, _ bool) {
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+
+	// ENOENT means the kernel has not looked up the path so there is nothing cached for it
+	ret := C.invalidate_path(path)
+	if ret != 0 && ret != -C.ENOENT {
+		log.Err("Libfuse::invalidateKernelCache : failed to invalidate %s [%d]", name, ret)
+	}
+}
+
 // destroyFuse is a no-op
 func (lf *Libfuse) destroyFuse() error {
 	log.Trace("Libfuse::destroyFuse : Destroying FUSE")
@@ -249,6 +269,7 @@ func libfuse_init(conn *C.fuse_conn_info_t, cfg *C.fuse_config_t) (res unsafe.Po
 	}
 
 	C.populate_uid_gid()
+	C.save_fuse_instance()
 
 	log.Info("Libfuse::libfuse_init : Kernel Caps : %d", conn.capable)
 
@@ -315,15 +336,16 @@ func libfuse_destroy(data unsafe.Pointer) {
 }
 
 func (lf *Libfuse) fillStat(attr *internal.ObjAttr, stbuf *C.stat_t) {
-	(*stbuf).st_uid = C.uint(lf.ownerUID)
-	(*stbuf).st_gid = C.uint(lf.ownerGID)
+	uid, gid := lf.getOwner(attr)
+	(*stbuf).st_uid = C.uint(uid)
+	(*stbuf).st_gid = C.uint(gid)
 	(*stbuf).st_nlink = 1
 	(*stbuf).st_size = C.long(attr.Size)
 
 	// Populate mode
 	// Backing storage implementation has support for mode.
 	if !attr.IsModeDefault() {
-		(*stbuf).st_mode = C.uint(attr.Mode) & 0xffffffff
+		(*stbuf).st_mode = C.uint(common.PosixFromFileMode(attr.Mode))
 	} else {
 		if attr.IsDir() {
 			(*stbuf).st_mode = C.uint(lf.dirPermission) & 0xffffffff
@@ -399,13 +421,13 @@ func libfuse_mkdir(path *C.char, mode C.mode_t) C.int {
 	name = common.NormalizeObjectName(name)
 	log.Trace("Libfuse::libfuse_mkdir : %s", name)
 
-	err := fuseFS.NextComponent().CreateDir(internal.CreateDirOptions{Name: name, Mode: fs.FileMode(uint32(mode) & 0xffffffff)})
+	err := fuseFS.NextComponent().CreateDir(internal.CreateDirOptions{Name: name, Mode: common.FileModeFromPosix(uint32(mode))})
 	if err != nil {
 		log.Err("Libfuse::libfuse_mkdir : Failed to create %s [%s]", name, err.Error())
 		return -C.EIO
 	}
 
-	libfuseStatsCollector.PushEvents(createDir, name, map[string]interface{}{md: fs.FileMode(uint32(mode) & 0xffffffff)})
+	libfuseStatsCollector.PushEvents(createDir, name, map[string]interface{}{md: common.FileModeFromPosix(uint32(mode))})
 	libfuseStatsCollector.UpdateStats(stats_manager.Increment, createDir, (int64)(1))
 
 	return 0
@@ -584,6 +606,16 @@ func libfuse_statfs(path *C.char, buf *C.statvfs_t) C.int {
 	return 0
 }
 
+// callerOwner returns the owner of the process making the request, or the mount owner outside of a request.
+func callerOwner() (int, int) {
+	var uid C.uid_t
+	var gid C.gid_t
+	if C.get_caller_owner(&uid, &gid) {
+		return int(uid), int(gid)
+	}
+	return int(fuseFS.ownerUID), int(fuseFS.ownerGID)
+}
+
 // libfuse_create creates a file with the specified mode and then opens it.
 //
 //export libfuse_create
@@ -592,7 +624,8 @@ func libfuse_create(path *C.char, mode C.mode_t, fi *C.fuse_file_info_t) C.int {
 	name = common.NormalizeObjectName(name)
 	log.Trace("Libfuse::libfuse_create : %s", name)
 
-	handle, err := fuseFS.NextComponent().CreateFile(internal.CreateFileOptions{Name: name, Mode: fs.FileMode(uint32(mode) & 0xffffffff)})
+	owner, group := callerOwner()
+	handle, err := fuseFS.NextComponent().CreateFile(internal.CreateFileOptions{Name: name, Mode: common.FileModeFromPosix(uint32(mode)), Owner: owner, Group: group})
 	if err != nil {
 		log.Err("Libfuse::libfuse_create : Failed to create %s [%s]", name, err.Error())
 		if os.IsExist(err) {
@@ -611,7 +644,7 @@ func libfuse_create(path *C.char, mode C.mode_t, fi *C.fuse_file_info_t) C.int {
 	log.Trace("Libfuse::libfuse_create : %s, handle %d", name, handle.ID)
 	fi.fh = C.ulong(uintptr(unsafe.Pointer(ret_val)))
 
-	libfuseStatsCollector.PushEvents(createFile, name, map[string]interface{}{md: fs.FileMode(uint32(mode) & 0xffffffff)})
+	libfuseStatsCollector.PushEvents(createFile, name, map[string]interface{}{md: common.FileModeFromPosix(uint32(mode))})
 
 	// increment open file handles count
 	libfuseStatsCollector.UpdateStats(stats_manager.Increment, openHandles, (int64)(1))
@@ -662,6 +695,11 @@ func libfuse_open(path *C.char, fi *C.fuse_file_info_t) C.int {
 		log.Err("Libfuse::libfuse_open : Failed to open %s [%s]", name, err.Error())
 		if os.IsNotExist(err) {
 			return -C.ENOENT
+		} else if err == syscall.EACCES {
+			return -C.EACCES
+		} else if err == syscall.ENODATA {
+			// Blob is in the archive tier
+			return -C.ENODATA
 		} else {
 			return -C.EIO
 		}
@@ -712,6 +750,9 @@ func libfuse_read(path *C.char, buf *C.char, size C.size_t, off C.off_t, fi *C.f
 	}
 	if err != nil {
 		log.Err("Libfuse::libfuse_read : error reading file %s, handle: %d [%s]", handle.Path, handle.ID, err.Error())
+		if err == syscall.ENODATA {
+			return -C.ENODATA
+		}
 		return -C.EIO
 	}
 
@@ -793,6 +834,161 @@ func libfuse_truncate(path *C.char, off C.off_t, fi *C.fuse_file_info_t) C.int {
 	return 0
 }
 
+// libfuse_fallocate allocates or zeroes out a range of a file
+//
+//export libfuse_fallocate
+func libfuse_fallocate(path *C.char, mode C.int, off C.off_t, count C.off_t, fi *C.fuse_file_info_t) C.int {
+	name := trimFusePath(path)
+	name = common.NormalizeObjectName(name)
+	log.Trace("Libfuse::libfuse_fallocate : %s mode %d, offset %d, length %d", name, mode, off, count)
+
+	// collapse and insert range would shift the data of the file, which blocks can not represent
+	if mode&^(C.FALLOC_FL_KEEP_SIZE|C.FALLOC_FL_PUNCH_HOLE|C.FALLOC_FL_ZERO_RANGE) != 0 {
+		log.Err("Libfuse::libfuse_fallocate : mode %d not supported for %s", mode, name)
+		return -C.EOPNOTSUPP
+	}
+
+	options := internal.FallocateFileOptions{
+		Name:      name,
+		Offset:    int64(off),
+		Length:    int64(count),
+		KeepSize:  mode&C.FALLOC_FL_KEEP_SIZE != 0,
+		PunchHole: mode&(C.FALLOC_FL_PUNCH_HOLE|C.FALLOC_FL_ZERO_RANGE) != 0,
+	}
+	if fi != nil && fi.fh != 0 {
+		fileHandle := (*C.file_handle_t)(unsafe.Pointer(uintptr(fi.fh)))
+		options.Handle = (*handlemap.Handle)(unsafe.Pointer(uintptr(fileHandle.obj)))
+	}
+
+	err := fuseFS.NextComponent().FallocateFile(options)
+	if err != nil {
+		log.Err("Libfuse::libfuse_fallocate : error allocating file %s [%s]", name, err.Error())
+		if os.IsNotExist(err) {
+			return -C.ENOENT
+		} else if err == syscall.EOPNOTSUPP {
+			return -C.EOPNOTSUPP
+		}
+		return -C.EIO
+	}
+
+	libfuseStatsCollector.PushEvents(fallocateFile, name, map[string]interface{}{offset: int64(off), length: int64(count)})
+	libfuseStatsCollector.UpdateStats(stats_manager.Increment, fallocateFile, (int64)(1))
+
+	return 0
+}
+
+// libfuse_lseek finds the next data or hole in a file for SEEK_DATA and SEEK_HOLE, kernel handles the other whence values
+//
+//export libfuse_lseek
+func libfuse_lseek(path *C.char, off C.off_t, whence C.int, fi *C.fuse_file_info_t) C.off_t {
+	fileHandle := (*C.file_handle_t)(unsafe.Pointer(uintptr(fi.fh)))
+	handle := (*handlemap.Handle)(unsafe.Pointer(uintptr(fileHandle.obj)))
+	log.Trace("Libfuse::libfuse_lseek : %s, handle: %d, offset %d, whence %d", handle.Path, handle.ID, off, whence)
+
+	if whence != C.SEEK_DATA && whence != C.SEEK_HOLE {
+		return -C.EINVAL
+	}
+
+	offset, err := fuseFS.NextComponent().SeekFile(
+		internal.SeekFileOptions{
+			Handle: handle,
+			Name:   handle.Path,
+			Offset: int64(off),
+			Whence: int(whence),
+		})
+	if err != nil {
+		if errors.Is(err, syscall.ENXIO) {
+			return -C.ENXIO
+		} else if os.IsNotExist(err) {
+			return -C.ENOENT
+		}
+		log.Err("Libfuse::libfuse_lseek : error seeking file %s, handle: %d [%s]", handle.Path, handle.ID, err.Error())
+		return -C.EIO
+	}
+
+	return C.off_t(offset)
+}
+
+// libfuse_copy_file_range copies a whole file within the mount in storage instead of moving the data through this machine
+//
+//export libfuse_copy_file_range
+func libfuse_copy_file_range(pathIn *C.char, fiIn *C.fuse_file_info_t, offIn C.off_t, pathOut *C.char, fiOut *C.fuse_file_info_t, offOut C.off_t, size C.size_t, flags C.int) C.ssize_t {
+	srcPath := common.NormalizeObjectName(trimFusePath(pathIn))
+	dstPath := common.NormalizeObjectName(trimFusePath(pathOut))
+	log.Trace("Libfuse::libfuse_copy_file_range : %s offset %d -> %s offset %d, size %d", srcPath, offIn, dstPath, offOut, size)
+
+	if flags != 0 {
+		return -C.EINVAL
+	}
+
+	// Only a copy of the whole file maps to a copy in storage, kernel falls back to read and write for the rest
+	if offIn != 0 || offOut != 0 {
+		return -C.EOPNOTSUPP
+	}
+
+	options := internal.CopyObjectOptions{Src: srcPath, Dst: dstPath}
+	var dstFileHandle *C.file_handle_t
+	if fiIn != nil && fiIn.fh != 0 {
+		fileHandle := (*C.file_handle_t)(unsafe.Pointer(uintptr(fiIn.fh)))
+		options.SrcHandle = (*handlemap.Handle)(unsafe.Pointer(uintptr(fileHandle.obj)))
+		if fileHandle.dirty != 0 {
+			options.SrcHandle.Flags.Set(handlemap.HandleFlagDirty)
+		}
+	}
+	if fiOut != nil && fiOut.fh != 0 {
+		dstFileHandle = (*C.file_handle_t)(unsafe.Pointer(uintptr(fiOut.fh)))
+		options.DstHandle = (*handlemap.Handle)(unsafe.Pointer(uintptr(dstFileHandle.obj)))
+		if dstFileHandle.dirty != 0 {
+			options.DstHandle.Flags.Set(handlemap.HandleFlagDirty)
+		}
+	}
+
+	srcAttr, err := fuseFS.NextComponent().GetAttr(internal.GetAttrOptions{Name: srcPath})
+	if err != nil {
+		log.Err("Libfuse::libfuse_copy_file_range : failed to get attributes of %s [%s]", srcPath, err.Error())
+		if os.IsNotExist(err) {
+			return -C.ENOENT
+		}
+		return -C.EIO
+	}
+
+	// Destination has to end up exactly as the source, a partial copy or a longer destination can not be done in storage
+	if uint64(size) < uint64(srcAttr.Size) {
+		return -C.EOPNOTSUPP
+	}
+	dstAttr, err := fuseFS.NextComponent().GetAttr(internal.GetAttrOptions{Name: dstPath})
+	if err == nil && dstAttr.Size > srcAttr.Size {
+		return -C.EOPNOTSUPP
+	}
+
+	err = fuseFS.NextComponent().CopyObject(options)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			log.Info("Libfuse::libfuse_copy_file_range : %s can not be copied in storage, falling back", srcPath)
+			return -C.EOPNOTSUPP
+		}
+		log.Err("Libfuse::libfuse_copy_file_range : error copying %s to %s [%s]", srcPath, dstPath, err.Error())
+		if os.IsNotExist(err) {
+			return -C.ENOENT
+		} else if err == syscall.EBUSY {
+			return -C.EBUSY
+		} else if err == syscall.ENOSPC {
+			return -C.ENOSPC
+		}
+		return -C.EIO
+	}
+
+	// Destination now holds the copy in storage, nothing written through the handle needs to be uploaded
+	if dstFileHandle != nil {
+		dstFileHandle.dirty = 0
+	}
+
+	libfuseStatsCollector.PushEvents(copyObject, srcPath, map[string]interface{}{source: srcPath, dest: dstPath})
+	libfuseStatsCollector.UpdateStats(stats_manager.Increment, copyObject, (int64)(1))
+
+	return C.ssize_t(srcAttr.Size)
+}
+
 // libfuse_release releases an open file
 //
 //export libfuse_release
@@ -808,6 +1004,15 @@ func libfuse_release(path *C.char, fi *C.fuse_file_info_t) C.int {
 	}
 
 	err := fuseFS.NextComponent().CloseFile(internal.CloseFileOptions{Handle: handle})
+
+	if fuseFS.fileLocks {
+		// Drop any lock still held through this handle once its data is uploaded, even if closing failed
+		lerr := fuseFS.NextComponent().ReleaseFile(internal.ReleaseFileOptions{Handle: handle})
+		if lerr != nil {
+			log.Err("Libfuse::libfuse_release : error releasing locks of %s, handle: %d [%s]", handle.Path, handle.ID, lerr.Error())
+		}
+	}
+
 	if err != nil {
 		log.Err("Libfuse::libfuse_release : error closing file %s, handle: %d [%s]", handle.Path, handle.ID, err.Error())
 		return -C.EIO
@@ -822,6 +1027,99 @@ func libfuse_release(path *C.char, fi *C.fuse_file_info_t) C.int {
 	return 0
 }
 
+// libfuse_flock applies or removes a BSD style advisory lock on an open file
+//
+//export libfuse_flock
+func libfuse_flock(path *C.char, fi *C.fuse_file_info_t, op C.int) C.int {
+	fileHandle := (*C.file_handle_t)(unsafe.Pointer(uintptr(fi.fh)))
+	handle := (*handlemap.Handle)(unsafe.Pointer(uintptr(fileHandle.obj)))
+	log.Trace("Libfuse::libfuse_flock : %s, handle: %d, op %d", handle.Path, handle.ID, op)
+
+	var err error
+	if op&C.LOCK_UN != 0 {
+		err = fuseFS.NextComponent().UnlockFile(internal.UnlockFileOptions{Handle: handle})
+	} else {
+		err = fuseFS.NextComponent().LockFile(
+			internal.LockFileOptions{
+				Handle:    handle,
+				Exclusive: op&C.LOCK_EX != 0,
+				Wait:      op&C.LOCK_NB == 0,
+			})
+	}
+
+	if err != nil && err != syscall.EAGAIN {
+		log.Err("Libfuse::libfuse_flock : error in flock of %s, handle: %d [%s]", handle.Path, handle.ID, err.Error())
+	}
+	return lockError(err)
+}
+
+// libfuse_lock tests, applies or removes a POSIX record lock on an open file
+// Byte ranges are not tracked, every lock covers the whole file
+//
+//export libfuse_lock
+func libfuse_lock(path *C.char, fi *C.fuse_file_info_t, cmd C.int, lock *C.struct_flock) C.int {
+	fileHandle := (*C.file_handle_t)(unsafe.Pointer(uintptr(fi.fh)))
+	handle := (*handlemap.Handle)(unsafe.Pointer(uintptr(fileHandle.obj)))
+	log.Trace("Libfuse::libfuse_lock : %s, handle: %d, cmd %d, type %d", handle.Path, handle.ID, cmd, lock.l_type)
+
+	var err error
+	switch {
+	case cmd == C.F_GETLK:
+		if lock.l_type == C.F_UNLCK {
+			return 0
+		}
+		err = fuseFS.NextComponent().LockFile(
+			internal.LockFileOptions{
+				Handle:    handle,
+				Exclusive: lock.l_type == C.F_WRLCK,
+				CheckOnly: true,
+			})
+		if err == syscall.EAGAIN {
+			// Holder of the conflicting lock is not known, report it as a write lock on the whole file
+			lock.l_type = C.F_WRLCK
+			lock.l_whence = C.SEEK_SET
+			lock.l_start = 0
+			lock.l_len = 0
+			lock.l_pid = 0
+			return 0
+		} else if err == nil {
+			lock.l_type = C.F_UNLCK
+		}
+	case cmd == C.F_SETLK || cmd == C.F_SETLKW:
+		if lock.l_type == C.F_UNLCK {
+			err = fuseFS.NextComponent().UnlockFile(internal.UnlockFileOptions{Handle: handle})
+		} else {
+			err = fuseFS.NextComponent().LockFile(
+				internal.LockFileOptions{
+					Handle:    handle,
+					Exclusive: lock.l_type == C.F_WRLCK,
+					Wait:      cmd == C.F_SETLKW,
+				})
+		}
+	default:
+		return -C.EINVAL
+	}
+
+	if err != nil && err != syscall.EAGAIN {
+		log.Err("Libfuse::libfuse_lock : error in lock of %s, handle: %d [%s]", handle.Path, handle.ID, err.Error())
+	}
+	return lockError(err)
+}
+
+// lockError maps the error returned by a file lock operation to an errno
+func lockError(err error) C.int {
+	if err == nil {
+		return 0
+	} else if err == syscall.EAGAIN {
+		return -C.EAGAIN
+	} else if err == syscall.EINTR {
+		return -C.EINTR
+	} else if os.IsNotExist(err) {
+		return -C.ENOENT
+	}
+	return -C.EIO
+}
+
 // libfuse_unlink removes a file
 //
 //export libfuse_unlink
@@ -907,6 +1205,9 @@ func libfuse_rename(src *C.char, dst *C.char, flags C.uint) C.int {
 		err := fuseFS.NextComponent().RenameDir(internal.RenameDirOptions{Src: srcPath, Dst: dstPath})
 		if err != nil {
 			log.Err("Libfuse::libfuse_rename : error renaming directory %s -> %s [%s]", srcPath, dstPath, err.Error())
+			if err == syscall.EEXIST {
+				return -C.EEXIST
+			}
 			return -C.EIO
 		}
 
@@ -917,6 +1218,9 @@ func libfuse_rename(src *C.char, dst *C.char, flags C.uint) C.int {
 		err := fuseFS.NextComponent().RenameFile(internal.RenameFileOptions{Src: srcPath, Dst: dstPath})
 		if err != nil {
 			log.Err("Libfuse::libfuse_rename : error renaming file %s -> %s [%s]", srcPath, dstPath, err.Error())
+			if err == syscall.EEXIST {
+				return -C.EEXIST
+			}
 			return -C.EIO
 		}
 
@@ -1041,7 +1345,7 @@ func libfuse_chmod(path *C.char, mode C.mode_t, fi *C.fuse_file_info_t) C.int {
 	err := fuseFS.NextComponent().Chmod(
 		internal.ChmodOptions{
 			Name: name,
-			Mode: fs.FileMode(uint32(mode) & 0xffffffff),
+			Mode: common.FileModeFromPosix(uint32(mode)),
 		})
 	if err != nil {
 		log.Err("Libfuse::libfuse_chmod : error in chmod of %s [%s]", name, err.Error())
@@ -1051,7 +1355,7 @@ func libfuse_chmod(path *C.char, mode C.mode_t, fi *C.fuse_file_info_t) C.int {
 		return -C.EIO
 	}
 
-	libfuseStatsCollector.PushEvents(chmod, name, map[string]interface{}{md: fs.FileMode(uint32(mode) & 0xffffffff)})
+	libfuseStatsCollector.PushEvents(chmod, name, map[string]interface{}{md: common.FileModeFromPosix(uint32(mode))})
 	libfuseStatsCollector.UpdateStats(stats_manager.Increment, chmod, (int64)(1))
 
 	return 0
@@ -1064,7 +1368,29 @@ func libfuse_chown(path *C.char, uid C.uid_t, gid C.gid_t, fi *C.fuse_file_info_
 	name := trimFusePath(path)
 	name = common.NormalizeObjectName(name)
 	log.Trace("Libfuse::libfuse_chown : %s", name)
-	// TODO: Implement
+
+	owner, group, err := fuseFS.resolveOwner(name, uint32(uid), uint32(gid))
+	if err == nil {
+		err = fuseFS.NextComponent().Chown(
+			internal.ChownOptions{
+				Name:  name,
+				Owner: owner,
+				Group: group,
+			})
+	}
+	if err != nil {
+		log.Err("Libfuse::libfuse_chown : error in chown of %s [%s]", name, err.Error())
+		if os.IsNotExist(err) {
+			return -C.ENOENT
+		} else if os.IsPermission(err) {
+			return -C.EPERM
+		}
+		return -C.EIO
+	}
+
+	libfuseStatsCollector.PushEvents(chown, name, map[string]interface{}{uidKey: owner, gidKey: group})
+	libfuseStatsCollector.UpdateStats(stats_manager.Increment, chown, (int64)(1))
+
 	return 0
 }
 
@@ -1075,9 +1401,208 @@ func libfuse_utimens(path *C.char, tv *C.timespec_t, fi *C.fuse_file_info_t) C.i
 	name := trimFusePath(path)
 	name = common.NormalizeObjectName(name)
 	log.Trace("Libfuse::libfuse_utimens : %s", name)
-	// TODO: is the conversion from [2]timespec to *timespec ok?
-	// TODO: Implement
-	// For now this returns 0 to allow touch to work correctly
+
+	atime, mtime := time.Now(), time.Now()
+	if tv != nil {
+		times := (*[2]C.timespec_t)(unsafe.Pointer(tv))
+		atime = timespecToTime(times[0])
+		mtime = timespecToTime(times[1])
+	}
+
+	err := fuseFS.NextComponent().SetTimes(
+		internal.SetTimesOptions{
+			Name:  name,
+			Atime: atime,
+			Mtime: mtime,
+		})
+	if err != nil {
+		log.Err("Libfuse::libfuse_utimens : error setting times of %s [%s]", name, err.Error())
+		if os.IsNotExist(err) {
+			return -C.ENOENT
+		}
+		return -C.EIO
+	}
+
+	libfuseStatsCollector.PushEvents(setTimes, name, map[string]interface{}{modTime: mtime.String()})
+	libfuseStatsCollector.UpdateStats(stats_manager.Increment, setTimes, (int64)(1))
+
+	return 0
+}
+
+// timespecToTime converts a timespec received in utimens, UTIME_OMIT is returned as zero time
+func timespecToTime(ts C.timespec_t) time.Time {
+	switch ts.tv_nsec {
+	case C.UTIME_NOW:
+		return time.Now()
+	case C.UTIME_OMIT:
+		return time.Time{}
+	}
+	return time.Unix(int64(ts.tv_sec), int64(ts.tv_nsec))
+}
+
+// xattrError maps the error returned by an extended attribute operation to an errno
+func xattrError(err error) C.int {
+	if os.IsNotExist(err) {
+		return -C.ENOENT
+	}
+
+	switch err {
+	case syscall.ENODATA:
+		return -C.ENODATA
+	case syscall.ENOTSUP:
+		return -C.ENOTSUP
+	case syscall.EEXIST:
+		return -C.EEXIST
+	case syscall.EINVAL:
+		return -C.EINVAL
+	case syscall.EISDIR:
+		return -C.EISDIR
+	case syscall.EPERM:
+		return -C.EPERM
+	case syscall.EROFS:
+		return -C.EROFS
+	case syscall.EBUSY:
+		return -C.EBUSY
+	case syscall.ENOSPC:
+		return -C.ENOSPC
+	}
+	return -C.EIO
+}
+
+// libfuse_setxattr sets an extended attribute of a file or directory
+//
+//export libfuse_setxattr
+func libfuse_setxattr(path *C.char, attr *C.char, value *C.char, size C.size_t, flags C.int) C.int {
+	name := trimFusePath(path)
+	name = common.NormalizeObjectName(name)
+	attrName := C.GoString(attr)
+	log.Trace("Libfuse::libfuse_setxattr : %s, attr %s", name, attrName)
+
+	if !strings.HasPrefix(attrName, internal.XattrUserPrefix) {
+		return -C.ENOTSUP
+	}
+
+	var err error
+	if attrName == internal.XattrCacheControl {
+		err = setCacheControlXattr(name, C.GoBytes(unsafe.Pointer(value), C.int(size)))
+	} else {
+		err = fuseFS.NextComponent().SetXattr(
+			internal.SetXattrOptions{
+				Name:  name,
+				Attr:  attrName,
+				Value: C.GoBytes(unsafe.Pointer(value), C.int(size)),
+				Flags: int(flags),
+			})
+	}
+	if err != nil {
+		log.Err("Libfuse::libfuse_setxattr : error setting xattr %s of %s [%s]", attrName, name, err.Error())
+		return xattrError(err)
+	}
+
+	libfuseStatsCollector.PushEvents(setXattr, name, map[string]interface{}{xattr: attrName})
+	libfuseStatsCollector.UpdateStats(stats_manager.Increment, setXattr, (int64)(1))
+
+	return 0
+}
+
+// libfuse_getxattr reads the value of an extended attribute of a file or directory
+//
+//export libfuse_getxattr
+func libfuse_getxattr(path *C.char, attr *C.char, value *C.char, size C.size_t) C.int {
+	name := trimFusePath(path)
+	name = common.NormalizeObjectName(name)
+	attrName := C.GoString(attr)
+	log.Trace("Libfuse::libfuse_getxattr : %s, attr %s", name, attrName)
+
+	if !strings.HasPrefix(attrName, internal.XattrUserPrefix) {
+		return -C.ENOTSUP
+	}
+
+	var data []byte
+	var err error
+	if attrName == internal.XattrCacheControl {
+		data, err = getCacheControlXattr(name)
+	} else {
+		data, err = fuseFS.NextComponent().GetXattr(internal.GetXattrOptions{Name: name, Attr: attrName})
+	}
+	if err != nil {
+		if err == syscall.ENODATA {
+			log.Debug("Libfuse::libfuse_getxattr : xattr %s not set on %s", attrName, name)
+		} else {
+			log.Err("Libfuse::libfuse_getxattr : error getting xattr %s of %s [%s]", attrName, name, err.Error())
+		}
+		return xattrError(err)
+	}
+
+	// A zero size call is made to find out the size of the buffer required
+	if size == 0 {
+		return C.int(len(data))
+	} else if int(size) < len(data) {
+		return -C.ERANGE
+	}
+
+	buf := (*[1 << 30]byte)(unsafe.Pointer(value))
+	copy(buf[:size], data)
+
+	return C.int(len(data))
+}
+
+// libfuse_listxattr lists the names of the extended attributes of a file or directory
+//
+//export libfuse_listxattr
+func libfuse_listxattr(path *C.char, list *C.char, size C.size_t) C.int {
+	name := trimFusePath(path)
+	name = common.NormalizeObjectName(name)
+	log.Trace("Libfuse::libfuse_listxattr : %s", name)
+
+	attrs, err := fuseFS.NextComponent().ListXattr(internal.ListXattrOptions{Name: name})
+	if err != nil {
+		log.Err("Libfuse::libfuse_listxattr : error listing xattrs of %s [%s]", name, err.Error())
+		return xattrError(err)
+	}
+
+	// Names are returned as a sequence of null terminated strings
+	var data []byte
+	for _, attr := range attrs {
+		data = append(data, attr...)
+		data = append(data, 0)
+	}
+
+	// A zero size call is made to find out the size of the buffer required
+	if size == 0 {
+		return C.int(len(data))
+	} else if int(size) < len(data) {
+		return -C.ERANGE
+	}
+
+	buf := (*[1 << 30]byte)(unsafe.Pointer(list))
+	copy(buf[:size], data)
+
+	return C.int(len(data))
+}
+
+// libfuse_removexattr removes an extended attribute of a file or directory
+//
+//export libfuse_removexattr
+func libfuse_removexattr(path *C.char, attr *C.char) C.int {
+	name := trimFusePath(path)
+	name = common.NormalizeObjectName(name)
+	attrName := C.GoString(attr)
+	log.Trace("Libfuse::libfuse_removexattr : %s, attr %s", name, attrName)
+
+	if !strings.HasPrefix(attrName, internal.XattrUserPrefix) {
+		return -C.ENOTSUP
+	}
+
+	err := fuseFS.NextComponent().RemoveXattr(internal.RemoveXattrOptions{Name: name, Attr: attrName})
+	if err != nil {
+		log.Err("Libfuse::libfuse_removexattr : error removing xattr %s of %s [%s]", attrName, name, err.Error())
+		return xattrError(err)
+	}
+
+	libfuseStatsCollector.PushEvents(removeXattr, name, map[string]interface{}{xattr: attrName})
+	libfuseStatsCollector.UpdateStats(stats_manager.Increment, removeXattr, (int64)(1))
+
 	return 0
 }
 
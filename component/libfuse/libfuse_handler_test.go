@@ -295,10 +295,110 @@ func (suite *libfuseTestSuite) TestChown() {
 	testChown(suite)
 }
 
+func (suite *libfuseTestSuite) TestChownUnchangedGroup() {
+	testChownUnchangedGroup(suite)
+}
+
+func (suite *libfuseTestSuite) TestChownError() {
+	testChownError(suite)
+}
+
 func (suite *libfuseTestSuite) TestUtimens() {
 	testUtimens(suite)
 }
 
+func (suite *libfuseTestSuite) TestUtimensTimes() {
+	testUtimensTimes(suite)
+}
+
+func (suite *libfuseTestSuite) TestUtimensNotExists() {
+	testUtimensNotExists(suite)
+}
+
+func (suite *libfuseTestSuite) TestSetXattr() {
+	testSetXattr(suite)
+}
+
+func (suite *libfuseTestSuite) TestSetXattrUnsupportedNamespace() {
+	testSetXattrUnsupportedNamespace(suite)
+}
+
+func (suite *libfuseTestSuite) TestGetXattr() {
+	testGetXattr(suite)
+}
+
+func (suite *libfuseTestSuite) TestGetXattrNoData() {
+	testGetXattrNoData(suite)
+}
+
+func (suite *libfuseTestSuite) TestListXattr() {
+	testListXattr(suite)
+}
+
+func (suite *libfuseTestSuite) TestRemoveXattr() {
+	testRemoveXattr(suite)
+}
+
+func (suite *libfuseTestSuite) TestFlock() {
+	testFlock(suite)
+}
+
+func (suite *libfuseTestSuite) TestFlockError() {
+	testFlockError(suite)
+}
+
+func (suite *libfuseTestSuite) TestLock() {
+	testLock(suite)
+}
+
+func (suite *libfuseTestSuite) TestLockGetLock() {
+	testLockGetLock(suite)
+}
+
+func (suite *libfuseTestSuite) TestReleaseWithFileLocks() {
+	testReleaseWithFileLocks(suite)
+}
+
+func (suite *libfuseTestSuite) TestFallocate() {
+	testFallocate(suite)
+}
+
+func (suite *libfuseTestSuite) TestFallocatePunchHole() {
+	testFallocatePunchHole(suite)
+}
+
+func (suite *libfuseTestSuite) TestFallocateNotSupported() {
+	testFallocateNotSupported(suite)
+}
+
+func (suite *libfuseTestSuite) TestFallocateError() {
+	testFallocateError(suite)
+}
+
+func (suite *libfuseTestSuite) TestLseek() {
+	testLseek(suite)
+}
+
+func (suite *libfuseTestSuite) TestLseekInvalidWhence() {
+	testLseekInvalidWhence(suite)
+}
+
+func (suite *libfuseTestSuite) TestCopyFileRange() {
+	testCopyFileRange(suite)
+}
+
+func (suite *libfuseTestSuite) TestCopyFileRangePartial() {
+	testCopyFileRangePartial(suite)
+}
+
+func (suite *libfuseTestSuite) TestCopyFileRangeError() {
+	testCopyFileRangeError(suite)
+}
+
+func (suite *libfuseTestSuite) TestCacheControlXattr() {
+	testCacheControlXattr(suite)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestLibfuseTestSuite(t *testing.T) {
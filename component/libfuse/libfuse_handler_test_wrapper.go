@@ -42,8 +42,10 @@ import "C"
 import (
 	"errors"
 	"io/fs"
+	"math"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/Azure/azure-storage-fuse/v2/common"
@@ -181,7 +183,7 @@ func testCreate(suite *libfuseTestSuite) {
 	defer C.free(unsafe.Pointer(path))
 	mode := fs.FileMode(0775)
 	info := &C.fuse_file_info_t{}
-	options := internal.CreateFileOptions{Name: name, Mode: mode}
+	options := internal.CreateFileOptions{Name: name, Mode: mode, Owner: int(suite.libfuse.ownerUID), Group: int(suite.libfuse.ownerGID)}
 	suite.mock.EXPECT().CreateFile(options).Return(&handlemap.Handle{}, nil)
 
 	err := libfuse_create(path, 0775, info)
@@ -202,7 +204,7 @@ func testCreateError(suite *libfuseTestSuite) {
 	defer C.free(unsafe.Pointer(path))
 	mode := fs.FileMode(0775)
 	info := &C.fuse_file_info_t{}
-	options := internal.CreateFileOptions{Name: name, Mode: mode}
+	options := internal.CreateFileOptions{Name: name, Mode: mode, Owner: int(suite.libfuse.ownerUID), Group: int(suite.libfuse.ownerGID)}
 	suite.mock.EXPECT().CreateFile(options).Return(&handlemap.Handle{}, errors.New("failed to create file"))
 
 	err := libfuse_create(path, 0775, info)
@@ -392,6 +394,162 @@ func testTruncateError(suite *libfuseTestSuite) {
 	suite.assert.Equal(C.int(-C.EIO), err)
 }
 
+func testFallocate(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle(name)
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+
+	options := internal.FallocateFileOptions{Handle: handle, Name: name, Offset: 0, Length: 1024}
+	suite.mock.EXPECT().FallocateFile(options).Return(nil)
+
+	err := libfuse_fallocate(path, 0, 0, 1024, info)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testFallocatePunchHole(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+
+	options := internal.FallocateFileOptions{Name: name, Offset: 512, Length: 1024, KeepSize: true, PunchHole: true}
+	suite.mock.EXPECT().FallocateFile(options).Return(nil)
+
+	err := libfuse_fallocate(path, C.FALLOC_FL_KEEP_SIZE|C.FALLOC_FL_PUNCH_HOLE, 512, 1024, nil)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testFallocateNotSupported(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+
+	err := libfuse_fallocate(path, C.FALLOC_FL_COLLAPSE_RANGE, 0, 1024, nil)
+	suite.assert.Equal(C.int(-C.EOPNOTSUPP), err)
+}
+
+func testFallocateError(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+
+	options := internal.FallocateFileOptions{Name: name, Offset: 0, Length: 1024}
+	suite.mock.EXPECT().FallocateFile(options).Return(syscall.ENOENT)
+	err := libfuse_fallocate(path, 0, 0, 1024, nil)
+	suite.assert.Equal(C.int(-C.ENOENT), err)
+
+	suite.mock.EXPECT().FallocateFile(options).Return(errors.New("failed to fallocate file"))
+	err = libfuse_fallocate(path, 0, 0, 1024, nil)
+	suite.assert.Equal(C.int(-C.EIO), err)
+}
+
+func testLseek(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+
+	options := internal.SeekFileOptions{Handle: handle, Name: "path", Offset: 10, Whence: common.SeekHole}
+	suite.mock.EXPECT().SeekFile(options).Return(int64(4096), nil)
+	suite.assert.Equal(C.off_t(4096), libfuse_lseek(path, 10, C.SEEK_HOLE, info))
+
+	options = internal.SeekFileOptions{Handle: handle, Name: "path", Offset: 10, Whence: common.SeekData}
+	suite.mock.EXPECT().SeekFile(options).Return(int64(0), syscall.ENXIO)
+	suite.assert.Equal(C.off_t(-C.ENXIO), libfuse_lseek(path, 10, C.SEEK_DATA, info))
+
+	suite.mock.EXPECT().SeekFile(options).Return(int64(0), errors.New("failed to seek file"))
+	suite.assert.Equal(C.off_t(-C.EIO), libfuse_lseek(path, 10, C.SEEK_DATA, info))
+}
+
+func testLseekInvalidWhence(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+
+	suite.assert.Equal(C.off_t(-C.EINVAL), libfuse_lseek(path, 10, C.SEEK_END, info))
+}
+
+func testCopyFileRange(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	src := C.CString("/src")
+	defer C.free(unsafe.Pointer(src))
+	dst := C.CString("/dst")
+	defer C.free(unsafe.Pointer(dst))
+	srcHandle := handlemap.NewHandle("src")
+	srcInfo := newTestFileInfo(srcHandle)
+	defer C.release_native_file_object(srcInfo)
+	dstHandle := handlemap.NewHandle("dst")
+	dstInfo := newTestFileInfo(dstHandle)
+	defer C.release_native_file_object(dstInfo)
+
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "src"}).Return(&internal.ObjAttr{Size: 1024}, nil)
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "dst"}).Return(&internal.ObjAttr{}, nil)
+	options := internal.CopyObjectOptions{SrcHandle: srcHandle, DstHandle: dstHandle, Src: "src", Dst: "dst"}
+	suite.mock.EXPECT().CopyObject(options).Return(nil)
+
+	ret := libfuse_copy_file_range(src, srcInfo, 0, dst, dstInfo, 0, 1<<30, 0)
+	suite.assert.Equal(C.ssize_t(1024), ret)
+}
+
+func testCopyFileRangePartial(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	src := C.CString("/src")
+	defer C.free(unsafe.Pointer(src))
+	dst := C.CString("/dst")
+	defer C.free(unsafe.Pointer(dst))
+
+	// Ranges not starting at the beginning of both files
+	ret := libfuse_copy_file_range(src, nil, 512, dst, nil, 0, 1024, 0)
+	suite.assert.Equal(C.ssize_t(-C.EOPNOTSUPP), ret)
+
+	// Range shorter than the source
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "src"}).Return(&internal.ObjAttr{Size: 1024}, nil)
+	ret = libfuse_copy_file_range(src, nil, 0, dst, nil, 0, 512, 0)
+	suite.assert.Equal(C.ssize_t(-C.EOPNOTSUPP), ret)
+
+	// Destination longer than the source
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "src"}).Return(&internal.ObjAttr{Size: 1024}, nil)
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "dst"}).Return(&internal.ObjAttr{Size: 2048}, nil)
+	ret = libfuse_copy_file_range(src, nil, 0, dst, nil, 0, 1024, 0)
+	suite.assert.Equal(C.ssize_t(-C.EOPNOTSUPP), ret)
+}
+
+func testCopyFileRangeError(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	src := C.CString("/src")
+	defer C.free(unsafe.Pointer(src))
+	dst := C.CString("/dst")
+	defer C.free(unsafe.Pointer(dst))
+	options := internal.CopyObjectOptions{Src: "src", Dst: "dst"}
+
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "src"}).Return(nil, syscall.ENOENT)
+	ret := libfuse_copy_file_range(src, nil, 0, dst, nil, 0, 1024, 0)
+	suite.assert.Equal(C.ssize_t(-C.ENOENT), ret)
+
+	// Components that can not copy in storage make the kernel fall back to read and write
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "src"}).Return(&internal.ObjAttr{Size: 1024}, nil)
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "dst"}).Return(nil, syscall.ENOENT)
+	suite.mock.EXPECT().CopyObject(options).Return(syscall.ENOTSUP)
+	ret = libfuse_copy_file_range(src, nil, 0, dst, nil, 0, 1024, 0)
+	suite.assert.Equal(C.ssize_t(-C.EOPNOTSUPP), ret)
+
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "src"}).Return(&internal.ObjAttr{Size: 1024}, nil)
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: "dst"}).Return(nil, syscall.ENOENT)
+	suite.mock.EXPECT().CopyObject(options).Return(errors.New("failed to copy file"))
+	ret = libfuse_copy_file_range(src, nil, 0, dst, nil, 0, 1024, 0)
+	suite.assert.Equal(C.ssize_t(-C.EIO), ret)
+}
+
 func testUnlink(suite *libfuseTestSuite) {
 	defer suite.cleanupTest()
 	name := "path"
@@ -654,17 +812,328 @@ func testChown(suite *libfuseTestSuite) {
 	defer C.free(unsafe.Pointer(path))
 	group := C.uint(5)
 	owner := C.uint(4)
+	options := internal.ChownOptions{Name: name, Owner: 4, Group: 5}
+	suite.mock.EXPECT().Chown(options).Return(nil)
 
 	err := libfuse_chown(path, owner, group, nil)
 	suite.assert.Equal(C.int(0), err)
 }
 
+func testChownUnchangedGroup(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	owner := C.uint(4)
+
+	// Group of -1 is replaced by the group currently reported for the path
+	attr := &internal.ObjAttr{Uid: 1, Gid: 2}
+	attr.Flags.Set(internal.PropFlagOwnerSet)
+	suite.mock.EXPECT().GetAttr(internal.GetAttrOptions{Name: name}).Return(attr, nil)
+	options := internal.ChownOptions{Name: name, Owner: 4, Group: 2}
+	suite.mock.EXPECT().Chown(options).Return(nil)
+
+	err := libfuse_chown(path, owner, C.uint(math.MaxUint32), nil)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testChownError(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	options := internal.ChownOptions{Name: name, Owner: 4, Group: 5}
+	suite.mock.EXPECT().Chown(options).Return(syscall.EPERM)
+
+	err := libfuse_chown(path, 4, 5, nil)
+	suite.assert.Equal(C.int(-C.EPERM), err)
+}
+
 func testUtimens(suite *libfuseTestSuite) {
 	defer suite.cleanupTest()
 	name := "path"
 	path := C.CString("/" + name)
 	defer C.free(unsafe.Pointer(path))
+	suite.mock.EXPECT().SetTimes(gomock.Any()).Return(nil)
+
+	err := libfuse_utimens(path, nil, nil)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testUtimensTimes(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	tv := (*[2]C.timespec_t)(C.malloc(C.size_t(unsafe.Sizeof(C.timespec_t{})) * 2))
+	defer C.free(unsafe.Pointer(tv))
+	tv[0].tv_sec = 0
+	tv[0].tv_nsec = C.UTIME_OMIT
+	tv[1].tv_sec = 1600000000
+	tv[1].tv_nsec = 500
+	options := internal.SetTimesOptions{Name: name, Atime: time.Time{}, Mtime: time.Unix(1600000000, 500)}
+	suite.mock.EXPECT().SetTimes(options).Return(nil)
+
+	err := libfuse_utimens(path, &tv[0], nil)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testUtimensNotExists(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	suite.mock.EXPECT().SetTimes(gomock.Any()).Return(syscall.ENOENT)
 
 	err := libfuse_utimens(path, nil, nil)
+	suite.assert.Equal(C.int(-C.ENOENT), err)
+}
+
+func testSetXattr(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	attr := C.CString("user.origin")
+	defer C.free(unsafe.Pointer(attr))
+	value := C.CString("pipeline")
+	defer C.free(unsafe.Pointer(value))
+	options := internal.SetXattrOptions{Name: name, Attr: "user.origin", Value: []byte("pipeline"), Flags: 0}
+	suite.mock.EXPECT().SetXattr(options).Return(nil)
+
+	err := libfuse_setxattr(path, attr, value, 8, 0)
 	suite.assert.Equal(C.int(0), err)
 }
+
+func testSetXattrUnsupportedNamespace(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	attr := C.CString("security.selinux")
+	defer C.free(unsafe.Pointer(attr))
+	value := C.CString("label")
+	defer C.free(unsafe.Pointer(value))
+
+	err := libfuse_setxattr(path, attr, value, 5, 0)
+	suite.assert.Equal(C.int(-C.ENOTSUP), err)
+}
+
+func testGetXattr(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	attr := C.CString("user.origin")
+	defer C.free(unsafe.Pointer(attr))
+	options := internal.GetXattrOptions{Name: name, Attr: "user.origin"}
+	suite.mock.EXPECT().GetXattr(options).Return([]byte("pipeline"), nil).Times(3)
+
+	// Size query
+	err := libfuse_getxattr(path, attr, nil, 0)
+	suite.assert.Equal(C.int(8), err)
+
+	buf := (*C.char)(C.malloc(8))
+	defer C.free(unsafe.Pointer(buf))
+	err = libfuse_getxattr(path, attr, buf, 4)
+	suite.assert.Equal(C.int(-C.ERANGE), err)
+
+	err = libfuse_getxattr(path, attr, buf, 8)
+	suite.assert.Equal(C.int(8), err)
+	suite.assert.Equal("pipeline", string(C.GoBytes(unsafe.Pointer(buf), 8)))
+}
+
+func testGetXattrNoData(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	attr := C.CString("user.origin")
+	defer C.free(unsafe.Pointer(attr))
+	options := internal.GetXattrOptions{Name: name, Attr: "user.origin"}
+	suite.mock.EXPECT().GetXattr(options).Return(nil, syscall.ENODATA)
+
+	err := libfuse_getxattr(path, attr, nil, 0)
+	suite.assert.Equal(C.int(-C.ENODATA), err)
+}
+
+func testCacheControlXattr(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	attr := C.CString(internal.XattrCacheControl)
+	defer C.free(unsafe.Pointer(attr))
+
+	// Commands run without an open handle and do not reach the xattrs of the file
+	value := C.CString("evict")
+	defer C.free(unsafe.Pointer(value))
+	options := internal.CacheControlOptions{Name: name, Command: common.CacheControlEvict}
+	suite.mock.EXPECT().CacheControl(options).Return(uint32(0), syscall.EBUSY)
+	err := libfuse_setxattr(path, attr, value, 5, 0)
+	suite.assert.Equal(C.int(-C.EBUSY), err)
+
+	unknown := C.CString("delete")
+	defer C.free(unsafe.Pointer(unknown))
+	err = libfuse_setxattr(path, attr, unknown, 6, 0)
+	suite.assert.Equal(C.int(-C.EINVAL), err)
+
+	options.Command = common.CacheControlStatus
+	suite.mock.EXPECT().CacheControl(options).Return(common.CacheStatusCached|common.CacheStatusPinned, nil)
+	buf := (*C.char)(C.malloc(16))
+	defer C.free(unsafe.Pointer(buf))
+	err = libfuse_getxattr(path, attr, buf, 16)
+	suite.assert.Equal(C.int(1), err)
+	suite.assert.Equal("5", string(C.GoBytes(unsafe.Pointer(buf), 1)))
+}
+
+func testListXattr(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	options := internal.ListXattrOptions{Name: name}
+	suite.mock.EXPECT().ListXattr(options).Return([]string{"user.a", "user.bc"}, nil).Times(2)
+
+	err := libfuse_listxattr(path, nil, 0)
+	suite.assert.Equal(C.int(15), err)
+
+	buf := (*C.char)(C.malloc(15))
+	defer C.free(unsafe.Pointer(buf))
+	err = libfuse_listxattr(path, buf, 15)
+	suite.assert.Equal(C.int(15), err)
+	suite.assert.Equal("user.a\x00user.bc\x00", string(C.GoBytes(unsafe.Pointer(buf), 15)))
+}
+
+func testRemoveXattr(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	name := "path"
+	path := C.CString("/" + name)
+	defer C.free(unsafe.Pointer(path))
+	attr := C.CString("user.origin")
+	defer C.free(unsafe.Pointer(attr))
+	options := internal.RemoveXattrOptions{Name: name, Attr: "user.origin"}
+	suite.mock.EXPECT().RemoveXattr(options).Return(syscall.ENOENT)
+
+	err := libfuse_removexattr(path, attr)
+	suite.assert.Equal(C.int(-C.ENOENT), err)
+}
+
+func newTestFileInfo(handle *handlemap.Handle) *C.fuse_file_info_t {
+	info := &C.fuse_file_info_t{}
+	fobj := C.allocate_native_file_object(0, C.ulong(uintptr(unsafe.Pointer(handle))), 0)
+	info.fh = C.ulong(uintptr(unsafe.Pointer(fobj)))
+	return info
+}
+
+func testFlock(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+
+	options := internal.LockFileOptions{Handle: handle, Exclusive: true, Wait: true}
+	suite.mock.EXPECT().LockFile(options).Return(nil)
+	err := libfuse_flock(path, info, C.LOCK_EX)
+	suite.assert.Equal(C.int(0), err)
+
+	options = internal.LockFileOptions{Handle: handle, Exclusive: false, Wait: false}
+	suite.mock.EXPECT().LockFile(options).Return(syscall.EAGAIN)
+	err = libfuse_flock(path, info, C.LOCK_SH|C.LOCK_NB)
+	suite.assert.Equal(C.int(-C.EAGAIN), err)
+
+	suite.mock.EXPECT().UnlockFile(internal.UnlockFileOptions{Handle: handle}).Return(nil)
+	err = libfuse_flock(path, info, C.LOCK_UN)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testFlockError(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+
+	options := internal.LockFileOptions{Handle: handle, Exclusive: true, Wait: false}
+	suite.mock.EXPECT().LockFile(options).Return(errors.New("failed to acquire lease"))
+	err := libfuse_flock(path, info, C.LOCK_EX|C.LOCK_NB)
+	suite.assert.Equal(C.int(-C.EIO), err)
+}
+
+func testLock(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+	lock := &C.struct_flock{}
+
+	lock.l_type = C.F_WRLCK
+	options := internal.LockFileOptions{Handle: handle, Exclusive: true, Wait: true}
+	suite.mock.EXPECT().LockFile(options).Return(nil)
+	err := libfuse_lock(path, info, C.F_SETLKW, lock)
+	suite.assert.Equal(C.int(0), err)
+
+	lock.l_type = C.F_RDLCK
+	options = internal.LockFileOptions{Handle: handle, Exclusive: false, Wait: false}
+	suite.mock.EXPECT().LockFile(options).Return(nil)
+	err = libfuse_lock(path, info, C.F_SETLK, lock)
+	suite.assert.Equal(C.int(0), err)
+
+	lock.l_type = C.F_UNLCK
+	suite.mock.EXPECT().UnlockFile(internal.UnlockFileOptions{Handle: handle}).Return(nil)
+	err = libfuse_lock(path, info, C.F_SETLK, lock)
+	suite.assert.Equal(C.int(0), err)
+}
+
+func testLockGetLock(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+	defer C.release_native_file_object(info)
+	lock := &C.struct_flock{}
+
+	// No conflicting lock
+	lock.l_type = C.F_RDLCK
+	options := internal.LockFileOptions{Handle: handle, Exclusive: false, CheckOnly: true}
+	suite.mock.EXPECT().LockFile(options).Return(nil)
+	err := libfuse_lock(path, info, C.F_GETLK, lock)
+	suite.assert.Equal(C.int(0), err)
+	suite.assert.EqualValues(C.F_UNLCK, lock.l_type)
+
+	// Conflicting lock is reported as a write lock on the whole file
+	lock.l_type = C.F_WRLCK
+	lock.l_start = 10
+	options = internal.LockFileOptions{Handle: handle, Exclusive: true, CheckOnly: true}
+	suite.mock.EXPECT().LockFile(options).Return(syscall.EAGAIN)
+	err = libfuse_lock(path, info, C.F_GETLK, lock)
+	suite.assert.Equal(C.int(0), err)
+	suite.assert.EqualValues(C.F_WRLCK, lock.l_type)
+	suite.assert.EqualValues(0, lock.l_start)
+	suite.assert.EqualValues(0, lock.l_len)
+}
+
+func testReleaseWithFileLocks(suite *libfuseTestSuite) {
+	defer suite.cleanupTest()
+	suite.cleanupTest() // clean up the default libfuse generated
+	config := "libfuse:\n  file-locks: true\n"
+	suite.setupTestHelper(config) // setup a new libfuse with a custom config (clean up will occur after the test as usual)
+	suite.assert.True(suite.libfuse.fileLocks)
+
+	path := C.CString("/path")
+	defer C.free(unsafe.Pointer(path))
+	handle := handlemap.NewHandle("path")
+	info := newTestFileInfo(handle)
+
+	// Locks are dropped even if the file fails to close
+	suite.mock.EXPECT().CloseFile(internal.CloseFileOptions{Handle: handle}).Return(errors.New("failed to upload"))
+	suite.mock.EXPECT().ReleaseFile(internal.ReleaseFileOptions{Handle: handle}).Return(nil)
+	err := libfuse_release(path, info)
+	suite.assert.Equal(C.int(-C.EIO), err)
+	C.release_native_file_object(info)
+}
@@ -45,6 +45,8 @@
 #include <dlfcn.h>
 #include <fcntl.h>
 #include <unistd.h>
+#include <sys/file.h>
+#include <linux/falloc.h>
 
 // Decide whether to add fuse2 or fuse3
 #ifdef __FUSE2__
@@ -93,6 +95,13 @@ static int populate_callbacks(fuse_operations_t *opt)
     opt->fsync      = (int (*)(const char *path, int, fuse_file_info_t *fi))libfuse_fsync;
     opt->fsyncdir   = (int (*)(const char *path, int, fuse_file_info_t *))libfuse_fsyncdir;
 
+    opt->fallocate  = (int (*)(const char *path, int mode, off_t off, off_t len, fuse_file_info_t *fi))libfuse_fallocate;
+
+    opt->setxattr   = (int (*)(const char *path, const char *name, const char *value, size_t size, int flags))libfuse_setxattr;
+    opt->getxattr   = (int (*)(const char *path, const char *name, char *value, size_t size))libfuse_getxattr;
+    opt->listxattr  = (int (*)(const char *path, char *list, size_t size))libfuse_listxattr;
+    opt->removexattr= (int (*)(const char *path, const char *name))libfuse_removexattr;
+
 
     #ifdef __FUSE2__
     opt->init       = (void *(*)(fuse_conn_info_t *))libfuse2_init;
@@ -113,14 +122,29 @@ static int populate_callbacks(fuse_operations_t *opt)
     opt->chmod      = (int (*)(const char *path, mode_t mode, fuse_file_info_t *fi))libfuse_chmod;
     opt->chown      = (int (*)(const char *path, uid_t uid, gid_t gid, fuse_file_info_t *fi))libfuse_chown;
     opt->utimens    = (int (*)(const char *path, const timespec_t tv[2], fuse_file_info_t *fi))libfuse_utimens;
+    opt->lseek      = (off_t (*)(const char *path, off_t off, int whence, fuse_file_info_t *fi))libfuse_lseek;
+    opt->copy_file_range = (ssize_t (*)(const char *path_in, fuse_file_info_t *fi_in, off_t off_in, const char *path_out, 
+                               fuse_file_info_t *fi_out, off_t off_out, size_t size, int flags))libfuse_copy_file_range;
     #endif
 
     return 0;
 }
 
+// Advisory lock callbacks are registered only when file locks are enabled, otherwise kernel handles the locks locally
+static int populate_lock_callbacks(fuse_operations_t *opt)
+{
+    opt->lock       = (int (*)(const char *path, fuse_file_info_t *fi, int cmd, struct flock *lock))libfuse_lock;
+    opt->flock      = (int (*)(const char *path, fuse_file_info_t *fi, int op))libfuse_flock;
+
+    return 0;
+}
+
 static fuse_options_t fuse_opts;
 static bool context_populated = false;
 
+// fuse object is saved on init so that kernel caches can be invalidated from outside the fuse callbacks
+static struct fuse *fuse_instance = NULL;
+
 // Main method to start fuse loop which will fork and send us callbacks
 static int start_fuse(fuse_args_t *args, fuse_operations_t *opt)
 {
@@ -150,6 +174,42 @@ static void populate_uid_gid()
     }
 }
 
+// Save the fuse object from the context of the init callback
+static void save_fuse_instance()
+{
+    fuse_instance = fuse_get_context()->fuse;
+}
+
+// Get uid and gid of the process making the current request, false outside of a fuse request
+static bool get_caller_owner(uid_t *uid, gid_t *gid)
+{
+    if (fuse_instance == NULL)
+        return false;
+
+    struct fuse_context *ctx = fuse_get_context();
+    if (ctx == NULL)
+        return false;
+
+    *uid = ctx->uid;
+    *gid = ctx->gid;
+    return true;
+}
+
+// Invalidate the kernel attribute, data and entry caches of a path
+// libfuse maps the path to its inode and notifies the kernel through notify_inval_inode and notify_inval_entry
+static int invalidate_path(const char *path)
+{
+    #ifdef __FUSE2__
+    // libfuse2 high level api can not map paths to inodes for the notify calls
+    return -ENOSYS;
+    #else
+    if (fuse_instance == NULL)
+        return -ENOENT;
+
+    return fuse_invalidate_path(fuse_instance, path);
+    #endif
+}
+
 // Properties for root (/) are static so just hardcoding them here
 static int get_root_properties(stat_t *stbuf)
 {
@@ -42,6 +42,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/Azure/azure-storage-fuse/v2/common/config"
 	"github.com/Azure/azure-storage-fuse/v2/common/log"
@@ -267,6 +268,32 @@ func (lfs *LoopbackFS) RenameFile(options internal.RenameFileOptions) error {
 	return os.Rename(oldPath, newPath)
 }
 
+func (lfs *LoopbackFS) CopyObject(options internal.CopyObjectOptions) error {
+	log.Trace("LoopbackFS::CopyObject : %s -> %s", options.Src, options.Dst)
+	srcPath := filepath.Join(lfs.path, options.Src)
+	dstPath := filepath.Join(lfs.path, options.Dst)
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
+
 func (lfs *LoopbackFS) ReadFile(options internal.ReadFileOptions) ([]byte, error) {
 	log.Trace("LoopbackFS::ReadFile : name=%s", options.Handle.Path)
 	f := options.Handle.GetFileObject()
@@ -339,6 +366,39 @@ func (lfs *LoopbackFS) TruncateFile(options internal.TruncateFileOptions) error
 	return os.Truncate(fsPath, options.Size)
 }
 
+func (lfs *LoopbackFS) FallocateFile(options internal.FallocateFileOptions) error {
+	log.Trace("LoopbackFS::FallocateFile : name=%s", options.Name)
+	fsPath := filepath.Join(lfs.path, options.Name)
+	f, err := os.OpenFile(fsPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// FALLOC_FL_KEEP_SIZE, FALLOC_FL_PUNCH_HOLE and FALLOC_FL_ZERO_RANGE from linux/falloc.h
+	var mode uint32
+	if options.KeepSize {
+		mode |= 0x01
+	}
+	if options.PunchHole && options.KeepSize {
+		mode |= 0x02
+	} else if options.PunchHole {
+		mode |= 0x10
+	}
+	return syscall.Fallocate(int(f.Fd()), mode, options.Offset, options.Length)
+}
+
+func (lfs *LoopbackFS) SeekFile(options internal.SeekFileOptions) (int64, error) {
+	log.Trace("LoopbackFS::SeekFile : name=%s", options.Name)
+	fsPath := filepath.Join(lfs.path, options.Name)
+	f, err := os.Open(fsPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return syscall.Seek(int(f.Fd()), options.Offset, options.Whence)
+}
+
 func (lfs *LoopbackFS) FlushFile(options internal.FlushFileOptions) error {
 	log.Trace("LoopbackFS::FlushFile : name=%s", options.Handle.Path)
 	f := options.Handle.GetFileObject()
@@ -395,7 +455,12 @@ func (lfs *LoopbackFS) CopyFromFile(options internal.CopyFromFileOptions) error
 		log.Err("LoopbackFS::CopyFromFile : error opening [%s]", err)
 		return err
 	}
-	_, err = io.Copy(fdst, options.File)
+	src, size, err := options.Source()
+	if err != nil {
+		log.Err("LoopbackFS::CopyFromFile : error reading source [%s]", err)
+		return err
+	}
+	_, err = io.Copy(fdst, io.NewSectionReader(src, 0, size))
 	if err != nil {
 		log.Err("LoopbackFS::CopyFromFile : error copying [%s]", err)
 		return err
@@ -446,6 +511,72 @@ func (lfs *LoopbackFS) Chown(options internal.ChownOptions) error {
 	return os.Chown(path, options.Owner, options.Group)
 }
 
+func (lfs *LoopbackFS) SetTimes(options internal.SetTimesOptions) error {
+	log.Trace("LoopbackFS::SetTimes : name=%s", options.Name)
+	path := filepath.Join(lfs.path, options.Name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	atime, mtime := options.Atime, options.Mtime
+	if atime.IsZero() {
+		stat := info.Sys().(*syscall.Stat_t)
+		atime = time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+	}
+	if mtime.IsZero() {
+		mtime = info.ModTime()
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+func (lfs *LoopbackFS) SetXattr(options internal.SetXattrOptions) error {
+	log.Trace("LoopbackFS::SetXattr : name=%s, attr=%s", options.Name, options.Attr)
+	path := filepath.Join(lfs.path, options.Name)
+	return syscall.Setxattr(path, options.Attr, options.Value, options.Flags)
+}
+
+func (lfs *LoopbackFS) GetXattr(options internal.GetXattrOptions) ([]byte, error) {
+	log.Trace("LoopbackFS::GetXattr : name=%s, attr=%s", options.Name, options.Attr)
+	path := filepath.Join(lfs.path, options.Name)
+	size, err := syscall.Getxattr(path, options.Attr, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(path, options.Attr, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+func (lfs *LoopbackFS) ListXattr(options internal.ListXattrOptions) ([]string, error) {
+	log.Trace("LoopbackFS::ListXattr : name=%s", options.Name)
+	path := filepath.Join(lfs.path, options.Name)
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]string, 0)
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if strings.HasPrefix(name, internal.XattrUserPrefix) {
+			attrs = append(attrs, name)
+		}
+	}
+	return attrs, nil
+}
+
+func (lfs *LoopbackFS) RemoveXattr(options internal.RemoveXattrOptions) error {
+	log.Trace("LoopbackFS::RemoveXattr : name=%s, attr=%s", options.Name, options.Attr)
+	path := filepath.Join(lfs.path, options.Name)
+	return syscall.Removexattr(path, options.Attr)
+}
+
 func (lfs *LoopbackFS) InvalidateObject(_ string) {
 }
 
@@ -268,6 +268,38 @@ func (suite *LoopbackFSTestSuite) TestTruncateFile() {
 	assert.Equal(info.Size(), int64(0))
 }
 
+func (suite *LoopbackFSTestSuite) TestFallocateFile() {
+	defer suite.cleanupTest()
+	assert := assert.New(suite.T())
+
+	err := suite.lfs.FallocateFile(internal.FallocateFileOptions{Name: fileLorem, Offset: 0, Length: 1024 * 1024})
+	assert.Nil(err)
+	info, err := os.Stat(filepath.Join(testPath, fileLorem))
+	assert.Nil(err, "FallocateFile: cannot stat file")
+	assert.Equal(int64(1024*1024), info.Size())
+
+	err = suite.lfs.FallocateFile(internal.FallocateFileOptions{Name: fileLorem, Offset: 0, Length: 4096, KeepSize: true, PunchHole: true})
+	assert.Nil(err)
+	data, err := os.ReadFile(filepath.Join(testPath, fileLorem))
+	assert.Nil(err)
+	assert.Equal(make([]byte, 4096), data[:4096])
+	assert.Equal(int64(1024*1024), int64(len(data)))
+}
+
+func (suite *LoopbackFSTestSuite) TestCopyObject() {
+	defer suite.cleanupTest()
+	assert := assert.New(suite.T())
+
+	err := suite.lfs.CopyObject(internal.CopyObjectOptions{Src: fileLorem, Dst: fileEmpty})
+	assert.Nil(err, "CopyObject: Failed")
+	data, err := os.ReadFile(filepath.Join(testPath, fileEmpty))
+	assert.Nil(err, "CopyObject: cannot read copied file")
+	assert.Equal(loremText, string(data))
+
+	err = suite.lfs.CopyObject(internal.CopyObjectOptions{Src: "missing.txt", Dst: fileEmpty})
+	assert.True(os.IsNotExist(err))
+}
+
 func (suite *LoopbackFSTestSuite) TestGetAttr() {
 	defer suite.cleanupTest()
 	assert := assert.New(suite.T())
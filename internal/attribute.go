@@ -66,21 +66,27 @@ const (
 	PropFlagSymlink
 	PropFlagMetadataRetrieved
 	PropFlagModeDefault // TODO: Does this sound better as ModeDefault or DefaultMode? The getter would be IsModeDefault or IsDefaultMode
+	PropFlagOwnerSet
 )
 
 // ObjAttr : Attributes of any file/directory
 type ObjAttr struct {
-	Mtime    time.Time       // modified time
-	Atime    time.Time       // access time
-	Ctime    time.Time       // change time
-	Crtime   time.Time       // creation time
-	Size     int64           // size of the file/directory
-	Mode     os.FileMode     // permissions in 0xxx format
-	Flags    common.BitMap16 // flags
-	Path     string          // full path
-	Name     string          // base name of the path
-	MD5      []byte
-	Metadata map[string]string // extra information to preserve
+	Mtime     time.Time       // modified time
+	Atime     time.Time       // access time
+	Ctime     time.Time       // change time
+	Crtime    time.Time       // creation time
+	Size      int64           // size of the file/directory
+	Mode      os.FileMode     // permissions in 0xxx format
+	Uid       uint32          // owner user id, valid only when PropFlagOwnerSet is set
+	Gid       uint32          // owner group id, valid only when PropFlagOwnerSet is set
+	Flags     common.BitMap16 // flags
+	Path      string          // full path
+	Name      string          // base name of the path
+	MD5       []byte
+	ETag      string            // changes whenever the object changes in storage
+	Metadata  map[string]string // extra information to preserve
+	VersionID string            // version of the object, set only when versions are listed
+	Tier      string            // access tier of the object, e.g. Hot, Cool or Archive, empty if storage does not report it
 }
 
 // IsDir : Test blob is a directory or not
@@ -104,3 +110,9 @@ func (attr *ObjAttr) IsMetadataRetrieved() bool {
 func (attr *ObjAttr) IsModeDefault() bool {
 	return attr.Flags.IsSet(PropFlagModeDefault)
 }
+
+// IsOwnerSet : Whether or not the storage service returned an owner for this path.
+// When not set the fuse layer reports the uid/gid of the mount.
+func (attr *ObjAttr) IsOwnerSet() bool {
+	return attr.Flags.IsSet(PropFlagOwnerSet)
+}
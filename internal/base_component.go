@@ -182,6 +182,13 @@ func (base *BaseComponent) RenameFile(options RenameFileOptions) error {
 	return nil
 }
 
+func (base *BaseComponent) CopyObject(options CopyObjectOptions) error {
+	if base.next != nil {
+		return base.next.CopyObject(options)
+	}
+	return nil
+}
+
 func (base *BaseComponent) ReadFile(options ReadFileOptions) (b []byte, err error) {
 	if base.next != nil {
 		return base.next.ReadFile(options)
@@ -210,6 +217,27 @@ func (base *BaseComponent) TruncateFile(options TruncateFileOptions) error {
 	return nil
 }
 
+func (base *BaseComponent) FallocateFile(options FallocateFileOptions) error {
+	if base.next != nil {
+		return base.next.FallocateFile(options)
+	}
+	return nil
+}
+
+func (base *BaseComponent) SeekFile(options SeekFileOptions) (int64, error) {
+	if base.next != nil {
+		return base.next.SeekFile(options)
+	}
+	return 0, nil
+}
+
+func (base *BaseComponent) CacheControl(options CacheControlOptions) (uint32, error) {
+	if base.next != nil {
+		return base.next.CacheControl(options)
+	}
+	return 0, nil
+}
+
 func (base *BaseComponent) CopyToFile(options CopyToFileOptions) error {
 	if base.next != nil {
 		return base.next.CopyToFile(options)
@@ -310,6 +338,55 @@ func (base *BaseComponent) Chown(options ChownOptions) error {
 	return nil
 }
 
+func (base *BaseComponent) SetTimes(options SetTimesOptions) error {
+	if base.next != nil {
+		return base.next.SetTimes(options)
+	}
+	return nil
+}
+
+func (base *BaseComponent) SetXattr(options SetXattrOptions) error {
+	if base.next != nil {
+		return base.next.SetXattr(options)
+	}
+	return nil
+}
+
+func (base *BaseComponent) GetXattr(options GetXattrOptions) ([]byte, error) {
+	if base.next != nil {
+		return base.next.GetXattr(options)
+	}
+	return nil, nil
+}
+
+func (base *BaseComponent) ListXattr(options ListXattrOptions) ([]string, error) {
+	if base.next != nil {
+		return base.next.ListXattr(options)
+	}
+	return nil, nil
+}
+
+func (base *BaseComponent) RemoveXattr(options RemoveXattrOptions) error {
+	if base.next != nil {
+		return base.next.RemoveXattr(options)
+	}
+	return nil
+}
+
+func (base *BaseComponent) LockFile(options LockFileOptions) error {
+	if base.next != nil {
+		return base.next.LockFile(options)
+	}
+	return nil
+}
+
+func (base *BaseComponent) UnlockFile(options UnlockFileOptions) error {
+	if base.next != nil {
+		return base.next.UnlockFile(options)
+	}
+	return nil
+}
+
 func (base *BaseComponent) InvalidateObject(name string) {
 	if base.next != nil {
 		base.next.InvalidateObject(name)
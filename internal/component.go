@@ -104,12 +104,28 @@ type Component interface {
 	CloseFile(CloseFileOptions) error
 
 	RenameFile(RenameFileOptions) error
+	//CopyObject: Implementation expectations:
+	//1. Dst is replaced by a copy of Src, components that cache either path must not serve stale data afterwards
+	//2. must return syscall.ENOTSUP if the copy can not be done without moving the data through this machine
+	CopyObject(CopyObjectOptions) error
 
 	ReadFile(ReadFileOptions) ([]byte, error)
 	ReadInBuffer(ReadInBufferOptions) (int, error)
 
 	WriteFile(WriteFileOptions) (int, error)
 	TruncateFile(TruncateFileOptions) error
+	//FallocateFile: Implementation expectations:
+	//1. must not upload zero filled data for the allocated range where the storage can represent it sparsely
+	//2. must return syscall.EOPNOTSUPP for modes it can not honour
+	FallocateFile(FallocateFileOptions) error
+	//SeekFile: Implementation expectations:
+	//1. Whence is either common.SeekData or common.SeekHole
+	//2. must return syscall.ENXIO if offset is beyond the file size or there is no data after it
+	SeekFile(SeekFileOptions) (int64, error)
+	//CacheControl: Implementation expectations:
+	//1. Command is one of the common.CacheControl* values, components act on the ones they cache for and pass it on
+	//2. must return the common.CacheStatus* flags of the file merged with those of the next component for common.CacheControlStatus
+	CacheControl(CacheControlOptions) (uint32, error)
 
 	CopyToFile(CopyToFileOptions) error
 	CopyFromFile(CopyFromFileOptions) error
@@ -133,6 +149,22 @@ type Component interface {
 
 	Chmod(ChmodOptions) error
 	Chown(ChownOptions) error
+	SetTimes(SetTimesOptions) error
+
+	// Extended attribute operations
+	//GetXattr: Implementation expectations:
+	//1. must return syscall.ENODATA if the attribute is not set on the object
+	SetXattr(SetXattrOptions) error
+	GetXattr(GetXattrOptions) ([]byte, error)
+	ListXattr(ListXattrOptions) ([]string, error)
+	RemoveXattr(RemoveXattrOptions) error
+
+	// Advisory file lock operations
+	//LockFile: Implementation expectations:
+	//1. must return syscall.EAGAIN if a conflicting lock is held and Wait is not set
+	LockFile(LockFileOptions) error
+	UnlockFile(UnlockFileOptions) error
+
 	//InvalidateObject: function used to clear any inode information relating to a particular fs object
 	InvalidateObject(string) // TODO: What does this do? Why do we need it if its a noop?
 	GetFileBlockOffsets(options GetFileBlockOffsetsOptions) (*common.BlockOffsetList, error)
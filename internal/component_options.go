@@ -34,7 +34,9 @@
 package internal
 
 import (
+	"io"
 	"os"
+	"time"
 
 	"github.com/Azure/azure-storage-fuse/v2/internal/handlemap"
 )
@@ -77,8 +79,10 @@ type RenameDirOptions struct {
 }
 
 type CreateFileOptions struct {
-	Name string
-	Mode os.FileMode
+	Name  string
+	Mode  os.FileMode
+	Owner int
+	Group int
 }
 
 type DeleteFileOptions struct {
@@ -100,6 +104,13 @@ type RenameFileOptions struct {
 	Dst string
 }
 
+type CopyObjectOptions struct {
+	SrcHandle *handlemap.Handle // open handles the copy was requested through, if any
+	DstHandle *handlemap.Handle
+	Src       string
+	Dst       string
+}
+
 type ReadFileOptions struct {
 	Handle *handlemap.Handle
 }
@@ -126,6 +137,27 @@ type TruncateFileOptions struct {
 	Size int64
 }
 
+type FallocateFileOptions struct {
+	Handle    *handlemap.Handle
+	Name      string
+	Offset    int64
+	Length    int64
+	KeepSize  bool // do not change the file size even if the range goes beyond it
+	PunchHole bool // zero out the range instead of only allocating it
+}
+
+type SeekFileOptions struct {
+	Handle *handlemap.Handle
+	Name   string
+	Offset int64
+	Whence int
+}
+
+type CacheControlOptions struct {
+	Name    string
+	Command uint32
+}
+
 type CopyToFileOptions struct {
 	Name   string
 	Offset int64
@@ -137,6 +169,22 @@ type CopyFromFileOptions struct {
 	Name     string
 	File     *os.File
 	Metadata map[string]string
+
+	// Reader, when set, supplies the Size bytes to upload in place of File
+	Reader io.ReaderAt
+	Size   int64
+}
+
+// Source : Contents to upload and their size
+func (o CopyFromFileOptions) Source() (io.ReaderAt, int64, error) {
+	if o.Reader != nil {
+		return o.Reader, o.Size, nil
+	}
+	info, err := o.File.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	return o.File, info.Size(), nil
 }
 
 type FlushFileOptions struct {
@@ -189,6 +237,44 @@ type ChownOptions struct {
 	Group int
 }
 
+type SetTimesOptions struct {
+	Name  string
+	Atime time.Time // zero value leaves the access time unchanged
+	Mtime time.Time // zero value leaves the modification time unchanged
+}
+
+type SetXattrOptions struct {
+	Name  string
+	Attr  string
+	Value []byte
+	Flags int
+}
+
+type GetXattrOptions struct {
+	Name string
+	Attr string
+}
+
+type ListXattrOptions struct {
+	Name string
+}
+
+type RemoveXattrOptions struct {
+	Name string
+	Attr string
+}
+
+type LockFileOptions struct {
+	Handle    *handlemap.Handle
+	Exclusive bool // exclusive (write) lock, shared (read) lock otherwise
+	Wait      bool // block till a conflicting lock is released
+	CheckOnly bool // only check whether the lock can be taken
+}
+
+type UnlockFileOptions struct {
+	Handle *handlemap.Handle
+}
+
 func TruncateDirName(name string) string {
 	if len(name) == 0 {
 		return ""
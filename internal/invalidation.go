@@ -0,0 +1,90 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package internal
+
+import (
+	"sync"
+
+	"github.com/Azure/azure-storage-fuse/v2/common/log"
+)
+
+// InvalidationHandler : Drops whatever a component holds for a path that was changed in storage outside this mount
+type InvalidationHandler func(name string, isDir bool)
+
+type invalidationEntry struct {
+	component string
+	handler   InvalidationHandler
+}
+
+var invalidationHandlers []invalidationEntry
+var invalidationLock sync.RWMutex
+
+// AddInvalidationHandler : Components caching above the one that detects remote changes register here in Start
+// Pipeline starts components from the bottom so handlers run in that order, a cache is emptied before the ones above it
+func AddInvalidationHandler(component string, handler InvalidationHandler) {
+	invalidationLock.Lock()
+	defer invalidationLock.Unlock()
+
+	removeInvalidationHandler(component)
+	invalidationHandlers = append(invalidationHandlers, invalidationEntry{component: component, handler: handler})
+}
+
+// RemoveInvalidationHandler : Components registered for invalidation shall call this in Stop
+func RemoveInvalidationHandler(component string) {
+	invalidationLock.Lock()
+	defer invalidationLock.Unlock()
+
+	removeInvalidationHandler(component)
+}
+
+func removeInvalidationHandler(component string) {
+	for i, entry := range invalidationHandlers {
+		if entry.component == component {
+			invalidationHandlers = append(invalidationHandlers[:i], invalidationHandlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// InvalidatePath : Let every registered component know the path was changed in storage outside this mount
+func InvalidatePath(name string, isDir bool) {
+	log.Debug("InvalidatePath : %s changed outside this mount", name)
+
+	invalidationLock.RLock()
+	defer invalidationLock.RUnlock()
+
+	for _, entry := range invalidationHandlers {
+		entry.handler(name, isDir)
+	}
+}
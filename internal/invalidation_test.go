@@ -0,0 +1,74 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type invalidationTestSuite struct {
+	suite.Suite
+}
+
+func (s *invalidationTestSuite) TestInvalidatePath() {
+	assert := assert.New(s.T())
+	var calls []string
+
+	AddInvalidationHandler("a", func(name string, isDir bool) { calls = append(calls, "a:"+name) })
+	AddInvalidationHandler("b", func(name string, isDir bool) { calls = append(calls, "b:"+name) })
+	defer RemoveInvalidationHandler("a")
+	defer RemoveInvalidationHandler("b")
+
+	// Handlers run in the order they were added
+	InvalidatePath("dir/file", false)
+	assert.Equal([]string{"a:dir/file", "b:dir/file"}, calls)
+
+	// Adding again replaces the earlier handler of the component
+	calls = nil
+	AddInvalidationHandler("a", func(name string, isDir bool) { calls = append(calls, "a2:"+name) })
+	InvalidatePath("file", false)
+	assert.Equal([]string{"b:file", "a2:file"}, calls)
+
+	calls = nil
+	RemoveInvalidationHandler("b")
+	InvalidatePath("file", false)
+	assert.Equal([]string{"a2:file"}, calls)
+}
+
+func TestInvalidationTestSuite(t *testing.T) {
+	suite.Run(t, new(invalidationTestSuite))
+}
@@ -72,6 +72,21 @@ func (m *MockComponent) EXPECT() *MockComponentMockRecorder {
 	return m.recorder
 }
 
+// CacheControl mocks base method.
+func (m *MockComponent) CacheControl(arg0 CacheControlOptions) (uint32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CacheControl", arg0)
+	ret0, _ := ret[0].(uint32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CacheControl indicates an expected call of CacheControl.
+func (mr *MockComponentMockRecorder) CacheControl(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheControl", reflect.TypeOf((*MockComponent)(nil).CacheControl), arg0)
+}
+
 // Chmod mocks base method.
 func (m *MockComponent) Chmod(arg0 ChmodOptions) error {
 	m.ctrl.T.Helper()
@@ -156,6 +171,20 @@ func (mr *MockComponentMockRecorder) CopyFromFile(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyFromFile", reflect.TypeOf((*MockComponent)(nil).CopyFromFile), arg0)
 }
 
+// CopyObject mocks base method.
+func (m *MockComponent) CopyObject(arg0 CopyObjectOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopyObject", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CopyObject indicates an expected call of CopyObject.
+func (mr *MockComponentMockRecorder) CopyObject(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyObject", reflect.TypeOf((*MockComponent)(nil).CopyObject), arg0)
+}
+
 // CopyToFile mocks base method.
 func (m *MockComponent) CopyToFile(arg0 CopyToFileOptions) error {
 	m.ctrl.T.Helper()
@@ -241,6 +270,137 @@ func (mr *MockComponentMockRecorder) DeleteFile(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFile", reflect.TypeOf((*MockComponent)(nil).DeleteFile), arg0)
 }
 
+// FallocateFile mocks base method.
+func (m *MockComponent) FallocateFile(arg0 FallocateFileOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FallocateFile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FallocateFile indicates an expected call of FallocateFile.
+func (mr *MockComponentMockRecorder) FallocateFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FallocateFile", reflect.TypeOf((*MockComponent)(nil).FallocateFile), arg0)
+}
+
+// GetXattr mocks base method.
+func (m *MockComponent) GetXattr(arg0 GetXattrOptions) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetXattr", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetXattr indicates an expected call of GetXattr.
+func (mr *MockComponentMockRecorder) GetXattr(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetXattr", reflect.TypeOf((*MockComponent)(nil).GetXattr), arg0)
+}
+
+// ListXattr mocks base method.
+func (m *MockComponent) ListXattr(arg0 ListXattrOptions) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListXattr", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListXattr indicates an expected call of ListXattr.
+func (mr *MockComponentMockRecorder) ListXattr(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListXattr", reflect.TypeOf((*MockComponent)(nil).ListXattr), arg0)
+}
+
+// LockFile mocks base method.
+func (m *MockComponent) LockFile(arg0 LockFileOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockFile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockFile indicates an expected call of LockFile.
+func (mr *MockComponentMockRecorder) LockFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockFile", reflect.TypeOf((*MockComponent)(nil).LockFile), arg0)
+}
+
+// RemoveXattr mocks base method.
+func (m *MockComponent) RemoveXattr(arg0 RemoveXattrOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveXattr", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveXattr indicates an expected call of RemoveXattr.
+func (mr *MockComponentMockRecorder) RemoveXattr(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveXattr", reflect.TypeOf((*MockComponent)(nil).RemoveXattr), arg0)
+}
+
+// SeekFile mocks base method.
+func (m *MockComponent) SeekFile(arg0 SeekFileOptions) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SeekFile", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SeekFile indicates an expected call of SeekFile.
+func (mr *MockComponentMockRecorder) SeekFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SeekFile", reflect.TypeOf((*MockComponent)(nil).SeekFile), arg0)
+}
+
+// SetTimes mocks base method.
+func (m *MockComponent) SetTimes(arg0 SetTimesOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTimes", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTimes indicates an expected call of SetTimes.
+func (mr *MockComponentMockRecorder) SetTimes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTimes", reflect.TypeOf((*MockComponent)(nil).SetTimes), arg0)
+}
+
+// SetXattr mocks base method.
+func (m *MockComponent) SetXattr(arg0 SetXattrOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetXattr", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetXattr indicates an expected call of SetXattr.
+func (mr *MockComponentMockRecorder) SetXattr(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetXattr", reflect.TypeOf((*MockComponent)(nil).SetXattr), arg0)
+}
+
+// StreamDir mocks base method.
+func (m *MockComponent) StreamDir(arg0 StreamDirOptions) ([]*ObjAttr, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamDir", arg0)
+	ret0, _ := ret[0].([]*ObjAttr)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// StreamDir indicates an expected call of StreamDir.
+func (mr *MockComponentMockRecorder) StreamDir(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamDir", reflect.TypeOf((*MockComponent)(nil).StreamDir), arg0)
+}
+
 // SyncFile mocks base method.
 func (m *MockComponent) SyncDir(arg0 SyncDirOptions) error {
 	m.ctrl.T.Helper()
@@ -435,15 +595,6 @@ func (m *MockComponent) ReadDir(arg0 ReadDirOptions) ([]*ObjAttr, error) {
 	return ret0, ret1
 }
 
-// ReadDir mocks base method.
-func (m *MockComponent) StreamDir(arg0 StreamDirOptions) ([]*ObjAttr, string, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "StreamDir", arg0)
-	ret0, _ := ret[0].([]*ObjAttr)
-	ret1, _ := ret[1].(error)
-	return ret0, "", ret1
-}
-
 // ReadDir indicates an expected call of ReadDir.
 func (mr *MockComponentMockRecorder) ReadDir(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
@@ -631,6 +782,20 @@ func (mr *MockComponentMockRecorder) UnlinkFile(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkFile", reflect.TypeOf((*MockComponent)(nil).UnlinkFile), arg0)
 }
 
+// UnlockFile mocks base method.
+func (m *MockComponent) UnlockFile(arg0 UnlockFileOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockFile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockFile indicates an expected call of UnlockFile.
+func (mr *MockComponentMockRecorder) UnlockFile(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockFile", reflect.TypeOf((*MockComponent)(nil).UnlockFile), arg0)
+}
+
 // WriteFile mocks base method.
 func (m *MockComponent) WriteFile(arg0 WriteFileOptions) (int, error) {
 	m.ctrl.T.Helper()
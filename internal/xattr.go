@@ -0,0 +1,215 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package internal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Extended attributes are persisted as entries in the object metadata. Only the "user." namespace is supported.
+// Metadata keys are case-insensitive on the service and must be valid C# identifiers, so the attribute name is
+// escaped: lower case letters and digits are kept as is and every other byte is written as "_xx" (hex).
+// Values are stored base64 encoded as they may carry arbitrary binary data.
+const (
+	XattrUserPrefix     = "user."
+	XattrMetadataPrefix = "bfxattr_"
+
+	// Access tier of the object, it is not kept in the metadata and setting it changes the tier
+	XattrAzureTier = "user.azure.tier"
+
+	// Cache control of a file, setting it to a command name runs the command and reading it gives the cache
+	// status flags in decimal. The file is not opened, so it is not downloaded to run a command.
+	XattrCacheControl = "user.blobfuse2.cache"
+
+	// Flags accepted by setxattr, see <sys/xattr.h>
+	XattrCreate  = 0x1
+	XattrReplace = 0x2
+)
+
+// XattrToMetadataKey : Convert an extended attribute name to the metadata key used to persist it
+func XattrToMetadataKey(attr string) (string, error) {
+	if !strings.HasPrefix(attr, XattrUserPrefix) || len(attr) == len(XattrUserPrefix) {
+		return "", syscall.ENOTSUP
+	}
+
+	var sb strings.Builder
+	sb.WriteString(XattrMetadataPrefix)
+	for _, c := range []byte(strings.TrimPrefix(attr, XattrUserPrefix)) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteString(fmt.Sprintf("_%02x", c))
+		}
+	}
+	return sb.String(), nil
+}
+
+// MetadataKeyToXattr : Convert a metadata key back to the extended attribute name
+// Returns false if the key does not represent an extended attribute
+func MetadataKeyToXattr(key string) (string, bool) {
+	key = strings.ToLower(key)
+	if !strings.HasPrefix(key, XattrMetadataPrefix) {
+		return "", false
+	}
+
+	encoded := strings.TrimPrefix(key, XattrMetadataPrefix)
+	name := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != '_' {
+			name = append(name, encoded[i])
+			continue
+		}
+
+		if i+2 >= len(encoded) {
+			return "", false
+		}
+		c, err := strconv.ParseUint(encoded[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		name = append(name, byte(c))
+		i += 2
+	}
+
+	if len(name) == 0 {
+		return "", false
+	}
+	return XattrUserPrefix + string(name), true
+}
+
+// findXattrKey : Metadata keys may come back with a different case from the service so match them case-insensitively
+func findXattrKey(metadata map[string]string, key string) (string, bool) {
+	if _, ok := metadata[key]; ok {
+		return key, true
+	}
+	for k := range metadata {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// GetXattrFromMetadata : Look up the value of an extended attribute in the given metadata
+func GetXattrFromMetadata(metadata map[string]string, attr string) ([]byte, error) {
+	key, err := XattrToMetadataKey(attr)
+	if err != nil {
+		return nil, err
+	}
+
+	k, found := findXattrKey(metadata, key)
+	if !found {
+		return nil, syscall.ENODATA
+	}
+
+	value, err := base64.StdEncoding.DecodeString(metadata[k])
+	if err != nil {
+		return nil, syscall.EIO
+	}
+	return value, nil
+}
+
+// GetXattrFromAttr : Look up the value of an extended attribute of the object, the tier comes from its properties
+func GetXattrFromAttr(attr *ObjAttr, name string) ([]byte, error) {
+	if name == XattrAzureTier {
+		if attr.Tier == "" {
+			return nil, syscall.ENODATA
+		}
+		return []byte(attr.Tier), nil
+	}
+	return GetXattrFromMetadata(attr.Metadata, name)
+}
+
+// ListXattrFromAttr : List the names of all extended attributes of the object
+func ListXattrFromAttr(attr *ObjAttr) []string {
+	attrs := ListXattrFromMetadata(attr.Metadata)
+	if attr.Tier != "" {
+		attrs = append(attrs, XattrAzureTier)
+		sort.Strings(attrs)
+	}
+	return attrs
+}
+
+// ListXattrFromMetadata : List the names of all extended attributes present in the given metadata
+func ListXattrFromMetadata(metadata map[string]string) []string {
+	attrs := make([]string, 0)
+	for k := range metadata {
+		if name, ok := MetadataKeyToXattr(k); ok {
+			attrs = append(attrs, name)
+		}
+	}
+	sort.Strings(attrs)
+	return attrs
+}
+
+// SetXattrInMetadata : Add or update an extended attribute in the given metadata honouring the create/replace flags
+func SetXattrInMetadata(metadata map[string]string, attr string, value []byte, flags int) error {
+	key, err := XattrToMetadataKey(attr)
+	if err != nil {
+		return err
+	}
+
+	k, found := findXattrKey(metadata, key)
+	if found && flags&XattrCreate != 0 {
+		return syscall.EEXIST
+	} else if !found && flags&XattrReplace != 0 {
+		return syscall.ENODATA
+	}
+
+	if found {
+		delete(metadata, k)
+	}
+	metadata[key] = base64.StdEncoding.EncodeToString(value)
+	return nil
+}
+
+// RemoveXattrFromMetadata : Remove an extended attribute from the given metadata
+func RemoveXattrFromMetadata(metadata map[string]string, attr string) error {
+	key, err := XattrToMetadataKey(attr)
+	if err != nil {
+		return err
+	}
+
+	k, found := findXattrKey(metadata, key)
+	if !found {
+		return syscall.ENODATA
+	}
+	delete(metadata, k)
+	return nil
+}
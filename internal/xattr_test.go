@@ -0,0 +1,148 @@
+/*
+    _____           _____   _____   ____          ______  _____  ------
+   |     |  |      |     | |     | |     |     | |       |            |
+   |     |  |      |     | |     | |     |     | |       |            |
+   | --- |  |      |     | |-----| |---- |     | |-----| |-----  ------
+   |     |  |      |     | |     | |     |     |       | |       |
+   | ____|  |_____ | ____| | ____| |     |_____|  _____| |_____  |_____
+
+
+   Licensed under the MIT License <http://opensource.org/licenses/MIT>.
+
+   Copyright © 2020-2023 Microsoft Corporation. All rights reserved.
+   Author : <blobfusedev@microsoft.com>
+
+   Permission is hereby granted, free of charge, to any person obtaining a copy
+   of this software and associated documentation files (the "Software"), to deal
+   in the Software without restriction, including without limitation the rights
+   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+   copies of the Software, and to permit persons to whom the Software is
+   furnished to do so, subject to the following conditions:
+
+   The above copyright notice and this permission notice shall be included in all
+   copies or substantial portions of the Software.
+
+   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+   SOFTWARE
+*/
+
+package internal
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type xattrTestSuite struct {
+	suite.Suite
+}
+
+func (s *xattrTestSuite) TestXattrToMetadataKey() {
+	assert := assert.New(s.T())
+	tests := []struct {
+		input          string
+		expectedOutput string
+	}{
+		{input: "user.source", expectedOutput: "bfxattr_source"},
+		{input: "user.Source", expectedOutput: "bfxattr__53ource"},
+		{input: "user.my_tag.v2", expectedOutput: "bfxattr_my_5ftag_2ev2"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.input, func() {
+			output, err := XattrToMetadataKey(tt.input)
+			assert.Nil(err)
+			assert.EqualValues(tt.expectedOutput, output)
+
+			name, ok := MetadataKeyToXattr(output)
+			assert.True(ok)
+			assert.EqualValues(tt.input, name)
+		})
+	}
+}
+
+func (s *xattrTestSuite) TestXattrToMetadataKeyUnsupportedNamespace() {
+	assert := assert.New(s.T())
+	for _, attr := range []string{"security.selinux", "trusted.x", "system.posix_acl_access", "user."} {
+		_, err := XattrToMetadataKey(attr)
+		assert.Equal(syscall.ENOTSUP, err)
+	}
+}
+
+func (s *xattrTestSuite) TestMetadataKeyToXattrInvalid() {
+	assert := assert.New(s.T())
+	for _, key := range []string{"hdi_isfolder", "bfxattr_", "bfxattr_a_4", "bfxattr_a_zz"} {
+		_, ok := MetadataKeyToXattr(key)
+		assert.False(ok)
+	}
+}
+
+func (s *xattrTestSuite) TestSetGetRemoveXattr() {
+	assert := assert.New(s.T())
+	metadata := map[string]string{"hdi_isfolder": "false"}
+
+	err := SetXattrInMetadata(metadata, "user.origin", []byte("pipeline"), 0)
+	assert.Nil(err)
+
+	value, err := GetXattrFromMetadata(metadata, "user.origin")
+	assert.Nil(err)
+	assert.EqualValues("pipeline", string(value))
+
+	err = SetXattrInMetadata(metadata, "user.origin", []byte("other"), XattrCreate)
+	assert.Equal(syscall.EEXIST, err)
+
+	err = SetXattrInMetadata(metadata, "user.missing", []byte("other"), XattrReplace)
+	assert.Equal(syscall.ENODATA, err)
+
+	err = SetXattrInMetadata(metadata, "user.Origin", []byte{0, 1, 2}, 0)
+	assert.Nil(err)
+	assert.EqualValues([]string{"user.Origin", "user.origin"}, ListXattrFromMetadata(metadata))
+
+	err = RemoveXattrFromMetadata(metadata, "user.origin")
+	assert.Nil(err)
+	_, err = GetXattrFromMetadata(metadata, "user.origin")
+	assert.Equal(syscall.ENODATA, err)
+
+	err = RemoveXattrFromMetadata(metadata, "user.origin")
+	assert.Equal(syscall.ENODATA, err)
+	assert.EqualValues([]string{"user.Origin"}, ListXattrFromMetadata(metadata))
+	assert.EqualValues("false", metadata["hdi_isfolder"])
+}
+
+func (s *xattrTestSuite) TestGetXattrCaseInsensitiveKey() {
+	assert := assert.New(s.T())
+	metadata := map[string]string{"Bfxattr_Origin": "cGlwZWxpbmU="}
+
+	value, err := GetXattrFromMetadata(metadata, "user.origin")
+	assert.Nil(err)
+	assert.EqualValues("pipeline", string(value))
+}
+
+func (s *xattrTestSuite) TestXattrFromAttr() {
+	assert := assert.New(s.T())
+	attr := &ObjAttr{Metadata: map[string]string{"bfxattr_origin": "cGlwZWxpbmU="}}
+
+	_, err := GetXattrFromAttr(attr, XattrAzureTier)
+	assert.Equal(syscall.ENODATA, err)
+	assert.Equal([]string{"user.origin"}, ListXattrFromAttr(attr))
+
+	attr.Tier = "Cool"
+	value, err := GetXattrFromAttr(attr, XattrAzureTier)
+	assert.Nil(err)
+	assert.EqualValues("Cool", string(value))
+	value, err = GetXattrFromAttr(attr, "user.origin")
+	assert.Nil(err)
+	assert.EqualValues("pipeline", string(value))
+	assert.Equal([]string{"user.azure.tier", "user.origin"}, ListXattrFromAttr(attr))
+}
+
+func TestXattrTestSuite(t *testing.T) {
+	suite.Run(t, new(xattrTestSuite))
+}